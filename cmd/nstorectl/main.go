@@ -0,0 +1,340 @@
+// Command nstorectl dumps, restores, copies and inspects nstorage stores.
+//
+// Stores are addressed by url:
+//
+//	badger:///path/to/dir
+//	redis://[:password@]host:port[/db]?table=name
+//
+// Usage:
+//
+//	nstorectl dump -from <store> [-pattern regexp] [-out file]
+//	nstorectl restore -to <store> [-in file] [-skip-existing]
+//	nstorectl copy -from <store> -to <store> [-pattern regexp] [-skip-existing]
+//	nstorectl list -from <store> [-pattern regexp] [-ttl]
+//	nstorectl inspect -from <store> -key <key>
+//	nstorectl verify [-in file]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dgraph-io/badger/v2"
+	redis "github.com/go-redis/redis/v8"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+	"github.com/influx6/npkg/nstorage/nbadger"
+	"github.com/influx6/npkg/nstorage/ndump"
+	"github.com/influx6/npkg/nstorage/nredis"
+)
+
+const usage = `nstorectl manages nstorage stores.
+
+Commands:
+  dump     write keys of a store into a dump
+  restore  write keys from a dump into a store
+  copy     copy keys between two stores
+  list     list keys of a store, with their size and ttl given -ttl
+  inspect  print the value and ttl of a key
+  verify   validate the checksums of a dump
+
+Stores:
+  badger:///path/to/dir
+  redis://[:password@]host:port[/db]?table=name
+
+Run 'nstorectl <command> -h' for the flags of a command.
+`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("nstorectl: ")
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(command string, args []string, stdin io.Reader, stdout io.Writer) error {
+	switch command {
+	case "dump":
+		return dumpCommand(args, stdout)
+	case "restore":
+		return restoreCommand(args, stdin, stdout)
+	case "copy":
+		return copyCommand(args, stdout)
+	case "list":
+		return listCommand(args, stdout)
+	case "inspect":
+		return inspectCommand(args, stdout)
+	case "verify":
+		return verifyCommand(args, stdin, stdout)
+	case "help", "-h", "--help":
+		_, err := fmt.Fprint(stdout, usage)
+		return err
+	}
+	return nerror.New("unknown command %q, run 'nstorectl help'", command)
+}
+
+func dumpCommand(args []string, stdout io.Writer) error {
+	var flags = flag.NewFlagSet("dump", flag.ExitOnError)
+	var from = flags.String("from", "", "url of store to dump")
+	var pattern = flags.String("pattern", "", "regexp keys must match")
+	var out = flags.String("out", "", "file to write dump into, defaults to stdout")
+	_ = flags.Parse(args)
+
+	var store, closer, err = openStore(*from)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	var output = stdout
+	if len(*out) != 0 {
+		var file, fileErr = os.Create(*out)
+		if fileErr != nil {
+			return nerror.WrapOnly(fileErr)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	var stats, dumpErr = ndump.Dump(output, store, *pattern)
+	if dumpErr != nil {
+		return dumpErr
+	}
+	log.Printf("dumped %d keys (%d bytes)", stats.Records, stats.Bytes)
+	return nil
+}
+
+func restoreCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	var flags = flag.NewFlagSet("restore", flag.ExitOnError)
+	var to = flags.String("to", "", "url of store to restore into")
+	var in = flags.String("in", "", "file to read dump from, defaults to stdin")
+	var skip = flags.Bool("skip-existing", false, "leave keys already in the store untouched")
+	_ = flags.Parse(args)
+
+	var store, closer, err = openStore(*to)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	var input, inputErr = openInput(*in, stdin)
+	if inputErr != nil {
+		return inputErr
+	}
+	defer input.Close()
+
+	var ops []ndump.RestoreOption
+	if *skip {
+		ops = append(ops, ndump.SkipExisting())
+	}
+
+	var stats, restoreErr = ndump.Restore(input, store, ops...)
+	if restoreErr != nil {
+		return restoreErr
+	}
+	_, err = fmt.Fprintf(stdout, "restored %d keys (%d bytes), skipped %d\n", stats.Records, stats.Bytes, stats.Skipped)
+	return err
+}
+
+func copyCommand(args []string, stdout io.Writer) error {
+	var flags = flag.NewFlagSet("copy", flag.ExitOnError)
+	var from = flags.String("from", "", "url of store to copy from")
+	var to = flags.String("to", "", "url of store to copy into")
+	var pattern = flags.String("pattern", "", "regexp keys must match")
+	var skip = flags.Bool("skip-existing", false, "leave keys already in the target store untouched")
+	_ = flags.Parse(args)
+
+	var src, srcCloser, err = openStore(*from)
+	if err != nil {
+		return err
+	}
+	defer srcCloser()
+
+	var dst, dstCloser, dstErr = openStore(*to)
+	if dstErr != nil {
+		return dstErr
+	}
+	defer dstCloser()
+
+	var ops []ndump.RestoreOption
+	if *skip {
+		ops = append(ops, ndump.SkipExisting())
+	}
+
+	var stats, copyErr = ndump.Copy(dst, src, *pattern, ops...)
+	if copyErr != nil {
+		return copyErr
+	}
+	_, err = fmt.Fprintf(stdout, "copied %d keys (%d bytes), skipped %d\n", stats.Records, stats.Bytes, stats.Skipped)
+	return err
+}
+
+func listCommand(args []string, stdout io.Writer) error {
+	var flags = flag.NewFlagSet("list", flag.ExitOnError)
+	var from = flags.String("from", "", "url of store to list")
+	var pattern = flags.String("pattern", "", "regexp keys must match")
+	var withTTL = flags.Bool("ttl", false, "print the size and ttl of each key")
+	_ = flags.Parse(args)
+
+	var store, closer, err = openStore(*from)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	return ndump.Walk(store, *pattern, func(record ndump.Record) error {
+		if *withTTL {
+			_, err := fmt.Fprintf(stdout, "%s\t%d\t%s\n", record.Key, len(record.Value), ttlString(record))
+			return err
+		}
+		_, err := fmt.Fprintln(stdout, record.Key)
+		return err
+	})
+}
+
+func inspectCommand(args []string, stdout io.Writer) error {
+	var flags = flag.NewFlagSet("inspect", flag.ExitOnError)
+	var from = flags.String("from", "", "url of store to inspect")
+	var key = flags.String("key", "", "key to inspect")
+	_ = flags.Parse(args)
+
+	if len(*key) == 0 {
+		return nerror.New("-key is required")
+	}
+
+	var store, closer, err = openStore(*from)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	var value, getErr = store.Get(*key)
+	if getErr != nil {
+		return getErr
+	}
+
+	var record = ndump.Record{Key: *key, Value: value}
+	if expirable, ok := store.(nstorage.ExpirableStore); ok {
+		if record.TTL, err = expirable.TTL(*key); err != nil {
+			return err
+		}
+	}
+
+	var content = string(value)
+	if !utf8.Valid(value) {
+		content = fmt.Sprintf("%x", value)
+	}
+
+	_, err = fmt.Fprintf(stdout, "key:   %s\nsize:  %d\nttl:   %s\nvalue: %s\n", record.Key, len(value), ttlString(record), content)
+	return err
+}
+
+func verifyCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	var flags = flag.NewFlagSet("verify", flag.ExitOnError)
+	var in = flags.String("in", "", "file to read dump from, defaults to stdin")
+	_ = flags.Parse(args)
+
+	var input, err = openInput(*in, stdin)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var stats, verifyErr = ndump.Verify(input)
+	if verifyErr != nil {
+		return verifyErr
+	}
+	_, err = fmt.Fprintf(stdout, "ok: %d keys (%d bytes)\n", stats.Records, stats.Bytes)
+	return err
+}
+
+func ttlString(record ndump.Record) string {
+	if record.TTL <= 0 {
+		return "persistent"
+	}
+	return record.TTL.String()
+}
+
+func openInput(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if len(path) == 0 {
+		return ioutil.NopCloser(stdin), nil
+	}
+	var file, err = os.Open(path)
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return file, nil
+}
+
+// openStore opens the store addressed by giving url, returning a function
+// to close it.
+func openStore(address string) (nstorage.ExpirableStore, func(), error) {
+	if len(address) == 0 {
+		return nil, nil, nerror.New("store url is required")
+	}
+
+	var target, err = url.Parse(address)
+	if err != nil {
+		return nil, nil, nerror.Wrap(err, "invalid store url %q", address)
+	}
+
+	switch target.Scheme {
+	case "badger":
+		var dir = target.Path
+		if len(target.Host) != 0 {
+			dir = target.Host + dir
+		}
+		if len(dir) == 0 {
+			return nil, nil, nerror.New("badger url requires a directory")
+		}
+
+		var ops = badger.DefaultOptions(dir).WithLogger(nil)
+		var store, openErr = nbadger.NewBadgerStore(ops, badger.DefaultIteratorOptions)
+		if openErr != nil {
+			return nil, nil, openErr
+		}
+		return store, func() { _ = store.Close() }, nil
+	case "redis":
+		var table = target.Query().Get("table")
+		if len(table) == 0 {
+			return nil, nil, nerror.New("redis url requires a table query parameter")
+		}
+
+		var ops redis.Options
+		ops.Addr = target.Host
+		if target.User != nil {
+			ops.Password, _ = target.User.Password()
+		}
+		if db := strings.Trim(target.Path, "/"); len(db) != 0 {
+			var index, convErr = strconv.Atoi(db)
+			if convErr != nil {
+				return nil, nil, nerror.Wrap(convErr, "invalid redis db %q", db)
+			}
+			ops.DB = index
+		}
+
+		var store, openErr = nredis.NewRedisStore(context.Background(), table, ops)
+		if openErr != nil {
+			return nil, nil, openErr
+		}
+		return store, func() { _ = store.Close() }, nil
+	}
+	return nil, nil, nerror.New("unsupported store %q", target.Scheme)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpRestore(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var src = "redis://" + server.Addr() + "?table=src"
+	var dst = "redis://" + server.Addr() + "?table=dst"
+
+	var store, closer, err = openStore(src)
+	require.NoError(t, err)
+	require.NoError(t, store.Save("user:1", []byte("ada")))
+	require.NoError(t, store.Save("user:2", []byte("grace")))
+	require.NoError(t, store.SaveTTL("session:1", []byte("token"), time.Hour))
+	closer()
+
+	var file = filepath.Join(t.TempDir(), "users.dump")
+	var out bytes.Buffer
+	require.NoError(t, run("dump", []string{"-from", src, "-pattern", "^user:", "-out", file}, nil, &out))
+
+	out.Reset()
+	require.NoError(t, run("verify", []string{"-in", file}, nil, &out))
+	require.Equal(t, "ok: 2 keys (8 bytes)\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run("restore", []string{"-to", dst, "-in", file}, nil, &out))
+	require.Equal(t, "restored 2 keys (8 bytes), skipped 0\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run("list", []string{"-from", dst, "-ttl"}, nil, &out))
+	require.Equal(t, "user:1\t3\tpersistent\nuser:2\t5\tpersistent\n", out.String())
+
+	// dumps are streamed through stdout and stdin without a file.
+	var dump bytes.Buffer
+	require.NoError(t, run("dump", []string{"-from", src, "-pattern", "^session:"}, nil, &dump))
+
+	out.Reset()
+	require.NoError(t, run("restore", []string{"-to", dst, "-skip-existing"}, &dump, &out))
+	require.Equal(t, "restored 1 keys (5 bytes), skipped 0\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run("inspect", []string{"-from", dst, "-key", "session:1"}, nil, &out))
+	require.Contains(t, out.String(), "value: token\n")
+	require.NotContains(t, out.String(), "persistent")
+
+	require.Error(t, run("restore", []string{"-to", "memcache://localhost"}, strings.NewReader(""), &out))
+}
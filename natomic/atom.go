@@ -0,0 +1,363 @@
+package natomic
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// atomIDs gives every Atom an id, ordering the locking of atoms by a Batch.
+var atomIDs uint64
+
+// Watcher defines a function type called with the previous and next value
+// of an Observable when it changes.
+type Watcher func(prev interface{}, next interface{})
+
+// Observable defines a value which can be read and watched for changes,
+// implemented by Atom and Computed.
+type Observable interface {
+	Read() interface{}
+
+	// Watch registers giving Watcher, returning a function removing it.
+	Watch(Watcher) (cancel func())
+}
+
+var (
+	_ Observable = (*Atom)(nil)
+	_ Observable = (*Computed)(nil)
+)
+
+//************************************************************************
+// Watchers
+//************************************************************************
+
+type watchEntry struct {
+	id uint64
+	fn Watcher
+}
+
+// watchers holds the watchers of an Observable, calling them in the order
+// they were added.
+type watchers struct {
+	lock sync.Mutex
+	last uint64
+	list []watchEntry
+}
+
+func (w *watchers) add(fn Watcher) func() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.last++
+	var id = w.last
+	w.list = append(w.list, watchEntry{id: id, fn: fn})
+
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		for index, entry := range w.list {
+			if entry.id == id {
+				w.list = append(w.list[:index:index], w.list[index+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (w *watchers) count() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.list)
+}
+
+// notify calls all watchers, watchers can hence be removed by a watcher.
+func (w *watchers) notify(prev interface{}, next interface{}) {
+	w.lock.Lock()
+	var list = w.list
+	w.lock.Unlock()
+
+	for _, entry := range list {
+		entry.fn(prev, next)
+	}
+}
+
+// equal returns true if giving values are equal, using reflect.DeepEqual
+// for values which are not comparable.
+func equal(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a).Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+//************************************************************************
+// Atom
+//************************************************************************
+
+// atomValue boxes the values of an Atom, as atomic.Value can not store
+// nil or values of different types.
+type atomValue struct {
+	value interface{}
+}
+
+// Watch registers giving Watcher, called with the previous and next value
+// after every change of the atom, returning a function removing it.
+//
+// Watchers are called in the order of changes, they must hence not set
+// the atom they watch.
+func (am *Atom) Watch(fn Watcher) func() {
+	return am.watches.add(fn)
+}
+
+// CompareAndSet sets giving value into atom only if it's current value
+// equals old, returning true if it was set.
+func (am *Atom) CompareAndSet(old interface{}, val interface{}) (bool, error) {
+	am.imx.Lock()
+	defer am.imx.Unlock()
+
+	if !equal(am.Read(), old) {
+		return false, nil
+	}
+
+	var prev, err = am.swap(val)
+	if err != nil {
+		return false, err
+	}
+	am.notify(prev, val)
+	return true, nil
+}
+
+// Update sets the value returned by fn for the current value of the atom,
+// returning the value set. No other change happens between reading and
+// setting the value, fn must hence not use the atom.
+func (am *Atom) Update(fn func(old interface{}) interface{}) (interface{}, error) {
+	am.imx.Lock()
+	defer am.imx.Unlock()
+
+	var val = fn(am.Read())
+	var prev, err = am.swap(val)
+	if err != nil {
+		return nil, err
+	}
+	am.notify(prev, val)
+	return val, nil
+}
+
+// check returns an error if giving value is not of the type of the current
+// value of the atom.
+func (am *Atom) check(val interface{}) error {
+	var current = am.Read()
+	if current == nil || val == nil {
+		return nil
+	}
+	if reflect.TypeOf(current) != reflect.TypeOf(val) {
+		return nerror.New("atom holds a %T, can not set a %T", current, val)
+	}
+	return nil
+}
+
+// swap stores giving value returning the previous one, it must be called
+// with imx held.
+func (am *Atom) swap(val interface{}) (interface{}, error) {
+	if err := am.check(val); err != nil {
+		return nil, err
+	}
+
+	var prev = am.Read()
+	am.store.Store(atomValue{value: val})
+	return prev, nil
+}
+
+// notify delivers the atom to it's responders and calls it's watchers,
+// it must be called with imx held.
+func (am *Atom) notify(prev interface{}, next interface{}) {
+	if am.signals != nil {
+		am.signals.Respond(am)
+	}
+	am.watches.notify(prev, next)
+}
+
+//************************************************************************
+// Batch
+//************************************************************************
+
+// Batch collects values to set into atoms, setting all of them at once
+// when committed with every atom notified once, even if it was set more
+// than once within the batch.
+//
+// Watchers of atoms set by a batch see the values of all atoms of the
+// batch, a Computed depending on more than one of them hence never sees
+// a mix of old and new values.
+type Batch struct {
+	lock   sync.Mutex
+	atoms  []*Atom
+	values map[*Atom]interface{}
+}
+
+// NewBatch returns a new Batch.
+func NewBatch() *Batch {
+	return &Batch{values: map[*Atom]interface{}{}}
+}
+
+// Batched calls fn with a new Batch, committing it once fn returns.
+func Batched(fn func(*Batch)) error {
+	var batch = NewBatch()
+	fn(batch)
+	return batch.Commit()
+}
+
+// Set sets giving value into atom once the batch is committed.
+func (b *Batch) Set(atom *Atom, val interface{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.values[atom]; !ok {
+		b.atoms = append(b.atoms, atom)
+	}
+	b.values[atom] = val
+}
+
+// Update sets the value returned by fn for the value of the atom set within
+// the batch, or it's current value, once the batch is committed.
+func (b *Batch) Update(atom *Atom, fn func(old interface{}) interface{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var old, ok = b.values[atom]
+	if !ok {
+		old = atom.Read()
+		b.atoms = append(b.atoms, atom)
+	}
+	b.values[atom] = fn(old)
+}
+
+// Commit sets all values of the batch then notifies every atom, the batch
+// is empty afterwards. No atom is set if a value is not of the type of
+// it's atom.
+func (b *Batch) Commit() error {
+	b.lock.Lock()
+	var atoms, values = b.atoms, b.values
+	b.atoms = nil
+	b.values = map[*Atom]interface{}{}
+	b.lock.Unlock()
+
+	// atoms are locked in the order of their ids, so batches sharing atoms
+	// can not deadlock.
+	sort.Slice(atoms, func(i, j int) bool {
+		return atoms[i].id < atoms[j].id
+	})
+	for _, atom := range atoms {
+		atom.imx.Lock()
+		defer atom.imx.Unlock()
+	}
+
+	for _, atom := range atoms {
+		if err := atom.check(values[atom]); err != nil {
+			return err
+		}
+	}
+
+	var prevs = make([]interface{}, len(atoms))
+	for index, atom := range atoms {
+		prevs[index], _ = atom.swap(values[atom])
+	}
+	for index, atom := range atoms {
+		atom.notify(prevs[index], values[atom])
+	}
+	return nil
+}
+
+//************************************************************************
+// Computed
+//************************************************************************
+
+// Computed is an Observable whose value is derived from other observables,
+// e.g atoms or other computed values.
+//
+// The value is computed lazily: changes of it's dependencies only mark it
+// stale, recomputing it when next read. Once watched it's recomputed on
+// every change of a dependency, calling it's watchers only if the computed
+// value changed.
+type Computed struct {
+	compute func() interface{}
+	cancels []func()
+	watches watchers
+
+	// nmx serializes recomputes on changes, so watchers are called in order.
+	nmx   sync.Mutex
+	lock  sync.Mutex
+	value interface{}
+	stale bool
+}
+
+// NewComputed returns a new Computed whose value is returned by compute,
+// recomputed after changes of giving dependencies. Close must be called
+// once it is no longer used.
+func NewComputed(compute func() interface{}, deps ...Observable) *Computed {
+	var c = &Computed{
+		compute: compute,
+		stale:   true,
+	}
+	for _, dep := range deps {
+		c.cancels = append(c.cancels, dep.Watch(c.changed))
+	}
+	return c
+}
+
+// Read returns the computed value, computing it if it is stale.
+func (c *Computed) Read() interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stale {
+		c.value = c.compute()
+		c.stale = false
+	}
+	return c.value
+}
+
+// Watch registers giving Watcher, called with the previous and next value
+// after every change of the computed value, returning a function removing it.
+func (c *Computed) Watch(fn Watcher) func() {
+	var cancel = c.watches.add(fn)
+
+	// the value is computed so the first change has a previous value.
+	c.Read()
+	return cancel
+}
+
+// Close stops watching the dependencies, the value is no longer recomputed
+// afterwards.
+func (c *Computed) Close() {
+	for _, cancel := range c.cancels {
+		cancel()
+	}
+	c.cancels = nil
+}
+
+func (c *Computed) changed(_ interface{}, _ interface{}) {
+	c.nmx.Lock()
+	defer c.nmx.Unlock()
+
+	c.lock.Lock()
+	if c.watches.count() == 0 {
+		c.stale = true
+		c.lock.Unlock()
+		return
+	}
+
+	var prev = c.value
+	var next = c.compute()
+	c.value = next
+	c.stale = false
+	c.lock.Unlock()
+
+	if !equal(prev, next) {
+		c.watches.notify(prev, next)
+	}
+}
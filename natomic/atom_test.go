@@ -0,0 +1,201 @@
+package natomic
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type change struct {
+	prev interface{}
+	next interface{}
+}
+
+type changes struct {
+	lock sync.Mutex
+	list []change
+}
+
+func (c *changes) watch(prev interface{}, next interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.list = append(c.list, change{prev: prev, next: next})
+}
+
+func (c *changes) all() []change {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]change(nil), c.list...)
+}
+
+func TestAtomWatch(t *testing.T) {
+	var atom = NewAtom(nil)
+	require.Nil(t, atom.Read())
+
+	var seen changes
+	var cancel = atom.Watch(seen.watch)
+
+	require.NoError(t, atom.Set(1))
+	require.NoError(t, atom.Set(2))
+	require.Error(t, atom.Set("three"))
+	require.Equal(t, 2, atom.Read())
+
+	cancel()
+	require.NoError(t, atom.Set(4))
+
+	require.Equal(t, []change{{nil, 1}, {1, 2}}, seen.all())
+}
+
+func TestAtomWatchCancelWithinWatcher(t *testing.T) {
+	var atom = NewAtom(nil)
+
+	var calls int
+	var cancel func()
+	cancel = atom.Watch(func(_ interface{}, _ interface{}) {
+		calls++
+		cancel()
+	})
+
+	require.NoError(t, atom.Set(1))
+	require.NoError(t, atom.Set(2))
+	require.Equal(t, 1, calls)
+}
+
+func TestAtomCompareAndSet(t *testing.T) {
+	var atom = NewAtom(nil)
+	require.NoError(t, atom.Set([]string{"a"}))
+
+	var set, err = atom.CompareAndSet([]string{"b"}, []string{"c"})
+	require.NoError(t, err)
+	require.False(t, set)
+
+	set, err = atom.CompareAndSet([]string{"a"}, []string{"c"})
+	require.NoError(t, err)
+	require.True(t, set)
+	require.Equal(t, []string{"c"}, atom.Read())
+
+	_, err = atom.CompareAndSet([]string{"c"}, 1)
+	require.Error(t, err)
+}
+
+func TestAtomUpdate(t *testing.T) {
+	var atom = NewAtom(nil)
+	require.NoError(t, atom.Set(0))
+
+	var waiter sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			_, err := atom.Update(func(old interface{}) interface{} {
+				return old.(int) + 1
+			})
+			require.NoError(t, err)
+		}()
+	}
+	waiter.Wait()
+
+	require.Equal(t, 50, atom.Read())
+}
+
+func TestBatch(t *testing.T) {
+	var first = NewAtom(nil)
+	var second = NewAtom(nil)
+	require.NoError(t, first.Set(1))
+	require.NoError(t, second.Set(10))
+
+	var seenFirst, seenSecond changes
+	first.Watch(seenFirst.watch)
+	second.Watch(seenSecond.watch)
+
+	var sums []int
+	second.Watch(func(_ interface{}, _ interface{}) {
+		sums = append(sums, first.Read().(int)+second.Read().(int))
+	})
+
+	require.NoError(t, Batched(func(batch *Batch) {
+		batch.Set(first, 2)
+		batch.Set(second, 20)
+		batch.Update(first, func(old interface{}) interface{} {
+			return old.(int) + 1
+		})
+	}))
+
+	require.Equal(t, []change{{1, 3}}, seenFirst.all())
+	require.Equal(t, []change{{10, 20}}, seenSecond.all())
+	require.Equal(t, []int{23}, sums)
+}
+
+func TestBatchTypeMismatch(t *testing.T) {
+	var first = NewAtom(nil)
+	var second = NewAtom(nil)
+	require.NoError(t, first.Set(1))
+	require.NoError(t, second.Set(10))
+
+	var batch = NewBatch()
+	batch.Set(first, 2)
+	batch.Set(second, "twenty")
+	require.Error(t, batch.Commit())
+
+	require.Equal(t, 1, first.Read())
+	require.Equal(t, 10, second.Read())
+}
+
+func TestComputedLazy(t *testing.T) {
+	var price = NewAtom(nil)
+	var count = NewAtom(nil)
+	require.NoError(t, price.Set(2))
+	require.NoError(t, count.Set(3))
+
+	var computes int
+	var total = NewComputed(func() interface{} {
+		computes++
+		return price.Read().(int) * count.Read().(int)
+	}, price, count)
+	defer total.Close()
+
+	require.Equal(t, 0, computes)
+	require.Equal(t, 6, total.Read())
+	require.Equal(t, 6, total.Read())
+	require.Equal(t, 1, computes)
+
+	require.NoError(t, price.Set(4))
+	require.NoError(t, count.Set(5))
+	require.Equal(t, 1, computes)
+	require.Equal(t, 20, total.Read())
+	require.Equal(t, 2, computes)
+}
+
+func TestComputedWatch(t *testing.T) {
+	var value = NewAtom(nil)
+	require.NoError(t, value.Set(1))
+
+	var parity = NewComputed(func() interface{} {
+		return value.Read().(int)%2 == 0
+	}, value)
+	defer parity.Close()
+
+	var label = NewComputed(func() interface{} {
+		if parity.Read().(bool) {
+			return "even"
+		}
+		return "odd"
+	}, parity)
+	defer label.Close()
+
+	var seen changes
+	label.Watch(seen.watch)
+
+	require.NoError(t, value.Set(3))
+	require.NoError(t, value.Set(4))
+	require.NoError(t, value.Set(6))
+	require.NoError(t, value.Set(7))
+
+	require.Equal(t, []change{{"odd", "even"}, {"even", "odd"}}, seen.all())
+	require.Equal(t, "odd", label.Read())
+
+	label.Close()
+	require.NoError(t, value.Set(8))
+	require.Equal(t, "odd", label.Read())
+}
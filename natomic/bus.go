@@ -0,0 +1,452 @@
+package natomic
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+//************************************************************************
+// Topics
+//************************************************************************
+
+// Topics are dot separated hierarchies like "user.42.created", which
+// subscribers match with patterns where a segment is either:
+//
+//	- a literal, matching the same segment.
+//	- "*", matching exactly one segment, e.g "user.*.created".
+//	- "**", matching zero or more segments, e.g "user.**".
+//	- a glob of path.Match, matching one segment, e.g "user.4?.created".
+
+const (
+	topicSeparator = "."
+	anySegment     = "*"
+	anySegments    = "**"
+)
+
+// ValidatePattern returns an error if giving topic pattern has an empty
+// segment or an invalid glob.
+func ValidatePattern(pattern string) error {
+	for _, segment := range strings.Split(pattern, topicSeparator) {
+		if segment == "" {
+			return nerror.New("topic pattern %q has an empty segment", pattern)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return nerror.Wrap(err, "topic pattern %q has an invalid glob", pattern)
+		}
+	}
+	return nil
+}
+
+// MatchTopic returns true if giving topic matches the pattern.
+func MatchTopic(pattern string, topic string) bool {
+	return matchSegments(strings.Split(pattern, topicSeparator), strings.Split(topic, topicSeparator))
+}
+
+func matchSegments(pattern []string, topic []string) bool {
+	for index, segment := range pattern {
+		if segment == anySegments {
+			for skip := index; skip <= len(topic); skip++ {
+				if matchSegments(pattern[index+1:], topic[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if index >= len(topic) || !matchSegment(segment, topic[index]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
+
+func matchSegment(pattern string, segment string) bool {
+	if pattern == anySegment || pattern == segment {
+		return true
+	}
+	if !isGlob(pattern) {
+		return false
+	}
+	var matched, _ = path.Match(pattern, segment)
+	return matched
+}
+
+func isGlob(segment string) bool {
+	return strings.ContainsAny(segment, "*?[\\")
+}
+
+//************************************************************************
+// Trie
+//************************************************************************
+
+// topicNode is a node of the trie routing signal types to subscriptions,
+// with a child per literal segment and separate children for wildcards.
+type topicNode struct {
+	literals map[string]*topicNode
+	globs    map[string]*topicNode
+	one      *topicNode
+	many     *topicNode
+	subs     map[*Subscription]struct{}
+}
+
+func newTopicNode() *topicNode {
+	return &topicNode{
+		literals: map[string]*topicNode{},
+		globs:    map[string]*topicNode{},
+		subs:     map[*Subscription]struct{}{},
+	}
+}
+
+func (n *topicNode) child(segment string, create bool) *topicNode {
+	var next *topicNode
+	switch {
+	case segment == anySegments:
+		if n.many == nil && create {
+			n.many = newTopicNode()
+		}
+		return n.many
+	case segment == anySegment:
+		if n.one == nil && create {
+			n.one = newTopicNode()
+		}
+		return n.one
+	case isGlob(segment):
+		next = n.globs[segment]
+		if next == nil && create {
+			next = newTopicNode()
+			n.globs[segment] = next
+		}
+	default:
+		next = n.literals[segment]
+		if next == nil && create {
+			next = newTopicNode()
+			n.literals[segment] = next
+		}
+	}
+	return next
+}
+
+func (n *topicNode) empty() bool {
+	return len(n.subs) == 0 && len(n.literals) == 0 && len(n.globs) == 0 && n.one == nil && n.many == nil
+}
+
+func (n *topicNode) add(segments []string, sub *Subscription) {
+	var node = n
+	for _, segment := range segments {
+		node = node.child(segment, true)
+	}
+	node.subs[sub] = struct{}{}
+}
+
+// remove removes giving subscription, returning true if the node is left
+// empty and can be removed from it's parent.
+func (n *topicNode) remove(segments []string, sub *Subscription) bool {
+	if len(segments) == 0 {
+		delete(n.subs, sub)
+		return n.empty()
+	}
+
+	var segment = segments[0]
+	var next = n.child(segment, false)
+	if next == nil || !next.remove(segments[1:], sub) {
+		return false
+	}
+
+	switch {
+	case segment == anySegments:
+		n.many = nil
+	case segment == anySegment:
+		n.one = nil
+	case isGlob(segment):
+		delete(n.globs, segment)
+	default:
+		delete(n.literals, segment)
+	}
+	return n.empty()
+}
+
+// collect adds the subscriptions of all patterns matching giving segments.
+func (n *topicNode) collect(segments []string, found map[*Subscription]struct{}) {
+	if n.many != nil {
+		for skip := 0; skip <= len(segments); skip++ {
+			n.many.collect(segments[skip:], found)
+		}
+	}
+
+	if len(segments) == 0 {
+		for sub := range n.subs {
+			found[sub] = struct{}{}
+		}
+		return
+	}
+
+	var segment = segments[0]
+	if next, ok := n.literals[segment]; ok {
+		next.collect(segments[1:], found)
+	}
+	if n.one != nil {
+		n.one.collect(segments[1:], found)
+	}
+	for pattern, next := range n.globs {
+		if matched, _ := path.Match(pattern, segment); matched {
+			next.collect(segments[1:], found)
+		}
+	}
+}
+
+// collectAll adds the subscriptions of all patterns.
+func (n *topicNode) collectAll(found map[*Subscription]struct{}) {
+	for sub := range n.subs {
+		found[sub] = struct{}{}
+	}
+	for _, next := range n.literals {
+		next.collectAll(found)
+	}
+	for _, next := range n.globs {
+		next.collectAll(found)
+	}
+	if n.one != nil {
+		n.one.collectAll(found)
+	}
+	if n.many != nil {
+		n.many.collectAll(found)
+	}
+}
+
+//************************************************************************
+// Bus
+//************************************************************************
+
+var _ SignalReceiver = (*Bus)(nil)
+
+// SubscribeOption defines a function type which sets the options of a
+// Subscription.
+type SubscribeOption func(*Subscription)
+
+// ForTarget sets the pattern the target of signals must match, signals
+// of all targets are delivered by default.
+func ForTarget(pattern string) SubscribeOption {
+	return func(s *Subscription) {
+		s.target = pattern
+	}
+}
+
+// Bus routes signals to subscribers by their type and target, unlike a
+// ResponderGroup which delivers every signal to every responder.
+//
+// Subscribers register a pattern matched against the type of signals,
+// see MatchTopic, with signal types routed through a trie of all patterns.
+// Every subscriber receives it's signals in the order they were published
+// from it's own goroutine, a slow subscriber hence does not delay others.
+type Bus struct {
+	lock       sync.RWMutex
+	closed     bool
+	root       *topicNode
+	responders map[SignalResponder]*Subscription
+	waiter     sync.WaitGroup
+}
+
+// NewBus returns a new Bus.
+func NewBus() *Bus {
+	return &Bus{
+		root:       newTopicNode(),
+		responders: map[SignalResponder]*Subscription{},
+	}
+}
+
+// Subscribe registers giving responder for signals whose type matches
+// the pattern, returning the Subscription used to unsubscribe it.
+func (b *Bus) Subscribe(pattern string, responder SignalResponder, ops ...SubscribeOption) (*Subscription, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return nil, err
+	}
+
+	var sub = &Subscription{
+		bus:       b,
+		pattern:   pattern,
+		responder: responder,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for _, op := range ops {
+		op(sub)
+	}
+	if sub.target != "" {
+		if err := ValidatePattern(sub.target); err != nil {
+			return nil, err
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return nil, nerror.New("bus is closed")
+	}
+
+	b.root.add(strings.Split(pattern, topicSeparator), sub)
+	b.waiter.Add(1)
+	go sub.run()
+	return sub, nil
+}
+
+// Add subscribes giving responder to all signals, implementing the
+// SignalReceiver interface.
+func (b *Bus) Add(responder SignalResponder) error {
+	var sub, err = b.Subscribe(anySegments, responder)
+	if err != nil {
+		return err
+	}
+
+	b.lock.Lock()
+	b.responders[responder] = sub
+	b.lock.Unlock()
+
+	if irs, ok := responder.(SignalResponderNotification); ok {
+		irs.OnAdded()
+	}
+	return nil
+}
+
+// Remove unsubscribes a responder registered with Add.
+func (b *Bus) Remove(responder SignalResponder) error {
+	b.lock.Lock()
+	var sub, ok = b.responders[responder]
+	delete(b.responders, responder)
+	b.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+	sub.Unsubscribe()
+
+	if irs, ok := responder.(SignalResponderNotification); ok {
+		irs.OnRemoved()
+	}
+	return nil
+}
+
+// Respond publishes giving signal to all subscribers whose patterns match
+// it, see Bus.
+func (b *Bus) Respond(s Signal) {
+	var found = map[*Subscription]struct{}{}
+
+	b.lock.RLock()
+	if !b.closed {
+		b.root.collect(strings.Split(s.Type(), topicSeparator), found)
+	}
+	b.lock.RUnlock()
+
+	for sub := range found {
+		if sub.target == "" || MatchTopic(sub.target, s.Target()) {
+			sub.enqueue(s)
+		}
+	}
+}
+
+// Close unsubscribes all subscribers, waiting for signals being delivered.
+// It must not be called by a subscriber.
+func (b *Bus) Close() {
+	b.lock.Lock()
+	b.closed = true
+	var found = map[*Subscription]struct{}{}
+	b.root.collectAll(found)
+	b.lock.Unlock()
+
+	for sub := range found {
+		sub.Unsubscribe()
+	}
+	b.waiter.Wait()
+}
+
+func (b *Bus) unsubscribe(sub *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.root.remove(strings.Split(sub.pattern, topicSeparator), sub)
+}
+
+// Subscription is the handle of a subscriber of a Bus.
+type Subscription struct {
+	bus       *Bus
+	pattern   string
+	target    string
+	responder SignalResponder
+
+	lock   sync.Mutex
+	queue  []Signal
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+// Pattern returns the pattern signal types are matched against.
+func (s *Subscription) Pattern() string {
+	return s.pattern
+}
+
+// Unsubscribe stops the delivery of signals, signals not yet delivered
+// are dropped. It can be called by the subscriber and more than once.
+func (s *Subscription) Unsubscribe() {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.closed = true
+	s.queue = nil
+	s.lock.Unlock()
+
+	s.bus.unsubscribe(s)
+	s.signal()
+}
+
+// Done returns a channel closed once the subscription stopped delivering
+// signals after Unsubscribe.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Subscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Subscription) enqueue(signal Signal) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.queue = append(s.queue, signal)
+	s.lock.Unlock()
+	s.signal()
+}
+
+// run delivers queued signals in order until the subscription is closed.
+func (s *Subscription) run() {
+	defer s.bus.waiter.Done()
+	defer close(s.done)
+
+	for {
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			return
+		}
+		if len(s.queue) == 0 {
+			s.lock.Unlock()
+			<-s.wake
+			continue
+		}
+
+		var next = s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.lock.Unlock()
+
+		s.responder.Respond(next)
+	}
+}
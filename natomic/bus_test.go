@@ -0,0 +1,159 @@
+package natomic
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type topicSignal struct {
+	kind   string
+	target string
+}
+
+func (t topicSignal) Type() string {
+	return t.kind
+}
+
+func (t topicSignal) Target() string {
+	return t.target
+}
+
+func (topicSignal) Source() string {
+	return "test"
+}
+
+type collector struct {
+	lock     sync.Mutex
+	received []string
+	arrived  chan struct{}
+}
+
+func newCollector() *collector {
+	return &collector{arrived: make(chan struct{}, 100)}
+}
+
+func (c *collector) Respond(s Signal) {
+	c.lock.Lock()
+	c.received = append(c.received, s.Type()+"@"+s.Target())
+	c.lock.Unlock()
+	c.arrived <- struct{}{}
+}
+
+func (c *collector) wait(t *testing.T, n int) []string {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.arrived:
+		case <-time.After(time.Second):
+			t.Fatalf("received %d of %d signals", i, n)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string(nil), c.received...)
+}
+
+func TestMatchTopic(t *testing.T) {
+	require.True(t, MatchTopic("user.created", "user.created"))
+	require.False(t, MatchTopic("user.created", "user.deleted"))
+	require.True(t, MatchTopic("user.*.created", "user.42.created"))
+	require.False(t, MatchTopic("user.*.created", "user.created"))
+	require.True(t, MatchTopic("user.**", "user"))
+	require.True(t, MatchTopic("user.**", "user.42.created"))
+	require.True(t, MatchTopic("**.created", "user.42.created"))
+	require.True(t, MatchTopic("user.4?.created", "user.42.created"))
+	require.False(t, MatchTopic("user.4?.created", "user.420.created"))
+
+	require.NoError(t, ValidatePattern("user.*.created"))
+	require.Error(t, ValidatePattern("user..created"))
+	require.Error(t, ValidatePattern("user.[.created"))
+}
+
+func TestBus(t *testing.T) {
+	var bus = NewBus()
+	defer bus.Close()
+
+	var created, users, admins, all = newCollector(), newCollector(), newCollector(), newCollector()
+
+	var _, err = bus.Subscribe("user.*.created", created)
+	require.NoError(t, err)
+	_, err = bus.Subscribe("user.**", users)
+	require.NoError(t, err)
+	_, err = bus.Subscribe("user.**", admins, ForTarget("admin.*"))
+	require.NoError(t, err)
+	require.NoError(t, bus.Add(all))
+
+	bus.Respond(topicSignal{kind: "user.1.created", target: "admin.eu"})
+	bus.Respond(topicSignal{kind: "user.1.deleted", target: "site"})
+	bus.Respond(topicSignal{kind: "order.1.created", target: "site"})
+	bus.Respond(topicSignal{kind: "user.2.created", target: "site"})
+
+	require.Equal(t, []string{"user.1.created@admin.eu", "user.2.created@site"}, created.wait(t, 2))
+	require.Equal(t, []string{"user.1.created@admin.eu", "user.1.deleted@site", "user.2.created@site"}, users.wait(t, 3))
+	require.Equal(t, []string{"user.1.created@admin.eu"}, admins.wait(t, 1))
+	require.Len(t, all.wait(t, 4), 4)
+}
+
+func TestBusOrderedDelivery(t *testing.T) {
+	var bus = NewBus()
+	defer bus.Close()
+
+	var slow = newCollector()
+	var _, err = bus.Subscribe("tick", SignalRespondHandler(func(s Signal) {
+		time.Sleep(time.Millisecond)
+		slow.Respond(s)
+	}))
+	require.NoError(t, err)
+
+	var expected []string
+	for i := 0; i < 50; i++ {
+		var target = string(rune('a' + i%26))
+		expected = append(expected, "tick@"+target)
+		bus.Respond(topicSignal{kind: "tick", target: target})
+	}
+	require.Equal(t, expected, slow.wait(t, 50))
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	var bus = NewBus()
+
+	var first, second = newCollector(), newCollector()
+	var sub, err = bus.Subscribe("user.*", first)
+	require.NoError(t, err)
+	_, err = bus.Subscribe("user.*", second)
+	require.NoError(t, err)
+
+	bus.Respond(topicSignal{kind: "user.a"})
+	first.wait(t, 1)
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	<-sub.Done()
+
+	bus.Respond(topicSignal{kind: "user.b"})
+	require.Equal(t, []string{"user.a@", "user.b@"}, second.wait(t, 2))
+	require.Equal(t, []string{"user.a@"}, first.wait(t, 0))
+
+	require.NoError(t, bus.Add(first))
+	require.NoError(t, bus.Remove(first))
+
+	bus.Close()
+	_, err = bus.Subscribe("user.*", first)
+	require.Error(t, err)
+	require.True(t, bus.root.empty())
+}
+
+func TestBusAtom(t *testing.T) {
+	var bus = NewBus()
+	defer bus.Close()
+
+	var atom = NewAtom(bus)
+	var signals = newCollector()
+	require.NoError(t, atom.Add(signals))
+
+	require.NoError(t, atom.Set(1))
+	require.Equal(t, []string{"natomic.Atom@"}, signals.wait(t, 1))
+}
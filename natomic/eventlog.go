@@ -0,0 +1,406 @@
+package natomic
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+	"github.com/influx6/npkg/nxid"
+)
+
+//************************************************************************
+// Event
+//************************************************************************
+
+// PayloadSignal defines a Signal providing the payload an EventLog
+// persists with it.
+type PayloadSignal interface {
+	Signal
+
+	Payload() ([]byte, error)
+}
+
+var _ PayloadSignal = (*Event)(nil)
+
+// Event is a Signal persisted by an EventLog, it implements the Signal
+// interface so events can be replayed to any SignalResponder.
+type Event struct {
+	ID           nxid.ID         `json:"id"`
+	Time         time.Time       `json:"time"`
+	SignalType   string          `json:"type"`
+	SignalTarget string          `json:"target"`
+	SignalSource string          `json:"source"`
+	Data         json.RawMessage `json:"payload,omitempty"`
+}
+
+// Type returns the type of the persisted signal.
+func (e Event) Type() string {
+	return e.SignalType
+}
+
+// Target returns the target of the persisted signal.
+func (e Event) Target() string {
+	return e.SignalTarget
+}
+
+// Source returns the source of the persisted signal.
+func (e Event) Source() string {
+	return e.SignalSource
+}
+
+// Payload returns the payload of the persisted signal.
+func (e Event) Payload() ([]byte, error) {
+	return e.Data, nil
+}
+
+// Decode decodes the json payload of the event into giving value.
+func (e Event) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+//************************************************************************
+// EventLog
+//************************************************************************
+
+var _ SignalResponder = (*EventLog)(nil)
+
+// EventLogOption defines a function type which sets the options of an
+// EventLog.
+type EventLogOption func(*EventLog)
+
+// EncodePayload sets the function encoding the payload of signals, it
+// must return json. Defaults to the Payload method of signals implementing
+// PayloadSignal, else the json encoding of the signal.
+func EncodePayload(fn func(Signal) ([]byte, error)) EventLogOption {
+	return func(l *EventLog) {
+		l.encode = fn
+	}
+}
+
+// RetainFor removes events older than giving age when the log is
+// compacted.
+func RetainFor(age time.Duration) EventLogOption {
+	return func(l *EventLog) {
+		l.maxAge = age
+	}
+}
+
+// RetainCount keeps only the latest n events when the log is compacted.
+func RetainCount(n int) EventLogOption {
+	return func(l *EventLog) {
+		l.maxCount = n
+	}
+}
+
+// CompactEvery compacts the log after every n appended events, when a
+// retention is set. Defaults to 100, a value of 0 leaves compaction to
+// calls of Compact.
+func CompactEvery(n int) EventLogOption {
+	return func(l *EventLog) {
+		l.compactEvery = n
+	}
+}
+
+// OnAppendError sets a function called with errors of appending signals
+// received through Respond.
+func OnAppendError(fn func(Signal, error)) EventLogOption {
+	return func(l *EventLog) {
+		l.onError = fn
+	}
+}
+
+// EventLogClock sets the function used to get the current time, defaults
+// to time.Now.
+func EventLogClock(now func() time.Time) EventLogOption {
+	return func(l *EventLog) {
+		l.now = now
+	}
+}
+
+// EventLog implements a SignalResponder persisting the signals it receives
+// with their payloads into a nstorage.ByteStore, allowing them to be
+// replayed after a restart, e.g by adding it to the ResponderGroup of an
+// Atom.
+//
+// Events are stored under the prefix followed by their nxid.ID, which
+// orders them by time of append. The ID of an event is the offset
+// consumers resume from, see Replay, Commit and Offset.
+type EventLog struct {
+	store        nstorage.ByteStore
+	prefix       string
+	encode       func(Signal) ([]byte, error)
+	maxAge       time.Duration
+	maxCount     int
+	compactEvery int
+	onError      func(Signal, error)
+	now          func() time.Time
+
+	lock     sync.Mutex
+	last     nxid.ID
+	appended int
+}
+
+// NewEventLog returns a new EventLog storing events in giving store
+// under the prefix.
+func NewEventLog(store nstorage.ByteStore, prefix string, ops ...EventLogOption) *EventLog {
+	var l = &EventLog{
+		store:        store,
+		prefix:       prefix,
+		encode:       encodePayload,
+		compactEvery: 100,
+		now:          time.Now,
+	}
+	for _, op := range ops {
+		op(l)
+	}
+	return l
+}
+
+func encodePayload(s Signal) ([]byte, error) {
+	if ps, ok := s.(PayloadSignal); ok {
+		return ps.Payload()
+	}
+	return json.Marshal(s)
+}
+
+// Respond appends giving signal, implementing the SignalResponder interface.
+func (l *EventLog) Respond(s Signal) {
+	if _, err := l.Append(s); err != nil && l.onError != nil {
+		l.onError(s, err)
+	}
+}
+
+// Append persists giving signal, returning it's event.
+func (l *EventLog) Append(s Signal) (Event, error) {
+	var payload, err = l.encode(s)
+	if err != nil {
+		return Event{}, nerror.WrapOnly(err)
+	}
+
+	var event = Event{
+		SignalType:   s.Type(),
+		SignalTarget: s.Target(),
+		SignalSource: s.Source(),
+		Data:         payload,
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	event.Time = l.now()
+	event.ID = l.nextID(event.Time)
+
+	var encoded []byte
+	if encoded, err = json.Marshal(event); err != nil {
+		return Event{}, nerror.WrapOnly(err)
+	}
+	if err = l.store.Save(l.eventKey(event.ID), encoded); err != nil {
+		return Event{}, nerror.WrapOnly(err)
+	}
+	l.last = event.ID
+
+	l.appended++
+	if l.compactEvery > 0 && l.appended >= l.compactEvery && (l.maxAge > 0 || l.maxCount > 0) {
+		l.appended = 0
+		if _, err = l.compact(); err != nil {
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+// nextID returns an id ordered after all ids given before, as the counter
+// of nxid.ID may wrap around within a second.
+func (l *EventLog) nextID(t time.Time) nxid.ID {
+	var id = nxid.NewWithTime(t)
+	if !l.last.IsNil() && id.Compare(l.last) <= 0 {
+		id = nxid.NewWithTime(l.last.Time().Add(time.Second))
+	}
+	return id
+}
+
+// Replay calls fn with all events appended after giving offset in order,
+// use nxid.NilID to replay all events. Replay stops if fn returns an error,
+// returning it unless it is nstorage.ErrJustStop.
+func (l *EventLog) Replay(after nxid.ID, fn func(Event) error) error {
+	var ids, err = l.ids()
+	if err != nil {
+		return err
+	}
+
+	var start = sort.Search(len(ids), func(i int) bool {
+		return ids[i].Compare(after) > 0
+	})
+	for _, id := range ids[start:] {
+		var event, getErr = l.Get(id)
+		if getErr != nil {
+			return getErr
+		}
+		if err := fn(event); err != nil {
+			if err == nstorage.ErrJustStop {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayTo delivers all events appended after giving offset to the responder
+// in order, returning the offset of the last event delivered.
+func (l *EventLog) ReplayTo(after nxid.ID, responder SignalResponder) (nxid.ID, error) {
+	var last = after
+	var err = l.Replay(after, func(event Event) error {
+		responder.Respond(event)
+		last = event.ID
+		return nil
+	})
+	return last, err
+}
+
+// Get returns the event with giving id.
+func (l *EventLog) Get(id nxid.ID) (Event, error) {
+	var event Event
+	var data, err = l.store.Get(l.eventKey(id))
+	if err != nil {
+		return event, nerror.WrapOnly(err)
+	}
+	if err = json.Unmarshal(data, &event); err != nil {
+		return event, nerror.WrapOnly(err)
+	}
+	return event, nil
+}
+
+// Count returns the number of events in the log.
+func (l *EventLog) Count() (int, error) {
+	var ids, err = l.ids()
+	return len(ids), err
+}
+
+// Commit stores giving offset as the position of the named consumer.
+func (l *EventLog) Commit(consumer string, offset nxid.ID) error {
+	if err := l.store.Save(l.offsetKey(consumer), offset.Bytes()); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+// Offset returns the offset last committed by the named consumer, or
+// nxid.NilID if it never committed one.
+func (l *EventLog) Offset(consumer string) (nxid.ID, error) {
+	var exists, err = l.store.Exists(l.offsetKey(consumer))
+	if err != nil {
+		return nxid.NilID(), nerror.WrapOnly(err)
+	}
+	if !exists {
+		return nxid.NilID(), nil
+	}
+
+	var data []byte
+	if data, err = l.store.Get(l.offsetKey(consumer)); err != nil {
+		return nxid.NilID(), nerror.WrapOnly(err)
+	}
+
+	var id nxid.ID
+	if id, err = nxid.FromBytes(data); err != nil {
+		return nxid.NilID(), nerror.WrapOnly(err)
+	}
+	return id, nil
+}
+
+// Resume delivers all events after the offset of the named consumer to
+// the responder, committing the offset of the last event delivered.
+func (l *EventLog) Resume(consumer string, responder SignalResponder) error {
+	var offset, err = l.Offset(consumer)
+	if err != nil {
+		return err
+	}
+
+	var last nxid.ID
+	last, err = l.ReplayTo(offset, responder)
+	if last != offset {
+		if commitErr := l.Commit(consumer, last); commitErr != nil && err == nil {
+			err = commitErr
+		}
+	}
+	return err
+}
+
+// Compact removes events outside the retention set with RetainFor and
+// RetainCount, returning the number of events removed.
+func (l *EventLog) Compact() (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.compact()
+}
+
+func (l *EventLog) compact() (int, error) {
+	var ids, err = l.ids()
+	if err != nil {
+		return 0, err
+	}
+
+	// ids are sorted, expired events are hence the first ones.
+	var remove int
+	if l.maxCount > 0 && len(ids) > l.maxCount {
+		remove = len(ids) - l.maxCount
+	}
+	if l.maxAge > 0 {
+		var since = l.now().Add(-l.maxAge)
+		for remove < len(ids) && ids[remove].Time().Before(since) {
+			remove++
+		}
+	}
+	if remove == 0 {
+		return 0, nil
+	}
+
+	var keys = make([]string, remove)
+	for index, id := range ids[:remove] {
+		keys[index] = l.eventKey(id)
+	}
+	if err = l.store.RemoveKeys(keys...); err != nil {
+		return 0, nerror.WrapOnly(err)
+	}
+	return remove, nil
+}
+
+// ids returns the ids of all events in order.
+func (l *EventLog) ids() ([]nxid.ID, error) {
+	var prefix = l.prefix + "events:"
+	var keys, err = l.store.EachKeyMatch("^" + regexp.QuoteMeta(prefix))
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	// guard against stores which do not anchor the regexp.
+	var ids = make([]nxid.ID, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		var id, parseErr = nxid.FromString(key[len(prefix):])
+		if parseErr != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	nxid.Sort(ids)
+	return ids, nil
+}
+
+func (l *EventLog) eventKey(id nxid.ID) string {
+	return l.prefix + "events:" + id.String()
+}
+
+func (l *EventLog) offsetKey(consumer string) string {
+	return l.prefix + "offsets:" + consumer
+}
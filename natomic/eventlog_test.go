@@ -0,0 +1,206 @@
+package natomic
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nstorage"
+	"github.com/influx6/npkg/nstorage/nmap"
+	"github.com/influx6/npkg/nxid"
+)
+
+type userSignal struct {
+	Name string `json:"name"`
+}
+
+func (userSignal) Type() string {
+	return "user.created"
+}
+
+func (userSignal) Target() string {
+	return "users"
+}
+
+func (userSignal) Source() string {
+	return "test"
+}
+
+func TestEventLog(t *testing.T) {
+	var store = nmap.NewExprByteStore()
+	var log = NewEventLog(store, "log:")
+
+	for _, name := range []string{"ada", "bob", "cid"} {
+		var event, err = log.Append(userSignal{Name: name})
+		require.NoError(t, err)
+		require.False(t, event.ID.IsNil())
+	}
+	require.NoError(t, store.Save("other", []byte("ignored")))
+
+	var names []string
+	var ids []nxid.ID
+	require.NoError(t, log.Replay(nxid.NilID(), func(event Event) error {
+		var user userSignal
+		require.NoError(t, event.Decode(&user))
+		require.Equal(t, "user.created", event.Type())
+		require.Equal(t, "users", event.Target())
+		require.Equal(t, "test", event.Source())
+		names = append(names, user.Name)
+		ids = append(ids, event.ID)
+		return nil
+	}))
+	require.Equal(t, []string{"ada", "bob", "cid"}, names)
+
+	// a new log over the same store resumes after an offset.
+	var restarted = NewEventLog(store, "log:")
+	var replayed []string
+	require.NoError(t, restarted.Replay(ids[0], func(event Event) error {
+		var user userSignal
+		require.NoError(t, event.Decode(&user))
+		replayed = append(replayed, user.Name)
+		return nstorage.ErrJustStop
+	}))
+	require.Equal(t, []string{"bob"}, replayed)
+
+	var errFailed = errors.New("failed")
+	require.Equal(t, errFailed, restarted.Replay(nxid.NilID(), func(Event) error {
+		return errFailed
+	}))
+}
+
+func TestEventLogConsumers(t *testing.T) {
+	var log = NewEventLog(nmap.NewExprByteStore(), "log:")
+
+	var offset, err = log.Offset("mailer")
+	require.NoError(t, err)
+	require.True(t, offset.IsNil())
+
+	_, err = log.Append(userSignal{Name: "ada"})
+	require.NoError(t, err)
+
+	var mailer = newCollector()
+	require.NoError(t, log.Resume("mailer", mailer))
+	require.Len(t, mailer.wait(t, 1), 1)
+
+	_, err = log.Append(userSignal{Name: "bob"})
+	require.NoError(t, err)
+
+	// only the event appended since the last resume is delivered.
+	require.NoError(t, log.Resume("mailer", mailer))
+	require.Len(t, mailer.wait(t, 1), 2)
+
+	offset, err = log.Offset("mailer")
+	require.NoError(t, err)
+
+	var last Event
+	require.NoError(t, log.Replay(nxid.NilID(), func(event Event) error {
+		last = event
+		return nil
+	}))
+	require.Equal(t, last.ID, offset)
+}
+
+func TestEventLogRetention(t *testing.T) {
+	var now = time.Now()
+	var clock = func() time.Time {
+		return now
+	}
+
+	var log = NewEventLog(nmap.NewExprByteStore(), "log:", RetainCount(3), RetainFor(time.Hour), CompactEvery(0), EventLogClock(clock))
+	for i := 0; i < 5; i++ {
+		var _, err = log.Append(userSignal{Name: "user"})
+		require.NoError(t, err)
+	}
+
+	var removed, err = log.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	var count int
+	count, err = log.Count()
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	now = now.Add(2 * time.Hour)
+	removed, err = log.Compact()
+	require.NoError(t, err)
+	require.Equal(t, 3, removed)
+
+	// compaction runs on append.
+	log = NewEventLog(nmap.NewExprByteStore(), "log:", RetainCount(2), CompactEvery(2))
+	for i := 0; i < 4; i++ {
+		var _, err = log.Append(userSignal{Name: "user"})
+		require.NoError(t, err)
+	}
+	count, err = log.Count()
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestEventLogAtom(t *testing.T) {
+	var group = NewResponderGroup()
+	var started = make(chan struct{}, 1)
+	group.Start(started)
+	<-started
+
+	var log = NewEventLog(nmap.NewExprByteStore(), "atoms:")
+	addResponder(t, group, log)
+
+	var atom = NewAtom(group)
+	require.NoError(t, atom.Set(map[string]int{"count": 1}))
+	group.Close()
+
+	var values []map[string]int
+	require.NoError(t, log.Replay(nxid.NilID(), func(event Event) error {
+		var value map[string]int
+		require.NoError(t, event.Decode(&value))
+		values = append(values, value)
+		return nil
+	}))
+	require.Equal(t, []map[string]int{{"count": 1}}, values)
+}
+
+// scanOnlyStore fails Keys, to ensure the log only scans it's prefix, and
+// Exists when unreachable is set.
+type scanOnlyStore struct {
+	*nmap.ExprByteStore
+	unreachable bool
+}
+
+func (s *scanOnlyStore) Keys() ([]string, error) {
+	return nil, errors.New("keys lists the whole store")
+}
+
+func (s *scanOnlyStore) Exists(key string) (bool, error) {
+	if s.unreachable {
+		return false, errors.New("store is unreachable")
+	}
+	return s.ExprByteStore.Exists(key)
+}
+
+func TestEventLogStoreErrors(t *testing.T) {
+	var store = &scanOnlyStore{ExprByteStore: nmap.NewExprByteStore()}
+	require.NoError(t, store.Save("other:events:"+nxid.New().String(), []byte("{}")))
+
+	var log = NewEventLog(store, "log:")
+	var _, err = log.Append(userSignal{Name: "ada"})
+	require.NoError(t, err)
+
+	var count int
+	count, err = log.Count()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	var mailer = newCollector()
+	require.NoError(t, log.Resume("mailer", mailer))
+	require.Len(t, mailer.wait(t, 1), 1)
+
+	// a failing store must not resume the consumer from the start.
+	store.unreachable = true
+	_, err = log.Offset("mailer")
+	require.Error(t, err)
+	require.Error(t, log.Resume("mailer", mailer))
+	require.Len(t, mailer.wait(t, 0), 1)
+}
@@ -0,0 +1,421 @@
+package natomic
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// cacheLine is the assumed size of a cpu cache line, fields written by
+// different goroutines are padded to it to avoid false sharing.
+const cacheLine = 64
+
+//******************************************************
+// RingQueue
+//******************************************************
+
+type ringCell struct {
+	seq   uint64
+	value interface{}
+}
+
+// RingQueue defines a lock-free bounded queue safe for use by multiple
+// producers and consumers, based on the bounded MPMC queue of Dmitry Vyukov.
+//
+// Every cell of the ring carries a sequence telling producers and consumers
+// if it is free or filled for their position, so a position is claimed with
+// a single compare-and-swap.
+//
+// Once created, you must not copy a RingQueue.
+type RingQueue struct {
+	head  uint64
+	_     [cacheLine - 8]byte
+	tail  uint64
+	_     [cacheLine - 8]byte
+	mask  uint64
+	cells []ringCell
+}
+
+// NewRingQueue returns a new RingQueue holding up to giving capacity,
+// rounded up to a power of two.
+func NewRingQueue(capacity int) *RingQueue {
+	var size = nextPowerOfTwo(capacity)
+	var q = &RingQueue{
+		mask:  uint64(size - 1),
+		cells: make([]ringCell, size),
+	}
+	for index := range q.cells {
+		q.cells[index].seq = uint64(index)
+	}
+	return q
+}
+
+// Offer adds giving value to the queue, returning false if it is full.
+func (q *RingQueue) Offer(value interface{}) bool {
+	var pos = atomic.LoadUint64(&q.head)
+	for {
+		var cell = &q.cells[pos&q.mask]
+		var diff = int64(atomic.LoadUint64(&cell.seq)) - int64(pos)
+		switch {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&q.head, pos, pos+1) {
+				cell.value = value
+				atomic.StoreUint64(&cell.seq, pos+1)
+				return true
+			}
+		case diff < 0:
+			return false
+		}
+		pos = atomic.LoadUint64(&q.head)
+	}
+}
+
+// Poll removes the oldest value of the queue, returning false if it is
+// empty.
+func (q *RingQueue) Poll() (interface{}, bool) {
+	var pos = atomic.LoadUint64(&q.tail)
+	for {
+		var cell = &q.cells[pos&q.mask]
+		var diff = int64(atomic.LoadUint64(&cell.seq)) - int64(pos+1)
+		switch {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&q.tail, pos, pos+1) {
+				var value = cell.value
+				cell.value = nil
+				atomic.StoreUint64(&cell.seq, pos+q.mask+1)
+				return value, true
+			}
+		case diff < 0:
+			return nil, false
+		}
+		pos = atomic.LoadUint64(&q.tail)
+	}
+}
+
+// Len returns the number of values in the queue, it is only a snapshot
+// while the queue is in use.
+func (q *RingQueue) Len() int {
+	var tail = atomic.LoadUint64(&q.tail)
+	var head = atomic.LoadUint64(&q.head)
+	if head < tail {
+		return 0
+	}
+	return int(head - tail)
+}
+
+// Cap returns the capacity of the queue.
+func (q *RingQueue) Cap() int {
+	return len(q.cells)
+}
+
+func nextPowerOfTwo(n int) int {
+	var size = 1
+	for size < n {
+		size <<= 1
+	}
+	return size
+}
+
+//******************************************************
+// MPSCQueue
+//******************************************************
+
+type queueNode struct {
+	next  unsafe.Pointer
+	value interface{}
+}
+
+// MPSCQueue defines a lock-free unbounded queue safe for use by multiple
+// producers but a single consumer, based on the intrusive MPSC queue of
+// Dmitry Vyukov.
+//
+// Producers only swap the head of the queue, so a Push never retries. A
+// value pushed is visible to the consumer once it's producer linked it,
+// Pop may hence briefly miss values of producers still linking them.
+//
+// Once created, you must not copy a MPSCQueue.
+type MPSCQueue struct {
+	size int64
+	head unsafe.Pointer
+	_    [cacheLine - 16]byte
+	tail *queueNode
+}
+
+// NewMPSCQueue returns a new MPSCQueue.
+func NewMPSCQueue() *MPSCQueue {
+	var stub = &queueNode{}
+	return &MPSCQueue{
+		head: unsafe.Pointer(stub),
+		tail: stub,
+	}
+}
+
+// Push adds giving value to the queue, it is safe for concurrent use.
+func (q *MPSCQueue) Push(value interface{}) {
+	var node = &queueNode{value: value}
+	atomic.AddInt64(&q.size, 1)
+	var prev = (*queueNode)(atomic.SwapPointer(&q.head, unsafe.Pointer(node)))
+	atomic.StorePointer(&prev.next, unsafe.Pointer(node))
+}
+
+// Pop removes the oldest value of the queue, returning false if it is
+// empty. It must only be called by a single consumer.
+func (q *MPSCQueue) Pop() (interface{}, bool) {
+	var next = (*queueNode)(atomic.LoadPointer(&q.tail.next))
+	if next == nil {
+		return nil, false
+	}
+
+	q.tail = next
+	var value = next.value
+	next.value = nil
+	atomic.AddInt64(&q.size, -1)
+	return value, true
+}
+
+// Len returns the number of values pushed and not yet popped.
+func (q *MPSCQueue) Len() int {
+	return int(atomic.LoadInt64(&q.size))
+}
+
+//******************************************************
+// Stack
+//******************************************************
+
+type stackNode struct {
+	next  *stackNode
+	value interface{}
+}
+
+// Stack defines a lock-free LIFO stack safe for concurrent use, implementing
+// the Treiber stack. Nodes are never reused, the garbage collector hence
+// rules out the ABA problem.
+//
+// The zero value is an empty stack, once used you must not copy a Stack.
+type Stack struct {
+	size int64
+	top  unsafe.Pointer
+}
+
+// Push adds giving value to the top of the stack.
+func (s *Stack) Push(value interface{}) {
+	var node = &stackNode{value: value}
+	for {
+		var top = atomic.LoadPointer(&s.top)
+		node.next = (*stackNode)(top)
+		if atomic.CompareAndSwapPointer(&s.top, top, unsafe.Pointer(node)) {
+			atomic.AddInt64(&s.size, 1)
+			return
+		}
+	}
+}
+
+// Pop removes the value at the top of the stack, returning false if it is
+// empty.
+func (s *Stack) Pop() (interface{}, bool) {
+	for {
+		var top = atomic.LoadPointer(&s.top)
+		if top == nil {
+			return nil, false
+		}
+
+		var node = (*stackNode)(top)
+		if atomic.CompareAndSwapPointer(&s.top, top, unsafe.Pointer(node.next)) {
+			atomic.AddInt64(&s.size, -1)
+			return node.value, true
+		}
+	}
+}
+
+// Peek returns the value at the top of the stack without removing it.
+func (s *Stack) Peek() (interface{}, bool) {
+	var top = (*stackNode)(atomic.LoadPointer(&s.top))
+	if top == nil {
+		return nil, false
+	}
+	return top.value, true
+}
+
+// Len returns the number of values in the stack.
+func (s *Stack) Len() int {
+	return int(atomic.LoadInt64(&s.size))
+}
+
+//******************************************************
+// CopyOnWriteMap
+//******************************************************
+
+// CopyOnWriteMap defines a map safe for concurrent use whose reads never
+// block nor retry: every write copies the map and swaps it in with a
+// compare-and-swap, retrying if another write happened meanwhile.
+//
+// It suits maps read far more often than written, like configurations,
+// as every write copies all entries.
+//
+// The zero value is an empty map, once used you must not copy a
+// CopyOnWriteMap.
+type CopyOnWriteMap struct {
+	items unsafe.Pointer
+}
+
+func (m *CopyOnWriteMap) load() map[string]interface{} {
+	var items = (*map[string]interface{})(atomic.LoadPointer(&m.items))
+	if items == nil {
+		return nil
+	}
+	return *items
+}
+
+// Get returns the value of giving key.
+func (m *CopyOnWriteMap) Get(key string) (interface{}, bool) {
+	var value, ok = m.load()[key]
+	return value, ok
+}
+
+// Len returns the number of entries.
+func (m *CopyOnWriteMap) Len() int {
+	return len(m.load())
+}
+
+// Range calls fn for all entries of the map as it was when called, until
+// fn returns false.
+func (m *CopyOnWriteMap) Range(fn func(key string, value interface{}) bool) {
+	for key, value := range m.load() {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of all entries.
+func (m *CopyOnWriteMap) Snapshot() map[string]interface{} {
+	var items = m.load()
+	var snapshot = make(map[string]interface{}, len(items))
+	for key, value := range items {
+		snapshot[key] = value
+	}
+	return snapshot
+}
+
+// Set sets the value of giving key.
+func (m *CopyOnWriteMap) Set(key string, value interface{}) {
+	m.Update(func(items map[string]interface{}) {
+		items[key] = value
+	})
+}
+
+// Delete removes giving key.
+func (m *CopyOnWriteMap) Delete(key string) {
+	m.Update(func(items map[string]interface{}) {
+		delete(items, key)
+	})
+}
+
+// Update calls fn with a copy of the map, swapping it in once fn returns
+// so all changes of fn are seen at once. fn is called again with a new copy
+// if another write happened meanwhile, it must hence have no side effects.
+func (m *CopyOnWriteMap) Update(fn func(items map[string]interface{})) {
+	for {
+		var current = atomic.LoadPointer(&m.items)
+
+		var items map[string]interface{}
+		if current != nil {
+			items = *(*map[string]interface{})(current)
+		}
+
+		var next = make(map[string]interface{}, len(items)+1)
+		for key, value := range items {
+			next[key] = value
+		}
+		fn(next)
+
+		if atomic.CompareAndSwapPointer(&m.items, current, unsafe.Pointer(&next)) {
+			return
+		}
+	}
+}
+
+//******************************************************
+// StripedCounter
+//******************************************************
+
+type counterStripe struct {
+	value int64
+	_     [cacheLine - 8]byte
+}
+
+// StripedCounter defines a counter for heavily contended increments, like
+// the LongAdder of java: increments are spread over stripes on their own
+// cache lines, which Read sums.
+//
+// Goroutines keep to a stripe through a sync.Pool of stripe hints, which
+// is local to a processor, moving to another stripe when theirs is
+// contended. Read is hence slower than with an Int64Counter.
+type StripedCounter struct {
+	seed    uint64
+	mask    uint64
+	stripes []counterStripe
+	hints   sync.Pool
+}
+
+// NewStripedCounter returns a new StripedCounter with giving number of
+// stripes rounded up to a power of two, a value of 0 uses four times
+// runtime.GOMAXPROCS.
+func NewStripedCounter(stripes int) *StripedCounter {
+	if stripes <= 0 {
+		stripes = 4 * runtime.GOMAXPROCS(0)
+	}
+
+	var size = nextPowerOfTwo(stripes)
+	var c = &StripedCounter{
+		mask:    uint64(size - 1),
+		stripes: make([]counterStripe, size),
+	}
+	c.hints.New = func() interface{} {
+		// hints must not be zero for xorshift.
+		var hint = atomic.AddUint64(&c.seed, 0x9E3779B97F4A7C15) | 1
+		return &hint
+	}
+	return c
+}
+
+// Add adds giving delta to the counter.
+func (c *StripedCounter) Add(delta int64) {
+	var hint = c.hints.Get().(*uint64)
+	var stripe = &c.stripes[*hint&c.mask]
+
+	var value = atomic.LoadInt64(&stripe.value)
+	if !atomic.CompareAndSwapInt64(&stripe.value, value, value+delta) {
+		// the stripe is contended, this goroutine moves to another one.
+		*hint ^= *hint << 13
+		*hint ^= *hint >> 7
+		*hint ^= *hint << 17
+		atomic.AddInt64(&c.stripes[*hint&c.mask].value, delta)
+	}
+	c.hints.Put(hint)
+}
+
+// Inc increments the counter by one.
+func (c *StripedCounter) Inc() {
+	c.Add(1)
+}
+
+// Read returns the sum of all stripes, it is only a snapshot while the
+// counter is incremented.
+func (c *StripedCounter) Read() int64 {
+	var sum int64
+	for index := range c.stripes {
+		sum += atomic.LoadInt64(&c.stripes[index].value)
+	}
+	return sum
+}
+
+// Reset sets the counter to zero, returning it's value before.
+func (c *StripedCounter) Reset() int64 {
+	var sum int64
+	for index := range c.stripes {
+		sum += atomic.SwapInt64(&c.stripes[index].value, 0)
+	}
+	return sum
+}
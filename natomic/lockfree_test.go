@@ -0,0 +1,312 @@
+package natomic
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	lockFreeWorkers = 8
+	lockFreeItems   = 2000
+)
+
+func TestRingQueue(t *testing.T) {
+	var q = NewRingQueue(3)
+	require.Equal(t, 4, q.Cap())
+
+	for i := 0; i < 4; i++ {
+		require.True(t, q.Offer(i))
+	}
+	require.False(t, q.Offer(4))
+	require.Equal(t, 4, q.Len())
+
+	for i := 0; i < 4; i++ {
+		var value, ok = q.Poll()
+		require.True(t, ok)
+		require.Equal(t, i, value)
+	}
+
+	var _, ok = q.Poll()
+	require.False(t, ok)
+	require.Equal(t, 0, q.Len())
+}
+
+func TestRingQueueConcurrent(t *testing.T) {
+	var q = NewRingQueue(64)
+
+	var consumed int64
+	var sum int64
+	var waiter sync.WaitGroup
+	for worker := 0; worker < lockFreeWorkers; worker++ {
+		waiter.Add(2)
+		go func() {
+			defer waiter.Done()
+			for i := 1; i <= lockFreeItems; i++ {
+				for !q.Offer(i) {
+					runtime.Gosched()
+				}
+			}
+		}()
+		go func() {
+			defer waiter.Done()
+			for atomic.LoadInt64(&consumed) < lockFreeWorkers*lockFreeItems {
+				var value, ok = q.Poll()
+				if !ok {
+					runtime.Gosched()
+					continue
+				}
+				atomic.AddInt64(&sum, int64(value.(int)))
+				atomic.AddInt64(&consumed, 1)
+			}
+		}()
+	}
+	waiter.Wait()
+
+	require.Equal(t, int64(lockFreeWorkers*lockFreeItems*(lockFreeItems+1)/2), sum)
+	require.Equal(t, 0, q.Len())
+}
+
+func TestMPSCQueue(t *testing.T) {
+	var q = NewMPSCQueue()
+	var _, ok = q.Pop()
+	require.False(t, ok)
+
+	var waiter sync.WaitGroup
+	for worker := 0; worker < lockFreeWorkers; worker++ {
+		waiter.Add(1)
+		go func(worker int) {
+			defer waiter.Done()
+			for i := 0; i < lockFreeItems; i++ {
+				q.Push([2]int{worker, i})
+			}
+		}(worker)
+	}
+
+	// values of every producer must be popped in the order they were pushed.
+	var next = make([]int, lockFreeWorkers)
+	for popped := 0; popped < lockFreeWorkers*lockFreeItems; {
+		var value, ok = q.Pop()
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		var item = value.([2]int)
+		require.Equal(t, next[item[0]], item[1])
+		next[item[0]]++
+		popped++
+	}
+	waiter.Wait()
+
+	require.Equal(t, 0, q.Len())
+}
+
+func TestStack(t *testing.T) {
+	var s Stack
+	s.Push(1)
+	s.Push(2)
+
+	var value, ok = s.Peek()
+	require.True(t, ok)
+	require.Equal(t, 2, value)
+
+	value, _ = s.Pop()
+	require.Equal(t, 2, value)
+	value, _ = s.Pop()
+	require.Equal(t, 1, value)
+	_, ok = s.Pop()
+	require.False(t, ok)
+}
+
+func TestStackConcurrent(t *testing.T) {
+	var s Stack
+	var seen = make([]int32, lockFreeWorkers*lockFreeItems)
+
+	var waiter sync.WaitGroup
+	for worker := 0; worker < lockFreeWorkers; worker++ {
+		waiter.Add(1)
+		go func(worker int) {
+			defer waiter.Done()
+			for i := 0; i < lockFreeItems; i++ {
+				s.Push(worker*lockFreeItems + i)
+				if value, ok := s.Pop(); ok {
+					atomic.AddInt32(&seen[value.(int)], 1)
+				}
+			}
+		}(worker)
+	}
+	waiter.Wait()
+
+	for {
+		var value, ok = s.Pop()
+		if !ok {
+			break
+		}
+		seen[value.(int)]++
+	}
+	for _, count := range seen {
+		require.Equal(t, int32(1), count)
+	}
+	require.Equal(t, 0, s.Len())
+}
+
+func TestCopyOnWriteMap(t *testing.T) {
+	var m CopyOnWriteMap
+	require.Equal(t, 0, m.Len())
+
+	m.Set("a", 1)
+	var snapshot = m.Snapshot()
+	m.Set("b", 2)
+	m.Delete("a")
+
+	require.Equal(t, map[string]interface{}{"a": 1}, snapshot)
+	require.Equal(t, map[string]interface{}{"b": 2}, m.Snapshot())
+
+	var value, ok = m.Get("b")
+	require.True(t, ok)
+	require.Equal(t, 2, value)
+	_, ok = m.Get("a")
+	require.False(t, ok)
+}
+
+func TestCopyOnWriteMapConcurrent(t *testing.T) {
+	var m CopyOnWriteMap
+
+	var waiter sync.WaitGroup
+	for worker := 0; worker < lockFreeWorkers; worker++ {
+		waiter.Add(2)
+		go func(worker int) {
+			defer waiter.Done()
+			for i := 0; i < 100; i++ {
+				m.Set(strconv.Itoa(worker)+"."+strconv.Itoa(i), i)
+			}
+		}(worker)
+		go func() {
+			defer waiter.Done()
+			for i := 0; i < 100; i++ {
+				m.Range(func(_ string, _ interface{}) bool {
+					return true
+				})
+			}
+		}()
+	}
+	waiter.Wait()
+
+	require.Equal(t, lockFreeWorkers*100, m.Len())
+}
+
+func TestStripedCounter(t *testing.T) {
+	var c = NewStripedCounter(0)
+
+	var waiter sync.WaitGroup
+	for worker := 0; worker < lockFreeWorkers; worker++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			for i := 0; i < lockFreeItems; i++ {
+				c.Inc()
+			}
+		}()
+	}
+	waiter.Wait()
+
+	require.Equal(t, int64(lockFreeWorkers*lockFreeItems), c.Read())
+	require.Equal(t, int64(lockFreeWorkers*lockFreeItems), c.Reset())
+	require.Equal(t, int64(0), c.Read())
+}
+
+func BenchmarkRingQueue(b *testing.B) {
+	b.ReportAllocs()
+
+	var q = NewRingQueue(1024)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Offer(1)
+			q.Poll()
+		}
+	})
+}
+
+func BenchmarkMPSCQueue(b *testing.B) {
+	b.ReportAllocs()
+
+	var q = NewMPSCQueue()
+	var done = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				q.Pop()
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Push(1)
+		}
+	})
+	b.StopTimer()
+	close(done)
+}
+
+func BenchmarkStack(b *testing.B) {
+	b.ReportAllocs()
+
+	var s Stack
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Push(1)
+			s.Pop()
+		}
+	})
+}
+
+func BenchmarkCopyOnWriteMapGet(b *testing.B) {
+	b.ReportAllocs()
+
+	var m CopyOnWriteMap
+	for i := 0; i < 100; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.Get("50")
+		}
+	})
+}
+
+func BenchmarkStripedCounter(b *testing.B) {
+	b.ReportAllocs()
+
+	var c = NewStripedCounter(0)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
+
+func BenchmarkInt64CounterContended(b *testing.B) {
+	b.ReportAllocs()
+
+	var c Int64Counter
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add(1)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package natomic
 
 import (
+	"encoding/json"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -76,6 +78,8 @@ type Atom struct {
 	signals SignalReceiver
 	imx     sync.Mutex
 	store   *atomic.Value
+	id      uint64
+	watches watchers
 }
 
 // NewAtom returns a new instance of *Atom.
@@ -84,6 +88,7 @@ func NewAtom(receiver SignalReceiver) *Atom {
 	return &Atom{
 		signals: receiver,
 		store:   &newStore,
+		id:      atomic.AddUint64(&atomIDs, 1),
 	}
 }
 
@@ -93,11 +98,12 @@ func NewAtom(receiver SignalReceiver) *Atom {
 func (am *Atom) Set(val interface{}) error {
 	am.imx.Lock()
 	defer am.imx.Unlock()
-	am.store.Store(val)
 
-	if am.signals != nil {
-		am.signals.Respond(am)
+	var prev, err = am.swap(val)
+	if err != nil {
+		return err
 	}
+	am.notify(prev, val)
 	return nil
 }
 
@@ -117,10 +123,23 @@ func (am *Atom) Type() string {
 	return "natomic.Atom"
 }
 
+// Payload returns the json encoding of the value stored within giving
+// atom, implementing the PayloadSignal interface.
+func (am *Atom) Payload() ([]byte, error) {
+	var data, err = json.Marshal(am.Read())
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return data, nil
+}
+
 // Read returns the giving value stored within giving atom.
 // It returns nil if no value was ever set.
 func (am *Atom) Read() interface{} {
-	return am.store.Load()
+	if boxed, ok := am.store.Load().(atomValue); ok {
+		return boxed.value
+	}
+	return nil
 }
 
 // Add adds a new SignalResponder into the atom responder
@@ -153,24 +172,140 @@ func (am *Atom) Respond(s Signal) {
 // ResponderGroup
 //************************************************************************
 
+var (
+	// ErrQueueFull is given to the dead-letter hook of a ResponderGroup for
+	// signals dropped as the queue of their responder was full.
+	ErrQueueFull = errors.New("responder queue is full")
+
+	// ErrResponderRemoved is given to the dead-letter hook of a ResponderGroup
+	// for signals left in the queue of a responder which was removed,
+	// disconnected or not drained when the group closed.
+	ErrResponderRemoved = errors.New("responder was removed")
+)
+
+// OverflowPolicy defines how a ResponderGroup handles a signal for a
+// responder whose queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks until the responder makes room in it's queue,
+	// delaying delivery to all responders of the group.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest signal of the queue.
+	OverflowDropOldest
+
+	// OverflowDropNewest drops the signal being delivered.
+	OverflowDropNewest
+
+	// OverflowDisconnect removes the responder from the group.
+	OverflowDisconnect
+)
+
+// GroupOption defines a function type which sets the options of a
+// ResponderGroup.
+type GroupOption func(*ResponderGroup)
+
+// QueueSize gives every responder it's own queue holding up to n signals
+// and goroutine delivering them in order, so a slow responder does not
+// delay others. By default signals are delivered to all responders in
+// turn by the goroutine of the group.
+func QueueSize(n int) GroupOption {
+	return func(rg *ResponderGroup) {
+		rg.queueSize = n
+	}
+}
+
+// Overflow sets the policy used when the queue of a responder is full,
+// defaults to OverflowBlock.
+func Overflow(policy OverflowPolicy) GroupOption {
+	return func(rg *ResponderGroup) {
+		rg.overflow = policy
+	}
+}
+
+// DeadLetter sets a function called with signals which could not be
+// delivered to a responder and the reason, either ErrQueueFull or
+// ErrResponderRemoved. It is called from the goroutines of the group and
+// must not block.
+func DeadLetter(fn func(r SignalResponder, s Signal, reason error)) GroupOption {
+	return func(rg *ResponderGroup) {
+		rg.deadLetter = fn
+	}
+}
+
+// DrainTimeout sets how long Close waits for responders to receive the
+// signals left in their queues, signals not delivered in time are given to
+// the dead-letter hook. Defaults to 5 seconds.
+func DrainTimeout(d time.Duration) GroupOption {
+	return func(rg *ResponderGroup) {
+		rg.drainTimeout = d
+	}
+}
+
+// DeliveryMetrics holds the counts of signals handled by a ResponderGroup
+// or one of it's responders.
+type DeliveryMetrics struct {
+	// Published is the number of signals given to Respond, only counted
+	// for the group.
+	Published uint64
+
+	// Delivered is the number of signals delivered to responders.
+	Delivered uint64
+
+	// Dropped is the number of signals dropped by an overflow policy.
+	Dropped uint64
+
+	// Undelivered is the number of signals left in the queue of removed
+	// responders.
+	Undelivered uint64
+
+	// Disconnected is the number of responders removed by the
+	// OverflowDisconnect policy.
+	Disconnected uint64
+
+	// Queued is the number of signals waiting in queues.
+	Queued int
+}
+
 // ResponderGroup defines a grouping and manager for SignalResponders, which manages
 // the addition, and removals, basically the management of the lifecycle of a set of
 // SignalResponders.
+//
+// By default all signals are delivered to responders by the goroutine of
+// the group, a slow responder hence delays all others. With QueueSize every
+// responder receives signals from it's own bounded queue, see OverflowPolicy
+// for the handling of full queues.
 type ResponderGroup struct {
+	queueSize    int
+	overflow     OverflowPolicy
+	deadLetter   func(SignalResponder, Signal, error)
+	drainTimeout time.Duration
+
 	sm         sync.Mutex
 	spawned    bool
 	waiter     sync.WaitGroup
 	closer     chan struct{}
 	actions    chan func()
-	responders map[SignalResponder]struct{}
+	responders map[SignalResponder]*responderQueue
+
+	published    Uint64Counter
+	delivered    Uint64Counter
+	dropped      Uint64Counter
+	undelivered  Uint64Counter
+	disconnected Uint64Counter
 }
 
 // NewResponderGroup returns a new ResponderGroup instance.
-func NewResponderGroup() *ResponderGroup {
+func NewResponderGroup(ops ...GroupOption) *ResponderGroup {
 	var rg ResponderGroup
 	rg.actions = make(chan func())
 	rg.closer = make(chan struct{})
-	rg.responders = map[SignalResponder]struct{}{}
+	rg.responders = map[SignalResponder]*responderQueue{}
+	rg.drainTimeout = 5 * time.Second
+	for _, op := range ops {
+		op(&rg)
+	}
 	return &rg
 }
 
@@ -182,14 +317,12 @@ func (rg *ResponderGroup) Remove(r SignalResponder) error {
 
 // RemoveGuaranteed returns an error if it fails to deliver SignalResponder into
 // responder group.
+//
+// Signals left in the queue of the responder are given to the dead-letter
+// hook.
 func (rg *ResponderGroup) RemoveGuaranteed(r SignalResponder, signal chan struct{}) error {
 	var action = func() {
-		if _, found := rg.responders[r]; found {
-			delete(rg.responders, r)
-			if irs, ok := r.(SignalResponderNotification); ok {
-				irs.OnRemoved()
-			}
-		}
+		rg.remove(r)
 
 		if signal != nil {
 			signal <- struct{}{}
@@ -214,9 +347,18 @@ func (rg *ResponderGroup) Add(r SignalResponder) error {
 // responder group.
 func (rg *ResponderGroup) AddGuaranteed(r SignalResponder, signal chan struct{}) error {
 	var action = func() {
-		rg.responders[r] = struct{}{}
-		if irs, ok := r.(SignalResponderNotification); ok {
-			irs.OnAdded()
+		if _, found := rg.responders[r]; !found {
+			var queue = newResponderQueue(rg, r)
+			rg.sm.Lock()
+			rg.responders[r] = queue
+			rg.sm.Unlock()
+			if queue.buffered() {
+				rg.waiter.Add(1)
+				go queue.run()
+			}
+			if irs, ok := r.(SignalResponderNotification); ok {
+				irs.OnAdded()
+			}
 		}
 
 		if signal != nil {
@@ -239,10 +381,23 @@ func (rg *ResponderGroup) Respond(s Signal) {
 
 // RespondGuaranteed returns an error if it fails to deliver SignalResponder into
 // responder group.
+//
+// With QueueSize the signal is queued for all responders before a signal is
+// sent on the channel, without it the signal is delivered to all of them.
 func (rg *ResponderGroup) RespondGuaranteed(s Signal, signal chan struct{}) {
 	var action = func() {
-		for responder := range rg.responders {
-			responder.Respond(s)
+		rg.published.Add(1)
+		for responder, queue := range rg.responders {
+			if !queue.buffered() {
+				responder.Respond(s)
+				queue.delivered.Add(1)
+				rg.delivered.Add(1)
+				continue
+			}
+			if !queue.push(s) {
+				rg.disconnected.Add(1)
+				rg.remove(responder)
+			}
 		}
 		if signal != nil {
 			signal <- struct{}{}
@@ -252,8 +407,48 @@ func (rg *ResponderGroup) RespondGuaranteed(s Signal, signal chan struct{}) {
 	rg.actions <- action
 }
 
+// Metrics returns the delivery metrics of the group.
+func (rg *ResponderGroup) Metrics() DeliveryMetrics {
+	var queued int
+	rg.sm.Lock()
+	for _, queue := range rg.queues() {
+		queued += queue.len()
+	}
+	rg.sm.Unlock()
+
+	return DeliveryMetrics{
+		Published:    rg.published.Read(),
+		Delivered:    rg.delivered.Read(),
+		Dropped:      rg.dropped.Read(),
+		Undelivered:  rg.undelivered.Read(),
+		Disconnected: rg.disconnected.Read(),
+		Queued:       queued,
+	}
+}
+
+// ResponderMetrics returns the delivery metrics of giving responder,
+// returning false if it is not part of the group.
+func (rg *ResponderGroup) ResponderMetrics(r SignalResponder) (DeliveryMetrics, bool) {
+	rg.sm.Lock()
+	defer rg.sm.Unlock()
+
+	var queue, ok = rg.responders[r]
+	if !ok {
+		return DeliveryMetrics{}, false
+	}
+	return DeliveryMetrics{
+		Delivered:   queue.delivered.Read(),
+		Dropped:     queue.dropped.Read(),
+		Undelivered: queue.undelivered.Read(),
+		Queued:      queue.len(),
+	}, true
+}
+
 // Close closes giving responder group, removing all
 // it's subscription.
+//
+// With QueueSize, Close waits up to the DrainTimeout for responders to
+// receive the signals left in their queues.
 func (rg *ResponderGroup) Close() {
 	rg.closer <- struct{}{}
 	rg.waiter.Wait()
@@ -276,7 +471,7 @@ func (rg *ResponderGroup) Start(started ...chan struct{}) {
 		rg.closer = make(chan struct{})
 	}
 	if rg.responders == nil {
-		rg.responders = map[SignalResponder]struct{}{}
+		rg.responders = map[SignalResponder]*responderQueue{}
 	}
 
 	if len(started) > 0 {
@@ -306,6 +501,61 @@ func (rg *ResponderGroup) running() bool {
 	return running
 }
 
+// queues returns the queues of all responders, rg.sm must be held.
+func (rg *ResponderGroup) queues() []*responderQueue {
+	var queues = make([]*responderQueue, 0, len(rg.responders))
+	for _, queue := range rg.responders {
+		queues = append(queues, queue)
+	}
+	return queues
+}
+
+// remove removes giving responder, giving signals left in it's queue to
+// the dead-letter hook.
+func (rg *ResponderGroup) remove(r SignalResponder) {
+	rg.sm.Lock()
+	var queue, found = rg.responders[r]
+	delete(rg.responders, r)
+	rg.sm.Unlock()
+
+	if !found {
+		return
+	}
+
+	queue.stop()
+	if irs, ok := r.(SignalResponderNotification); ok {
+		irs.OnRemoved()
+	}
+}
+
+// drain closes all queues, waiting up to the drain timeout for them to
+// be delivered before stopping them.
+func (rg *ResponderGroup) drain() {
+	rg.sm.Lock()
+	var queues = rg.queues()
+	rg.responders = map[SignalResponder]*responderQueue{}
+	rg.sm.Unlock()
+
+	var timeout = time.NewTimer(rg.drainTimeout)
+	defer timeout.Stop()
+
+	for _, queue := range queues {
+		queue.close()
+	}
+	for _, queue := range queues {
+		if !queue.buffered() {
+			continue
+		}
+		select {
+		case <-queue.done:
+		case <-timeout.C:
+			// the timer fired, later queues are stopped at once.
+			timeout.Reset(0)
+			queue.stop()
+		}
+	}
+}
+
 // manage setups the necessary goroutine for managing the underline
 // process for giving responder.
 //
@@ -326,7 +576,7 @@ func (rg *ResponderGroup) manage(started chan struct{}) {
 		for {
 			select {
 			case <-rg.closer:
-				rg.responders = map[SignalResponder]struct{}{}
+				rg.drain()
 				return
 			case action := <-rg.actions:
 				action()
@@ -334,3 +584,157 @@ func (rg *ResponderGroup) manage(started chan struct{}) {
 		}
 	}()
 }
+
+//************************************************************************
+// responderQueue
+//************************************************************************
+
+// responderQueue holds the signals waiting for delivery to a responder
+// of a ResponderGroup.
+type responderQueue struct {
+	group     *ResponderGroup
+	responder SignalResponder
+
+	lock     sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []Signal
+	closed   bool
+	stopped  bool
+	done     chan struct{}
+
+	delivered   Uint64Counter
+	dropped     Uint64Counter
+	undelivered Uint64Counter
+}
+
+func newResponderQueue(rg *ResponderGroup, r SignalResponder) *responderQueue {
+	var queue = &responderQueue{group: rg, responder: r, done: make(chan struct{})}
+	queue.notEmpty = sync.NewCond(&queue.lock)
+	queue.notFull = sync.NewCond(&queue.lock)
+	return queue
+}
+
+// buffered returns true if signals are delivered from the queue rather
+// than by the goroutine of the group.
+func (q *responderQueue) buffered() bool {
+	return q.group.queueSize > 0
+}
+
+func (q *responderQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.items)
+}
+
+// push adds giving signal to the queue, applying the overflow policy if
+// it is full. It returns false if the responder must be disconnected.
+func (q *responderQueue) push(s Signal) bool {
+	q.lock.Lock()
+	for len(q.items) >= q.group.queueSize && !q.closed {
+		switch q.group.overflow {
+		case OverflowDropOldest:
+			var oldest = q.items[0]
+			q.items[0] = nil
+			q.items = q.items[1:]
+			q.lock.Unlock()
+			q.dropFull(oldest)
+			q.lock.Lock()
+		case OverflowDropNewest:
+			q.lock.Unlock()
+			q.dropFull(s)
+			return true
+		case OverflowDisconnect:
+			q.lock.Unlock()
+			q.dropFull(s)
+			return false
+		default:
+			q.notFull.Wait()
+		}
+	}
+
+	if q.closed {
+		q.lock.Unlock()
+		q.dropRemoved(s)
+		return true
+	}
+
+	q.items = append(q.items, s)
+	q.notEmpty.Signal()
+	q.lock.Unlock()
+	return true
+}
+
+// dropFull records a signal dropped by the overflow policy.
+func (q *responderQueue) dropFull(s Signal) {
+	q.dropped.Add(1)
+	q.group.dropped.Add(1)
+	q.deadLetter(s, ErrQueueFull)
+}
+
+// dropRemoved records a signal left in the queue of a removed responder.
+func (q *responderQueue) dropRemoved(s Signal) {
+	q.undelivered.Add(1)
+	q.group.undelivered.Add(1)
+	q.deadLetter(s, ErrResponderRemoved)
+}
+
+func (q *responderQueue) deadLetter(s Signal, reason error) {
+	if q.group.deadLetter != nil {
+		q.group.deadLetter(q.responder, s, reason)
+	}
+}
+
+// close stops accepting signals, the queue stops once it is empty.
+func (q *responderQueue) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.lock.Unlock()
+}
+
+// stop stops the queue at once, giving the signals left in it to the
+// dead-letter hook.
+func (q *responderQueue) stop() {
+	q.lock.Lock()
+	var left = q.items
+	q.items = nil
+	q.closed = true
+	q.stopped = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.lock.Unlock()
+
+	for _, s := range left {
+		q.dropRemoved(s)
+	}
+}
+
+// run delivers queued signals in order until the queue is closed and
+// empty or stopped.
+func (q *responderQueue) run() {
+	defer q.group.waiter.Done()
+	defer close(q.done)
+
+	for {
+		q.lock.Lock()
+		for len(q.items) == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.stopped || len(q.items) == 0 {
+			q.lock.Unlock()
+			return
+		}
+
+		var next = q.items[0]
+		q.items[0] = nil
+		q.items = q.items[1:]
+		q.notFull.Signal()
+		q.lock.Unlock()
+
+		q.responder.Respond(next)
+		q.delivered.Add(1)
+		q.group.delivered.Add(1)
+	}
+}
@@ -1,8 +1,10 @@
 package natomic
 
 import (
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -81,3 +83,205 @@ type signalDelivery struct {
 func (s *signalDelivery) Respond(_ Signal) {
 	atomic.AddInt64(&s.event, 1)
 }
+
+type deadLetters struct {
+	lock    sync.Mutex
+	signals []string
+	reasons []error
+}
+
+func (d *deadLetters) record(_ SignalResponder, s Signal, reason error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.signals = append(d.signals, s.Type())
+	d.reasons = append(d.reasons, reason)
+}
+
+// blockedResponder blocks delivery until release is closed.
+type blockedResponder struct {
+	*collector
+	release chan struct{}
+}
+
+func newBlockedResponder() *blockedResponder {
+	return &blockedResponder{collector: newCollector(), release: make(chan struct{})}
+}
+
+func (b *blockedResponder) Respond(s Signal) {
+	<-b.release
+	b.collector.Respond(s)
+}
+
+// sleepyResponder sleeps before every delivery.
+type sleepyResponder struct {
+	*collector
+}
+
+func (s *sleepyResponder) Respond(signal Signal) {
+	time.Sleep(time.Millisecond)
+	s.collector.Respond(signal)
+}
+
+func startGroup(t *testing.T, ops ...GroupOption) *ResponderGroup {
+	var group = NewResponderGroup(ops...)
+	var started = make(chan struct{}, 1)
+	group.Start(started)
+	<-started
+	return group
+}
+
+func addResponder(t *testing.T, group *ResponderGroup, r SignalResponder) {
+	var added = make(chan struct{}, 1)
+	require.NoError(t, group.AddGuaranteed(r, added))
+	<-added
+}
+
+func respond(group *ResponderGroup, kinds ...string) {
+	var done = make(chan struct{}, 1)
+	for _, kind := range kinds {
+		group.RespondGuaranteed(topicSignal{kind: kind}, done)
+		<-done
+	}
+}
+
+func TestRespondGroupSlowResponder(t *testing.T) {
+	var letters deadLetters
+	var group = startGroup(t, QueueSize(2), Overflow(OverflowDropNewest), DeadLetter(letters.record))
+
+	var slow, fast = newBlockedResponder(), newCollector()
+	addResponder(t, group, slow)
+	addResponder(t, group, fast)
+
+	// the first signal is taken by the blocked responder, leaving room
+	// for two in it's queue.
+	respond(group, "a")
+	fast.wait(t, 1)
+	require.Eventually(t, func() bool {
+		var metrics, _ = group.ResponderMetrics(slow)
+		return metrics.Queued == 0
+	}, time.Second, time.Millisecond)
+
+	// the fast responder keeps up while the slow one drops signals.
+	for _, kind := range []string{"b", "c", "d", "e"} {
+		respond(group, kind)
+		fast.wait(t, 1)
+	}
+	require.Equal(t, []string{"a@", "b@", "c@", "d@", "e@"}, fast.wait(t, 0))
+	require.Equal(t, []string{"d", "e"}, letters.signals)
+	require.Equal(t, ErrQueueFull, letters.reasons[0])
+
+	close(slow.release)
+	require.Equal(t, []string{"a@", "b@", "c@"}, slow.wait(t, 3))
+
+	group.Close()
+
+	var metrics = group.Metrics()
+	require.Equal(t, uint64(5), metrics.Published)
+	require.Equal(t, uint64(8), metrics.Delivered)
+	require.Equal(t, uint64(2), metrics.Dropped)
+	require.Equal(t, 0, metrics.Queued)
+}
+
+func TestRespondGroupDropOldest(t *testing.T) {
+	var group = startGroup(t, QueueSize(2), Overflow(OverflowDropOldest))
+
+	var slow = newBlockedResponder()
+	addResponder(t, group, slow)
+
+	respond(group, "a")
+	require.Eventually(t, func() bool {
+		var metrics, _ = group.ResponderMetrics(slow)
+		return metrics.Queued == 0
+	}, time.Second, time.Millisecond)
+	respond(group, "b", "c", "d")
+
+	close(slow.release)
+	require.Equal(t, []string{"a@", "c@", "d@"}, slow.wait(t, 3))
+	group.Close()
+}
+
+func TestRespondGroupDisconnect(t *testing.T) {
+	var letters deadLetters
+	var group = startGroup(t, QueueSize(1), Overflow(OverflowDisconnect), DeadLetter(letters.record))
+
+	var slow = newBlockedResponder()
+	addResponder(t, group, slow)
+
+	respond(group, "a")
+	require.Eventually(t, func() bool {
+		var metrics, _ = group.ResponderMetrics(slow)
+		return metrics.Queued == 0
+	}, time.Second, time.Millisecond)
+	respond(group, "b", "c")
+
+	var _, found = group.ResponderMetrics(slow)
+	require.False(t, found)
+	require.Equal(t, uint64(1), group.Metrics().Disconnected)
+	require.Equal(t, []string{"c", "b"}, letters.signals)
+	require.Equal(t, []error{ErrQueueFull, ErrResponderRemoved}, letters.reasons)
+
+	close(slow.release)
+	require.Equal(t, []string{"a@"}, slow.wait(t, 1))
+	group.Close()
+}
+
+func TestRespondGroupBlock(t *testing.T) {
+	var group = startGroup(t, QueueSize(1))
+
+	var slow = newBlockedResponder()
+	addResponder(t, group, slow)
+
+	respond(group, "a", "b")
+
+	var published = make(chan struct{})
+	go func() {
+		respond(group, "c")
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("respond did not block on a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(slow.release)
+	<-published
+	require.Equal(t, []string{"a@", "b@", "c@"}, slow.wait(t, 3))
+	group.Close()
+}
+
+func TestRespondGroupDrain(t *testing.T) {
+	var group = startGroup(t, QueueSize(10))
+
+	var responder = &sleepyResponder{collector: newCollector()}
+	addResponder(t, group, responder)
+
+	respond(group, "a", "b", "c")
+	group.Close()
+	require.Equal(t, []string{"a@", "b@", "c@"}, responder.wait(t, 0))
+
+	var letters deadLetters
+	group = startGroup(t, QueueSize(10), DrainTimeout(10*time.Millisecond), DeadLetter(letters.record))
+
+	var slow = newBlockedResponder()
+	addResponder(t, group, slow)
+	respond(group, "a", "b", "c")
+
+	var closed = make(chan struct{})
+	go func() {
+		group.Close()
+		close(closed)
+	}()
+
+	require.Eventually(t, func() bool {
+		letters.lock.Lock()
+		defer letters.lock.Unlock()
+		return len(letters.signals) == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, []string{"b", "c"}, letters.signals)
+
+	close(slow.release)
+	<-closed
+	require.Equal(t, uint64(2), group.Metrics().Undelivered)
+}
@@ -0,0 +1,449 @@
+package nchain
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/npool"
+)
+
+// CycleError is returned when the dependencies of tasks of a DAG form a
+// cycle, it holds the names of the tasks of the cycle.
+type CycleError struct {
+	Path []string
+}
+
+// Error returns the path of the cycle.
+func (c *CycleError) Error() string {
+	return "dag has a dependency cycle: " + strings.Join(c.Path, " -> ")
+}
+
+// FailurePolicy defines what a DAG does with the remaining tasks once a
+// task failed.
+type FailurePolicy int
+
+// Failure policies.
+const (
+	// FailFast cancels the context of running tasks and cancels all tasks
+	// not yet started.
+	FailFast FailurePolicy = iota
+
+	// SkipDependents skips the tasks depending directly or not on failed
+	// tasks, running all others.
+	SkipDependents
+
+	// ContinueOnFailure runs all tasks, including the ones depending on
+	// failed tasks.
+	ContinueOnFailure
+)
+
+// TaskStatus defines the outcome of a task of a DAG.
+type TaskStatus int
+
+// Task statuses.
+const (
+	TaskPending TaskStatus = iota
+	TaskSucceeded
+	TaskFailed
+	TaskSkipped
+	TaskCanceled
+)
+
+// String returns the name of the status.
+func (t TaskStatus) String() string {
+	switch t {
+	case TaskPending:
+		return "pending"
+	case TaskSucceeded:
+		return "succeeded"
+	case TaskFailed:
+		return "failed"
+	case TaskSkipped:
+		return "skipped"
+	case TaskCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// TaskReport holds the outcome of a task of a DAG.
+type TaskReport struct {
+	Name     string
+	Deps     []string
+	Status   TaskStatus
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Report lists the outcome of all tasks of a DAG run, in the order of
+// their dependencies.
+type Report struct {
+	Tasks    []TaskReport
+	Duration time.Duration
+}
+
+// Task returns the report of the named task.
+func (r *Report) Task(name string) (TaskReport, bool) {
+	for _, task := range r.Tasks {
+		if task.Name == name {
+			return task, true
+		}
+	}
+	return TaskReport{}, false
+}
+
+// Succeeded returns true if all tasks succeeded.
+func (r *Report) Succeeded() bool {
+	for _, task := range r.Tasks {
+		if task.Status != TaskSucceeded {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns the error of the first failed task, or nil if none failed.
+func (r *Report) Err() error {
+	for _, task := range r.Tasks {
+		if task.Status == TaskFailed {
+			return task.Err
+		}
+	}
+	return nil
+}
+
+// String returns a table of the status, duration and error of all tasks.
+func (r *Report) String() string {
+	var width = len("task")
+	for _, task := range r.Tasks {
+		if len(task.Name) > width {
+			width = len(task.Name)
+		}
+	}
+
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, "%-*s  %-9s  %10s  %s\n", width, "task", "status", "duration", "error")
+	for _, task := range r.Tasks {
+		var message string
+		if task.Err != nil {
+			message = task.Err.Error()
+		}
+		_, _ = fmt.Fprintf(&builder, "%-*s  %-9s  %10s  %s\n", width, task.Name, task.Status, task.Duration.Round(time.Microsecond), message)
+	}
+	_, _ = fmt.Fprintf(&builder, "total %s", r.Duration.Round(time.Microsecond))
+	return builder.String()
+}
+
+// RunOption defines a function type which sets the options of a DAG run.
+type RunOption func(*dagRun)
+
+// Concurrency sets the number of tasks running at once, defaults to
+// runtime.GOMAXPROCS. It is ignored if RunOn is used.
+func Concurrency(n int) RunOption {
+	return func(r *dagRun) {
+		r.concurrency = n
+	}
+}
+
+// OnFailure sets the failure policy, defaults to FailFast.
+func OnFailure(policy FailurePolicy) RunOption {
+	return func(r *dagRun) {
+		r.policy = policy
+	}
+}
+
+// RunOn sets the executor running the tasks, instead of a npool.Pool of
+// Concurrency workers created for the run.
+func RunOn(executor npool.Executor) RunOption {
+	return func(r *dagRun) {
+		r.executor = executor
+	}
+}
+
+type dagTask struct {
+	name string
+	deps []string
+	fn   func(context.Context) error
+}
+
+// DAG holds tasks depending on other tasks by name, which Run runs once
+// their dependencies completed, running independent tasks in parallel.
+//
+// Every task is run by a FutureChain triggered once all it's dependencies
+// settled, with the functions of the chains run by a npool.Executor which
+// bounds the number of tasks running at once.
+type DAG struct {
+	lock  sync.Mutex
+	tasks map[string]*dagTask
+	names []string
+}
+
+// NewDAG returns a new empty DAG.
+func NewDAG() *DAG {
+	return &DAG{tasks: map[string]*dagTask{}}
+}
+
+// Add adds the named task running giving function once the tasks named by
+// deps completed. Dependencies may be added after the task depending on
+// them, they are validated by Validate and Run.
+func (d *DAG) Add(name string, fn func(context.Context) error, deps ...string) error {
+	if name == "" {
+		return nerror.New("task name can not be empty")
+	}
+	if fn == nil {
+		return nerror.New("task %q has no function", name)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.tasks[name]; ok {
+		return nerror.New("task %q is already added", name)
+	}
+	d.tasks[name] = &dagTask{name: name, deps: deps, fn: fn}
+	d.names = append(d.names, name)
+	return nil
+}
+
+// Validate returns an error if a task depends on a task which was not
+// added, or a *CycleError if dependencies form a cycle.
+func (d *DAG) Validate() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var _, err = d.sort()
+	return err
+}
+
+// sort returns the tasks ordered so every task comes after it's
+// dependencies, tasks added first coming first otherwise.
+func (d *DAG) sort() ([]*dagTask, error) {
+	var pending = map[string]int{}
+	var dependents = map[string][]string{}
+	for _, name := range d.names {
+		var task = d.tasks[name]
+		for _, dep := range task.deps {
+			if _, ok := d.tasks[dep]; !ok {
+				return nil, nerror.New("task %q depends on unknown task %q", name, dep)
+			}
+			if dep == name {
+				return nil, nerror.WrapOnly(&CycleError{Path: []string{name, name}})
+			}
+			pending[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var index = map[string]int{}
+	for i, name := range d.names {
+		index[name] = i
+	}
+
+	var ready []string
+	for _, name := range d.names {
+		if pending[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	var sorted = make([]*dagTask, 0, len(d.names))
+	for len(ready) > 0 {
+		var name = ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, d.tasks[name])
+
+		var unlocked []string
+		for _, dependent := range dependents[name] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				unlocked = append(unlocked, dependent)
+			}
+		}
+		sort.Slice(unlocked, func(i, j int) bool {
+			return index[unlocked[i]] < index[unlocked[j]]
+		})
+		ready = append(ready, unlocked...)
+	}
+
+	if len(sorted) < len(d.names) {
+		return nil, nerror.WrapOnly(&CycleError{Path: d.cycle(pending)})
+	}
+	return sorted, nil
+}
+
+// cycle returns the path of a cycle among tasks left with pending
+// dependencies by sort.
+func (d *DAG) cycle(pending map[string]int) []string {
+	var start string
+	for _, name := range d.names {
+		if pending[name] > 0 {
+			start = name
+			break
+		}
+	}
+
+	// every task left has a dependency left, following them must loop.
+	var seen = map[string]int{}
+	var path []string
+	for name := start; ; {
+		if at, ok := seen[name]; ok {
+			return append(path[at:], name)
+		}
+		seen[name] = len(path)
+		path = append(path, name)
+		for _, dep := range d.tasks[name].deps {
+			if pending[dep] > 0 {
+				name = dep
+				break
+			}
+		}
+	}
+}
+
+// Run runs all tasks of the DAG, returning the report of all tasks and the
+// error of the first failed task in order. An error is returned without a
+// report if the DAG is not valid, see Validate.
+func (d *DAG) Run(ctx context.Context, ops ...RunOption) (*Report, error) {
+	d.lock.Lock()
+	var tasks, err = d.sort()
+	d.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var run = &dagRun{
+		concurrency: runtime.GOMAXPROCS(0),
+		policy:      FailFast,
+		reports:     map[string]*TaskReport{},
+		done:        map[string]chan struct{}{},
+	}
+	for _, op := range ops {
+		op(run)
+	}
+	if run.executor == nil {
+		var pool = npool.NewPool(npool.Workers(run.concurrency), npool.QueueSize(0))
+		defer pool.ShutdownNow()
+		run.executor = pool
+	}
+
+	run.ctx, run.cancel = context.WithCancel(ctx)
+	defer run.cancel()
+
+	var report = &Report{Tasks: make([]TaskReport, len(tasks))}
+	for index, task := range tasks {
+		report.Tasks[index] = TaskReport{Name: task.name, Deps: task.deps}
+		run.reports[task.name] = &report.Tasks[index]
+		run.done[task.name] = make(chan struct{})
+	}
+
+	var start = time.Now()
+	var waiter sync.WaitGroup
+	for _, task := range tasks {
+		waiter.Add(1)
+		go func(task *dagTask) {
+			defer waiter.Done()
+			run.schedule(task)
+		}(task)
+	}
+	waiter.Wait()
+	report.Duration = time.Since(start)
+
+	if err := report.Err(); err != nil {
+		return report, err
+	}
+	if err := ctx.Err(); err != nil {
+		return report, nerror.WrapOnly(err)
+	}
+	return report, nil
+}
+
+type dagRun struct {
+	concurrency int
+	policy      FailurePolicy
+	executor    npool.Executor
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	lock    sync.Mutex
+	failed  bool
+	reports map[string]*TaskReport
+	done    map[string]chan struct{}
+}
+
+// schedule runs giving task with a FutureChain once it's dependencies
+// settled, recording it's outcome.
+func (r *dagRun) schedule(task *dagTask) {
+	defer close(r.done[task.name])
+
+	var depsOK = true
+	for _, dep := range task.deps {
+		<-r.done[dep]
+
+		r.lock.Lock()
+		if r.reports[dep].Status != TaskSucceeded {
+			depsOK = false
+		}
+		r.lock.Unlock()
+	}
+
+	var report = r.reports[task.name]
+	if r.ctx.Err() != nil {
+		r.settle(report, TaskCanceled, nil)
+		return
+	}
+	if !depsOK && r.policy != ContinueOnFailure {
+		r.settle(report, TaskSkipped, nil)
+		return
+	}
+
+	var chain = newFutureChain(r.ctx, r.executor, func(ctx context.Context) error {
+		var started = time.Now()
+		var err = task.fn(ctx)
+
+		r.lock.Lock()
+		report.Started = started
+		report.Duration = time.Since(started)
+		r.lock.Unlock()
+		return err
+	})
+	chain.exec(nil, true)
+
+	if err := chain.Wait(); err != nil {
+		r.fail(report, err)
+		return
+	}
+	r.settle(report, TaskSucceeded, nil)
+}
+
+func (r *dagRun) settle(report *TaskReport, status TaskStatus, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	report.Status = status
+	report.Err = err
+}
+
+// fail records the failure of a task, canceling the run with FailFast.
+// Tasks failing once the run is canceled are recorded as canceled.
+func (r *dagRun) fail(report *TaskReport, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	report.Err = err
+	if r.ctx.Err() != nil && (r.failed || r.policy == FailFast) {
+		report.Status = TaskCanceled
+		return
+	}
+
+	report.Status = TaskFailed
+	r.failed = true
+	if r.policy == FailFast {
+		r.cancel()
+	}
+}
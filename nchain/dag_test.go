@@ -0,0 +1,160 @@
+package nchain_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nchain"
+	"github.com/influx6/npkg/nerror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the order tasks run in.
+type recorder struct {
+	lock  sync.Mutex
+	order []string
+}
+
+func (r *recorder) task(name string, err error) func(context.Context) error {
+	return func(_ context.Context) error {
+		r.lock.Lock()
+		r.order = append(r.order, name)
+		r.lock.Unlock()
+		return err
+	}
+}
+
+func (r *recorder) index(name string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for index, ran := range r.order {
+		if ran == name {
+			return index
+		}
+	}
+	return -1
+}
+
+func TestDAG_Run(t *testing.T) {
+	var ran recorder
+	dag := nchain.NewDAG()
+	require.NoError(t, dag.Add("package", ran.task("package", nil), "build", "test"))
+	require.NoError(t, dag.Add("fetch", ran.task("fetch", nil)))
+	require.NoError(t, dag.Add("build", ran.task("build", nil), "fetch"))
+	require.NoError(t, dag.Add("test", ran.task("test", nil), "fetch"))
+	require.Error(t, dag.Add("fetch", ran.task("fetch", nil)))
+
+	report, err := dag.Run(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.Succeeded())
+
+	assert.Equal(t, 0, ran.index("fetch"))
+	assert.Equal(t, 3, ran.index("package"))
+
+	var names []string
+	for _, task := range report.Tasks {
+		names = append(names, task.Name)
+	}
+	assert.Equal(t, []string{"fetch", "build", "test", "package"}, names)
+	assert.Contains(t, report.String(), "package")
+}
+
+func TestDAG_Validate(t *testing.T) {
+	dag := nchain.NewDAG()
+	require.NoError(t, dag.Add("a", func(_ context.Context) error { return nil }, "c"))
+	require.NoError(t, dag.Add("b", func(_ context.Context) error { return nil }, "a"))
+	require.NoError(t, dag.Add("c", func(_ context.Context) error { return nil }, "b"))
+	require.NoError(t, dag.Add("d", func(_ context.Context) error { return nil }))
+
+	err := dag.Validate()
+	cycle, ok := nerror.UnwrapDeep(err).(*nchain.CycleError)
+	require.True(t, ok)
+	assert.Equal(t, []string{"a", "c", "b", "a"}, cycle.Path)
+
+	_, err = dag.Run(context.Background())
+	require.Error(t, err)
+
+	unknown := nchain.NewDAG()
+	require.NoError(t, unknown.Add("a", func(_ context.Context) error { return nil }, "missing"))
+	require.Error(t, unknown.Validate())
+}
+
+func TestDAG_Concurrency(t *testing.T) {
+	var running, peak int64
+	work := func(_ context.Context) error {
+		now := atomic.AddInt64(&running, 1)
+		for {
+			last := atomic.LoadInt64(&peak)
+			if now <= last || atomic.CompareAndSwapInt64(&peak, last, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return nil
+	}
+
+	dag := nchain.NewDAG()
+	require.NoError(t, dag.Add("root", work))
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		require.NoError(t, dag.Add(name, work, "root"))
+	}
+
+	_, err := dag.Run(context.Background(), nchain.Concurrency(3))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&peak))
+}
+
+func TestDAG_FailurePolicies(t *testing.T) {
+	badErr := errors.New("bad task")
+
+	build := func() (*nchain.DAG, *recorder) {
+		var ran recorder
+		dag := nchain.NewDAG()
+		require.NoError(t, dag.Add("fetch", ran.task("fetch", badErr)))
+		require.NoError(t, dag.Add("build", ran.task("build", nil), "fetch"))
+		require.NoError(t, dag.Add("package", ran.task("package", nil), "build"))
+		require.NoError(t, dag.Add("lint", ran.task("lint", nil)))
+		return dag, &ran
+	}
+
+	statuses := func(report *nchain.Report) map[string]nchain.TaskStatus {
+		var all = map[string]nchain.TaskStatus{}
+		for _, task := range report.Tasks {
+			all[task.Name] = task.Status
+		}
+		return all
+	}
+
+	dag, ran := build()
+	report, err := dag.Run(context.Background(), nchain.OnFailure(nchain.SkipDependents))
+	require.Equal(t, badErr, err)
+	assert.Equal(t, map[string]nchain.TaskStatus{
+		"fetch":   nchain.TaskFailed,
+		"build":   nchain.TaskSkipped,
+		"package": nchain.TaskSkipped,
+		"lint":    nchain.TaskSucceeded,
+	}, statuses(report))
+	assert.Equal(t, -1, ran.index("build"))
+
+	dag, ran = build()
+	report, err = dag.Run(context.Background(), nchain.OnFailure(nchain.ContinueOnFailure))
+	require.Equal(t, badErr, err)
+	assert.Equal(t, nchain.TaskSucceeded, statuses(report)["package"])
+	assert.NotEqual(t, -1, ran.index("package"))
+
+	dag, _ = build()
+	report, err = dag.Run(context.Background(), nchain.OnFailure(nchain.FailFast), nchain.Concurrency(1))
+	require.Equal(t, badErr, err)
+	assert.Equal(t, nchain.TaskFailed, statuses(report)["fetch"])
+	assert.Equal(t, nchain.TaskCanceled, statuses(report)["build"])
+
+	task, ok := report.Task("fetch")
+	require.True(t, ok)
+	assert.Equal(t, badErr, task.Err)
+}
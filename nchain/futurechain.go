@@ -2,8 +2,11 @@ package nchain
 
 import (
 	"context"
+	"sync"
 
 	"golang.org/x/sync/errgroup"
+
+	"github.com/influx6/npkg/npool"
 )
 
 // FutureChain implements a pure Future implementation which
@@ -13,6 +16,9 @@ import (
 //
 // FutureChain relies on errgroup.Group underneath.
 //
+// A FutureChain created with an npool.Executor runs it's functions with the
+// executor instead of a goroutine per function, see NewFutureChainOn.
+//
 type FutureChain struct {
 	ctx context.Context
 	g   *errgroup.Group
@@ -22,6 +28,16 @@ type FutureChain struct {
 	closer    chan struct{}
 	signal    chan struct{}
 	revSignal chan struct{}
+
+	executor npool.Executor
+	cancel   context.CancelFunc
+	tasks    sync.WaitGroup
+	lock     sync.Mutex
+	started  bool
+	run      bool
+	pending  []func(context.Context) error
+	errOnce  sync.Once
+	err      error
 }
 
 func noWork(_ context.Context) error { return nil }
@@ -33,10 +49,29 @@ func NoWorkChain(ctx context.Context) *FutureChain {
 	return NewFutureChain(ctx, noWork)
 }
 
+// NoWorkChainOn returns a new FutureChain as NoWorkChain, whose chained
+// functions run with giving executor.
+func NoWorkChainOn(ctx context.Context, executor npool.Executor) *FutureChain {
+	return NewFutureChainOn(ctx, executor, noWork)
+}
+
 // NewFutureChain returns a new instance of a FutureChain using provided function.
 // It immediately lunches function into future chain error group.
 func NewFutureChain(ctx context.Context, fx func(context.Context) error) *FutureChain {
-	chain := newFutureChain(ctx, fx)
+	chain := newFutureChain(ctx, nil, fx)
+	chain.exec(nil, true)
+	return chain
+}
+
+// NewFutureChainOn returns a new FutureChain as NewFutureChain, running
+// it's functions with giving executor, e.g a npool.Pool bounding the number
+// of functions running at once. Chains created from it with When, Then and
+// Chain use the same executor.
+//
+// Functions rejected or dropped by the executor fail the chain with the
+// error of the executor.
+func NewFutureChainOn(ctx context.Context, executor npool.Executor, fx func(context.Context) error) *FutureChain {
+	chain := newFutureChain(ctx, executor, fx)
 	chain.exec(nil, true)
 	return chain
 }
@@ -48,12 +83,19 @@ func NewFutureChain(ctx context.Context, fx func(context.Context) error) *Future
 // This is also useful for deferred calculations that will be performed
 // based on the completion of some other future yet to be created.
 func DeferredChain(ctx context.Context, fx func(context.Context) error) *FutureChain {
-	chain := newFutureChain(ctx, fx)
+	chain := newFutureChain(ctx, nil, fx)
+	return chain
+}
+
+// DeferredChainOn returns a new FutureChain as DeferredChain, running it's
+// functions with giving executor.
+func DeferredChainOn(ctx context.Context, executor npool.Executor, fx func(context.Context) error) *FutureChain {
+	chain := newFutureChain(ctx, executor, fx)
 	return chain
 }
 
 // NewFutureChain returns a new instance of a FutureChain using provided function.
-func newFutureChain(ctx context.Context, fx func(context.Context) error) *FutureChain {
+func newFutureChain(ctx context.Context, executor npool.Executor, fx func(context.Context) error) *FutureChain {
 	ew, ctx := errgroup.WithContext(ctx)
 	chain := &FutureChain{
 		g:         ew,
@@ -63,6 +105,12 @@ func newFutureChain(ctx context.Context, fx func(context.Context) error) *Future
 		closer:    make(chan struct{}, 0),
 		signal:    make(chan struct{}, 0),
 		revSignal: make(chan struct{}, 0),
+		executor:  executor,
+	}
+	if executor != nil {
+		// functions run by the executor cancel the context on failure, as
+		// the ones of the error group do.
+		chain.ctx, chain.cancel = context.WithCancel(ctx)
 	}
 
 	// schedule error kicker.
@@ -85,6 +133,26 @@ func newFutureChain(ctx context.Context, fx func(context.Context) error) *Future
 //
 // It returns itself, to allow chaining.
 func (f *FutureChain) Go(fx func(context.Context) error) *FutureChain {
+	if f.executor != nil {
+		f.tasks.Add(1)
+
+		f.lock.Lock()
+		if !f.started {
+			f.pending = append(f.pending, fx)
+			f.lock.Unlock()
+			return f
+		}
+		var run = f.run
+		f.lock.Unlock()
+
+		if run {
+			f.submit(fx)
+		} else {
+			f.tasks.Done()
+		}
+		return f
+	}
+
 	f.g.Go(func() error {
 		<-f.closer
 		select {
@@ -104,7 +172,7 @@ func (f *FutureChain) Go(fx func(context.Context) error) *FutureChain {
 // It always returns a new FutureChain instance.
 //
 func (f *FutureChain) When(fx func(context.Context) error) *FutureChain {
-	newChain := newFutureChain(f.ctx, fx)
+	newChain := newFutureChain(f.ctx, f.executor, fx)
 	newChain.execIfNoError(f)
 	return newChain
 }
@@ -128,7 +196,7 @@ func (f *FutureChain) WhenFuture(fm *FutureChain) *FutureChain {
 // It always returns a new FutureChain instance.
 //
 func (f *FutureChain) Then(fx func(context.Context) error) *FutureChain {
-	newChain := newFutureChain(f.ctx, fx)
+	newChain := newFutureChain(f.ctx, f.executor, fx)
 	newChain.execBranchDownError(f)
 	return newChain
 }
@@ -152,7 +220,7 @@ func (f *FutureChain) ThenFuture(fm *FutureChain) *FutureChain {
 // error that occurred for this chain as the returned future will only ever
 // return an error that occurred from calling the function passed  in.
 func (f *FutureChain) Chain(fx func(context.Context) error) *FutureChain {
-	newChain := newFutureChain(f.ctx, fx)
+	newChain := newFutureChain(f.ctx, f.executor, fx)
 	newChain.execNoBranchDownError(f)
 	return newChain
 }
@@ -172,7 +240,19 @@ func (f *FutureChain) ChainFuture(fm *FutureChain) *FutureChain {
 // Wait blocks till the operation is completed and
 // returns error seen.
 func (f *FutureChain) Wait() error {
-	return f.g.Wait()
+	err := f.g.Wait()
+	if f.executor == nil {
+		return err
+	}
+
+	// the context is cancelled once all functions completed, as the one
+	// of the error group is.
+	f.tasks.Wait()
+	f.cancel()
+	if err == nil {
+		err = f.err
+	}
+	return err
 }
 
 // exec launches function within future chain error group.
@@ -183,6 +263,52 @@ func (f *FutureChain) exec(err error, execFunc bool) {
 		close(f.signal)
 	}
 	close(f.closer)
+
+	if f.executor == nil {
+		return
+	}
+
+	f.lock.Lock()
+	f.started = true
+	f.run = execFunc
+	pending := f.pending
+	f.pending = nil
+	f.lock.Unlock()
+
+	for _, fx := range pending {
+		if execFunc {
+			f.submit(fx)
+		} else {
+			f.tasks.Done()
+		}
+	}
+}
+
+// submit runs giving function with the executor of the chain.
+func (f *FutureChain) submit(fx func(context.Context) error) {
+	// the context of chains is done once their parent completed, it hence
+	// can not be given to the executor which would drop the function.
+	err := f.executor.Execute(context.Background(), npool.Task{
+		Run: func(_ context.Context) {
+			f.finish(fx(f.ctx))
+		},
+		OnDrop: f.finish,
+	})
+	if err != nil {
+		f.finish(err)
+	}
+}
+
+// finish records the result of a function run with the executor, keeping
+// the first error as errgroup.Group does.
+func (f *FutureChain) finish(err error) {
+	if err != nil {
+		f.errOnce.Do(func() {
+			f.err = err
+			f.cancel()
+		})
+	}
+	f.tasks.Done()
 }
 
 // execIfNoError will execute future chains function if parent chain
@@ -3,10 +3,12 @@ package nchain_test
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/influx6/npkg/nchain"
+	"github.com/influx6/npkg/npool"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -218,3 +220,87 @@ func TestFutureChain_When(t *testing.T) {
 	assert.Equal(t, secondChain.Wait(), firstError)
 	assert.Len(t, signal, 0)
 }
+
+func TestFutureChainOn(t *testing.T) {
+	pool := npool.NewPool(npool.Workers(2), npool.QueueSize(0))
+	defer pool.ShutdownNow()
+
+	var running, peak int64
+	work := func(_ context.Context) error {
+		now := atomic.AddInt64(&running, 1)
+		for {
+			last := atomic.LoadInt64(&peak)
+			if now <= last || atomic.CompareAndSwapInt64(&peak, last, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return nil
+	}
+
+	chain := nchain.NewFutureChainOn(context.Background(), pool, work)
+	for i := 0; i < 50; i++ {
+		chain.Go(work)
+	}
+
+	signal := make(chan struct{}, 1)
+	next := chain.When(func(_ context.Context) error {
+		signal <- struct{}{}
+		return nil
+	})
+
+	assert.NoError(t, next.Wait())
+	assert.Len(t, signal, 1)
+	assert.True(t, atomic.LoadInt64(&peak) <= 2)
+}
+
+func TestFutureChainOn_Cancel(t *testing.T) {
+	pool := npool.NewPool(npool.Workers(2))
+	defer pool.ShutdownNow()
+
+	var done <-chan struct{}
+	chain := nchain.NewFutureChainOn(context.Background(), pool, func(ctx context.Context) error {
+		done = ctx.Done()
+		return nil
+	})
+
+	// the context is done once the chain completed successfully.
+	assert.NoError(t, chain.Wait())
+	select {
+	case <-done:
+	default:
+		t.Fatal("context of chain was not cancelled")
+	}
+}
+
+func TestFutureChainOn_Error(t *testing.T) {
+	firstError := errors.New("first error")
+	pool := npool.NewPool(npool.Workers(2))
+	defer pool.ShutdownNow()
+
+	chain := nchain.NewFutureChainOn(context.Background(), pool, func(_ context.Context) error {
+		return firstError
+	})
+
+	signal := make(chan struct{}, 1)
+	secondChain := chain.When(func(_ context.Context) error {
+		signal <- struct{}{}
+		return nil
+	})
+
+	assert.Equal(t, secondChain.Wait(), firstError)
+	assert.Len(t, signal, 0)
+
+	deferred := nchain.DeferredChainOn(context.Background(), pool, func(_ context.Context) error {
+		return nil
+	})
+	pool.ShutdownNow()
+	chain.ThenFuture(deferred)
+	assert.Equal(t, deferred.Wait(), firstError)
+
+	rejected := nchain.NewFutureChainOn(context.Background(), pool, func(_ context.Context) error {
+		return nil
+	})
+	assert.True(t, npool.IsShutdown(rejected.Wait()))
+}
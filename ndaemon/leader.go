@@ -0,0 +1,36 @@
+package ndaemon
+
+import (
+	"context"
+
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nstorage/nlock"
+)
+
+// LeaderJob returns a DaemonJob which runs giving job only while holding a
+// lease on key from locker, runs on replicas which fail to acquire the lease
+// are skipped.
+//
+// The context given to job is cancelled if the lease is lost, e.g:
+//
+//	var locker = nlock.New(nlock.NewRedisStore("crons:", client), nlock.TTL(30*time.Second))
+//	var daemon = ndaemon.Cron(ctx, cancel, "0 */5 * * * *", "reports", "", logger, daemon.SystemDaemon,
+//		ndaemon.LeaderJob(locker, "reports", generateReports))
+func LeaderJob(locker *nlock.Locker, key string, job DaemonJob) DaemonJob {
+	return func(ctx context.Context, logger Logger) {
+		var err = locker.Do(ctx, key, func(ctx context.Context, lease *nlock.Lease) error {
+			job(ctx, logger)
+			return nil
+		})
+		if err == nil || nlock.IsHeld(err) {
+			return
+		}
+
+		njson.Log(logger).New().
+			LError().
+			Message("failed to run job as leader").
+			String("key", key).
+			String("error", err.Error()).
+			End()
+	}
+}
@@ -0,0 +1,78 @@
+package ndaemon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nstorage/nlock"
+)
+
+type countLogger struct {
+	logs int32
+}
+
+func (l *countLogger) Log(_ *njson.JSON) {
+	atomic.AddInt32(&l.logs, 1)
+}
+
+// unreachableStore fails acquiring any lease.
+type unreachableStore struct {
+	*nlock.MemoryStore
+}
+
+func (unreachableStore) Acquire(_ context.Context, _ string, _ string, _ time.Duration) (uint64, error) {
+	return 0, errors.New("store is unreachable")
+}
+
+func TestLeaderJob(t *testing.T) {
+	var store = nlock.NewMemoryStore()
+
+	var runs int32
+	var started = make(chan struct{})
+	var release = make(chan struct{})
+	var job = func(_ context.Context, _ Logger) {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			close(started)
+			<-release
+		}
+	}
+
+	// replicas share the store, each with it's own locker.
+	var leader, follower countLogger
+	var first = LeaderJob(nlock.New(store, nlock.TTL(time.Minute)), "reports", job)
+	var second = LeaderJob(nlock.New(store, nlock.TTL(time.Minute)), "reports", job)
+
+	var waiter sync.WaitGroup
+	waiter.Add(1)
+	go func() {
+		defer waiter.Done()
+		first(context.Background(), &leader)
+	}()
+	<-started
+
+	// the replica failing to acquire the lease skips the run silently.
+	second(context.Background(), &follower)
+	require.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	require.Equal(t, int32(0), atomic.LoadInt32(&follower.logs))
+
+	close(release)
+	waiter.Wait()
+	require.Equal(t, int32(0), atomic.LoadInt32(&leader.logs))
+
+	// the lease is released once the job completed.
+	second(context.Background(), &follower)
+	require.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	require.Equal(t, int32(0), atomic.LoadInt32(&follower.logs))
+
+	var failing countLogger
+	LeaderJob(nlock.New(unreachableStore{store}), "reports", job)(context.Background(), &failing)
+	require.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	require.Equal(t, int32(1), atomic.LoadInt32(&failing.logs))
+}
@@ -0,0 +1,45 @@
+package ndaemon
+
+import (
+	"context"
+
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nstorage/nqueue"
+)
+
+// QueueJob returns a DaemonJob which processes the jobs of queue until the
+// daemon's context is cancelled, waiting for the jobs being processed
+// before it returns.
+//
+// Jobs must be handled before the daemon runs, failures can be logged with
+// LogQueueErrors, e.g:
+//
+//	var queue = nqueue.New(nqueue.NewRedisStore("jobs:", client), "mails",
+//		nqueue.Workers(8), nqueue.OnError(ndaemon.LogQueueErrors(logger)))
+//	queue.Handle("welcome", sendWelcomeMail)
+//	var daemon = &ndaemon.ServiceDaemon{Name: "mailer", Ctx: ctx, Cancel: cancel, Logger: logger,
+//		Job: ndaemon.QueueJob(queue)}
+func QueueJob(queue *nqueue.Queue) DaemonJob {
+	return func(ctx context.Context, _ Logger) {
+		queue.Run(ctx)
+	}
+}
+
+// LogQueueErrors returns a function for nqueue.OnError which logs failed
+// jobs and store errors to logger.
+func LogQueueErrors(logger Logger) func(job *nqueue.Job, err error) {
+	var logStack = njson.Log(logger)
+	return func(job *nqueue.Job, err error) {
+		var log = logStack.New().
+			LError().
+			Message("failed to process job").
+			String("error", err.Error())
+		if job != nil {
+			log.String("queue", job.Queue).
+				String("job_id", job.ID).
+				String("job_name", job.Name).
+				Int("attempts", job.Attempts)
+		}
+		log.End()
+	}
+}
@@ -77,6 +77,13 @@ const (
 	HeaderXRequestID          = "X-Request-Id"
 	HeaderServer              = "Server"
 	HeaderOrigin              = "Origin"
+	HeaderRetryAfter          = "Retry-After"
+
+	// Rate limiting
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
 
 	// Access control
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
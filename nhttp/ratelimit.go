@@ -0,0 +1,306 @@
+package nhttp
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nip"
+	"github.com/influx6/npkg/nrates"
+)
+
+// RateOwner returns the owner a request is rate limited as, requests
+// with an empty owner are not limited.
+type RateOwner func(*Ctx) (string, error)
+
+// ByRealIP returns a RateOwner using the ip returned by Ctx.RealIP.
+//
+// Ctx.RealIP trusts the X-Forwarded-For and X-Real-IP headers, use
+// ByRequestIP when requests are not only received through a proxy.
+func ByRealIP() RateOwner {
+	return func(c *Ctx) (string, error) {
+		return "ip:" + c.RealIP(), nil
+	}
+}
+
+// ByRequestIP returns a RateOwner using the first public ip found by
+// nip.PrivateSubnets.ParseRequestIP, skipping addresses of giving subnets.
+func ByRequestIP(subnets nip.PrivateSubnets) RateOwner {
+	return func(c *Ctx) (string, error) {
+		var addr, err = subnets.ParseRequestIP(c.Request())
+		if err != nil {
+			return "", nerror.WrapOnly(err)
+		}
+		return "ip:" + addr.String(), nil
+	}
+}
+
+// ByHeader returns a RateOwner using the value of giving header, e.g an
+// api key header. Requests without the header are not limited.
+func ByHeader(header string) RateOwner {
+	return func(c *Ctx) (string, error) {
+		var value = c.GetHeader(header)
+		if value == "" {
+			return "", nil
+		}
+		return "header:" + header + ":" + value, nil
+	}
+}
+
+// ByContextValue returns a RateOwner using the string stored under giving
+// key in the request context, e.g the id of an authenticated user set by
+// an authentication middleware. Requests without the value are not limited.
+func ByContextValue(key interface{}) RateOwner {
+	return func(c *Ctx) (string, error) {
+		var value, ok = c.Context().Value(key).(string)
+		if !ok || value == "" {
+			return "", nil
+		}
+		return "user:" + value, nil
+	}
+}
+
+// FirstOf returns a RateOwner using the first non-empty owner returned
+// by giving owners, e.g to limit users by id and anonymous requests by ip.
+func FirstOf(owners ...RateOwner) RateOwner {
+	return func(c *Ctx) (string, error) {
+		for _, owner := range owners {
+			var value, err = owner(c)
+			if err != nil {
+				return "", err
+			}
+			if value != "" {
+				return value, nil
+			}
+		}
+		return "", nil
+	}
+}
+
+// RateLimitOption defines a function type which sets the options of a
+// rate limit middleware.
+type RateLimitOption func(*rateLimiter)
+
+// RateBy sets how the owner of a request is derived, defaults to ByRealIP.
+func RateBy(owner RateOwner) RateLimitOption {
+	return func(r *rateLimiter) {
+		r.owner = owner
+	}
+}
+
+// RateScope sets a prefix added to all owners, routes sharing a store
+// with different limits must use different scopes.
+func RateScope(scope string) RateLimitOption {
+	return func(r *rateLimiter) {
+		r.scope = scope + ":"
+	}
+}
+
+// RateFailOpen allows requests when the limiter fails, e.g when it's
+// store is unreachable, instead of failing them.
+func RateFailOpen() RateLimitOption {
+	return func(r *rateLimiter) {
+		r.failOpen = true
+	}
+}
+
+type rateLimiter struct {
+	limiter  *nrates.Limiter
+	owner    RateOwner
+	scope    string
+	failOpen bool
+}
+
+func newRateLimiter(limiter *nrates.Limiter, ops []RateLimitOption) *rateLimiter {
+	var r = &rateLimiter{limiter: limiter, owner: ByRealIP()}
+	for _, op := range ops {
+		op(r)
+	}
+	return r
+}
+
+// allow checks the request against the limiter, setting the RateLimit
+// headers on the response. It returns a HTTPError with the status 429
+// if the request is not allowed.
+func (r *rateLimiter) allow(c *Ctx) error {
+	var owner, err = r.owner(c)
+	if err != nil {
+		return HTTPError{Code: http.StatusBadRequest, Err: nerror.WrapOnly(err)}
+	}
+	if owner == "" {
+		return nil
+	}
+
+	var result nrates.Result
+	result, err = r.limiter.Allow(c.Context(), r.scope+owner)
+	if err != nil {
+		if r.failOpen {
+			return nil
+		}
+		return HTTPError{Code: http.StatusServiceUnavailable, Err: nerror.WrapOnly(err)}
+	}
+
+	var header = c.Response().Header()
+	var limit = result.Limit
+	header.Set(HeaderRateLimitLimit, strconv.FormatInt(limit.Rate, 10))
+	header.Set(HeaderRateLimitRemaining, strconv.FormatInt(result.Remaining, 10))
+	header.Set(HeaderRateLimitReset, strconv.FormatInt(seconds(result.ResetAfter), 10))
+	header.Set(HeaderRateLimitPolicy, strconv.FormatInt(limit.Rate, 10)+";w="+strconv.FormatInt(seconds(limit.Period), 10))
+
+	if !result.Allowed {
+		header.Set(HeaderRetryAfter, strconv.FormatInt(seconds(result.RetryAfter), 10))
+		return HTTPError{Code: http.StatusTooManyRequests, Err: nrates.ErrLimitExceeded}
+	}
+	return nil
+}
+
+// RateLimit returns a function which wraps a ContextHandler, limiting it's
+// requests with giving limiter. Use a limiter per route to apply per route
+// limits.
+//
+// Every limited response carries the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers. Requests exceeding the limit
+// receive a Retry-After header and a HTTPError with the status 429 is returned
+// instead of calling the handler.
+func RateLimit(limiter *nrates.Limiter, ops ...RateLimitOption) func(ContextHandler) ContextHandler {
+	var r = newRateLimiter(limiter, ops)
+	return func(next ContextHandler) ContextHandler {
+		return func(c *Ctx) error {
+			if err := r.allow(c); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimitMW returns a Middleware limiting requests with giving limiter,
+// see RateLimit.
+//
+// Requests which are not allowed receive a json error response with the
+// status code of the failure.
+func RateLimitMW(limiter *nrates.Limiter, ops ...RateLimitOption) Middleware {
+	var r = newRateLimiter(limiter, ops)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// the request is not set with SetRequest, as it would parse
+			// the form and consume the body before next receives it.
+			var c = NewContext(SetResponseWriter(w))
+			c.request = req
+			c.ctx = req.Context()
+
+			if err := r.allow(c); err != nil {
+				var code = http.StatusInternalServerError
+				var errorCode = "rate_limit_failed"
+				if httpErr, ok := err.(HTTPError); ok {
+					code = httpErr.Code
+					if code == http.StatusTooManyRequests {
+						errorCode = "rate_limit_exceeded"
+					}
+				}
+				_ = c.Error(code, errorCode, http.StatusText(code), err)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// seconds returns giving duration in whole seconds, rounding up.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// ConcurrencyOption defines a function type which sets the options of a
+// concurrency limit middleware.
+type ConcurrencyOption func(*concurrencyLimiter)
+
+// PriorityBy sets how the priority of a request is derived, which a
+// nrates.Shedder uses to reject lower priority requests first. Defaults
+// to the priority of the request context, see nrates.WithPriority.
+func PriorityBy(fn func(*Ctx) nrates.Priority) ConcurrencyOption {
+	return func(l *concurrencyLimiter) {
+		l.priority = fn
+	}
+}
+
+type concurrencyLimiter struct {
+	limiter  nrates.ConcurrencyLimiter
+	priority func(*Ctx) nrates.Priority
+}
+
+func (l *concurrencyLimiter) acquire(c *Ctx) (nrates.Permit, error) {
+	var ctx = c.Context()
+	if l.priority != nil {
+		ctx = nrates.WithPriority(ctx, l.priority(c))
+	}
+
+	var permit, err = l.limiter.Acquire(ctx)
+	if err != nil {
+		if nrates.IsOverloaded(err) {
+			return nil, HTTPError{Code: http.StatusServiceUnavailable, Err: nrates.ErrOverloaded}
+		}
+		return nil, HTTPError{Code: http.StatusServiceUnavailable, Err: nerror.WrapOnly(err)}
+	}
+	return permit, nil
+}
+
+// ConcurrencyLimit returns a function which wraps a ContextHandler, limiting
+// the requests it handles at once with giving limiter. Requests which are
+// rejected receive a HTTPError with the status 503.
+//
+// The permit of a request is released with the error returned by the
+// handler, which adaptive limiters use to adjust their limit, or with an
+// error describing it's panic if the handler panics.
+func ConcurrencyLimit(limiter nrates.ConcurrencyLimiter, ops ...ConcurrencyOption) func(ContextHandler) ContextHandler {
+	var l = &concurrencyLimiter{limiter: limiter}
+	for _, op := range ops {
+		op(l)
+	}
+
+	return func(next ContextHandler) ContextHandler {
+		return func(c *Ctx) error {
+			var permit, err = l.acquire(c)
+			if err != nil {
+				return err
+			}
+			defer nrates.ReleaseOnPanic(permit)
+
+			var handleErr = next(c)
+			permit.Release(handleErr)
+			return handleErr
+		}
+	}
+}
+
+// ConcurrencyLimitMW returns a Middleware limiting the requests handled at
+// once with giving limiter, see ConcurrencyLimit.
+//
+// Requests which are rejected receive a json error response with the
+// status 503.
+func ConcurrencyLimitMW(limiter nrates.ConcurrencyLimiter, ops ...ConcurrencyOption) Middleware {
+	var l = &concurrencyLimiter{limiter: limiter}
+	for _, op := range ops {
+		op(l)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var c = NewContext(SetResponseWriter(w))
+			c.request = req
+			c.ctx = req.Context()
+
+			var permit, err = l.acquire(c)
+			if err != nil {
+				_ = c.Error(http.StatusServiceUnavailable, "overloaded", http.StatusText(http.StatusServiceUnavailable), err)
+				return
+			}
+			defer nrates.ReleaseOnPanic(permit)
+
+			next.ServeHTTP(w, req)
+			permit.Release(nil)
+		})
+	}
+}
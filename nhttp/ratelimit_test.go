@@ -0,0 +1,207 @@
+package nhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nrates"
+)
+
+type failingStore struct{}
+
+func (failingStore) AllowN(_ context.Context, _ string, _ nrates.Limit, _ int64) (nrates.Result, error) {
+	return nrates.Result{}, errors.New("store is unreachable")
+}
+
+type userKey struct{}
+
+// newLimiter returns a limiter allowing 2 requests per minute, at a fixed
+// time.
+func newLimiter() *nrates.Limiter {
+	var now = time.Unix(1700000000, 0)
+	var store = nrates.NewMemoryStore(nrates.SlidingLog, nrates.Clock(func() time.Time { return now }))
+	return nrates.NewLimiter(store, nrates.PerMinuteLimit(2))
+}
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	var recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRateLimit(t *testing.T) {
+	var handler = ServeHandler(RateLimit(newLimiter())(OKRequest))
+	var request = func(ip string) *http.Request {
+		var req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderXRealIP, ip)
+		return req
+	}
+
+	for _, remaining := range []string{"1", "0"} {
+		var res = serve(handler, request("10.0.0.1"))
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, "2", res.Header().Get(HeaderRateLimitLimit))
+		require.Equal(t, remaining, res.Header().Get(HeaderRateLimitRemaining))
+		require.Equal(t, "60", res.Header().Get(HeaderRateLimitReset))
+		require.Equal(t, "2;w=60", res.Header().Get(HeaderRateLimitPolicy))
+		require.Empty(t, res.Header().Get(HeaderRetryAfter))
+	}
+
+	var res = serve(handler, request("10.0.0.1"))
+	require.Equal(t, http.StatusTooManyRequests, res.Code)
+	require.Equal(t, "60", res.Header().Get(HeaderRetryAfter))
+	require.Equal(t, "0", res.Header().Get(HeaderRateLimitRemaining))
+
+	// other owners have their own quota.
+	res = serve(handler, request("10.0.0.2"))
+	require.Equal(t, http.StatusOK, res.Code)
+}
+
+func TestRateLimitMW(t *testing.T) {
+	var handler = RateLimitMW(newLimiter())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for i := 0; i < 2; i++ {
+		var res = serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusNoContent, res.Code)
+	}
+
+	var res = serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusTooManyRequests, res.Code)
+	require.Equal(t, "60", res.Header().Get(HeaderRetryAfter))
+	require.Contains(t, res.Body.String(), "rate_limit_exceeded")
+}
+
+func TestRateLimitFailures(t *testing.T) {
+	var limiter = nrates.NewLimiter(failingStore{}, nrates.PerMinuteLimit(2))
+
+	var res = serve(ServeHandler(RateLimit(limiter)(OKRequest)), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, res.Code)
+
+	res = serve(RateLimitMW(limiter)(IdentityHandler()), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, res.Code)
+	require.Contains(t, res.Body.String(), "rate_limit_failed")
+
+	res = serve(ServeHandler(RateLimit(limiter, RateFailOpen())(OKRequest)), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Empty(t, res.Header().Get(HeaderRateLimitLimit))
+}
+
+func TestRateOwners(t *testing.T) {
+	var owner = FirstOf(ByContextValue(userKey{}), ByHeader("X-Api-Key"))
+	var handler = ServeHandler(RateLimit(newLimiter(), RateBy(owner))(OKRequest))
+
+	// requests without an owner are not limited.
+	for i := 0; i < 3; i++ {
+		var res = serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Empty(t, res.Header().Get(HeaderRateLimitLimit))
+	}
+
+	var withKey = func(key string) *http.Request {
+		var req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", key)
+		return req
+	}
+	var withUser = func(user string) *http.Request {
+		var req = withKey("shared")
+		return req.WithContext(context.WithValue(req.Context(), userKey{}, user))
+	}
+
+	require.Equal(t, http.StatusOK, serve(handler, withKey("shared")).Code)
+	require.Equal(t, http.StatusOK, serve(handler, withKey("shared")).Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(handler, withKey("shared")).Code)
+
+	// the user takes precedence over the exhausted api key.
+	require.Equal(t, http.StatusOK, serve(handler, withUser("ada")).Code)
+	require.Equal(t, http.StatusOK, serve(handler, withUser("ada")).Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(handler, withUser("ada")).Code)
+	require.Equal(t, http.StatusOK, serve(handler, withUser("grace")).Code)
+
+	var key, err = ByHeader("X-Api-Key")(NewContext(SetRequest(withKey("k1"))))
+	require.NoError(t, err)
+	require.Equal(t, "header:X-Api-Key:k1", key)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var semaphore = nrates.NewSemaphore(1, nrates.MaxWaiting(-1))
+	var started = make(chan struct{})
+	var release = make(chan struct{})
+	var handler = ServeHandler(ConcurrencyLimit(semaphore)(func(c *Ctx) error {
+		if c.Request().URL.Path == "/panic" {
+			panic("handler crashed")
+		}
+		if c.Request().URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		return OKRequest(c)
+	}))
+
+	var waiter sync.WaitGroup
+	waiter.Add(1)
+	go func() {
+		defer waiter.Done()
+		serve(handler, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}()
+	<-started
+
+	// requests beyond the limit are rejected.
+	var res = serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, res.Code)
+	close(release)
+	waiter.Wait()
+	require.Equal(t, 0, semaphore.InFlight())
+
+	// a panicking handler releases it's permit.
+	require.Panics(t, func() {
+		serve(handler, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	})
+	require.Equal(t, 0, semaphore.InFlight())
+	require.Equal(t, http.StatusOK, serve(handler, httptest.NewRequest(http.MethodGet, "/", nil)).Code)
+}
+
+func TestConcurrencyLimitPriority(t *testing.T) {
+	var semaphore = nrates.NewSemaphore(4, nrates.MaxWaiting(-1))
+	var shedder = nrates.NewShedder(semaphore)
+	var byHeader = PriorityBy(func(c *Ctx) nrates.Priority {
+		if c.GetHeader("X-Priority") == "critical" {
+			return nrates.PriorityCritical
+		}
+		return nrates.PriorityLow
+	})
+
+	var release = make(chan struct{})
+	var handler = ConcurrencyLimitMW(shedder, byHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer close(release)
+
+	// low priority requests may use half of the limit.
+	for i := 0; i < 2; i++ {
+		go serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	require.Eventually(t, func() bool {
+		return semaphore.InFlight() == 2
+	}, time.Second, time.Millisecond)
+
+	var res = serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, res.Code)
+	require.Contains(t, res.Body.String(), "overloaded")
+
+	var critical = httptest.NewRequest(http.MethodGet, "/", nil)
+	critical.Header.Set("X-Priority", "critical")
+	go serve(handler, critical)
+	require.Eventually(t, func() bool {
+		return semaphore.InFlight() == 3
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,168 @@
+package njobs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+// maxDiffCells bounds the size of the table used to diff two files, larger
+// files are shown as entirely replaced.
+const maxDiffCells = 4 << 20
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// writeFileDiff writes the unified diff of a file between it's content
+// before and after, existed and exists telling if it was and is present.
+func writeFileDiff(w io.Writer, name string, before []byte, after []byte, existed bool, exists bool) {
+	if existed && exists && bytes.Equal(before, after) {
+		return
+	}
+
+	// paths are shown as recorded, a Tx has no root to make them relative to.
+	var from, to = name, name
+	if !existed {
+		from = "/dev/null"
+	}
+	if !exists {
+		to = "/dev/null"
+	}
+
+	if bytes.IndexByte(before, 0) != -1 || bytes.IndexByte(after, 0) != -1 {
+		_, _ = fmt.Fprintf(w, "Binary files %s and %s differ\n", from, to)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "--- %s\n+++ %s\n", from, to)
+	writeHunks(w, diffLines(splitLines(before), splitLines(after)))
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	var lines = strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the edits turning a into b, from their longest common
+// subsequence of lines.
+func diffLines(a []string, b []string) []diffLine {
+	var lines = make([]diffLine, 0, len(a)+len(b))
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			lines = append(lines, diffLine{op: '-', text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, diffLine{op: '+', text: line})
+		}
+		return lines
+	}
+
+	// common[i][j] is the length of the common subsequence of a[i:] and b[j:].
+	var common = make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var i, j int
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{op: ' ', text: a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{op: '-', text: a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{op: '+', text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{op: '-', text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{op: '+', text: b[j]})
+	}
+	return lines
+}
+
+// writeHunks writes the changed lines with diffContext unchanged lines
+// around them, merging changes closer than twice the context.
+func writeHunks(w io.Writer, lines []diffLine) {
+	// the line numbers in a and b of every edit.
+	var aLines, bLines = make([]int, len(lines)+1), make([]int, len(lines)+1)
+	aLines[0], bLines[0] = 1, 1
+	for index, line := range lines {
+		aLines[index+1], bLines[index+1] = aLines[index], bLines[index]
+		if line.op != '+' {
+			aLines[index+1]++
+		}
+		if line.op != '-' {
+			bLines[index+1]++
+		}
+	}
+
+	for index := 0; index < len(lines); {
+		if lines[index].op == ' ' {
+			index++
+			continue
+		}
+
+		var start = index - diffContext
+		if start < 0 {
+			start = 0
+		}
+		var last = index
+		for next := index; next < len(lines) && next-last <= 2*diffContext; next++ {
+			if lines[next].op != ' ' {
+				last = next
+			}
+		}
+		var stop = last + diffContext + 1
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		var aStart, aCount = aLines[start], aLines[stop] - aLines[start]
+		var bStart, bCount = bLines[start], bLines[stop] - bLines[start]
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+
+		_, _ = fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		for _, line := range lines[start:stop] {
+			_, _ = fmt.Fprintf(w, "%c%s", line.op, line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				_, _ = io.WriteString(w, "\n\\ No newline at end of file\n")
+			}
+		}
+		index = stop
+	}
+}
@@ -0,0 +1,596 @@
+package njobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nretries"
+	"github.com/influx6/npkg/nstorage"
+)
+
+// ContextJob defines a Job which is given a context, canceled once the
+// timeout of it's step elapsed or the pipeline is canceled.
+type ContextJob interface {
+	DoContext(ctx context.Context, data interface{}) (interface{}, error)
+}
+
+// ContextJobFunction implements the Job and ContextJob interfaces for
+// a function.
+type ContextJobFunction func(ctx context.Context, data interface{}) (interface{}, error)
+
+// DoContext calls the function.
+func (fn ContextJobFunction) DoContext(ctx context.Context, data interface{}) (interface{}, error) {
+	return fn(ctx, data)
+}
+
+// Do calls the function with a background context.
+func (fn ContextJobFunction) Do(data interface{}) (interface{}, error) {
+	return fn(context.Background(), data)
+}
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// Typed returns a ContextJobFunction calling giving function, which must
+// be a func(In) (Out, error) or func(context.Context, In) (Out, error).
+//
+// Data not of the In type is converted by encoding it to json and
+// decoding it into an In, as with data restored from checkpoints. An
+// error is returned when called if fn is not such a function.
+func Typed(fn interface{}) ContextJobFunction {
+	var value = reflect.ValueOf(fn)
+	var kind = value.Type()
+	var withContext = kind.Kind() == reflect.Func && kind.NumIn() == 2 && kind.In(0) == contextType
+	if kind.Kind() != reflect.Func || kind.NumOut() != 2 || kind.Out(1) != errorType ||
+		(kind.NumIn() != 1 && !withContext) {
+		return func(_ context.Context, _ interface{}) (interface{}, error) {
+			return nil, nerror.New("%T is not a func(In) (Out, error) or func(context.Context, In) (Out, error)", fn)
+		}
+	}
+
+	var in = kind.In(kind.NumIn() - 1)
+	return func(ctx context.Context, data interface{}) (interface{}, error) {
+		var arg, err = convert(data, in)
+		if err != nil {
+			return nil, err
+		}
+
+		var args = []reflect.Value{arg}
+		if withContext {
+			args = []reflect.Value{reflect.ValueOf(ctx), arg}
+		}
+
+		var results = value.Call(args)
+		if errValue := results[1].Interface(); errValue != nil {
+			return results[0].Interface(), errValue.(error)
+		}
+		return results[0].Interface(), nil
+	}
+}
+
+// convert returns giving data as a value of the target type.
+func convert(data interface{}, target reflect.Type) (reflect.Value, error) {
+	if data == nil {
+		return reflect.Zero(target), nil
+	}
+
+	var value = reflect.ValueOf(data)
+	if value.Type().AssignableTo(target) {
+		return value, nil
+	}
+
+	var encoded, err = json.Marshal(data)
+	if err != nil {
+		return reflect.Value{}, nerror.Wrap(err, "failed to convert %T to %s", data, target)
+	}
+	var decoded = reflect.New(target)
+	if err := json.Unmarshal(encoded, decoded.Interface()); err != nil {
+		return reflect.Value{}, nerror.Wrap(err, "failed to convert %T to %s", data, target)
+	}
+	return decoded.Elem(), nil
+}
+
+// ParallelForEach returns a ContextJobFunction calling giving job with
+// every item of a slice or array, running up to concurrency jobs at once.
+// The results are returned in the order of the items.
+//
+// Once a job fails the context of the others is canceled and no more jobs
+// are started, the error of the first failed job is returned.
+func ParallelForEach(job Job, concurrency int) ContextJobFunction {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return func(ctx context.Context, targetList interface{}) (interface{}, error) {
+		var refValue = reflect.ValueOf(targetList)
+		if refValue.Kind() == reflect.Ptr {
+			refValue = refValue.Elem()
+		}
+		var refKind = refValue.Kind()
+		if refKind != reflect.Slice && refKind != reflect.Array {
+			return nil, nerror.New("argument is neither a slice or array")
+		}
+
+		var itemsCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		var results = make([]interface{}, refValue.Len())
+		var slots = make(chan struct{}, concurrency)
+		var waiter sync.WaitGroup
+		var once sync.Once
+		var firstErr error
+
+		for i := 0; i < refValue.Len(); i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-itemsCtx.Done():
+			}
+			if itemsCtx.Err() != nil {
+				break
+			}
+
+			waiter.Add(1)
+			go func(index int, item interface{}) {
+				defer waiter.Done()
+				defer func() { <-slots }()
+
+				var result, err = doJob(itemsCtx, job, item)
+				if err != nil {
+					once.Do(func() {
+						firstErr = nerror.Wrap(err, "Failed processing: %#v", item)
+						cancel()
+					})
+					return
+				}
+				results[index] = result
+			}(i, refValue.Index(i).Interface())
+		}
+		waiter.Wait()
+
+		if firstErr != nil {
+			return results, firstErr
+		}
+		if err := ctx.Err(); err != nil {
+			return results, nerror.WrapOnly(err)
+		}
+		return results, nil
+	}
+}
+
+// doJob calls giving job, with the context if it is a ContextJob. Other
+// jobs are abandoned once the context is done, as they can not be stopped.
+func doJob(ctx context.Context, job Job, data interface{}) (interface{}, error) {
+	if cj, ok := job.(ContextJob); ok {
+		return cj.DoContext(ctx, data)
+	}
+	if ctx.Done() == nil {
+		return job.Do(data)
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	var done = make(chan result, 1)
+	go func() {
+		var value, err = job.Do(data)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		return nil, nerror.WrapOnly(ctx.Err())
+	}
+}
+
+//************************************************************************
+// Pipeline
+//************************************************************************
+
+// StepStatus defines the outcome of a step of a Pipeline.
+type StepStatus int
+
+// Step statuses.
+const (
+	StepPending StepStatus = iota
+	StepSucceeded
+	StepFailed
+	StepResumed
+	StepPlanned
+)
+
+// String returns the name of the status.
+func (s StepStatus) String() string {
+	switch s {
+	case StepPending:
+		return "pending"
+	case StepSucceeded:
+		return "succeeded"
+	case StepFailed:
+		return "failed"
+	case StepResumed:
+		return "resumed"
+	case StepPlanned:
+		return "planned"
+	default:
+		return "unknown"
+	}
+}
+
+// StepReport holds the outcome of a step of a Pipeline.
+type StepReport struct {
+	Name     string        `json:"name"`
+	Status   StepStatus    `json:"status"`
+	Attempts int           `json:"attempts"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+}
+
+// Report lists the outcome of all steps of a Pipeline run, in order.
+type Report struct {
+	Pipeline string        `json:"pipeline"`
+	RunID    string        `json:"run_id,omitempty"`
+	DryRun   bool          `json:"dry_run"`
+	Steps    []StepReport  `json:"steps"`
+	Duration time.Duration `json:"duration"`
+	Output   interface{}   `json:"-"`
+}
+
+// Err returns the error of the failed step, or nil if none failed.
+func (r *Report) Err() error {
+	for _, step := range r.Steps {
+		if step.Status == StepFailed {
+			return step.Err
+		}
+	}
+	return nil
+}
+
+// String returns a table of the status, attempts, duration and error of all
+// steps.
+func (r *Report) String() string {
+	var width = len("step")
+	for _, step := range r.Steps {
+		if len(step.Name) > width {
+			width = len(step.Name)
+		}
+	}
+
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, "pipeline %s", r.Pipeline)
+	if r.DryRun {
+		builder.WriteString(" (dry run)")
+	}
+	builder.WriteString("\n")
+
+	_, _ = fmt.Fprintf(&builder, "%-*s  %-9s  %8s  %10s  %s\n", width, "step", "status", "attempts", "duration", "error")
+	for _, step := range r.Steps {
+		var message string
+		if step.Err != nil {
+			message = step.Err.Error()
+		}
+		_, _ = fmt.Fprintf(&builder, "%-*s  %-9s  %8d  %10s  %s\n", width, step.Name, step.Status, step.Attempts, step.Duration.Round(time.Microsecond), message)
+	}
+	_, _ = fmt.Fprintf(&builder, "total %s", r.Duration.Round(time.Microsecond))
+	return builder.String()
+}
+
+// StepOption defines a function type which sets the options of a step.
+type StepOption func(*step)
+
+// Retries sets the options retrying a failed step with nretries.Retry,
+// e.g nretries.MaxAttempts and nretries.BackOff. Steps are not retried by
+// default.
+func Retries(ops ...nretries.RetryOption) StepOption {
+	return func(s *step) {
+		s.retries = append(s.retries, ops...)
+	}
+}
+
+// StepTimeout sets the timeout of a step, including it's retries.
+func StepTimeout(d time.Duration) StepOption {
+	return func(s *step) {
+		s.timeout = d
+	}
+}
+
+// PipelineOption defines a function type which sets the options of a
+// Pipeline.
+type PipelineOption func(*Pipeline)
+
+// DryRun sets the pipeline to report the steps it would run without
+// running them, passing it's input through.
+func DryRun() PipelineOption {
+	return func(p *Pipeline) {
+		p.dryRun = true
+	}
+}
+
+// Checkpoints sets the store the output of every step is saved into once
+// it succeeds, under keys prefixed with the pipeline name and giving run
+// id. A run with the same id resumes after the last step saved, using it's
+// output as input, and checkpoints are removed once a run succeeds.
+//
+// Outputs are saved as json, steps after a resumed step are hence given
+// the json decoding of the output, see Typed.
+func Checkpoints(store nstorage.ByteStore, runID string) PipelineOption {
+	return func(p *Pipeline) {
+		p.store = store
+		p.runID = runID
+	}
+}
+
+type step struct {
+	name    string
+	job     Job
+	retries []nretries.RetryOption
+	timeout time.Duration
+}
+
+// checkpoint is the saved output of a step.
+type checkpoint struct {
+	Step   string          `json:"step"`
+	Output json.RawMessage `json:"output"`
+	Saved  time.Time       `json:"saved"`
+}
+
+// Pipeline runs named steps one after another, each given the output of
+// the one before, like Jobs but with per step retries and timeouts, a
+// report of every run and checkpoints to resume interrupted runs.
+type Pipeline struct {
+	name   string
+	steps  []*step
+	dryRun bool
+	store  nstorage.ByteStore
+	runID  string
+}
+
+// NewPipeline returns a new Pipeline with giving name.
+func NewPipeline(name string, ops ...PipelineOption) *Pipeline {
+	var p = &Pipeline{name: name}
+	for _, op := range ops {
+		op(p)
+	}
+	return p
+}
+
+// Step adds the named step running giving job, returning the pipeline for
+// chaining. Step names must be unique within a pipeline.
+func (p *Pipeline) Step(name string, job Job, ops ...StepOption) *Pipeline {
+	var s = &step{
+		name:    name,
+		job:     job,
+		retries: []nretries.RetryOption{nretries.MaxAttempts(1)},
+	}
+	for _, op := range ops {
+		op(s)
+	}
+	p.steps = append(p.steps, s)
+	return p
+}
+
+// ForEach adds the named step running giving job for every item of it's
+// input, running up to concurrency jobs at once, see ParallelForEach.
+func (p *Pipeline) ForEach(name string, job Job, concurrency int, ops ...StepOption) *Pipeline {
+	return p.Step(name, ParallelForEach(job, concurrency), ops...)
+}
+
+// Do runs the pipeline with a background context, implementing the Job
+// interface so pipelines can be steps of others.
+func (p *Pipeline) Do(data interface{}) (interface{}, error) {
+	return p.DoContext(context.Background(), data)
+}
+
+// DoContext runs the pipeline, implementing the ContextJob interface.
+func (p *Pipeline) DoContext(ctx context.Context, data interface{}) (interface{}, error) {
+	var report, err = p.Run(ctx, data)
+	if report == nil {
+		return nil, err
+	}
+	return report.Output, err
+}
+
+// Run runs all steps with giving input, returning the report of the run
+// and the error of the failed step if any. The output of the last step is
+// the Output of the report.
+func (p *Pipeline) Run(ctx context.Context, input interface{}) (*Report, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	var report = &Report{
+		Pipeline: p.name,
+		RunID:    p.runID,
+		DryRun:   p.dryRun,
+		Steps:    make([]StepReport, len(p.steps)),
+	}
+	for index, s := range p.steps {
+		report.Steps[index].Name = s.name
+	}
+
+	var start = time.Now()
+	defer func() {
+		report.Duration = time.Since(start)
+	}()
+
+	var data = input
+	var resuming = p.store != nil && !p.dryRun
+	for index, s := range p.steps {
+		var stepReport = &report.Steps[index]
+
+		if p.dryRun {
+			stepReport.Status = StepPlanned
+			continue
+		}
+
+		if resuming {
+			var output, found, err = p.restore(s)
+			if err != nil {
+				return report, err
+			}
+			if found {
+				stepReport.Status = StepResumed
+				data = output
+				continue
+			}
+
+			// steps after the first one to run are run again, their
+			// checkpoints are outdated.
+			resuming = false
+			if err := p.clear(index); err != nil {
+				return report, err
+			}
+		}
+
+		var output, err = p.runStep(ctx, s, data, stepReport)
+		if err != nil {
+			stepReport.Status = StepFailed
+			stepReport.Err = err
+			report.Output = output
+			return report, nerror.Wrap(err, "step %q of pipeline %q failed", s.name, p.name)
+		}
+		stepReport.Status = StepSucceeded
+		data = output
+
+		if p.store != nil {
+			if err := p.save(s, output); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	report.Output = data
+	if p.store != nil && !p.dryRun {
+		if err := p.clear(0); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (p *Pipeline) validate() error {
+	var names = map[string]bool{}
+	for _, s := range p.steps {
+		if s.name == "" {
+			return nerror.New("pipeline %q has a step without a name", p.name)
+		}
+		if s.job == nil {
+			return nerror.New("step %q of pipeline %q has no job", s.name, p.name)
+		}
+		if names[s.name] {
+			return nerror.New("pipeline %q has more than one step named %q", p.name, s.name)
+		}
+		names[s.name] = true
+	}
+	return nil
+}
+
+// runStep runs giving step with it's retries and timeout, returning the
+// error of it's last attempt.
+func (p *Pipeline) runStep(ctx context.Context, s *step, data interface{}, report *StepReport) (interface{}, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	report.Started = time.Now()
+	defer func() {
+		report.Duration = time.Since(report.Started)
+	}()
+
+	var output interface{}
+	var err = nretries.Retry(ctx, func(ctx context.Context) error {
+		report.Attempts++
+
+		var result, err = doJob(ctx, s.job, data)
+		output = result
+		return err
+	}, s.retries...)
+	if err != nil {
+		if errs := nretries.AttemptErrors(err); len(errs) > 0 {
+			return output, errs[len(errs)-1]
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+func (p *Pipeline) key(name string) string {
+	return p.name + ":" + p.runID + ":checkpoints:" + name
+}
+
+func (p *Pipeline) save(s *step, output interface{}) error {
+	var encoded, err = json.Marshal(output)
+	if err != nil {
+		return nerror.Wrap(err, "failed to encode checkpoint of step %q", s.name)
+	}
+
+	var data []byte
+	if data, err = json.Marshal(checkpoint{Step: s.name, Output: encoded, Saved: time.Now()}); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if err = p.store.Save(p.key(s.name), data); err != nil {
+		return nerror.Wrap(err, "failed to save checkpoint of step %q", s.name)
+	}
+	return nil
+}
+
+func (p *Pipeline) restore(s *step) (interface{}, bool, error) {
+	var exists, err = p.store.Exists(p.key(s.name))
+	if err != nil {
+		return nil, false, nerror.Wrap(err, "failed to check checkpoint of step %q", s.name)
+	}
+	if !exists {
+		return nil, false, nil
+	}
+
+	var data []byte
+	if data, err = p.store.Get(p.key(s.name)); err != nil {
+		return nil, false, nerror.Wrap(err, "failed to read checkpoint of step %q", s.name)
+	}
+
+	var saved checkpoint
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return nil, false, nerror.Wrap(err, "failed to decode checkpoint of step %q", s.name)
+	}
+
+	var output interface{}
+	if err = json.Unmarshal(saved.Output, &output); err != nil {
+		return nil, false, nerror.Wrap(err, "failed to decode checkpoint of step %q", s.name)
+	}
+	return output, true, nil
+}
+
+// clear removes the checkpoints of all steps from giving index.
+func (p *Pipeline) clear(from int) error {
+	var keys = make([]string, 0, len(p.steps)-from)
+	for _, s := range p.steps[from:] {
+		var exists, err = p.store.Exists(p.key(s.name))
+		if err != nil {
+			return nerror.Wrap(err, "failed to check checkpoint of step %q", s.name)
+		}
+		if exists {
+			keys = append(keys, p.key(s.name))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := p.store.RemoveKeys(keys...); err != nil {
+		return nerror.Wrap(err, "failed to remove checkpoints")
+	}
+	return nil
+}
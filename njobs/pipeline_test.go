@@ -0,0 +1,232 @@
+package njobs_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njobs"
+	"github.com/influx6/npkg/nretries"
+	"github.com/influx6/npkg/nstorage/nmap"
+)
+
+func TestTyped(t *testing.T) {
+	var double = njobs.Typed(func(n int) (int, error) {
+		return n * 2, nil
+	})
+
+	var result, err = double.Do(4)
+	require.NoError(t, err)
+	require.Equal(t, 8, result)
+
+	// json numbers are converted.
+	result, err = double.Do(float64(3))
+	require.NoError(t, err)
+	require.Equal(t, 6, result)
+
+	_, err = double.Do("three")
+	require.Error(t, err)
+
+	_, err = njobs.Typed(func(n int) int { return n }).Do(1)
+	require.Error(t, err)
+}
+
+func TestParallelForEach(t *testing.T) {
+	var running, peak int64
+	var square = njobs.Typed(func(n int) (int, error) {
+		var now = atomic.AddInt64(&running, 1)
+		for {
+			var last = atomic.LoadInt64(&peak)
+			if now <= last || atomic.CompareAndSwapInt64(&peak, last, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return n * n, nil
+	})
+
+	var result, err = njobs.ParallelForEach(square, 2).Do([]int{1, 2, 3, 4, 5})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{1, 4, 9, 16, 25}, result)
+	require.Equal(t, int64(2), atomic.LoadInt64(&peak))
+
+	var badErr = errors.New("bad item")
+	_, err = njobs.ParallelForEach(njobs.JobFunction(func(d interface{}) (interface{}, error) {
+		if d.(int) == 3 {
+			return nil, badErr
+		}
+		return d, nil
+	}), 2).Do([]int{1, 2, 3, 4, 5})
+	require.Error(t, err)
+	require.Equal(t, badErr, nerror.UnwrapDeep(err))
+}
+
+func TestPipeline_Run(t *testing.T) {
+	var pipeline = njobs.NewPipeline("numbers").
+		Step("parse", njobs.Typed(func(s string) ([]string, error) {
+			return strings.Split(s, ","), nil
+		})).
+		ForEach("length", njobs.Typed(func(s string) (int, error) {
+			return len(s), nil
+		}), 2).
+		Step("sum", njobs.Typed(func(_ context.Context, lengths []int) (int, error) {
+			var total int
+			for _, length := range lengths {
+				total += length
+			}
+			return total, nil
+		}))
+
+	var report, err = pipeline.Run(context.Background(), "a,bb,ccc")
+	require.NoError(t, err)
+	require.Equal(t, 6, report.Output)
+	require.Len(t, report.Steps, 3)
+	for _, step := range report.Steps {
+		require.Equal(t, njobs.StepSucceeded, step.Status)
+		require.Equal(t, 1, step.Attempts)
+	}
+	require.Contains(t, report.String(), "length")
+
+	var output, doErr = pipeline.Do("a")
+	require.NoError(t, doErr)
+	require.Equal(t, 1, output)
+}
+
+func TestPipeline_Retries(t *testing.T) {
+	var calls int
+	var flaky = njobs.JobFunction(func(d interface{}) (interface{}, error) {
+		if calls++; calls < 3 {
+			return nil, errors.New("flaky")
+		}
+		return d, nil
+	})
+
+	var report, err = njobs.NewPipeline("retries").
+		Step("flaky", flaky, njobs.Retries(
+			nretries.MaxAttempts(3),
+			nretries.BackOff(func(_ int) time.Duration { return time.Millisecond }),
+		)).
+		Run(context.Background(), "done")
+	require.NoError(t, err)
+	require.Equal(t, "done", report.Output)
+	require.Equal(t, 3, report.Steps[0].Attempts)
+
+	calls = 0
+	report, err = njobs.NewPipeline("no-retries").Step("flaky", flaky).Run(context.Background(), "done")
+	require.Error(t, err)
+	require.Equal(t, njobs.StepFailed, report.Steps[0].Status)
+	require.Equal(t, 1, report.Steps[0].Attempts)
+	require.EqualError(t, report.Err(), "flaky")
+}
+
+func TestPipeline_StepTimeout(t *testing.T) {
+	var report, err = njobs.NewPipeline("timeout").
+		Step("slow", njobs.ContextJobFunction(func(ctx context.Context, d interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}), njobs.StepTimeout(10*time.Millisecond)).
+		Step("never", njobs.JobFunction(func(d interface{}) (interface{}, error) {
+			t.Error("step after a failed step ran")
+			return d, nil
+		})).
+		Run(context.Background(), nil)
+	require.Error(t, err)
+	require.Equal(t, njobs.StepFailed, report.Steps[0].Status)
+	require.Equal(t, njobs.StepPending, report.Steps[1].Status)
+}
+
+func TestPipeline_DryRun(t *testing.T) {
+	var report, err = njobs.NewPipeline("dry", njobs.DryRun()).
+		Step("write", njobs.JobFunction(func(d interface{}) (interface{}, error) {
+			t.Error("step ran in a dry run")
+			return d, nil
+		})).
+		Run(context.Background(), "input")
+	require.NoError(t, err)
+	require.Equal(t, "input", report.Output)
+	require.Equal(t, njobs.StepPlanned, report.Steps[0].Status)
+	require.Contains(t, report.String(), "dry run")
+}
+
+func TestPipeline_Checkpoints(t *testing.T) {
+	var store = nmap.NewExprByteStore()
+
+	var fetched, failing int
+	var build = func() *njobs.Pipeline {
+		return njobs.NewPipeline("deploy", njobs.Checkpoints(store, "run-1")).
+			Step("fetch", njobs.Typed(func(n int) (int, error) {
+				fetched++
+				return n + 1, nil
+			})).
+			Step("deploy", njobs.Typed(func(n int) (int, error) {
+				if failing > 0 {
+					failing--
+					return 0, errors.New("deploy failed")
+				}
+				return n * 10, nil
+			}))
+	}
+
+	failing = 1
+	var report, err = build().Run(context.Background(), 1)
+	require.Error(t, err)
+	require.Equal(t, njobs.StepSucceeded, report.Steps[0].Status)
+	require.Equal(t, 1, fetched)
+
+	report, err = build().Run(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, njobs.StepResumed, report.Steps[0].Status)
+	require.Equal(t, njobs.StepSucceeded, report.Steps[1].Status)
+	require.Equal(t, 20, report.Output)
+	require.Equal(t, 1, fetched)
+
+	// checkpoints are removed once a run succeeds.
+	count, err := store.Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), count)
+}
+
+// unreachableKeys fails Exists for keys with the suffix.
+type unreachableKeys struct {
+	*nmap.ExprByteStore
+	suffix string
+}
+
+func (s unreachableKeys) Exists(key string) (bool, error) {
+	if strings.HasSuffix(key, s.suffix) {
+		return false, errors.New("store is unreachable")
+	}
+	return s.ExprByteStore.Exists(key)
+}
+
+func TestPipeline_CheckpointErrors(t *testing.T) {
+	var ran int
+	var build = func(suffix string) *njobs.Pipeline {
+		var store = unreachableKeys{ExprByteStore: nmap.NewExprByteStore(), suffix: suffix}
+		return njobs.NewPipeline("deploy", njobs.Checkpoints(store, "run-1")).
+			Step("fetch", njobs.Typed(func(n int) (int, error) {
+				ran++
+				return n + 1, nil
+			})).
+			Step("deploy", njobs.Typed(func(n int) (int, error) {
+				ran++
+				return n * 10, nil
+			}))
+	}
+
+	// a failing store must not be taken as missing checkpoints.
+	var _, err = build(":fetch").Run(context.Background(), 1)
+	require.Error(t, err)
+	require.Equal(t, 0, ran)
+
+	_, err = build(":deploy").Run(context.Background(), 1)
+	require.Error(t, err)
+	require.Equal(t, 0, ran)
+}
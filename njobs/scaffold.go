@@ -0,0 +1,777 @@
+package njobs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nexec"
+)
+
+// ManifestFile is the name of the manifest within a scaffold directory.
+const ManifestFile = "scaffold.json"
+
+// TemplateExt is the extension of the files of a scaffold rendered as
+// templates, it's removed from the generated path. Other files are copied
+// as they are.
+const TemplateExt = ".tmpl"
+
+// conflict markers written around the differences of merged files.
+const (
+	markerExisting  = "<<<<<<< existing\n"
+	markerSeparator = "=======\n"
+	markerGenerated = ">>>>>>> generated\n"
+)
+
+// ConflictStrategy defines how a Scaffold handles existing files which
+// differ from the generated ones.
+type ConflictStrategy int
+
+// Conflict strategies.
+const (
+	// SkipConflicts keeps the existing file.
+	SkipConflicts ConflictStrategy = iota
+
+	// OverwriteConflicts replaces the existing file with the generated one.
+	OverwriteConflicts
+
+	// MarkConflicts merges the generated file into the existing one,
+	// writing conflict markers around the lines which differ. Files which
+	// still contain conflict markers are kept.
+	MarkConflicts
+)
+
+// String returns the name of the strategy, as used in a manifest.
+func (c ConflictStrategy) String() string {
+	switch c {
+	case SkipConflicts:
+		return "skip"
+	case OverwriteConflicts:
+		return "overwrite"
+	case MarkConflicts:
+		return "merge"
+	default:
+		return "unknown"
+	}
+}
+
+// UnmarshalJSON decodes the strategy from it's name.
+func (c *ConflictStrategy) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	switch name {
+	case "skip":
+		*c = SkipConflicts
+	case "overwrite":
+		*c = OverwriteConflicts
+	case "merge":
+		*c = MarkConflicts
+	default:
+		return nerror.New("unknown conflict strategy %q", name)
+	}
+	return nil
+}
+
+// Manifest declares the variables, prompts, conditional files and
+// commands of a scaffold, it's read from the ManifestFile of the scaffold
+// directory, e.g:
+//
+//	{
+//		"name": "service",
+//		"variables": {"GoVersion": "1.15"},
+//		"prompts": [
+//			{"name": "Name", "message": "Service name", "default": "api"},
+//			{"name": "Module", "default": "github.com/acme/{{.Name}}"},
+//			{"name": "Docker", "type": "bool", "default": "true"}
+//		],
+//		"files": [
+//			{"path": "deploy", "when": ".Docker"},
+//			{"path": "go.sum", "conflict": "overwrite"}
+//		],
+//		"commands": [
+//			{"run": "go mod tidy"}
+//		]
+//	}
+type Manifest struct {
+	Name      string                 `json:"name"`
+	Variables map[string]interface{} `json:"variables"`
+	Prompts   []Prompt               `json:"prompts"`
+	Files     []FileRule             `json:"files"`
+	Commands  []PostCommand          `json:"commands"`
+}
+
+// Prompt declares a variable asked for when generating a scaffold.
+//
+// Default is a template rendered with the variables known before the
+// prompt. Prompts of type "bool" set a bool variable, others a string.
+type Prompt struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Default string `json:"default"`
+	Type    string `json:"type"`
+}
+
+// FileRule declares conditions of the files of a scaffold matching Path,
+// a path.Match pattern matched against the path of the files and their
+// parent directories within the scaffold directory.
+//
+// When is a template pipeline, e.g `.Docker` or `eq .Database "postgres"`,
+// excluding the files when false. Conflict sets the strategy of the files
+// over the one of the generation.
+type FileRule struct {
+	Path     string            `json:"path"`
+	When     string            `json:"when"`
+	Conflict *ConflictStrategy `json:"conflict"`
+}
+
+// PostCommand declares a shell command run with nexec in the target
+// directory, or Dir within it, after the files are generated. Run and Dir
+// are templates, When a template pipeline like FileRule.When.
+type PostCommand struct {
+	Run  string `json:"run"`
+	Dir  string `json:"dir"`
+	When string `json:"when"`
+}
+
+// FileStatus defines what generating a scaffold did to a path.
+type FileStatus int
+
+// File statuses.
+const (
+	FileCreated FileStatus = iota
+	FileUpdated
+	FileUnchanged
+	FileSkipped
+	FileMerged
+)
+
+// String returns the name of the status.
+func (s FileStatus) String() string {
+	switch s {
+	case FileCreated:
+		return "created"
+	case FileUpdated:
+		return "updated"
+	case FileUnchanged:
+		return "unchanged"
+	case FileSkipped:
+		return "skipped"
+	case FileMerged:
+		return "merged"
+	default:
+		return "unknown"
+	}
+}
+
+// ScaffoldFile is a path generated by a scaffold, relative to the target
+// directory.
+type ScaffoldFile struct {
+	Path   string
+	Dir    bool
+	Status FileStatus
+}
+
+// ScaffoldReport reports the generation of a scaffold.
+type ScaffoldReport struct {
+	Target   string
+	Values   map[string]interface{}
+	Files    []ScaffoldFile
+	Commands []string
+}
+
+// Changed returns true if the generation created or changed any path.
+func (r *ScaffoldReport) Changed() bool {
+	for _, file := range r.Files {
+		switch file.Status {
+		case FileCreated, FileUpdated, FileMerged:
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a line for every path and command of the report.
+func (r *ScaffoldReport) String() string {
+	var builder strings.Builder
+	for _, file := range r.Files {
+		var name = file.Path
+		if file.Dir {
+			name += "/"
+		}
+		_, _ = fmt.Fprintf(&builder, "%-9s %s\n", file.Status, name)
+	}
+	for _, command := range r.Commands {
+		_, _ = fmt.Fprintf(&builder, "%-9s %s\n", "ran", command)
+	}
+	return builder.String()
+}
+
+// PromptFunction asks for the value of a prompt, returning def if no value
+// is given.
+type PromptFunction func(prompt Prompt, def string) (string, error)
+
+// ReadPrompts returns a PromptFunction writing prompts to out and reading
+// a line of in as their answer.
+func ReadPrompts(in io.Reader, out io.Writer) PromptFunction {
+	var reader = bufio.NewReader(in)
+	return func(prompt Prompt, def string) (string, error) {
+		var message = prompt.Message
+		if message == "" {
+			message = prompt.Name
+		}
+		if def != "" {
+			message = fmt.Sprintf("%s [%s]", message, def)
+		}
+		if _, err := fmt.Fprintf(out, "%s: ", message); err != nil {
+			return "", nerror.WrapOnly(err)
+		}
+
+		var line, err = reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", nerror.WrapOnly(err)
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			return def, nil
+		}
+		return line, nil
+	}
+}
+
+// ScaffoldOption defines a function type which sets the options of a
+// scaffold generation.
+type ScaffoldOption func(*scaffoldOptions)
+
+type scaffoldOptions struct {
+	values   map[string]interface{}
+	prompt   PromptFunction
+	conflict ConflictStrategy
+	funcs    template.FuncMap
+	output   io.Writer
+	tx       []TxOption
+}
+
+// ScaffoldValues sets the value of variables, prompts of giving variables
+// are not asked.
+func ScaffoldValues(values map[string]interface{}) ScaffoldOption {
+	return func(o *scaffoldOptions) {
+		for name, value := range values {
+			o.values[name] = value
+		}
+	}
+}
+
+// Prompter sets the function asking prompts, the defaults of prompts are
+// used without one.
+func Prompter(fn PromptFunction) ScaffoldOption {
+	return func(o *scaffoldOptions) {
+		o.prompt = fn
+	}
+}
+
+// OnConflict sets the strategy for existing files differing from the
+// generated ones, defaults to SkipConflicts.
+func OnConflict(strategy ConflictStrategy) ScaffoldOption {
+	return func(o *scaffoldOptions) {
+		o.conflict = strategy
+	}
+}
+
+// Funcs adds functions to the templates of the scaffold.
+func Funcs(funcs template.FuncMap) ScaffoldOption {
+	return func(o *scaffoldOptions) {
+		for name, fn := range funcs {
+			o.funcs[name] = fn
+		}
+	}
+}
+
+// CommandOutput sets the writer the output of post commands is written to.
+func CommandOutput(w io.Writer) ScaffoldOption {
+	return func(o *scaffoldOptions) {
+		o.output = w
+	}
+}
+
+// ScaffoldTx sets the options of the transaction writing the files, e.g
+// Preview and Confirm.
+func ScaffoldTx(ops ...TxOption) ScaffoldOption {
+	return func(o *scaffoldOptions) {
+		o.tx = append(o.tx, ops...)
+	}
+}
+
+// Scaffold generates a tree from a directory of text/template files and
+// it's Manifest.
+//
+// Paths of the directory are templates too, a file or directory whose
+// path renders an empty name is excluded. Generating is idempotent, files
+// identical to the generated ones are left alone and commands only run
+// when the generation changed the target, e.g:
+//
+//	var scaffold, err = njobs.LoadScaffold("templates/service")
+//	if err != nil {
+//		return err
+//	}
+//	report, err := scaffold.Generate(ctx, "services/billing",
+//		njobs.Prompter(njobs.ReadPrompts(os.Stdin, os.Stdout)),
+//		njobs.OnConflict(njobs.MarkConflicts),
+//		njobs.ScaffoldTx(njobs.Preview(os.Stdout)))
+type Scaffold struct {
+	dir      string
+	manifest Manifest
+}
+
+// LoadScaffold returns the Scaffold of giving directory, reading it's
+// ManifestFile.
+func LoadScaffold(dir string) (*Scaffold, error) {
+	var content, err = ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, nerror.Wrap(err, "failed to read scaffold manifest")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, nerror.Wrap(err, "failed to decode scaffold manifest")
+	}
+	return NewScaffold(dir, manifest)
+}
+
+// NewScaffold returns a Scaffold of giving directory and manifest.
+func NewScaffold(dir string, manifest Manifest) (*Scaffold, error) {
+	var names = map[string]bool{}
+	for _, prompt := range manifest.Prompts {
+		if prompt.Name == "" {
+			return nil, nerror.New("scaffold prompt has no name")
+		}
+		if names[prompt.Name] {
+			return nil, nerror.New("scaffold prompt %q is declared twice", prompt.Name)
+		}
+		if prompt.Type != "" && prompt.Type != "string" && prompt.Type != "bool" {
+			return nil, nerror.New("scaffold prompt %q has unknown type %q", prompt.Name, prompt.Type)
+		}
+		names[prompt.Name] = true
+	}
+	for _, rule := range manifest.Files {
+		if _, err := path.Match(rule.Path, ""); err != nil {
+			return nil, nerror.Wrap(err, "invalid scaffold file pattern %q", rule.Path)
+		}
+	}
+	return &Scaffold{dir: dir, manifest: manifest}, nil
+}
+
+// Manifest returns the manifest of the scaffold.
+func (s *Scaffold) Manifest() Manifest {
+	return s.manifest
+}
+
+// Job returns a JobFunction generating the scaffold into the directory
+// passed to it, returning the *ScaffoldReport.
+func (s *Scaffold) Job(ctx context.Context, ops ...ScaffoldOption) JobFunction {
+	return func(dir interface{}) (interface{}, error) {
+		var target, ok = dir.(string)
+		if !ok {
+			return nil, nerror.New("Expected target path string as input")
+		}
+		return s.Generate(ctx, target, ops...)
+	}
+}
+
+// scaffoldEntry is a path of the scaffold rendered for a target.
+type scaffoldEntry struct {
+	ScaffoldFile
+	mod      os.FileMode
+	content  []byte
+	conflict ConflictStrategy
+}
+
+// Generate renders the scaffold into target within a Tx, rolled back if
+// writing fails, then runs the post commands if the target changed.
+//
+// The report lists the planned changes if the transaction is aborted.
+func (s *Scaffold) Generate(ctx context.Context, target string, ops ...ScaffoldOption) (*ScaffoldReport, error) {
+	var options = scaffoldOptions{
+		values: map[string]interface{}{},
+		funcs: template.FuncMap{
+			"lower":   strings.ToLower,
+			"upper":   strings.ToUpper,
+			"title":   strings.Title,
+			"replace": strings.ReplaceAll,
+		},
+	}
+	for _, op := range ops {
+		op(&options)
+	}
+
+	var report = &ScaffoldReport{Target: target}
+	var values, err = s.values(&options)
+	if err != nil {
+		return report, err
+	}
+	report.Values = values
+
+	var renderer = &scaffoldRenderer{funcs: options.funcs, values: values}
+	var entries []*scaffoldEntry
+	if entries, err = s.render(renderer, options.conflict); err != nil {
+		return report, err
+	}
+
+	for _, entry := range entries {
+		if err := plan(entry, filepath.Join(target, filepath.FromSlash(entry.Path))); err != nil {
+			return report, err
+		}
+		report.Files = append(report.Files, entry.ScaffoldFile)
+	}
+	if !report.Changed() {
+		return report, nil
+	}
+
+	var generate = Transaction(func(tx *Tx) Job {
+		var jobs Jobs
+		jobs.Add(MoveLastForward(tx.Mkdir("", 0755)))
+		for _, entry := range entries {
+			switch {
+			case entry.Dir && entry.Status == FileCreated:
+				jobs.Add(MoveLastForward(tx.Mkdir(entry.Path, entry.mod)))
+			case !entry.Dir && entry.content != nil:
+				jobs.Add(MoveLastForward(tx.Mkdir(path.Dir(entry.Path), 0755)))
+				jobs.Add(MoveLastForward(tx.NewFile(entry.Path, entry.mod, bytes.NewReader(entry.content))))
+			}
+		}
+		return &jobs
+	}, options.tx...)
+	if _, err := generate(target); err != nil {
+		return report, err
+	}
+
+	for _, command := range s.manifest.Commands {
+		var ran, err = runCommand(ctx, renderer, command, target, options.output)
+		if ran != "" {
+			report.Commands = append(report.Commands, ran)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// values returns the variables of the manifest, overridden by the values
+// set and the answers of the prompts.
+func (s *Scaffold) values(options *scaffoldOptions) (map[string]interface{}, error) {
+	var values = map[string]interface{}{}
+	for name, value := range s.manifest.Variables {
+		values[name] = value
+	}
+	for name, value := range options.values {
+		values[name] = value
+	}
+
+	var renderer = &scaffoldRenderer{funcs: options.funcs, values: values}
+	for _, prompt := range s.manifest.Prompts {
+		if _, ok := options.values[prompt.Name]; ok {
+			continue
+		}
+
+		var answer, err = renderer.render("prompt "+prompt.Name, prompt.Default)
+		if err != nil {
+			return nil, err
+		}
+		if options.prompt != nil {
+			if answer, err = options.prompt(prompt, answer); err != nil {
+				return nil, nerror.Wrap(err, "failed to ask prompt %q", prompt.Name)
+			}
+		}
+
+		if prompt.Type != "bool" {
+			values[prompt.Name] = answer
+			continue
+		}
+		var value, parseErr = strconv.ParseBool(answer)
+		if parseErr != nil {
+			return nil, nerror.New("invalid answer %q to prompt %q, expected true or false", answer, prompt.Name)
+		}
+		values[prompt.Name] = value
+	}
+	return values, nil
+}
+
+// render walks the scaffold directory, returning the paths to generate.
+func (s *Scaffold) render(renderer *scaffoldRenderer, conflict ConflictStrategy) ([]*scaffoldEntry, error) {
+	var entries []*scaffoldEntry
+	var err = filepath.Walk(s.dir, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nerror.WrapOnly(err)
+		}
+
+		var rel, relErr = filepath.Rel(s.dir, current)
+		if relErr != nil {
+			return nerror.WrapOnly(relErr)
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." || rel == ManifestFile {
+			return nil
+		}
+
+		var skip error
+		if info.IsDir() {
+			skip = filepath.SkipDir
+		}
+
+		var entry = &scaffoldEntry{conflict: conflict, mod: info.Mode().Perm()}
+		entry.Dir = info.IsDir()
+		for _, rule := range s.manifest.Files {
+			if !matchesPath(rule.Path, rel) {
+				continue
+			}
+			if rule.When != "" {
+				var ok, whenErr = renderer.when(rel, rule.When)
+				if whenErr != nil {
+					return whenErr
+				}
+				if !ok {
+					return skip
+				}
+			}
+			if rule.Conflict != nil {
+				entry.conflict = *rule.Conflict
+			}
+		}
+
+		var templated = !entry.Dir && strings.HasSuffix(rel, TemplateExt)
+		var name = rel
+		if templated {
+			name = strings.TrimSuffix(rel, TemplateExt)
+		}
+
+		var ok bool
+		if entry.Path, ok, err = renderer.path(name); err != nil || !ok {
+			if err != nil {
+				return err
+			}
+			return skip
+		}
+		if entry.Dir {
+			entries = append(entries, entry)
+			return nil
+		}
+
+		var content []byte
+		if content, err = ioutil.ReadFile(current); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		if templated {
+			var rendered string
+			if rendered, err = renderer.render(rel, string(content)); err != nil {
+				return err
+			}
+			content = []byte(rendered)
+		}
+		entry.content = content
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// plan sets the status of an entry against the existing path, leaving the
+// content to write or nil if nothing is written.
+func plan(entry *scaffoldEntry, abs string) error {
+	var stat, statErr = os.Stat(abs)
+	if os.IsNotExist(statErr) {
+		entry.Status = FileCreated
+		return nil
+	}
+	if statErr != nil {
+		return nerror.WrapOnly(statErr)
+	}
+
+	if entry.Dir {
+		if !stat.IsDir() {
+			return nerror.New("%q is not a directory", abs)
+		}
+		entry.Status = FileUnchanged
+		return nil
+	}
+	if stat.IsDir() {
+		return nerror.New("%q is a directory", abs)
+	}
+
+	var existing, err = ioutil.ReadFile(abs)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	var generated = entry.content
+	entry.content = nil
+	switch {
+	case bytes.Equal(existing, generated):
+		entry.Status = FileUnchanged
+	case entry.conflict == OverwriteConflicts:
+		entry.Status = FileUpdated
+		entry.content = generated
+	case entry.conflict == MarkConflicts && !hasMarkers(existing) &&
+		bytes.IndexByte(existing, 0) == -1 && bytes.IndexByte(generated, 0) == -1:
+		entry.Status = FileMerged
+		entry.content = markConflicts(existing, generated)
+	default:
+		entry.Status = FileSkipped
+	}
+	return nil
+}
+
+// markConflicts returns the existing content with the lines differing from
+// generated between conflict markers.
+func markConflicts(existing []byte, generated []byte) []byte {
+	var merged bytes.Buffer
+	var ours, theirs []string
+	var flush = func() {
+		if len(ours) == 0 && len(theirs) == 0 {
+			return
+		}
+		merged.WriteString(markerExisting)
+		writeMarkedLines(&merged, ours)
+		merged.WriteString(markerSeparator)
+		writeMarkedLines(&merged, theirs)
+		merged.WriteString(markerGenerated)
+		ours, theirs = nil, nil
+	}
+
+	for _, line := range diffLines(splitLines(existing), splitLines(generated)) {
+		switch line.op {
+		case '-':
+			ours = append(ours, line.text)
+		case '+':
+			theirs = append(theirs, line.text)
+		default:
+			flush()
+			merged.WriteString(line.text)
+		}
+	}
+	flush()
+	return merged.Bytes()
+}
+
+func writeMarkedLines(w *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		w.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			w.WriteByte('\n')
+		}
+	}
+}
+
+// hasMarkers returns true if content has conflict markers left from a
+// previous merge.
+func hasMarkers(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(markerExisting)) ||
+		bytes.Contains(content, []byte("\n"+markerExisting))
+}
+
+// matchesPath returns true if pattern matches name or one of it's parent
+// directories.
+func matchesPath(pattern string, name string) bool {
+	for current := name; current != "." && current != "/"; current = path.Dir(current) {
+		if ok, _ := path.Match(pattern, current); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand runs a post command if it's condition holds, returning the
+// rendered command run.
+func runCommand(ctx context.Context, renderer *scaffoldRenderer, command PostCommand, target string, output io.Writer) (string, error) {
+	if command.When != "" {
+		var ok, err = renderer.when("command "+command.Run, command.When)
+		if err != nil || !ok {
+			return "", err
+		}
+	}
+
+	var run, err = renderer.render("command "+command.Run, command.Run)
+	if err != nil {
+		return "", err
+	}
+	var dir string
+	if dir, err = renderer.render("command "+command.Run, command.Dir); err != nil {
+		return "", err
+	}
+
+	var errBuf bytes.Buffer
+	var errOut io.Writer = &errBuf
+	if output == nil {
+		output = ioutil.Discard
+	} else {
+		errOut = io.MultiWriter(&errBuf, output)
+	}
+
+	var cmd = nexec.New(
+		nexec.Command(run),
+		nexec.Dir(filepath.Join(target, filepath.FromSlash(dir))),
+		nexec.Output(output),
+		nexec.Err(errOut),
+		nexec.Async(),
+	)
+	if _, cmdErr := cmd.Exec(ctx); cmdErr != nil {
+		return run, nerror.Wrap(cmdErr, "command %q failed: %q", run, errBuf.String())
+	}
+	return run, nil
+}
+
+// scaffoldRenderer renders the templates of a scaffold with it's values.
+type scaffoldRenderer struct {
+	funcs  template.FuncMap
+	values map[string]interface{}
+}
+
+func (r *scaffoldRenderer) render(name string, text string) (string, error) {
+	var tml, err = template.New(name).Funcs(r.funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", nerror.Wrap(err, "failed to parse template %q", name)
+	}
+
+	var out strings.Builder
+	if err := tml.Execute(&out, r.values); err != nil {
+		return "", nerror.Wrap(err, "failed to render template %q", name)
+	}
+	return out.String(), nil
+}
+
+// when returns the truth of a template pipeline.
+func (r *scaffoldRenderer) when(name string, pipeline string) (bool, error) {
+	var result, err = r.render(name, "{{if "+pipeline+"}}true{{end}}")
+	return result == "true", err
+}
+
+// path renders every element of a slash separated path, returning false
+// if one is empty.
+func (r *scaffoldRenderer) path(name string) (string, bool, error) {
+	var elems = strings.Split(name, "/")
+	for index, elem := range elems {
+		var rendered, err = r.render(name, elem)
+		if err != nil {
+			return "", false, err
+		}
+		if rendered = strings.TrimSpace(rendered); rendered == "" {
+			return "", false, nil
+		}
+		if strings.Contains(rendered, "/") || rendered == "." || rendered == ".." {
+			return "", false, nerror.New("path %q renders invalid name %q", name, rendered)
+		}
+		elems[index] = rendered
+	}
+	return strings.Join(elems, "/"), true, nil
+}
@@ -0,0 +1,161 @@
+package njobs_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/njobs"
+)
+
+const serviceManifest = `{
+	"name": "service",
+	"variables": {"Port": 8080},
+	"prompts": [
+		{"name": "Name", "message": "Service name", "default": "api"},
+		{"name": "Module", "default": "github.com/acme/{{.Name}}"},
+		{"name": "Docker", "type": "bool", "default": "true"}
+	],
+	"files": [
+		{"path": "deploy", "when": ".Docker"},
+		{"path": "*.yml.tmpl", "conflict": "merge"}
+	],
+	"commands": [
+		{"run": "echo {{.Name}} >> generated.log"},
+		{"run": "echo docker >> generated.log", "when": ".Docker"}
+	]
+}`
+
+func serviceScaffold(t *testing.T) *njobs.Scaffold {
+	var dir = tree(t, map[string]string{
+		njobs.ManifestFile:                   serviceManifest,
+		"README.md.tmpl":                     "# {{.Name}}\n\nModule {{.Module}}\n",
+		"cmd/{{.Name}}/main.go.tmpl":         "package main\n\n// serves {{.Name | upper}}\nfunc main() {}\n",
+		"config.yml.tmpl":                    "name: {{.Name}}\nport: {{.Port}}\ndebug: false\n",
+		"deploy/Dockerfile":                  "FROM scratch\n",
+		"{{if .Docker}}.dockerignore{{end}}": "*.log\n",
+	})
+
+	var scaffold, err = njobs.LoadScaffold(dir)
+	require.NoError(t, err)
+	return scaffold
+}
+
+func TestScaffold_Generate(t *testing.T) {
+	var scaffold = serviceScaffold(t)
+	var target = filepath.Join(tree(t, nil), "billing")
+
+	var prompts bytes.Buffer
+	var report, err = scaffold.Generate(context.Background(), target,
+		njobs.Prompter(njobs.ReadPrompts(strings.NewReader("billing\n\nfalse\n"), &prompts)))
+	require.NoError(t, err)
+	require.Equal(t, "Service name [api]: Module [github.com/acme/billing]: Docker [true]: ", prompts.String())
+	require.Equal(t, map[string]interface{}{
+		"Port":   float64(8080),
+		"Name":   "billing",
+		"Module": "github.com/acme/billing",
+		"Docker": false,
+	}, report.Values)
+	require.Equal(t, []string{"echo billing >> generated.log"}, report.Commands)
+	require.Equal(t, map[string]string{
+		"./":                  "",
+		"README.md":           "# billing\n\nModule github.com/acme/billing\n",
+		"cmd/":                "",
+		"cmd/billing/":        "",
+		"cmd/billing/main.go": "package main\n\n// serves BILLING\nfunc main() {}\n",
+		"config.yml":          "name: billing\nport: 8080\ndebug: false\n",
+		"generated.log":       "billing\n",
+	}, snapshot(t, target))
+
+	// generating again with the same values changes nothing.
+	var values = report.Values
+	report, err = scaffold.Generate(context.Background(), target, njobs.ScaffoldValues(values))
+	require.NoError(t, err)
+	require.False(t, report.Changed())
+	require.Len(t, report.Commands, 0)
+	require.Equal(t, "unchanged README.md\n"+
+		"unchanged cmd/\n"+
+		"unchanged cmd/billing/\n"+
+		"unchanged cmd/billing/main.go\n"+
+		"unchanged config.yml\n", report.String())
+
+	// enabling docker adds it's files only.
+	values["Docker"] = true
+	var output bytes.Buffer
+	report, err = scaffold.Generate(context.Background(), target,
+		njobs.ScaffoldValues(values), njobs.CommandOutput(&output))
+	require.NoError(t, err)
+	require.Contains(t, report.String(), "created   .dockerignore\n")
+	require.Contains(t, report.String(), "created   deploy/\ncreated   deploy/Dockerfile\n")
+	require.Contains(t, report.String(), "ran       echo docker >> generated.log\n")
+
+	var log, readErr = ioutil.ReadFile(filepath.Join(target, "generated.log"))
+	require.NoError(t, readErr)
+	require.Equal(t, "billing\nbilling\ndocker\n", string(log))
+}
+
+func TestScaffold_Conflicts(t *testing.T) {
+	var scaffold = serviceScaffold(t)
+	var target = tree(t, map[string]string{
+		"README.md":  "# Billing service\n",
+		"config.yml": "name: billing\nport: 9090\ndebug: false\n",
+	})
+	var values = njobs.ScaffoldValues(map[string]interface{}{"Name": "billing", "Docker": false})
+	var statuses = func(report *njobs.ScaffoldReport) map[string]string {
+		var files = map[string]string{}
+		for _, file := range report.Files {
+			files[file.Path] = file.Status.String()
+		}
+		return files
+	}
+
+	// an unconfirmed preview writes nothing.
+	var preview bytes.Buffer
+	var before = snapshot(t, target)
+	var report, err = scaffold.Generate(context.Background(), target, values,
+		njobs.ScaffoldTx(njobs.Preview(&preview), njobs.Confirm(func(_ string) bool { return false })))
+	require.True(t, njobs.IsAborted(err))
+	require.Contains(t, preview.String(), "+++ "+filepath.Join(target, "cmd/billing/main.go")+"\n")
+	require.Equal(t, before, snapshot(t, target))
+	require.Equal(t, "created", statuses(report)["cmd/billing/main.go"])
+
+	// existing files are skipped by default, config.yml is merged.
+	report, err = scaffold.Generate(context.Background(), target, values)
+	require.NoError(t, err)
+	require.Equal(t, "skipped", statuses(report)["README.md"])
+	require.Equal(t, "merged", statuses(report)["config.yml"])
+
+	var files = snapshot(t, target)
+	require.Equal(t, "# Billing service\n", files["README.md"])
+	require.Equal(t, "name: billing\n"+
+		"<<<<<<< existing\n"+
+		"port: 9090\n"+
+		"=======\n"+
+		"port: 8080\n"+
+		">>>>>>> generated\n"+
+		"debug: false\n", files["config.yml"])
+
+	// unresolved conflicts are not merged again.
+	report, err = scaffold.Generate(context.Background(), target, values, njobs.OnConflict(njobs.MarkConflicts))
+	require.NoError(t, err)
+	require.Equal(t, "skipped", statuses(report)["config.yml"])
+	require.Equal(t, "merged", statuses(report)["README.md"])
+	require.Equal(t, "<<<<<<< existing\n"+
+		"# Billing service\n"+
+		"=======\n"+
+		"# billing\n"+
+		"\n"+
+		"Module github.com/acme/billing\n"+
+		">>>>>>> generated\n", snapshot(t, target)["README.md"])
+
+	report, err = scaffold.Generate(context.Background(), target, values, njobs.OnConflict(njobs.OverwriteConflicts))
+	require.NoError(t, err)
+	require.Equal(t, "updated", statuses(report)["README.md"])
+	require.Equal(t, "skipped", statuses(report)["config.yml"])
+	require.Equal(t, "# billing\n\nModule github.com/acme/billing\n", snapshot(t, target)["README.md"])
+}
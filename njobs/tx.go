@@ -0,0 +1,698 @@
+package njobs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+var (
+	// ErrAborted is returned when a transaction is aborted, by a job or
+	// because the preview of it's changes was not confirmed.
+	ErrAborted = errors.New("njobs: transaction aborted")
+
+	// ErrTxDone is returned when using a transaction which was committed
+	// or rolled back.
+	ErrTxDone = errors.New("njobs: transaction is already committed or rolled back")
+)
+
+// IsAborted returns true if giving error is or wraps ErrAborted.
+func IsAborted(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrAborted
+}
+
+// ChangeKind defines the kind of a change recorded by a Tx.
+type ChangeKind int
+
+// Change kinds.
+const (
+	Created ChangeKind = iota
+	Overwritten
+	Deleted
+)
+
+// String returns the name of the kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Created:
+		return "created"
+	case Overwritten:
+		return "overwritten"
+	case Deleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a change of the tree recorded by a Tx.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Dir  bool
+
+	abs    string
+	backup string
+}
+
+// String returns the kind and path of the change.
+func (c Change) String() string {
+	if c.Dir {
+		return c.Kind.String() + " " + c.Path + "/"
+	}
+	return c.Kind.String() + " " + c.Path
+}
+
+// TxOption defines a function type which sets the options of a Tx.
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	staging string
+	preview io.Writer
+	confirm func(diff string) bool
+}
+
+// StagingDir sets the directory in which a Tx stages backups, defaults to
+// the temporary directory of the system. Deleted paths are moved to it
+// when possible, hence a directory on the same device as the tree avoids
+// copying them.
+func StagingDir(dir string) TxOption {
+	return func(o *txOptions) {
+		o.staging = dir
+	}
+}
+
+// Preview sets the writer Transaction writes the diff of the changes to
+// before committing them.
+func Preview(w io.Writer) TxOption {
+	return func(o *txOptions) {
+		o.preview = w
+	}
+}
+
+// Confirm sets the function Transaction asks with the diff of the changes
+// before committing them, the transaction is rolled back and ErrAborted
+// returned if it returns false.
+func Confirm(fn func(diff string) bool) TxOption {
+	return func(o *txOptions) {
+		o.confirm = fn
+	}
+}
+
+// Tx records the changes made to a tree through it, staging backups of
+// overwritten and deleted paths, so the tree can be rolled back to it's
+// state before the transaction.
+//
+// The jobs of a Tx mirror the file jobs of the package, e.g Tx.Mkdir for
+// Mkdir and Tx.NewFile for NewFile, and can replace them in Jobs.
+type Tx struct {
+	lock    sync.Mutex
+	staging string
+	changes []Change
+	saved   map[string]bool
+	done    bool
+}
+
+// Begin returns a new Tx staging backups in a new directory.
+func Begin(ops ...TxOption) (*Tx, error) {
+	var options txOptions
+	for _, op := range ops {
+		op(&options)
+	}
+
+	var staging, err = ioutil.TempDir(options.staging, "njobs-tx-")
+	if err != nil {
+		return nil, nerror.Wrap(err, "failed to create staging directory")
+	}
+	return &Tx{staging: staging, saved: map[string]bool{}}, nil
+}
+
+// Changes returns the changes recorded by the transaction, in order.
+func (tx *Tx) Changes() []Change {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	var changes = make([]Change, len(tx.changes))
+	copy(changes, tx.changes)
+	return changes
+}
+
+// CreateDir creates giving directory and it's missing parents, recording
+// every directory created.
+func (tx *Tx) CreateDir(dir string, mod os.FileMode) error {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	if tx.done {
+		return nerror.WrapOnly(ErrTxDone)
+	}
+
+	var abs, err = filepath.Abs(dir)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	// record the missing directories from the top.
+	var missing []string
+	for current := abs; ; current = filepath.Dir(current) {
+		var stat, statErr = os.Stat(current)
+		if statErr == nil {
+			if !stat.IsDir() {
+				return nerror.New("%q is not a directory", current)
+			}
+			break
+		}
+		if !os.IsNotExist(statErr) {
+			return nerror.WrapOnly(statErr)
+		}
+		missing = append(missing, current)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	for index := len(missing) - 1; index >= 0; index-- {
+		var current = missing[index]
+		if err := os.Mkdir(current, mod); err != nil && !os.IsExist(err) {
+			return nerror.WrapOnly(err)
+		}
+		tx.record(Change{Kind: Created, Path: relativeTo(dir, abs, current), Dir: true, abs: current})
+	}
+	return nil
+}
+
+// WriteFile writes the content of r to giving file, recording it as
+// created or staging a backup of it as overwritten.
+func (tx *Tx) WriteFile(name string, mod os.FileMode, r io.Reader) error {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	if tx.done {
+		return nerror.WrapOnly(ErrTxDone)
+	}
+
+	var abs, err = filepath.Abs(name)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	var change = Change{Kind: Created, Path: name, abs: abs}
+	var stat, statErr = os.Stat(abs)
+	switch {
+	case statErr == nil && stat.IsDir():
+		return nerror.New("%q is a directory", name)
+	case statErr == nil:
+		change.Kind = Overwritten
+		if !tx.covered(abs) {
+			if change.backup, err = tx.backup(abs, false); err != nil {
+				return err
+			}
+		}
+	case !os.IsNotExist(statErr):
+		return nerror.WrapOnly(statErr)
+	}
+
+	// the change is recorded first so a partial write is rolled back.
+	tx.record(change)
+
+	var file *os.File
+	if file, err = os.OpenFile(abs, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mod); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if _, err := io.Copy(file, r); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+// RemoveAll removes giving path and any children it contains, staging a
+// backup of it. Removing a missing path records nothing.
+func (tx *Tx) RemoveAll(name string) error {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	if tx.done {
+		return nerror.WrapOnly(ErrTxDone)
+	}
+
+	var abs, err = filepath.Abs(name)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	var stat, statErr = os.Lstat(abs)
+	if os.IsNotExist(statErr) {
+		return nil
+	}
+	if statErr != nil {
+		return nerror.WrapOnly(statErr)
+	}
+
+	var change = Change{Kind: Deleted, Path: name, Dir: stat.IsDir(), abs: abs}
+	if !tx.covered(abs) {
+		if change.backup, err = tx.backup(abs, true); err != nil {
+			return err
+		}
+	}
+	tx.record(change)
+	if err := os.RemoveAll(abs); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+func (tx *Tx) record(change Change) {
+	tx.changes = append(tx.changes, change)
+	tx.saved[change.abs] = true
+}
+
+// covered returns true if the original state of giving path is already
+// known, as it or a parent was created or backed up by the transaction.
+func (tx *Tx) covered(abs string) bool {
+	for current := abs; ; current = filepath.Dir(current) {
+		if tx.saved[current] {
+			return true
+		}
+		if filepath.Dir(current) == current {
+			return false
+		}
+	}
+}
+
+// backup stages a copy of giving path, moving it if move is true and the
+// staging directory is on the same device.
+func (tx *Tx) backup(abs string, move bool) (string, error) {
+	var target = filepath.Join(tx.staging, strconv.Itoa(len(tx.changes)))
+	if move {
+		if err := os.Rename(abs, target); err == nil {
+			return target, nil
+		}
+	}
+	if err := copyPath(abs, target); err != nil {
+		return "", nerror.Wrap(err, "failed to backup %q", abs)
+	}
+	return target, nil
+}
+
+// Commit keeps the changes and removes the staged backups.
+func (tx *Tx) Commit() error {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	if tx.done {
+		return nerror.WrapOnly(ErrTxDone)
+	}
+	tx.done = true
+
+	if err := os.RemoveAll(tx.staging); err != nil {
+		return nerror.Wrap(err, "failed to remove staging directory")
+	}
+	return nil
+}
+
+// Rollback undoes the changes in reverse order, restoring the backups of
+// overwritten and deleted paths, and removes the staged backups.
+//
+// All changes are undone even if some fail, the first error is returned
+// and the staged backups are kept so they can be restored by hand.
+func (tx *Tx) Rollback() error {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	if tx.done {
+		return nerror.WrapOnly(ErrTxDone)
+	}
+	tx.done = true
+
+	var firstErr error
+	for index := len(tx.changes) - 1; index >= 0; index-- {
+		if err := undo(tx.changes[index]); err != nil && firstErr == nil {
+			firstErr = nerror.Wrap(err, "failed to undo %s, backups are kept in %q", tx.changes[index], tx.staging)
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if err := os.RemoveAll(tx.staging); err != nil {
+		return nerror.Wrap(err, "failed to remove staging directory")
+	}
+	return nil
+}
+
+func undo(change Change) error {
+	switch {
+	case change.Kind == Created:
+		return os.RemoveAll(change.abs)
+	case change.backup == "":
+		// the original state is restored by an earlier change.
+		return nil
+	default:
+		if err := os.RemoveAll(change.abs); err != nil {
+			return err
+		}
+		if err := os.Rename(change.backup, change.abs); err == nil {
+			return nil
+		}
+		return copyPath(change.backup, change.abs)
+	}
+}
+
+// Abort rolls the transaction back, returning ErrAborted or the error of
+// the rollback.
+func (tx *Tx) Abort() error {
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	return nerror.WrapOnly(ErrAborted)
+}
+
+// Diff returns a unified diff of the changed files between their state
+// before the transaction and their current one.
+func (tx *Tx) Diff() (string, error) {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+
+	var builder strings.Builder
+	var seen = map[string]bool{}
+	for _, change := range tx.changes {
+		if seen[change.abs] {
+			continue
+		}
+		seen[change.abs] = true
+
+		var before, beforeDir, existed, err = tx.original(change.abs)
+		if err != nil {
+			return "", err
+		}
+		var after, afterDir, exists, readErr = readPath(change.abs)
+		if readErr != nil {
+			return "", readErr
+		}
+
+		if existed && beforeDir && !(exists && afterDir) {
+			_, _ = fmt.Fprintf(&builder, "deleted directory %s/\n", change.Path)
+		}
+		if exists && afterDir && !(existed && beforeDir) {
+			_, _ = fmt.Fprintf(&builder, "created directory %s/\n", change.Path)
+		}
+
+		var fileExisted = existed && !beforeDir
+		var fileExists = exists && !afterDir
+		if fileExisted || fileExists {
+			writeFileDiff(&builder, change.Path, before, after, fileExisted, fileExists)
+		}
+	}
+	return builder.String(), nil
+}
+
+// original returns the content of giving path before the transaction,
+// from the backup of it or of a parent.
+func (tx *Tx) original(abs string) ([]byte, bool, bool, error) {
+	for _, change := range tx.changes {
+		var rel, err = filepath.Rel(change.abs, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if change.Kind == Created || change.backup == "" {
+			return nil, false, false, nil
+		}
+		return readPath(filepath.Join(change.backup, rel))
+	}
+	return nil, false, false, nil
+}
+
+// readPath returns the content of the file at giving path, if it is a
+// directory and if it exists.
+func readPath(abs string) ([]byte, bool, bool, error) {
+	var stat, err = os.Stat(abs)
+	if os.IsNotExist(err) {
+		return nil, false, false, nil
+	}
+	if err != nil {
+		return nil, false, false, nerror.WrapOnly(err)
+	}
+	if stat.IsDir() {
+		return nil, true, true, nil
+	}
+
+	var content []byte
+	if content, err = ioutil.ReadFile(abs); err != nil {
+		return nil, false, false, nerror.WrapOnly(err)
+	}
+	return content, false, true, nil
+}
+
+// relativeTo returns target, a parent of abs, as a path relative to name
+// which abs is the absolute path of.
+func relativeTo(name string, abs string, target string) string {
+	if target == abs {
+		return name
+	}
+	var rel, err = filepath.Rel(target, abs)
+	if err != nil {
+		return target
+	}
+	var result = filepath.Clean(name)
+	for range strings.Split(rel, string(filepath.Separator)) {
+		result = filepath.Dir(result)
+	}
+	return result
+}
+
+// copyPath copies the file, symlink or directory tree at src to dst,
+// keeping their modes.
+func copyPath(src string, dst string) error {
+	var stat, err = os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case stat.Mode()&os.ModeSymlink != 0:
+		var link, err = os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	case stat.IsDir():
+		if err := os.MkdirAll(dst, stat.Mode().Perm()); err != nil {
+			return err
+		}
+		var entries, err = ioutil.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		var in, err = os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+
+		var out *os.File
+		if out, err = os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, stat.Mode().Perm()); err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, in); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
+
+//************************************************************************
+// Tx jobs
+//************************************************************************
+
+// Mkdir returns a JobFunction creating a directory within the directory
+// passed to it, like Mkdir.
+func (tx *Tx) Mkdir(dir string, mod os.FileMode) JobFunction {
+	return func(rootDirData interface{}) (interface{}, error) {
+		var rootDir, ok = rootDirData.(string)
+		if !ok {
+			return nil, nerror.New("Expected rootDir path string as input")
+		}
+		var targetDir = path.Join(rootDir, dir)
+		if err := tx.CreateDir(targetDir, mod); err != nil {
+			return nil, nerror.WrapOnly(err)
+		}
+		return targetDir, nil
+	}
+}
+
+// File returns a JobFunction creating a file within the directory passed
+// to it if not existing, like File.
+func (tx *Tx) File(name string, mod os.FileMode, r io.Reader) JobFunction {
+	return func(dir interface{}) (interface{}, error) {
+		var rootDir, ok = dir.(string)
+		if !ok {
+			return nil, nerror.New("Expected value to be a string")
+		}
+		var targetFile = path.Join(rootDir, name)
+		if _, statErr := os.Stat(targetFile); statErr == nil {
+			return targetFile, nil
+		}
+		if err := tx.WriteFile(targetFile, mod, r); err != nil {
+			return nil, nerror.WrapOnly(err)
+		}
+		return targetFile, nil
+	}
+}
+
+// NewFile returns a JobFunction creating or overwriting a file within the
+// directory passed to it, like NewFile.
+func (tx *Tx) NewFile(name string, mod os.FileMode, r io.Reader) JobFunction {
+	return func(dir interface{}) (interface{}, error) {
+		var rootDir, ok = dir.(string)
+		if !ok {
+			return nil, nerror.New("Expected value to be a string")
+		}
+		var targetFile = path.Join(rootDir, name)
+		if err := tx.WriteFile(targetFile, mod, r); err != nil {
+			return nil, nerror.WrapOnly(err)
+		}
+		return targetFile, nil
+	}
+}
+
+// DeletePath returns a JobFunction deleting the path passed to it, like
+// DeletePath.
+func (tx *Tx) DeletePath() JobFunction {
+	return func(dir interface{}) (interface{}, error) {
+		var rootDir, ok = dir.(string)
+		if !ok {
+			return nil, nerror.New("Expected rootDir path string as input")
+		}
+		if err := tx.RemoveAll(rootDir); err != nil {
+			return rootDir, nerror.WrapOnly(err)
+		}
+		return rootDir, nil
+	}
+}
+
+// DeleteDir returns a JobFunction deleting giving directory, like
+// DeleteDir.
+func (tx *Tx) DeleteDir(targetDir string) JobFunction {
+	return func(_ interface{}) (interface{}, error) {
+		if err := tx.RemoveAll(targetDir); err != nil {
+			return targetDir, nerror.WrapOnly(err)
+		}
+		return targetDir, nil
+	}
+}
+
+// DeleteFile returns a JobFunction deleting giving file, like DeleteFile.
+func (tx *Tx) DeleteFile(targetFile string) JobFunction {
+	return func(_ interface{}) (interface{}, error) {
+		if _, err := os.Stat(targetFile); err != nil {
+			return targetFile, nerror.WrapOnly(err)
+		}
+		if err := tx.RemoveAll(targetFile); err != nil {
+			return targetFile, nerror.WrapOnly(err)
+		}
+		return targetFile, nil
+	}
+}
+
+// DeleteDirectoryFrom returns a JobFunction deleting a directory within
+// the directory passed to it, like DeleteDirectoryFrom.
+func (tx *Tx) DeleteDirectoryFrom(name string) JobFunction {
+	return func(dir interface{}) (interface{}, error) {
+		var rootDir, ok = dir.(string)
+		if !ok {
+			return nil, nerror.New("Expected rootDir path string as input")
+		}
+		return tx.DeleteDir(path.Join(rootDir, name))(nil)
+	}
+}
+
+// DeleteFileFrom returns a JobFunction deleting a file within the
+// directory passed to it, like DeleteFileFrom.
+func (tx *Tx) DeleteFileFrom(name string) JobFunction {
+	return func(dir interface{}) (interface{}, error) {
+		var rootDir, ok = dir.(string)
+		if !ok {
+			return nil, nerror.New("Expected rootDir path string as input")
+		}
+		return tx.DeleteFile(path.Join(rootDir, name))(nil)
+	}
+}
+
+// Transaction returns a JobFunction running the job returned by build
+// within a new Tx, which is rolled back if the job fails and committed
+// otherwise, after it's diff is written to the Preview writer and
+// confirmed by the Confirm function if set.
+//
+// A job can abort the transaction by returning ErrAborted, e.g:
+//
+//	var scaffold = njobs.Transaction(func(tx *njobs.Tx) njobs.Job {
+//		var jobs njobs.Jobs
+//		jobs.Add(tx.Mkdir("cmd", 0755))
+//		jobs.Add(tx.NewFile("main.go", 0644, strings.NewReader(mainFile)))
+//		return &jobs
+//	}, njobs.Preview(os.Stdout))
+func Transaction(build func(tx *Tx) Job, ops ...TxOption) JobFunction {
+	return func(data interface{}) (interface{}, error) {
+		var options txOptions
+		for _, op := range ops {
+			op(&options)
+		}
+
+		var tx, err = Begin(ops...)
+		if err != nil {
+			return nil, err
+		}
+
+		var result interface{}
+		if result, err = build(tx).Do(data); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return result, nerror.Wrap(rollbackErr, "failed to roll back after: %s", err)
+			}
+			return result, nerror.WrapOnly(err)
+		}
+
+		if options.preview != nil || options.confirm != nil {
+			var diff, diffErr = tx.Diff()
+			if diffErr != nil {
+				_ = tx.Rollback()
+				return result, diffErr
+			}
+			if options.preview != nil {
+				if _, err := io.WriteString(options.preview, diff); err != nil {
+					_ = tx.Rollback()
+					return result, nerror.WrapOnly(err)
+				}
+			}
+			if options.confirm != nil && !options.confirm(diff) {
+				return result, tx.Abort()
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,163 @@
+package njobs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/njobs"
+)
+
+// tree creates a directory with giving files, returning it's path.
+func tree(t *testing.T, files map[string]string) string {
+	var root, err = ioutil.TempDir("", "njobs-tree-")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(root)
+	})
+
+	for name, content := range files {
+		var target = filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(target), 0755))
+		require.NoError(t, ioutil.WriteFile(target, []byte(content), 0644))
+	}
+	return root
+}
+
+// snapshot returns the content of all files of a directory by their path.
+func snapshot(t *testing.T, root string) map[string]string {
+	var files = map[string]string{}
+	require.NoError(t, filepath.Walk(root, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		var rel, _ = filepath.Rel(root, current)
+		if info.IsDir() {
+			files[rel+"/"] = ""
+			return nil
+		}
+		var content, readErr = ioutil.ReadFile(current)
+		files[rel] = string(content)
+		return readErr
+	}))
+	return files
+}
+
+func TestTx_Rollback(t *testing.T) {
+	var root = tree(t, map[string]string{
+		"main.go":        "package main\n",
+		"docs/guide.md":  "# Guide\n",
+		"docs/readme.md": "# Readme\n",
+	})
+	var before = snapshot(t, root)
+
+	var tx, err = njobs.Begin()
+	require.NoError(t, err)
+
+	var jobs njobs.Jobs
+	jobs.Add(tx.Mkdir("cmd/server", 0755))
+	jobs.Add(tx.NewFile("main.go", 0644, strings.NewReader("package server\n")))
+	jobs.Add(njobs.BackupPath())
+	jobs.Add(njobs.BackupPath())
+	jobs.Add(njobs.BackupPath())
+	jobs.Add(tx.NewFile("main.go", 0644, strings.NewReader("package main\n\nfunc main() {}\n")))
+	jobs.Add(njobs.BackupPath())
+	jobs.Add(tx.DeleteDirectoryFrom("docs"))
+	jobs.Add(njobs.BackupPath())
+	jobs.Add(tx.File("main.go", 0644, strings.NewReader("ignored")))
+
+	_, err = jobs.Do(root)
+	require.NoError(t, err)
+	require.NotEqual(t, before, snapshot(t, root))
+
+	var kinds []string
+	for _, change := range tx.Changes() {
+		kinds = append(kinds, change.Kind.String())
+	}
+	require.Equal(t, []string{"created", "created", "created", "overwritten", "deleted"}, kinds)
+
+	require.NoError(t, tx.Rollback())
+	require.Equal(t, before, snapshot(t, root))
+	require.Error(t, tx.Commit())
+}
+
+func TestTx_Diff(t *testing.T) {
+	var root = tree(t, map[string]string{
+		"main.go":  "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n",
+		"old.txt":  "old\n",
+		"keep.txt": "keep\n",
+	})
+
+	var tx, err = njobs.Begin()
+	require.NoError(t, err)
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	require.NoError(t, tx.WriteFile(filepath.Join(root, "main.go"), 0644,
+		strings.NewReader("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"bye\")\n}\n")))
+	require.NoError(t, tx.RemoveAll(filepath.Join(root, "old.txt")))
+	require.NoError(t, tx.WriteFile(filepath.Join(root, "new.txt"), 0644, strings.NewReader("new")))
+	require.NoError(t, tx.WriteFile(filepath.Join(root, "keep.txt"), 0644, strings.NewReader("keep\n")))
+
+	var diff string
+	diff, err = tx.Diff()
+	require.NoError(t, err)
+	require.Contains(t, diff, "@@ -3,5 +3,5 @@\n import \"fmt\"\n \n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n }\n")
+	require.Contains(t, diff, "--- "+filepath.Join(root, "old.txt")+"\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-old\n")
+	require.Contains(t, diff, "+++ "+filepath.Join(root, "new.txt")+"\n@@ -0,0 +1,1 @@\n+new\n\\ No newline at end of file\n")
+	require.NotContains(t, diff, "keep.txt")
+}
+
+func TestTransaction(t *testing.T) {
+	var root = tree(t, map[string]string{"config.yml": "debug: false\n"})
+	var before = snapshot(t, root)
+
+	var build = func(fail error) func(tx *njobs.Tx) njobs.Job {
+		return func(tx *njobs.Tx) njobs.Job {
+			var jobs njobs.Jobs
+			jobs.Add(tx.NewFile("config.yml", 0644, strings.NewReader("debug: true\n")))
+			jobs.Add(njobs.BackupPath())
+			jobs.Add(tx.Mkdir("logs", 0755))
+			jobs.Add(njobs.JobFunction(func(d interface{}) (interface{}, error) {
+				return d, fail
+			}))
+			return &jobs
+		}
+	}
+
+	// a failing job rolls the tree back.
+	var _, err = njobs.Transaction(build(errors.New("bad job")))(root)
+	require.Error(t, err)
+	require.Equal(t, before, snapshot(t, root))
+
+	_, err = njobs.Transaction(build(njobs.ErrAborted))(root)
+	require.True(t, njobs.IsAborted(err))
+	require.Equal(t, before, snapshot(t, root))
+
+	// a preview which is not confirmed rolls the tree back.
+	var preview bytes.Buffer
+	_, err = njobs.Transaction(build(nil), njobs.Preview(&preview), njobs.Confirm(func(diff string) bool {
+		return false
+	}))(root)
+	require.True(t, njobs.IsAborted(err))
+	require.Contains(t, preview.String(), "-debug: false\n+debug: true\n")
+	require.Contains(t, preview.String(), "created directory "+filepath.Join(root, "logs")+"/\n")
+	require.Equal(t, before, snapshot(t, root))
+
+	_, err = njobs.Transaction(build(nil), njobs.Confirm(func(diff string) bool {
+		return true
+	}))(root)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"./":         "",
+		"config.yml": "debug: true\n",
+		"logs/":      "",
+	}, snapshot(t, root))
+}
@@ -0,0 +1,314 @@
+// Package nmetrics provides a lightweight in-process metrics registry
+// with counters, gauges and histograms safe for concurrent use.
+package nmetrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRegistry is the registry used when none is provided.
+var DefaultRegistry = NewRegistry()
+
+// LatencyBuckets are the default histogram bounds for latencies in
+// milliseconds.
+var LatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// SizeBuckets are the default histogram bounds for sizes in bytes.
+var SizeBuckets = ExponentialBuckets(64, 4, 10)
+
+// ExponentialBuckets returns count bounds where the first is start and each
+// following is multiplied by factor.
+func ExponentialBuckets(start float64, factor float64, count int) []float64 {
+	var buckets = make([]float64, count)
+	for index := range buckets {
+		buckets[index] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Milliseconds returns giving duration as fractional milliseconds, which is
+// the unit used by LatencyBuckets.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+//**********************************************************************
+// Counter
+//**********************************************************************
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	value int64
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+// Add adds giving value to the counter.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+//**********************************************************************
+// Gauge
+//**********************************************************************
+
+// Gauge is a value which can go up and down.
+type Gauge struct {
+	value int64
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(n int64) {
+	atomic.StoreInt64(&g.value, n)
+}
+
+// Add adds giving value, which may be negative, to the gauge.
+func (g *Gauge) Add(n int64) {
+	atomic.AddInt64(&g.value, n)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+//**********************************************************************
+// Histogram
+//**********************************************************************
+
+// Histogram counts observed values into buckets with fixed upper bounds,
+// values above the last bound are counted in an overflow bucket.
+type Histogram struct {
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     uint64
+}
+
+// NewHistogram returns a new Histogram with giving bucket upper bounds.
+func NewHistogram(bounds []float64) *Histogram {
+	var sorted = append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	return &Histogram{
+		bounds:  sorted,
+		buckets: make([]uint64, len(sorted)+1),
+	}
+}
+
+// hasBounds returns true if the histogram has giving bucket upper bounds,
+// in any order.
+func (h *Histogram) hasBounds(bounds []float64) bool {
+	if len(bounds) != len(h.bounds) {
+		return false
+	}
+	var sorted = append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	for index, bound := range sorted {
+		if bound != h.bounds[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// Observe records giving value.
+func (h *Histogram) Observe(value float64) {
+	var index = sort.SearchFloat64s(h.bounds, value)
+	atomic.AddUint64(&h.buckets[index], 1)
+	atomic.AddUint64(&h.count, 1)
+	for {
+		var old = atomic.LoadUint64(&h.sum)
+		var next = math.Float64bits(math.Float64frombits(old) + value)
+		if atomic.CompareAndSwapUint64(&h.sum, old, next) {
+			return
+		}
+	}
+}
+
+// ObserveDuration records giving duration in milliseconds.
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(Milliseconds(d))
+}
+
+// Snapshot returns the current state of the histogram.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	var snap HistogramSnapshot
+	snap.Bounds = h.bounds
+	snap.Counts = make([]uint64, len(h.buckets))
+	for index := range h.buckets {
+		snap.Counts[index] = atomic.LoadUint64(&h.buckets[index])
+	}
+	snap.Count = atomic.LoadUint64(&h.count)
+	snap.Sum = math.Float64frombits(atomic.LoadUint64(&h.sum))
+	return snap
+}
+
+// HistogramSnapshot holds the state of a Histogram at a point in time.
+//
+// Counts has one more entry than Bounds, the last being the count of
+// values above the last bound.
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// Mean returns the average of all observed values.
+func (h HistogramSnapshot) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// Quantile returns an estimate of giving quantile (0 to 1) as the upper
+// bound of the bucket containing it. Values in the overflow bucket are
+// reported as +Inf.
+func (h HistogramSnapshot) Quantile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	var rank = uint64(math.Ceil(q * float64(h.Count)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for index, count := range h.Counts {
+		seen += count
+		if seen >= rank {
+			if index < len(h.Bounds) {
+				return h.Bounds[index]
+			}
+			return math.Inf(1)
+		}
+	}
+	return math.Inf(1)
+}
+
+//**********************************************************************
+// Registry
+//**********************************************************************
+
+// Registry holds named metrics, creating them on first access.
+type Registry struct {
+	lock       sync.RWMutex
+	counters   map[string]*Counter
+	gauges     map[string]*Gauge
+	histograms map[string]*Histogram
+}
+
+// NewRegistry returns a new instance of a Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   map[string]*Counter{},
+		gauges:     map[string]*Gauge{},
+		histograms: map[string]*Histogram{},
+	}
+}
+
+// Counter returns the counter with giving name.
+func (r *Registry) Counter(name string) *Counter {
+	r.lock.RLock()
+	var counter, ok = r.counters[name]
+	r.lock.RUnlock()
+	if ok {
+		return counter
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if counter, ok = r.counters[name]; !ok {
+		counter = &Counter{}
+		r.counters[name] = counter
+	}
+	return counter
+}
+
+// Gauge returns the gauge with giving name.
+func (r *Registry) Gauge(name string) *Gauge {
+	r.lock.RLock()
+	var gauge, ok = r.gauges[name]
+	r.lock.RUnlock()
+	if ok {
+		return gauge
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if gauge, ok = r.gauges[name]; !ok {
+		gauge = &Gauge{}
+		r.gauges[name] = gauge
+	}
+	return gauge
+}
+
+// Histogram returns the histogram with giving name, creating it with
+// giving bounds if it does not exists.
+//
+// It panics if the histogram exists with other bounds, as observations
+// would be recorded in the wrong buckets, a nil bounds matches any.
+func (r *Registry) Histogram(name string, bounds []float64) *Histogram {
+	r.lock.RLock()
+	var histogram, ok = r.histograms[name]
+	r.lock.RUnlock()
+
+	if !ok {
+		r.lock.Lock()
+		if histogram, ok = r.histograms[name]; !ok {
+			histogram = NewHistogram(bounds)
+			r.histograms[name] = histogram
+		}
+		r.lock.Unlock()
+	}
+
+	if ok && bounds != nil && !histogram.hasBounds(bounds) {
+		panic(fmt.Sprintf("nmetrics: histogram %q already registered with bounds %v", name, histogram.bounds))
+	}
+	return histogram
+}
+
+// Snapshot holds the values of all metrics of a Registry.
+type Snapshot struct {
+	Counters   map[string]int64
+	Gauges     map[string]int64
+	Histograms map[string]HistogramSnapshot
+}
+
+// Snapshot returns the current values of all metrics.
+func (r *Registry) Snapshot() Snapshot {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var snap = Snapshot{
+		Counters:   make(map[string]int64, len(r.counters)),
+		Gauges:     make(map[string]int64, len(r.gauges)),
+		Histograms: make(map[string]HistogramSnapshot, len(r.histograms)),
+	}
+	for name, counter := range r.counters {
+		snap.Counters[name] = counter.Value()
+	}
+	for name, gauge := range r.gauges {
+		snap.Gauges[name] = gauge.Value()
+	}
+	for name, histogram := range r.histograms {
+		snap.Histograms[name] = histogram.Snapshot()
+	}
+	return snap
+}
@@ -0,0 +1,64 @@
+package nmetrics
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	var registry = NewRegistry()
+	require.Same(t, registry.Counter("calls"), registry.Counter("calls"))
+	require.Same(t, registry.Gauge("inflight"), registry.Gauge("inflight"))
+	require.Same(t, registry.Histogram("latency", LatencyBuckets), registry.Histogram("latency", nil))
+
+	// asking for other bounds must not record into the wrong buckets.
+	require.Panics(t, func() {
+		registry.Histogram("latency", SizeBuckets)
+	})
+	require.Panics(t, func() {
+		registry.Histogram("latency", LatencyBuckets[1:])
+	})
+
+	var waiter sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			for j := 0; j < 100; j++ {
+				registry.Counter("calls").Inc()
+				registry.Gauge("inflight").Add(1)
+				registry.Histogram("latency", LatencyBuckets).ObserveDuration(time.Millisecond)
+			}
+		}()
+	}
+	waiter.Wait()
+
+	var snap = registry.Snapshot()
+	require.Equal(t, int64(1000), snap.Counters["calls"])
+	require.Equal(t, int64(1000), snap.Gauges["inflight"])
+	require.Equal(t, uint64(1000), snap.Histograms["latency"].Count)
+	require.InDelta(t, 1000.0, snap.Histograms["latency"].Sum, 0.0001)
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var histogram = NewHistogram([]float64{10, 1, 100})
+	for i := 0; i < 90; i++ {
+		histogram.Observe(0.5)
+	}
+	for i := 0; i < 9; i++ {
+		histogram.Observe(50)
+	}
+	histogram.Observe(1000)
+
+	var snap = histogram.Snapshot()
+	require.Equal(t, []float64{1, 10, 100}, snap.Bounds)
+	require.Equal(t, []uint64{90, 0, 9, 1}, snap.Counts)
+	require.Equal(t, 1.0, snap.Quantile(0.5))
+	require.Equal(t, 100.0, snap.Quantile(0.99))
+	require.True(t, math.IsInf(snap.Quantile(1), 1))
+	require.InDelta(t, 14.95, snap.Mean(), 0.0001)
+}
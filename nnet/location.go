@@ -102,13 +102,17 @@ func (f DudLocationService) Get(address string) (Location, error) {
 
 type CampIPService struct {
 	Addr string
+
+	// Client sends the lookups, e.g a client using a Transport, defaults
+	// to http.DefaultClient.
+	Client *http.Client
 }
 
 func (f CampIPService) Get(address string) (Location, error) {
 	var lt Location
 
 	// Use campip service to get a JSON response
-	var response, err = http.Get(fmt.Sprintf("%s/%s", f.Addr, address))
+	var response, err = clientOrDefault(f.Client).Get(fmt.Sprintf("%s/%s", f.Addr, address))
 	if err != nil {
 		return lt, nerror.WrapOnly(err)
 	}
@@ -133,6 +137,10 @@ func (f CampIPService) Get(address string) (Location, error) {
 
 type IPStackService struct {
 	Token string
+
+	// Client sends the lookups, e.g a client using a Transport, defaults
+	// to http.DefaultClient.
+	Client *http.Client
 }
 
 func (f IPStackService) Get(address string) (Location, error) {
@@ -140,7 +148,7 @@ func (f IPStackService) Get(address string) (Location, error) {
 
 	// Use freegeoip.net to get a JSON response
 	// There is also /xml/ and /csv/ formats available
-	var response, err = http.Get(fmt.Sprintf("http://api.ipstack.com/%s?access_key=%s", address, f.Token))
+	var response, err = clientOrDefault(f.Client).Get(fmt.Sprintf("http://api.ipstack.com/%s?access_key=%s", address, f.Token))
 	if err != nil {
 		return lt, nerror.WrapOnly(err)
 	}
@@ -162,3 +170,10 @@ func (f IPStackService) Get(address string) (Location, error) {
 
 	return lt, nil
 }
+
+func clientOrDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
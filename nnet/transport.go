@@ -0,0 +1,357 @@
+package nnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/njson"
+	"github.com/influx6/npkg/nretries"
+	"github.com/influx6/npkg/ntrace"
+)
+
+// ErrBodyTooLarge is returned when the body of a request or response sent
+// through a Transport exceeds it's limit.
+var ErrBodyTooLarge = errors.New("body exceeds size limit")
+
+// IsBodyTooLarge returns true if giving error is or wraps ErrBodyTooLarge,
+// including errors wrapped by a http.Client.
+func IsBodyTooLarge(err error) bool {
+	for {
+		err = nerror.UnwrapDeep(err)
+		if err == ErrBodyTooLarge {
+			return true
+		}
+		if err = errors.Unwrap(err); err == nil {
+			return false
+		}
+	}
+}
+
+// TransportOption defines a function type which sets the options of a
+// Transport.
+type TransportOption func(*Transport)
+
+// RetryWith sets the options used to retry requests, e.g nretries.MaxAttempts
+// or nretries.BackOff. Defaults to the options of nretries.Retry, except
+// errors matching IsBodyTooLarge are not retried.
+func RetryWith(ops ...nretries.RetryOption) TransportOption {
+	return func(t *Transport) {
+		t.retries = append(t.retries, ops...)
+	}
+}
+
+// NoRetries disables retries, sending all requests once.
+func NoRetries() TransportOption {
+	return func(t *Transport) {
+		t.retries = append(t.retries, nretries.MaxAttempts(1))
+	}
+}
+
+// HostBreakers sets the options of the circuit breaker created for every
+// host, requests to a host whose breaker is open fail with an error
+// matching nretries.IsCircuitOpen. Errors matching IsBodyTooLarge are not
+// counted as failures by default.
+func HostBreakers(ops ...nretries.BreakerOption) TransportOption {
+	return func(t *Transport) {
+		t.breakerOps = append(t.breakerOps, ops...)
+	}
+}
+
+// NoBreakers disables circuit breaking.
+func NoBreakers() TransportOption {
+	return func(t *Transport) {
+		t.noBreakers = true
+	}
+}
+
+// AttemptTimeout sets the timeout of every attempt, including the time
+// spent reading the response body.
+func AttemptTimeout(d time.Duration) TransportOption {
+	return func(t *Transport) {
+		t.timeout = d
+	}
+}
+
+// MaxRequestBody sets the maximum size in bytes of request bodies, requests
+// exceeding it fail with an error matching IsBodyTooLarge.
+func MaxRequestBody(n int64) TransportOption {
+	return func(t *Transport) {
+		t.maxRequest = n
+	}
+}
+
+// MaxResponseBody sets the maximum size in bytes of response bodies, reading
+// past it fails with an error matching IsBodyTooLarge.
+func MaxResponseBody(n int64) TransportOption {
+	return func(t *Transport) {
+		t.maxResponse = n
+	}
+}
+
+// LogWith sets the logger every attempt is logged to.
+func LogWith(logger njson.Logger) TransportOption {
+	return func(t *Transport) {
+		t.logger = logger
+	}
+}
+
+// Transport implements a http.RoundTripper for outbound requests which
+// retries, circuit breaks, traces and logs the requests it sends through
+// an underline http.RoundTripper.
+//
+// Replayable requests are retried on transport errors and responses with
+// a retryable status, see nretries.IsReplayable and nretries.IsRetryableStatus,
+// others are sent once. Every host has it's own circuit breaker, counting
+// the same failures.
+//
+// When the request context holds a ntrace span, a child span is created for
+// the request and injected in it's headers.
+type Transport struct {
+	next        http.RoundTripper
+	retries     []nretries.RetryOption
+	breakerOps  []nretries.BreakerOption
+	noBreakers  bool
+	timeout     time.Duration
+	maxRequest  int64
+	maxResponse int64
+	logger      njson.Logger
+
+	lock     sync.Mutex
+	breakers map[string]*nretries.Breaker
+}
+
+// NewTransport returns a new Transport sending requests through next,
+// using http.DefaultTransport if next is nil.
+func NewTransport(next http.RoundTripper, ops ...TransportOption) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var t = &Transport{
+		next:       next,
+		retries:    []nretries.RetryOption{nretries.RetryIf(isRetryable)},
+		breakerOps: []nretries.BreakerOption{nretries.FailureWhen(isFailure)},
+		breakers:   map[string]*nretries.Breaker{},
+	}
+	for _, op := range ops {
+		op(t)
+	}
+	return t
+}
+
+// NewClient returns a new http.Client using a Transport sending requests
+// through http.DefaultTransport.
+func NewClient(ops ...TransportOption) *http.Client {
+	return &http.Client{Transport: NewTransport(nil, ops...)}
+}
+
+// Breaker returns the circuit breaker of giving host, or nil if circuit
+// breaking is disabled.
+func (t *Transport) Breaker(host string) *nretries.Breaker {
+	if t.noBreakers {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var breaker, ok = t.breakers[host]
+	if !ok {
+		breaker = nretries.NewBreaker(t.breakerOps...)
+		t.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// RoundTrip sends giving request, see Transport.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.maxRequest > 0 && req.ContentLength > t.maxRequest {
+		closeBody(req)
+		return nil, nerror.WrapOnly(ErrBodyTooLarge)
+	}
+
+	var ctx, span = ntrace.NewSpanFromContext(req.Context(), "HTTP "+req.Method)
+	if span != nil {
+		defer span.Finish()
+		ntrace.WithTag(span, "http.method", req.Method)
+		ntrace.WithTag(span, "http.url", req.URL.Redacted())
+	}
+
+	// the request is cloned as a http.RoundTripper must not modify it.
+	var base = req.Clone(ctx)
+	if span != nil {
+		_ = opentracing.GlobalTracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(base.Header))
+	}
+	if t.maxRequest > 0 && base.Body != nil && base.Body != http.NoBody {
+		base.Body = &limitedBody{ReadCloser: base.Body, left: t.maxRequest}
+	}
+
+	var res *http.Response
+	var attempt int
+	var send = func(_ context.Context) error {
+		if res != nil {
+			nretries.DiscardResponse(res)
+			res = nil
+		}
+
+		var attemptReq, err = t.replay(ctx, base, attempt)
+		attempt++
+		if err != nil {
+			return err
+		}
+
+		var start = time.Now()
+		res, err = t.send(attemptReq)
+		t.log(attemptReq, attempt, res, err, time.Since(start))
+		if err != nil {
+			return err
+		}
+		if nretries.IsRetryableStatus(res.StatusCode) {
+			return nerror.New("received retryable status %d", res.StatusCode)
+		}
+		return nil
+	}
+
+	var ops = t.retries
+	if !nretries.IsReplayable(base) {
+		ops = append(ops[:len(ops):len(ops)], nretries.MaxAttempts(1))
+	}
+	if breaker := t.Breaker(req.URL.Host); breaker != nil {
+		ops = append(ops[:len(ops):len(ops)], nretries.WithBreaker(breaker))
+	}
+
+	var err = nretries.Retry(ctx, send, ops...)
+	if span != nil {
+		ntrace.WithTag(span, "http.attempts", attempt)
+		if res != nil {
+			ntrace.WithTag(span, "http.status_code", res.StatusCode)
+		}
+		if res == nil && err != nil {
+			ntrace.WithTag(span, "error", true)
+		}
+	}
+
+	// responses with a retryable status are returned once retries end.
+	if res != nil {
+		return res, nil
+	}
+
+	// a request failing once returns it's error, as with http.RoundTripper.
+	if errs := nretries.AttemptErrors(err); len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, err
+}
+
+func isRetryable(err error) bool {
+	return nretries.IsRetryable(err) && !IsBodyTooLarge(err)
+}
+
+func isFailure(err error) bool {
+	return err != nil && nerror.UnwrapDeep(err) != context.Canceled && !IsBodyTooLarge(err)
+}
+
+// replay returns the request sent by giving attempt, limiting the size of
+// it's new body.
+func (t *Transport) replay(ctx context.Context, req *http.Request, attempt int) (*http.Request, error) {
+	var next, err = nretries.Replay(ctx, req, attempt)
+	if err != nil {
+		return nil, err
+	}
+	if attempt > 0 && t.maxRequest > 0 && next.Body != nil && next.Body != http.NoBody {
+		next.Body = &limitedBody{ReadCloser: next.Body, left: t.maxRequest}
+	}
+	return next, nil
+}
+
+// send sends giving request through the underline http.RoundTripper,
+// applying the attempt timeout and response body limit.
+func (t *Transport) send(req *http.Request) (*http.Response, error) {
+	var cancel = func() {}
+	if t.timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), t.timeout)
+		req = req.WithContext(ctx)
+	}
+
+	var res, err = t.next.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, nerror.WrapOnly(err)
+	}
+
+	if t.maxResponse > 0 && res.ContentLength > t.maxResponse {
+		nretries.DiscardResponse(res)
+		cancel()
+		return nil, nerror.WrapOnly(ErrBodyTooLarge)
+	}
+
+	var body = res.Body
+	if t.maxResponse > 0 {
+		body = &limitedBody{ReadCloser: body, left: t.maxResponse}
+	}
+	res.Body = nretries.CancelOnClose(body, cancel)
+	return res, nil
+}
+
+func (t *Transport) log(req *http.Request, attempt int, res *http.Response, err error, elapsed time.Duration) {
+	if t.logger == nil {
+		return
+	}
+
+	var stack = njson.Log(t.logger).New()
+	if err != nil || nretries.IsRetryableStatus(res.StatusCode) {
+		stack.LError()
+	} else {
+		stack.LInfo()
+	}
+
+	stack.Message("sent http request").
+		String("method", req.Method).
+		String("url", req.URL.Redacted()).
+		Int("attempt", attempt).
+		Int64("duration_ms", elapsed.Milliseconds())
+	if res != nil {
+		stack.Int("status", res.StatusCode).
+			Int64("content_length", res.ContentLength)
+	}
+	if err != nil {
+		stack.String("error", err.Error())
+	}
+	stack.End()
+}
+
+// limitedBody fails reads once more than left bytes were read.
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, nerror.WrapOnly(ErrBodyTooLarge)
+	}
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+
+	var n, err = l.ReadCloser.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n + int(l.left), nerror.WrapOnly(ErrBodyTooLarge)
+	}
+	return n, err
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
@@ -0,0 +1,172 @@
+package nnet
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nretries"
+)
+
+func noBackOff(_ int) time.Duration {
+	return 0
+}
+
+func TestTransport_Retries(t *testing.T) {
+	var lock sync.Mutex
+	var bodies []string
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body, _ = ioutil.ReadAll(r.Body)
+		lock.Lock()
+		bodies = append(bodies, r.Method+" "+string(body))
+		var count = len(bodies)
+		lock.Unlock()
+
+		if count < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("stored"))
+	}))
+	defer server.Close()
+
+	var client = NewClient(RetryWith(nretries.MaxAttempts(3), nretries.BackOff(noBackOff)), NoBreakers())
+
+	// the body of replayable requests is sent again.
+	var req, err = http.NewRequest(http.MethodPut, server.URL, strings.NewReader("config"))
+	require.NoError(t, err)
+	var res *http.Response
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	var body, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "stored", string(body))
+	require.Equal(t, []string{"PUT config", "PUT config", "PUT config"}, bodies)
+
+	// requests which are not idempotent are sent once.
+	bodies = nil
+	res, err = client.Post(server.URL, "text/plain", strings.NewReader("order"))
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.Equal(t, []string{"POST order"}, bodies)
+}
+
+func TestTransport_BodyLimits(t *testing.T) {
+	var hits int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = w.Write([]byte(strings.Repeat("a", 64)))
+	}))
+	defer server.Close()
+
+	var client = NewClient(MaxRequestBody(8), MaxResponseBody(32), NoBreakers())
+
+	var _, err = client.Post(server.URL, "text/plain", strings.NewReader("a large body"))
+	require.True(t, IsBodyTooLarge(err))
+	require.Equal(t, int32(0), atomic.LoadInt32(&hits))
+
+	// the response is rejected from it's content length.
+	_, err = client.Get(server.URL)
+	require.True(t, IsBodyTooLarge(err))
+	require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestTransport_Breaker(t *testing.T) {
+	var hits int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	var transport = NewTransport(nil, NoRetries(),
+		HostBreakers(nretries.MinCalls(2), nretries.FailureRatio(0.5), nretries.CoolDown(time.Hour)))
+	var client = &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		var res, err = client.Get(server.URL)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+	}
+
+	var host = strings.TrimPrefix(server.URL, "http://")
+	require.Equal(t, nretries.StateOpen, transport.Breaker(host).State())
+
+	var _, err = client.Get(server.URL)
+	require.True(t, nretries.IsCircuitOpen(err))
+	require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestTransport_Budget(t *testing.T) {
+	// a closed server refuses all connections.
+	var server = httptest.NewServer(http.NotFoundHandler())
+	var url = server.URL
+	server.Close()
+
+	var attempts int32
+	var budget = nretries.NewBudget(0, nretries.MinRetries(1))
+	var client = NewClient(NoBreakers(), RetryWith(
+		nretries.MaxAttempts(5),
+		nretries.BackOff(noBackOff),
+		nretries.WithBudget(budget),
+		nretries.OnRetry(func(_ int, _ error, _ time.Duration) {
+			atomic.AddInt32(&attempts, 1)
+		}),
+	))
+
+	var _, err = client.Get(url)
+	require.True(t, nretries.IsBudgetExhausted(err))
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// the budget is exhausted, the next request is not retried.
+	_, err = client.Get(url)
+	require.True(t, nretries.IsBudgetExhausted(err))
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	var requests, retries = budget.Counts()
+	require.Equal(t, 2, requests)
+	require.Equal(t, 1, retries)
+}
+
+func TestTransport_Hedging(t *testing.T) {
+	var hits int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request stalls until it is cancelled.
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("hedged"))
+	}))
+	defer server.Close()
+
+	var hedger = nretries.NewHedger(nretries.HedgeDelay(20 * time.Millisecond))
+	var client = &http.Client{Transport: NewTransport(nretries.HedgeTransport(http.DefaultTransport, hedger), NoBreakers())}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var req, err = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	var res *http.Response
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	var body, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.Equal(t, "hedged", string(body))
+	require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
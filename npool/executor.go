@@ -0,0 +1,118 @@
+// Package npool implements executors running tasks on bounded pools of
+// worker goroutines, with bounded priority queues and rejection policies.
+package npool
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+var (
+	// ErrRejected is returned when a task is rejected as the queue of the
+	// pool is full.
+	ErrRejected = errors.New("task rejected, queue is full")
+
+	// ErrDiscarded is given to the OnDrop function of queued tasks discarded
+	// for newer tasks, see DiscardOldest.
+	ErrDiscarded = errors.New("task discarded for a newer task")
+
+	// ErrShutdown is returned when a task is submitted to a pool which is
+	// shut down, and given to the OnDrop function of queued tasks canceled
+	// by a shutdown.
+	ErrShutdown = errors.New("pool is shut down")
+)
+
+// IsRejected returns true if giving error is or wraps ErrRejected or
+// ErrDiscarded.
+func IsRejected(err error) bool {
+	var cause = nerror.UnwrapDeep(err)
+	return cause == ErrRejected || cause == ErrDiscarded
+}
+
+// IsShutdown returns true if giving error is or wraps ErrShutdown.
+func IsShutdown(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrShutdown
+}
+
+// Task defines a function run by an Executor with it's settings.
+type Task struct {
+	// Run is the function of the task, the context given is done once the
+	// context of the submitter is done, the deadline passed or the task is
+	// canceled by a shutdown.
+	Run func(ctx context.Context)
+
+	// Priority orders queued tasks, tasks of a higher priority run first
+	// and tasks of the same priority in the order they were submitted.
+	Priority int
+
+	// Deadline sets the time by which the task must have run, it is
+	// dropped if it is still queued then. A zero time sets no deadline.
+	Deadline time.Time
+
+	// OnDrop is called with the reason a task accepted by an executor will
+	// not run, e.g ErrShutdown or the error of it's context, so submitters
+	// waiting for it can stop waiting.
+	OnDrop func(err error)
+}
+
+func (t Task) drop(err error) {
+	if t.OnDrop != nil {
+		t.OnDrop(err)
+	}
+}
+
+// context returns the context the task runs with, applying it's deadline.
+func (t Task) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.Deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, t.Deadline)
+}
+
+// expired returns the error of tasks which can no longer run.
+func (t Task) expired(ctx context.Context, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if !t.Deadline.IsZero() && !now.Before(t.Deadline) {
+		return nerror.WrapOnly(context.DeadlineExceeded)
+	}
+	return nil
+}
+
+// Executor defines a runner of tasks.
+type Executor interface {
+	// Execute runs giving task, possibly later, returning an error if it
+	// is rejected. Tasks accepted either run or are dropped with their
+	// OnDrop function called.
+	Execute(ctx context.Context, task Task) error
+}
+
+// Go runs giving function on the executor, it is a shorthand for
+// executing a Task with no settings.
+func Go(ctx context.Context, executor Executor, fn func(ctx context.Context)) error {
+	return executor.Execute(ctx, Task{Run: fn})
+}
+
+var _ Executor = GoExecutor{}
+
+// GoExecutor implements an Executor running every task in a new
+// goroutine, it never rejects tasks.
+type GoExecutor struct{}
+
+// Execute runs giving task in a new goroutine.
+func (GoExecutor) Execute(ctx context.Context, task Task) error {
+	if err := task.expired(ctx, time.Now()); err != nil {
+		return err
+	}
+
+	go func() {
+		var taskCtx, cancel = task.context(ctx)
+		defer cancel()
+		task.Run(taskCtx)
+	}()
+	return nil
+}
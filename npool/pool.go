@@ -0,0 +1,506 @@
+package npool
+
+import (
+	"container/heap"
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// RejectPolicy defines what a Pool does with tasks submitted while it's
+// queue is full.
+type RejectPolicy int
+
+// Rejection policies.
+const (
+	// Abort rejects the task, returning ErrRejected.
+	Abort RejectPolicy = iota
+
+	// CallerRuns runs the task in the goroutine submitting it, slowing
+	// submitters down to the pace of the pool.
+	CallerRuns
+
+	// Block waits for the queue to have room, or the context of the task
+	// to be done.
+	Block
+
+	// DiscardOldest drops the oldest queued task of the lowest priority,
+	// calling it's OnDrop function with ErrDiscarded.
+	DiscardOldest
+)
+
+// String returns the name of the policy.
+func (r RejectPolicy) String() string {
+	switch r {
+	case Abort:
+		return "abort"
+	case CallerRuns:
+		return "caller-runs"
+	case Block:
+		return "block"
+	case DiscardOldest:
+		return "discard-oldest"
+	default:
+		return "unknown"
+	}
+}
+
+// PoolOption defines a function type which sets the options of a Pool.
+type PoolOption func(*Pool)
+
+// Workers sets a fixed number of workers, defaults to runtime.GOMAXPROCS.
+func Workers(n int) PoolOption {
+	return func(p *Pool) {
+		p.min = n
+		p.max = n
+	}
+}
+
+// Elastic sets the pool to keep min workers, starting more up to max
+// while tasks are queued. Workers above min stop after being idle for
+// giving duration.
+func Elastic(min int, max int, idle time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.min = min
+		p.max = max
+		p.idle = idle
+	}
+}
+
+// QueueSize sets the number of tasks queued while all workers are busy,
+// defaults to 1024. A size of 0 sets an unbounded queue.
+func QueueSize(n int) PoolOption {
+	return func(p *Pool) {
+		p.size = n
+	}
+}
+
+// Reject sets the policy for tasks submitted while the queue is full,
+// defaults to Abort.
+func Reject(policy RejectPolicy) PoolOption {
+	return func(p *Pool) {
+		p.policy = policy
+	}
+}
+
+// OnPanic sets a function called with the value a task panicked with,
+// keeping it's worker running. Panics of tasks are not recovered by default.
+func OnPanic(fn func(recovered interface{})) PoolOption {
+	return func(p *Pool) {
+		p.onPanic = fn
+	}
+}
+
+// Stats holds the state and counts of a Pool.
+type Stats struct {
+	Workers   int
+	Idle      int
+	Busy      int
+	Queued    int
+	Completed uint64
+	Rejected  uint64
+	Dropped   uint64
+}
+
+type poolState int
+
+const (
+	poolRunning poolState = iota
+	poolDraining
+	poolStopped
+)
+
+type queuedTask struct {
+	task Task
+	ctx  context.Context
+	seq  uint64
+}
+
+// taskHeap orders tasks by priority, then by order of submission.
+type taskHeap []*queuedTask
+
+func (h taskHeap) Len() int {
+	return len(h)
+}
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].task.Priority != h[j].task.Priority {
+		return h[i].task.Priority > h[j].task.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+// oldestLowest returns the index of the oldest task of the lowest
+// priority, the task which would run last among it's priority.
+func (h taskHeap) oldestLowest() int {
+	var index int
+	for i := 1; i < len(h); i++ {
+		if h[i].task.Priority < h[index].task.Priority ||
+			(h[i].task.Priority == h[index].task.Priority && h[i].seq < h[index].seq) {
+			index = i
+		}
+	}
+	return index
+}
+
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	*h = append(*h, x.(*queuedTask))
+}
+
+func (h *taskHeap) Pop() interface{} {
+	var old = *h
+	var last = old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return last
+}
+
+var _ Executor = (*Pool)(nil)
+
+// Pool implements an Executor running tasks on a bounded number of worker
+// goroutines, queueing tasks in a bounded priority queue while all workers
+// are busy.
+//
+// A Pool must be shut down with Shutdown, which drains queued tasks, or
+// ShutdownNow, which cancels them.
+type Pool struct {
+	min     int
+	max     int
+	idle    time.Duration
+	size    int
+	policy  RejectPolicy
+	onPanic func(interface{})
+
+	lock    sync.Mutex
+	ready   *sync.Cond
+	space   *sync.Cond
+	queue   taskHeap
+	seq     uint64
+	state   poolState
+	workers int
+	idlers  int
+	busy    int
+	minIdle int
+	reap    int
+	running map[uint64]context.CancelFunc
+	stats   Stats
+
+	waiter   sync.WaitGroup
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPool returns a new Pool, starting it's workers.
+func NewPool(ops ...PoolOption) *Pool {
+	var p = &Pool{
+		min:     runtime.GOMAXPROCS(0),
+		max:     runtime.GOMAXPROCS(0),
+		size:    1024,
+		running: map[uint64]context.CancelFunc{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, op := range ops {
+		op(p)
+	}
+	if p.max < 1 {
+		p.max = 1
+	}
+	if p.min > p.max {
+		p.min = p.max
+	}
+	if p.min < 0 {
+		p.min = 0
+	}
+
+	p.ready = sync.NewCond(&p.lock)
+	p.space = sync.NewCond(&p.lock)
+
+	p.lock.Lock()
+	for p.workers < p.min {
+		p.spawn()
+	}
+	p.lock.Unlock()
+
+	if p.max > p.min && p.idle > 0 {
+		go p.reaper()
+	}
+	return p
+}
+
+// Execute queues giving task to run on a worker, applying the reject
+// policy if the queue is full. It returns ErrShutdown if the pool is shut
+// down, and the error of the context if it is done.
+func (p *Pool) Execute(ctx context.Context, task Task) error {
+	if task.Run == nil {
+		return nerror.New("task has no Run function")
+	}
+
+	var discarded []*queuedTask
+	defer func() {
+		for _, qt := range discarded {
+			qt.task.drop(nerror.WrapOnly(ErrDiscarded))
+		}
+	}()
+
+	var stopWatch chan struct{}
+	defer func() {
+		if stopWatch != nil {
+			close(stopWatch)
+		}
+	}()
+
+	p.lock.Lock()
+	for p.size > 0 && len(p.queue) >= p.size {
+		if p.state != poolRunning {
+			break
+		}
+		if err := task.expired(ctx, time.Now()); err != nil {
+			p.lock.Unlock()
+			return err
+		}
+
+		switch p.policy {
+		case CallerRuns:
+			p.seq++
+			var qt = &queuedTask{task: task, ctx: ctx, seq: p.seq}
+			var cancel = p.begin(qt)
+			p.lock.Unlock()
+			p.run(qt, cancel)
+			return nil
+		case DiscardOldest:
+			discarded = append(discarded, heap.Remove(&p.queue, p.queue.oldestLowest()).(*queuedTask))
+			p.stats.Dropped++
+		case Block:
+			if stopWatch == nil {
+				stopWatch = make(chan struct{})
+				go p.wakeOnDone(ctx, stopWatch)
+			}
+			p.space.Wait()
+		default:
+			p.stats.Rejected++
+			p.lock.Unlock()
+			return nerror.WrapOnly(ErrRejected)
+		}
+	}
+
+	if p.state != poolRunning {
+		p.lock.Unlock()
+		return nerror.WrapOnly(ErrShutdown)
+	}
+	if err := task.expired(ctx, time.Now()); err != nil {
+		p.lock.Unlock()
+		return err
+	}
+
+	p.seq++
+	heap.Push(&p.queue, &queuedTask{task: task, ctx: ctx, seq: p.seq})
+	if len(p.queue) > p.idlers && p.workers < p.max {
+		p.spawn()
+	}
+	p.ready.Signal()
+	p.lock.Unlock()
+	return nil
+}
+
+// Go runs giving function on a worker, see Execute.
+func (p *Pool) Go(ctx context.Context, fn func(ctx context.Context)) error {
+	return p.Execute(ctx, Task{Run: fn})
+}
+
+// wakeOnDone wakes submitters blocked on a full queue once giving context
+// is done.
+func (p *Pool) wakeOnDone(ctx context.Context, stop chan struct{}) {
+	select {
+	case <-ctx.Done():
+		p.lock.Lock()
+		p.space.Broadcast()
+		p.lock.Unlock()
+	case <-stop:
+	}
+}
+
+// Stats returns the state and counts of the pool.
+func (p *Pool) Stats() Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var stats = p.stats
+	stats.Workers = p.workers
+	stats.Idle = p.idlers
+	stats.Busy = p.busy
+	stats.Queued = len(p.queue)
+	return stats
+}
+
+// Shutdown stops accepting tasks and waits for queued and running tasks
+// to complete. If giving context is done first, the pool is shut down as
+// with ShutdownNow and the error of the context is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.lock.Lock()
+	if p.state == poolRunning {
+		p.state = poolDraining
+	}
+	p.ready.Broadcast()
+	p.space.Broadcast()
+	p.lock.Unlock()
+	p.stopOnce.Do(p.stopWorkers)
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.ShutdownNow()
+		return nerror.WrapOnly(ctx.Err())
+	}
+}
+
+// ShutdownNow stops accepting tasks, drops queued tasks calling their
+// OnDrop function with ErrShutdown and cancels the context of running
+// tasks, waiting for them to return.
+func (p *Pool) ShutdownNow() {
+	p.lock.Lock()
+	p.state = poolStopped
+	var queued = p.queue
+	p.queue = nil
+	p.stats.Dropped += uint64(len(queued))
+	for _, cancel := range p.running {
+		cancel()
+	}
+	p.ready.Broadcast()
+	p.space.Broadcast()
+	p.lock.Unlock()
+	p.stopOnce.Do(p.stopWorkers)
+
+	// queued tasks are dropped in the order they would have run.
+	sort.Sort(queued)
+	for _, qt := range queued {
+		qt.task.drop(nerror.WrapOnly(ErrShutdown))
+	}
+	<-p.done
+}
+
+func (p *Pool) stopWorkers() {
+	close(p.stop)
+	go func() {
+		p.waiter.Wait()
+		close(p.done)
+	}()
+}
+
+// spawn starts a worker, it must be called with the lock held.
+func (p *Pool) spawn() {
+	p.workers++
+	p.waiter.Add(1)
+	go p.work()
+}
+
+// reaper stops workers above the minimum which were idle for a whole idle
+// period.
+func (p *Pool) reaper() {
+	var ticker = time.NewTicker(p.idle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.lock.Lock()
+		var excess = p.workers - p.min
+		if p.minIdle < excess {
+			excess = p.minIdle
+		}
+		if excess > 0 {
+			p.reap = excess
+			p.ready.Broadcast()
+		}
+		p.minIdle = p.idlers
+		p.lock.Unlock()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.waiter.Done()
+
+	// the lock is not released with defer, as a panic of a task unwinds
+	// this function without holding it.
+	p.lock.Lock()
+	for {
+		p.idlers++
+		for len(p.queue) == 0 {
+			if p.state != poolRunning || (p.reap > 0 && p.workers > p.min) {
+				if p.reap > 0 {
+					p.reap--
+				}
+				p.idlers--
+				p.workers--
+				p.lock.Unlock()
+				return
+			}
+			p.ready.Wait()
+		}
+		p.idlers--
+		if p.idlers < p.minIdle {
+			p.minIdle = p.idlers
+		}
+
+		var qt = heap.Pop(&p.queue).(*queuedTask)
+		p.space.Signal()
+
+		if err := qt.task.expired(qt.ctx, time.Now()); err != nil {
+			p.stats.Dropped++
+			p.lock.Unlock()
+			qt.task.drop(err)
+			p.lock.Lock()
+			continue
+		}
+
+		var cancel = p.begin(qt)
+		p.lock.Unlock()
+		p.run(qt, cancel)
+		p.lock.Lock()
+	}
+}
+
+// begin registers giving task as running, returning the function canceling
+// it's context. It must be called with the lock held.
+func (p *Pool) begin(qt *queuedTask) context.CancelFunc {
+	var ctx, cancel = qt.task.context(qt.ctx)
+	qt.ctx = ctx
+	p.running[qt.seq] = cancel
+	p.busy++
+	return cancel
+}
+
+func (p *Pool) run(qt *queuedTask, cancel context.CancelFunc) {
+	defer func() {
+		cancel()
+
+		p.lock.Lock()
+		delete(p.running, qt.seq)
+		p.busy--
+		p.stats.Completed++
+		p.lock.Unlock()
+	}()
+
+	if p.onPanic != nil {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				p.onPanic(recovered)
+			}
+		}()
+	}
+	qt.task.Run(qt.ctx)
+}
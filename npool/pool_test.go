@@ -0,0 +1,272 @@
+package npool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gate blocks tasks until it is opened.
+type gate chan struct{}
+
+func (g gate) wait(_ context.Context) {
+	<-g
+}
+
+// fill occupies all workers of giving pool with tasks waiting on a gate,
+// returning once they all run.
+func fill(t *testing.T, p *Pool, workers int) gate {
+	var g = make(gate)
+	var started sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		started.Add(1)
+		require.NoError(t, p.Go(context.Background(), func(ctx context.Context) {
+			started.Done()
+			g.wait(ctx)
+		}))
+	}
+	started.Wait()
+	return g
+}
+
+func TestPoolBoundsWorkers(t *testing.T) {
+	var p = NewPool(Workers(4), QueueSize(0))
+
+	var current, peak int64
+	var waiter sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		waiter.Add(1)
+		require.NoError(t, p.Go(context.Background(), func(_ context.Context) {
+			defer waiter.Done()
+			var now = atomic.AddInt64(&current, 1)
+			for {
+				var last = atomic.LoadInt64(&peak)
+				if now <= last || atomic.CompareAndSwapInt64(&peak, last, now) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&current, -1)
+		}))
+	}
+	waiter.Wait()
+
+	require.True(t, atomic.LoadInt64(&peak) <= 4)
+	require.Equal(t, 4, p.Stats().Workers)
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.Equal(t, uint64(100), p.Stats().Completed)
+}
+
+func TestPoolPriority(t *testing.T) {
+	var p = NewPool(Workers(1))
+	var g = fill(t, p, 1)
+
+	var lock sync.Mutex
+	var order []int
+	for _, priority := range []int{1, 5, 1, 10} {
+		var priority = priority
+		require.NoError(t, p.Execute(context.Background(), Task{
+			Priority: priority,
+			Run: func(_ context.Context) {
+				lock.Lock()
+				order = append(order, priority)
+				lock.Unlock()
+			},
+		}))
+	}
+
+	close(g)
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.Equal(t, []int{10, 5, 1, 1}, order)
+}
+
+func TestPoolAbort(t *testing.T) {
+	var p = NewPool(Workers(1), QueueSize(1))
+	var g = fill(t, p, 1)
+
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {}))
+	var err = p.Go(context.Background(), func(_ context.Context) {})
+	require.True(t, IsRejected(err))
+	require.Equal(t, uint64(1), p.Stats().Rejected)
+
+	close(g)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestPoolCallerRuns(t *testing.T) {
+	var p = NewPool(Workers(1), QueueSize(1), Reject(CallerRuns))
+	var g = fill(t, p, 1)
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {}))
+
+	var ran bool
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {
+		ran = true
+	}))
+	require.True(t, ran)
+
+	close(g)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestPoolDiscardOldest(t *testing.T) {
+	var p = NewPool(Workers(1), QueueSize(1), Reject(DiscardOldest))
+	var g = fill(t, p, 1)
+
+	var dropped = make(chan error, 1)
+	require.NoError(t, p.Execute(context.Background(), Task{
+		Run:    func(_ context.Context) {},
+		OnDrop: func(err error) { dropped <- err },
+	}))
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {}))
+	require.True(t, IsRejected(<-dropped))
+
+	close(g)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestPoolDiscardOldestPriority(t *testing.T) {
+	var p = NewPool(Workers(1), QueueSize(3), Reject(DiscardOldest))
+	var g = fill(t, p, 1)
+
+	var lock sync.Mutex
+	var ran, dropped []string
+	var submit = func(name string, priority int) {
+		require.NoError(t, p.Execute(context.Background(), Task{
+			Priority: priority,
+			Run: func(_ context.Context) {
+				lock.Lock()
+				ran = append(ran, name)
+				lock.Unlock()
+			},
+			OnDrop: func(_ error) {
+				lock.Lock()
+				dropped = append(dropped, name)
+				lock.Unlock()
+			},
+		}))
+	}
+
+	// the oldest task of the lowest priority is dropped, not the next one.
+	submit("critical", 10)
+	submit("low", 0)
+	submit("later-low", 0)
+	submit("normal", 5)
+	submit("high", 8)
+
+	close(g)
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.Equal(t, []string{"low", "later-low"}, dropped)
+	require.Equal(t, []string{"critical", "high", "normal"}, ran)
+}
+
+func TestPoolBlock(t *testing.T) {
+	var p = NewPool(Workers(1), QueueSize(1), Reject(Block))
+	var g = fill(t, p, 1)
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {}))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.Error(t, p.Go(ctx, func(_ context.Context) {}))
+
+	var submitted = make(chan error, 1)
+	go func() {
+		submitted <- p.Go(context.Background(), func(_ context.Context) {})
+	}()
+	close(g)
+	require.NoError(t, <-submitted)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestPoolDeadline(t *testing.T) {
+	var p = NewPool(Workers(1))
+	var g = fill(t, p, 1)
+
+	var dropped = make(chan error, 1)
+	require.NoError(t, p.Execute(context.Background(), Task{
+		Deadline: time.Now().Add(10 * time.Millisecond),
+		Run:      func(_ context.Context) { t.Error("task past it's deadline ran") },
+		OnDrop:   func(err error) { dropped <- err },
+	}))
+
+	time.Sleep(20 * time.Millisecond)
+	close(g)
+	require.Error(t, <-dropped)
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.Equal(t, uint64(1), p.Stats().Dropped)
+}
+
+func TestPoolShutdownNow(t *testing.T) {
+	var p = NewPool(Workers(1))
+
+	var started = make(chan struct{})
+	var canceled = make(chan struct{})
+	require.NoError(t, p.Go(context.Background(), func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+	}))
+	<-started
+
+	var dropped = make(chan error, 1)
+	require.NoError(t, p.Execute(context.Background(), Task{
+		Run:    func(_ context.Context) {},
+		OnDrop: func(err error) { dropped <- err },
+	}))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.Error(t, p.Shutdown(ctx))
+
+	<-canceled
+	require.True(t, IsShutdown(<-dropped))
+	require.True(t, IsShutdown(p.Go(context.Background(), func(_ context.Context) {})))
+}
+
+func TestPoolElastic(t *testing.T) {
+	var p = NewPool(Elastic(1, 4, 20*time.Millisecond))
+	require.Equal(t, 1, p.Stats().Workers)
+
+	var g = fill(t, p, 4)
+	require.Equal(t, 4, p.Stats().Workers)
+	close(g)
+
+	require.Eventually(t, func() bool {
+		return p.Stats().Workers == 1
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestPoolOnPanic(t *testing.T) {
+	var recovered = make(chan interface{}, 1)
+	var p = NewPool(Workers(1), OnPanic(func(r interface{}) {
+		recovered <- r
+	}))
+
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {
+		panic("bad task")
+	}))
+	require.Equal(t, "bad task", <-recovered)
+
+	var ran = make(chan struct{})
+	require.NoError(t, p.Go(context.Background(), func(_ context.Context) {
+		close(ran)
+	}))
+	<-ran
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestGoExecutor(t *testing.T) {
+	var ran = make(chan struct{})
+	require.NoError(t, Go(context.Background(), GoExecutor{}, func(_ context.Context) {
+		close(ran)
+	}))
+	<-ran
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, Go(ctx, GoExecutor{}, func(_ context.Context) {}))
+}
@@ -0,0 +1,454 @@
+package nrates
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nexec"
+)
+
+// ErrOverloaded is returned when work is rejected because too much work
+// is already in flight.
+var ErrOverloaded = errors.New("too much work in flight, try again later")
+
+// IsOverloaded returns true if giving error is or wraps ErrOverloaded.
+func IsOverloaded(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrOverloaded
+}
+
+// Permit is a slot of in-flight work acquired from a ConcurrencyLimiter,
+// it must be released once the work is done.
+type Permit interface {
+	// Release releases the slot with the error the work ended with, which
+	// adaptive limiters use as a signal of overload.
+	Release(err error)
+}
+
+// ConcurrencyLimiter limits the amount of work in flight.
+type ConcurrencyLimiter interface {
+	// Acquire returns a Permit if work may start, else an error
+	// matching IsOverloaded or the error of the context.
+	Acquire(ctx context.Context) (Permit, error)
+
+	// Limit returns the current maximum of work in flight.
+	Limit() int
+
+	// InFlight returns the current amount of work in flight.
+	InFlight() int
+}
+
+// ReleaseOnPanic releases giving permit with an error describing the
+// panic of the work, then panics again, so a panicking work does not
+// leak it's permit. It must be deferred right after acquiring the permit:
+//
+//	defer nrates.ReleaseOnPanic(permit)
+//	var err = work(ctx)
+//	permit.Release(err)
+func ReleaseOnPanic(permit Permit) {
+	if r := recover(); r != nil {
+		permit.Release(nerror.New("work panicked: %v", r))
+		panic(r)
+	}
+}
+
+// Do runs fn once a permit is acquired from limiter, releasing it with
+// the error returned by fn.
+func Do(ctx context.Context, limiter ConcurrencyLimiter, fn func(ctx context.Context) error) error {
+	var permit, err = limiter.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer ReleaseOnPanic(permit)
+
+	var fnErr = fn(ctx)
+	permit.Release(fnErr)
+	return fnErr
+}
+
+// Executor defines a type which executes a command, as nexec.Commander does.
+type Executor interface {
+	Exec(ctx context.Context) (int, error)
+}
+
+var _ Executor = (*nexec.Commander)(nil)
+
+// LimitedExecutor runs an Executor only once a permit is acquired
+// from a ConcurrencyLimiter.
+type LimitedExecutor struct {
+	limiter  ConcurrencyLimiter
+	executor Executor
+}
+
+// LimitExecutor returns a LimitedExecutor running executor, e.g a
+// nexec.Commander, within giving limiter.
+func LimitExecutor(limiter ConcurrencyLimiter, executor Executor) *LimitedExecutor {
+	return &LimitedExecutor{limiter: limiter, executor: executor}
+}
+
+// Exec acquires a permit and executes the underline executor, returning
+// -1 with the error if no permit is acquired.
+func (l *LimitedExecutor) Exec(ctx context.Context) (int, error) {
+	var permit, err = l.limiter.Acquire(ctx)
+	if err != nil {
+		return -1, err
+	}
+	defer ReleaseOnPanic(permit)
+
+	var code, execErr = l.executor.Exec(ctx)
+	permit.Release(execErr)
+	return code, execErr
+}
+
+//**********************************************************************
+// Semaphore
+//**********************************************************************
+
+var _ ConcurrencyLimiter = (*Semaphore)(nil)
+
+// SemaphoreOption defines a function type which sets the options of a
+// Semaphore.
+type SemaphoreOption func(*Semaphore)
+
+// MaxWaiting sets the maximum number of callers waiting for a permit,
+// further callers are rejected with ErrOverloaded. Defaults to no maximum,
+// a negative value rejects callers as soon as no permit is available.
+func MaxWaiting(n int) SemaphoreOption {
+	return func(s *Semaphore) {
+		s.maxWaiting = n
+	}
+}
+
+// Semaphore limits work in flight to a fixed limit, callers wait for a
+// permit until their context is done.
+type Semaphore struct {
+	limit      int
+	maxWaiting int
+	slots      chan struct{}
+
+	lock    sync.Mutex
+	waiting int
+}
+
+// NewSemaphore returns a new Semaphore allowing limit work in flight, a
+// limit below 1 is raised to 1.
+func NewSemaphore(limit int, ops ...SemaphoreOption) *Semaphore {
+	if limit < 1 {
+		limit = 1
+	}
+	var s = &Semaphore{limit: limit, slots: make(chan struct{}, limit)}
+	for _, op := range ops {
+		op(s)
+	}
+	return s
+}
+
+// Limit returns the maximum work in flight.
+func (s *Semaphore) Limit() int {
+	return s.limit
+}
+
+// InFlight returns the current work in flight.
+func (s *Semaphore) InFlight() int {
+	return len(s.slots)
+}
+
+// Acquire waits for a permit until giving context is done.
+func (s *Semaphore) Acquire(ctx context.Context) (Permit, error) {
+	select {
+	case s.slots <- struct{}{}:
+		return &semaphorePermit{s: s}, nil
+	default:
+	}
+
+	s.lock.Lock()
+	if s.maxWaiting < 0 || (s.maxWaiting > 0 && s.waiting >= s.maxWaiting) {
+		s.lock.Unlock()
+		return nil, nerror.WrapOnly(ErrOverloaded)
+	}
+	s.waiting++
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.waiting--
+		s.lock.Unlock()
+	}()
+
+	select {
+	case s.slots <- struct{}{}:
+		return &semaphorePermit{s: s}, nil
+	case <-ctx.Done():
+		return nil, nerror.WrapOnly(ctx.Err())
+	}
+}
+
+type semaphorePermit struct {
+	s    *Semaphore
+	once sync.Once
+}
+
+func (p *semaphorePermit) Release(_ error) {
+	p.once.Do(func() {
+		<-p.s.slots
+	})
+}
+
+//**********************************************************************
+// AdaptiveLimiter
+//**********************************************************************
+
+var _ ConcurrencyLimiter = (*AdaptiveLimiter)(nil)
+
+// AdaptiveOption defines a function type which sets the options of an
+// AdaptiveLimiter.
+type AdaptiveOption func(*AdaptiveLimiter)
+
+// InitialLimit sets the starting limit, defaults to 20.
+func InitialLimit(limit int) AdaptiveOption {
+	return func(a *AdaptiveLimiter) {
+		a.limit = float64(limit)
+	}
+}
+
+// LimitBounds sets the minimum and maximum of the limit, defaults
+// to 1 and 1000.
+func LimitBounds(min int, max int) AdaptiveOption {
+	return func(a *AdaptiveLimiter) {
+		a.min = float64(min)
+		a.max = float64(max)
+	}
+}
+
+// LatencyThreshold sets the latency above which work is considered a
+// sign of overload, defaults to 0 where only errors are.
+func LatencyThreshold(d time.Duration) AdaptiveOption {
+	return func(a *AdaptiveLimiter) {
+		a.threshold = d
+	}
+}
+
+// BackoffRatio sets the ratio the limit is multiplied by on overload,
+// defaults to 0.9.
+func BackoffRatio(ratio float64) AdaptiveOption {
+	return func(a *AdaptiveLimiter) {
+		a.backoff = ratio
+	}
+}
+
+// OverloadWhen sets the function deciding if an error returned by work
+// is a sign of overload, defaults to IsOverloadError.
+func OverloadWhen(fn func(error) bool) AdaptiveOption {
+	return func(a *AdaptiveLimiter) {
+		a.isOverload = fn
+	}
+}
+
+// IsOverloadError returns true for errors matching IsOverloaded and
+// context deadlines.
+func IsOverloadError(err error) bool {
+	var root = nerror.UnwrapDeep(err)
+	return root == ErrOverloaded || root == context.DeadlineExceeded
+}
+
+// AdaptiveLimiter limits work in flight to a limit adjusted with additive
+// increase, multiplicative decrease (AIMD): the limit grows by one for
+// every successful work done while the limiter is close to saturation, and
+// is multiplied by the backoff ratio for every work which ends with an
+// overload error or takes longer than the latency threshold.
+//
+// Work above the limit is rejected immediately with ErrOverloaded.
+type AdaptiveLimiter struct {
+	threshold  time.Duration
+	backoff    float64
+	isOverload func(error) bool
+	now        func() time.Time
+
+	lock     sync.Mutex
+	limit    float64
+	min      float64
+	max      float64
+	inflight int
+}
+
+// NewAdaptiveLimiter returns a new AdaptiveLimiter.
+func NewAdaptiveLimiter(ops ...AdaptiveOption) *AdaptiveLimiter {
+	var a = &AdaptiveLimiter{
+		limit:      20,
+		min:        1,
+		max:        1000,
+		backoff:    0.9,
+		isOverload: IsOverloadError,
+		now:        time.Now,
+	}
+	for _, op := range ops {
+		op(a)
+	}
+	a.limit = math.Max(a.min, math.Min(a.max, a.limit))
+	return a
+}
+
+// Limit returns the current limit.
+func (a *AdaptiveLimiter) Limit() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return int(a.limit)
+}
+
+// InFlight returns the current work in flight.
+func (a *AdaptiveLimiter) InFlight() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.inflight
+}
+
+// Acquire returns a permit if work in flight is below the limit.
+func (a *AdaptiveLimiter) Acquire(ctx context.Context) (Permit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.inflight >= int(a.limit) {
+		return nil, nerror.WrapOnly(ErrOverloaded)
+	}
+	a.inflight++
+	return &adaptivePermit{limiter: a, started: a.now(), inflight: a.inflight}, nil
+}
+
+func (a *AdaptiveLimiter) release(p *adaptivePermit, err error) {
+	var latency = a.now().Sub(p.started)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.inflight--
+
+	if (err != nil && a.isOverload(err)) || (a.threshold > 0 && latency > a.threshold) {
+		a.limit = math.Max(a.min, math.Floor(a.limit*a.backoff))
+		return
+	}
+
+	// only grow when the limit was close to being used, else an idle
+	// service would grow it's limit without bounds.
+	if err == nil && float64(p.inflight)*2 >= a.limit {
+		a.limit = math.Min(a.max, a.limit+1)
+	}
+}
+
+type adaptivePermit struct {
+	limiter  *AdaptiveLimiter
+	started  time.Time
+	inflight int
+	once     sync.Once
+}
+
+func (p *adaptivePermit) Release(err error) {
+	p.once.Do(func() {
+		p.limiter.release(p, err)
+	})
+}
+
+//**********************************************************************
+// Shedder
+//**********************************************************************
+
+// Priority defines the importance of work, lower priority work is
+// rejected first by a Shedder.
+type Priority int
+
+// Priorities supported by a Shedder.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	PriorityCritical
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context carrying giving priority.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFrom returns the priority of giving context, PriorityNormal
+// if it has none.
+func PriorityFrom(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+var _ ConcurrencyLimiter = (*Shedder)(nil)
+
+// ShedderOption defines a function type which sets the options of a Shedder.
+type ShedderOption func(*Shedder)
+
+// Reserve sets the share (0 to 1) of the limit work of giving priority may
+// use, defaults to 0.5 for PriorityLow, 0.75 for PriorityNormal, 0.9 for
+// PriorityHigh and 1 for PriorityCritical.
+func Reserve(priority Priority, share float64) ShedderOption {
+	return func(s *Shedder) {
+		s.shares[priority] = share
+	}
+}
+
+// Shedder wraps a ConcurrencyLimiter, rejecting work whose priority may not
+// use the current share of the limit with ErrOverloaded, so that lower
+// priority work is rejected first as work in flight grows.
+//
+// The priority of work is read from the context given to Acquire, see
+// WithPriority.
+type Shedder struct {
+	limiter ConcurrencyLimiter
+	shares  map[Priority]float64
+}
+
+// NewShedder returns a new Shedder over giving limiter.
+func NewShedder(limiter ConcurrencyLimiter, ops ...ShedderOption) *Shedder {
+	var s = &Shedder{
+		limiter: limiter,
+		shares: map[Priority]float64{
+			PriorityLow:      0.5,
+			PriorityNormal:   0.75,
+			PriorityHigh:     0.9,
+			PriorityCritical: 1,
+		},
+	}
+	for _, op := range ops {
+		op(s)
+	}
+	return s
+}
+
+// Limit returns the limit of the underline limiter.
+func (s *Shedder) Limit() int {
+	return s.limiter.Limit()
+}
+
+// InFlight returns the work in flight of the underline limiter.
+func (s *Shedder) InFlight() int {
+	return s.limiter.InFlight()
+}
+
+// Acquire returns a permit from the underline limiter if the priority of
+// giving context may use the current work in flight.
+func (s *Shedder) Acquire(ctx context.Context) (Permit, error) {
+	var priority = PriorityFrom(ctx)
+	var share, ok = s.shares[priority]
+	if !ok {
+		share = 1
+	}
+
+	var allowed = int(math.Ceil(share * float64(s.limiter.Limit())))
+	if s.limiter.InFlight() >= allowed {
+		return nil, nerror.WrapOnly(ErrOverloaded)
+	}
+	return s.limiter.Acquire(ctx)
+}
@@ -0,0 +1,195 @@
+package nrates
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nexec"
+)
+
+func TestSemaphore(t *testing.T) {
+	var semaphore = NewSemaphore(2, MaxWaiting(1))
+
+	var first, err = semaphore.Acquire(context.Background())
+	require.NoError(t, err)
+	_, err = semaphore.Acquire(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, semaphore.InFlight())
+
+	var acquired = make(chan Permit)
+	go func() {
+		var permit, waitErr = semaphore.Acquire(context.Background())
+		require.NoError(t, waitErr)
+		acquired <- permit
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err = semaphore.Acquire(context.Background())
+		return IsOverloaded(err)
+	}, time.Second, time.Millisecond)
+
+	first.Release(nil)
+	first.Release(nil)
+	(<-acquired).Release(nil)
+	require.Equal(t, 1, semaphore.InFlight())
+
+	// a full semaphore waits until the context is done.
+	var full = NewSemaphore(1)
+	_, err = full.Acquire(context.Background())
+	require.NoError(t, err)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = full.Acquire(ctx)
+	require.Error(t, err)
+	require.False(t, IsOverloaded(err))
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	var limiter = NewAdaptiveLimiter(InitialLimit(4), LimitBounds(2, 6))
+
+	var permits []Permit
+	for i := 0; i < 4; i++ {
+		var permit, err = limiter.Acquire(context.Background())
+		require.NoError(t, err)
+		permits = append(permits, permit)
+	}
+
+	var _, err = limiter.Acquire(context.Background())
+	require.True(t, IsOverloaded(err))
+
+	// successes at saturation grow the limit up to it's maximum.
+	for _, permit := range permits {
+		permit.Release(nil)
+	}
+	require.Equal(t, 6, limiter.Limit())
+
+	// overloads shrink it down to it's minimum.
+	for i := 0; i < 10; i++ {
+		var permit, acquireErr = limiter.Acquire(context.Background())
+		require.NoError(t, acquireErr)
+		permit.Release(context.DeadlineExceeded)
+	}
+	require.Equal(t, 2, limiter.Limit())
+	require.Equal(t, 0, limiter.InFlight())
+
+	// other errors leave the limit as is.
+	var permit, acquireErr = limiter.Acquire(context.Background())
+	require.NoError(t, acquireErr)
+	permit.Release(errors.New("bad request"))
+	require.Equal(t, 2, limiter.Limit())
+}
+
+func TestAdaptiveLimiterLatency(t *testing.T) {
+	var limiter = NewAdaptiveLimiter(InitialLimit(10), LatencyThreshold(5*time.Millisecond))
+	require.NoError(t, Do(context.Background(), limiter, func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}))
+	require.Equal(t, 9, limiter.Limit())
+}
+
+func TestShedder(t *testing.T) {
+	var shedder = NewShedder(NewSemaphore(4, MaxWaiting(-1)))
+	var low = WithPriority(context.Background(), PriorityLow)
+	var critical = WithPriority(context.Background(), PriorityCritical)
+
+	for i := 0; i < 2; i++ {
+		var _, err = shedder.Acquire(low)
+		require.NoError(t, err)
+	}
+
+	var _, err = shedder.Acquire(low)
+	require.True(t, IsOverloaded(err))
+
+	// normal priority may use up to 3 of 4 slots.
+	_, err = shedder.Acquire(context.Background())
+	require.NoError(t, err)
+	_, err = shedder.Acquire(context.Background())
+	require.True(t, IsOverloaded(err))
+
+	_, err = shedder.Acquire(critical)
+	require.NoError(t, err)
+	_, err = shedder.Acquire(critical)
+	require.True(t, IsOverloaded(err))
+}
+
+func TestLimitExecutor(t *testing.T) {
+	var semaphore = NewSemaphore(1)
+	var executor = LimitExecutor(semaphore, nexec.New(nexec.Command("exit 0")))
+
+	var code, err = executor.Exec(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+	require.Equal(t, 0, semaphore.InFlight())
+}
+
+func TestDoConcurrency(t *testing.T) {
+	var semaphore = NewSemaphore(3)
+
+	var running int32
+	var waiter sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			require.NoError(t, Do(context.Background(), semaphore, func(ctx context.Context) error {
+				require.True(t, atomic.AddInt32(&running, 1) <= 3)
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			}))
+		}()
+	}
+	waiter.Wait()
+}
+
+type panicExecutor struct{}
+
+func (panicExecutor) Exec(_ context.Context) (int, error) {
+	panic("executor crashed")
+}
+
+func TestDoPanic(t *testing.T) {
+	var semaphore = NewSemaphore(1)
+	require.Panics(t, func() {
+		_ = Do(context.Background(), semaphore, func(ctx context.Context) error {
+			panic("work crashed")
+		})
+	})
+	require.Equal(t, 0, semaphore.InFlight())
+
+	require.Panics(t, func() {
+		_, _ = LimitExecutor(semaphore, panicExecutor{}).Exec(context.Background())
+	})
+	require.Equal(t, 0, semaphore.InFlight())
+
+	// the panic is released as an error, shrinking an adaptive limit.
+	var adaptive = NewAdaptiveLimiter(InitialLimit(10), OverloadWhen(func(err error) bool {
+		return err != nil
+	}))
+	require.Panics(t, func() {
+		_ = Do(context.Background(), adaptive, func(ctx context.Context) error {
+			panic("work crashed")
+		})
+	})
+	require.Equal(t, 0, adaptive.InFlight())
+	require.Less(t, adaptive.Limit(), 10)
+}
+
+func TestSemaphoreMinimumLimit(t *testing.T) {
+	var semaphore = NewSemaphore(0)
+	require.Equal(t, 1, semaphore.Limit())
+
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var permit, err = semaphore.Acquire(ctx)
+	require.NoError(t, err)
+	permit.Release(nil)
+}
@@ -0,0 +1,210 @@
+package nrates
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/ntrace"
+	openTracing "github.com/opentracing/opentracing-go"
+)
+
+// ErrLimitExceeded is returned when a request exceeds it's rate limit.
+var ErrLimitExceeded = errors.New("requests are throttled, try again later")
+
+// IsLimitExceeded returns true if giving error is or wraps ErrLimitExceeded.
+func IsLimitExceeded(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrLimitExceeded
+}
+
+// Algorithm defines the algorithm used by a Store to decide if a
+// request is allowed.
+type Algorithm int
+
+const (
+	// TokenBucket refills a bucket of Burst tokens at Rate tokens per
+	// Period, each request takes a token from the bucket.
+	TokenBucket Algorithm = iota
+
+	// SlidingLog records the time of every request, allowing Rate requests
+	// within any Period. It is exact but uses memory per request.
+	SlidingLog
+
+	// SlidingWindow counts requests in fixed windows of Period, weighting
+	// the previous window by how much of it overlaps the sliding window.
+	SlidingWindow
+
+	// GCRA implements the generic cell rate algorithm, spacing requests by
+	// Period/Rate while allowing bursts of Burst requests.
+	GCRA
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token_bucket"
+	case SlidingLog:
+		return "sliding_log"
+	case SlidingWindow:
+		return "sliding_window"
+	case GCRA:
+		return "gcra"
+	}
+	return "unknown"
+}
+
+// Limit defines the allowed rate of requests.
+type Limit struct {
+	// Rate is the number of requests allowed within Period.
+	Rate int64
+
+	// Period is the window Rate applies to.
+	Period time.Duration
+
+	// Burst is the maximum number of requests allowed at once by the
+	// TokenBucket and GCRA algorithms, defaults to Rate.
+	Burst int64
+}
+
+// NewLimit returns a Limit of rate requests per period.
+func NewLimit(rate int64, period time.Duration) Limit {
+	return Limit{Rate: rate, Period: period, Burst: rate}
+}
+
+// PerSecondLimit returns a Limit of rate requests per second.
+func PerSecondLimit(rate int64) Limit {
+	return NewLimit(rate, time.Second)
+}
+
+// PerMinuteLimit returns a Limit of rate requests per minute.
+func PerMinuteLimit(rate int64) Limit {
+	return NewLimit(rate, time.Minute)
+}
+
+// WithBurst returns a copy of the limit with giving burst.
+func (l Limit) WithBurst(burst int64) Limit {
+	l.Burst = burst
+	return l
+}
+
+func (l Limit) burst() int64 {
+	if l.Burst <= 0 {
+		return l.Rate
+	}
+	return l.Burst
+}
+
+// interval returns the time between two requests at the limit's rate.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 || l.Period <= 0 {
+		return nerror.New("limit must have a positive rate and period")
+	}
+	if l.interval() == 0 {
+		return nerror.New("limit must not allow more than one request per nanosecond")
+	}
+	return nil
+}
+
+// Result holds the outcome of a rate limited request.
+type Result struct {
+	// Allowed is true if the request was allowed.
+	Allowed bool
+
+	// Limit is the limit the request was checked against.
+	Limit Limit
+
+	// Remaining is the number of requests still allowed now.
+	Remaining int64
+
+	// ResetAfter is the time until the full quota of the limit is
+	// available again.
+	ResetAfter time.Duration
+
+	// RetryAfter is the time until the request would be allowed, zero
+	// if it was allowed.
+	RetryAfter time.Duration
+}
+
+// Err returns an error wrapping ErrLimitExceeded if the request was
+// not allowed.
+func (r Result) Err() error {
+	if r.Allowed {
+		return nil
+	}
+	return nerror.WrapOnly(ErrLimitExceeded)
+}
+
+// Store defines a type which atomically records requests for a key
+// against a limit.
+type Store interface {
+	// AllowN records n requests for key if they are allowed by limit.
+	AllowN(ctx context.Context, key string, limit Limit, n int64) (Result, error)
+}
+
+// Limiter applies a Limit to requests using a Store.
+type Limiter struct {
+	store Store
+	limit Limit
+}
+
+// NewLimiter returns a new Limiter applying limit using store.
+func NewLimiter(store Store, limit Limit) *Limiter {
+	return &Limiter{store: store, limit: limit}
+}
+
+// Limit returns the limit of the limiter.
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow records a single request for key.
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN records n requests for key if allowed, the returned Result
+// reports if they were.
+func (l *Limiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	var span openTracing.Span
+	if ctx, span = ntrace.NewMethodSpanFromContext(ctx); span != nil {
+		defer span.Finish()
+	}
+
+	if err := l.limit.validate(); err != nil {
+		return Result{}, err
+	}
+
+	var result, err = l.store.AllowN(ctx, key, l.limit, n)
+	if err != nil {
+		return result, nerror.WrapOnly(err)
+	}
+	return result, nil
+}
+
+// Wait blocks until a request for key is allowed or giving context
+// is cancelled.
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	for {
+		var result, err = l.AllowN(ctx, key, 1)
+		if err != nil {
+			return err
+		}
+		if result.Allowed {
+			return nil
+		}
+
+		var timer = time.NewTimer(result.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nerror.WrapOnly(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package nrates
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// Option defines a function type which sets the options of a Store.
+type Option func(*options)
+
+type options struct {
+	now    func() time.Time
+	prefix string
+}
+
+func newOptions(ops []Option) options {
+	var o = options{now: time.Now, prefix: "nrates:"}
+	for _, op := range ops {
+		op(&o)
+	}
+	return o
+}
+
+// Clock sets the function used to get the current time, defaults
+// to time.Now.
+func Clock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Prefix sets the prefix of keys in shared stores, defaults to "nrates:".
+func Prefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// sweepEvery is the number of calls after which a MemoryStore removes
+// expired entries.
+const sweepEvery = 1024
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore implements the Store interface in memory using one of the
+// supported algorithms, limiting requests only within the current process.
+type MemoryStore struct {
+	algorithm Algorithm
+	ops       options
+
+	lock    sync.Mutex
+	calls   int
+	entries map[string]*memoryEntry
+}
+
+// memoryEntry holds the state of a key for all algorithms.
+type memoryEntry struct {
+	expires time.Time
+
+	// TokenBucket
+	tokens float64
+	last   time.Time
+
+	// SlidingLog
+	log []time.Time
+
+	// SlidingWindow
+	window   int64
+	current  int64
+	previous int64
+
+	// GCRA
+	tat time.Time
+}
+
+// NewMemoryStore returns a new MemoryStore using giving algorithm.
+func NewMemoryStore(algorithm Algorithm, ops ...Option) *MemoryStore {
+	return &MemoryStore{
+		algorithm: algorithm,
+		ops:       newOptions(ops),
+		entries:   map[string]*memoryEntry{},
+	}
+}
+
+// AllowN records n requests for key if they are allowed by limit.
+func (m *MemoryStore) AllowN(ctx context.Context, key string, limit Limit, n int64) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, nerror.WrapOnly(err)
+	}
+	if err := limit.validate(); err != nil {
+		return Result{}, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var now = m.ops.now()
+	m.sweep(now)
+
+	var entry, ok = m.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		entry = &memoryEntry{tokens: float64(limit.burst()), last: now}
+		m.entries[key] = entry
+	}
+
+	var result = Result{Limit: limit}
+	switch m.algorithm {
+	case TokenBucket:
+		entry.tokenBucket(now, limit, n, &result)
+	case SlidingLog:
+		entry.slidingLog(now, limit, n, &result)
+	case SlidingWindow:
+		entry.slidingWindow(now, limit, n, &result)
+	case GCRA:
+		entry.gcra(now, limit, n, &result)
+	default:
+		return Result{}, nerror.New("unknown algorithm %d", m.algorithm)
+	}
+
+	if !result.Allowed && result.RetryAfter <= 0 {
+		result.RetryAfter = time.Microsecond
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	m.calls++
+	if m.calls < sweepEvery {
+		return
+	}
+	m.calls = 0
+	for key, entry := range m.entries {
+		if !now.Before(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (e *memoryEntry) tokenBucket(now time.Time, limit Limit, n int64, result *Result) {
+	var capacity = float64(limit.burst())
+	var perNano = float64(limit.Rate) / float64(limit.Period)
+
+	if elapsed := now.Sub(e.last); elapsed > 0 {
+		e.tokens = math.Min(capacity, e.tokens+float64(elapsed)*perNano)
+	}
+	e.last = now
+
+	if float64(n) <= e.tokens {
+		e.tokens -= float64(n)
+		result.Allowed = true
+	} else {
+		result.RetryAfter = nanos((float64(n) - e.tokens) / perNano)
+	}
+
+	result.Remaining = int64(math.Floor(e.tokens))
+	result.ResetAfter = nanos((capacity - e.tokens) / perNano)
+	if !result.Allowed && n > limit.burst() {
+		result.RetryAfter = result.ResetAfter
+	}
+	e.expires = now.Add(result.ResetAfter)
+}
+
+func (e *memoryEntry) slidingLog(now time.Time, limit Limit, n int64, result *Result) {
+	var since = now.Add(-limit.Period)
+	var index int
+	for index < len(e.log) && !e.log[index].After(since) {
+		index++
+	}
+	e.log = e.log[index:]
+
+	var count = int64(len(e.log))
+	if count+n <= limit.Rate {
+		for i := int64(0); i < n; i++ {
+			e.log = append(e.log, now)
+		}
+		count += n
+		result.Allowed = true
+	} else {
+		// the request is allowed once enough of the oldest entries
+		// leave the window.
+		var needed = count + n - limit.Rate
+		if needed > count {
+			needed = count
+		}
+		if needed > 0 {
+			result.RetryAfter = e.log[needed-1].Add(limit.Period).Sub(now)
+		} else {
+			result.RetryAfter = limit.Period
+		}
+	}
+
+	result.Remaining = limit.Rate - count
+	if len(e.log) > 0 {
+		result.ResetAfter = e.log[len(e.log)-1].Add(limit.Period).Sub(now)
+	}
+	e.expires = now.Add(result.ResetAfter)
+}
+
+func (e *memoryEntry) slidingWindow(now time.Time, limit Limit, n int64, result *Result) {
+	var window = now.UnixNano() / int64(limit.Period)
+	switch window {
+	case e.window:
+	case e.window + 1:
+		e.previous, e.current = e.current, 0
+	default:
+		e.previous, e.current = 0, 0
+	}
+	e.window = window
+
+	var elapsed = time.Duration(now.UnixNano() - window*int64(limit.Period))
+	var left = limit.Period - elapsed
+	var weight = float64(left) / float64(limit.Period)
+	var estimated = float64(e.previous)*weight + float64(e.current)
+
+	if estimated+float64(n) <= float64(limit.Rate) {
+		e.current += n
+		estimated += float64(n)
+		result.Allowed = true
+	} else {
+		// the weight of the previous window decreases over time, the
+		// request may be allowed before the window ends.
+		result.RetryAfter = left
+		if e.previous > 0 {
+			var excess = estimated + float64(n) - float64(limit.Rate)
+			var wait = nanos(excess * float64(limit.Period) / float64(e.previous))
+			if wait < left {
+				result.RetryAfter = wait
+			}
+		}
+	}
+
+	result.Remaining = int64(math.Max(0, math.Floor(float64(limit.Rate)-estimated)))
+	switch {
+	case e.current > 0:
+		result.ResetAfter = left + limit.Period
+	case e.previous > 0:
+		result.ResetAfter = left
+	}
+	e.expires = now.Add(left + limit.Period)
+}
+
+func (e *memoryEntry) gcra(now time.Time, limit Limit, n int64, result *Result) {
+	var interval = limit.interval()
+	var tolerance = interval * time.Duration(limit.burst())
+
+	var tat = e.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	var next = tat.Add(interval * time.Duration(n))
+	var allowAt = next.Add(-tolerance)
+	if now.Before(allowAt) {
+		result.RetryAfter = allowAt.Sub(now)
+	} else {
+		tat = next
+		e.tat = next
+		result.Allowed = true
+	}
+
+	var remaining = int64((tolerance - tat.Sub(now)) / interval)
+	if remaining < 0 {
+		remaining = 0
+	}
+	result.Remaining = remaining
+	result.ResetAfter = tat.Sub(now)
+	e.expires = tat
+}
+
+// nanos converts a fractional count of nanoseconds into a duration,
+// rounding up.
+func nanos(value float64) time.Duration {
+	return time.Duration(math.Ceil(value))
+}
@@ -74,7 +74,9 @@ func (b *RedisIncr) Count(ctx context.Context, r Request) (int64, error) {
 	}
 
 	var res = b.Client.Get(ctx, r.Owner())
-	if err := res.Err(); err != nil {
+	if err := res.Err(); err == redis.Nil {
+		return 0, nil
+	} else if err != nil {
 		return -1, nerror.WrapOnly(err)
 	}
 
@@ -86,26 +88,27 @@ func (b *RedisIncr) Count(ctx context.Context, r Request) (int64, error) {
 	return int64(count), nil
 }
 
-func (b *RedisIncr) Inc(ctx context.Context, r Request, dur time.Duration) error {
+// incrScript increments the counter of a key, setting it's expiry only
+// when the counter is created so the window is not extended by every call.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (b *RedisIncr) Inc(ctx context.Context, r Request, dur time.Duration) (int64, error) {
 	var span openTracing.Span
 	if ctx, span = ntrace.NewMethodSpanFromContext(ctx); span != nil {
 		defer span.Finish()
 	}
 
-	status := b.Client.Ping(ctx)
-	if err := status.Err(); err != nil {
-		return nerror.WrapOnly(err)
-	}
-
-	_, err := b.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
-		pipe.Incr(ctx, r.Owner())
-		pipe.Expire(ctx, r.Owner(), dur)
-		return nil
-	})
+	var count, err = incrScript.Run(ctx, b.Client, []string{r.Owner()}, dur.Milliseconds()).Int64()
 	if err != nil {
-		return nerror.WrapOnly(err)
+		return -1, nerror.WrapOnly(err)
 	}
-	return nil
+	return count, nil
 }
 
 type Request interface {
@@ -170,18 +173,15 @@ func (l *RateLimiter) RateLimit(ctx context.Context, r Request) error {
 		expiry = time.Minute
 	}
 
-	var count, err = l.store.Count(ctx, r)
+	// increment first so concurrent requests can not all pass a count
+	// check before any of them is recorded.
+	var count, err = l.store.Inc(ctx, r, expiry)
 	if err != nil {
 		return nerror.WrapOnly(err)
 	}
 
-	if count >= l.max {
-		return nerror.New("requests are throttled, try again later")
-	}
-
-	_, err = l.store.Inc(ctx, r, expiry)
-	if err != nil {
-		return nerror.WrapOnly(err)
+	if count > l.max {
+		return nerror.WrapOnly(ErrLimitExceeded)
 	}
 	return nil
 }
@@ -0,0 +1,187 @@
+package nrates
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Add(d time.Duration) {
+	f.lock.Lock()
+	f.now = f.now.Add(d)
+	f.lock.Unlock()
+}
+
+var algorithms = []Algorithm{TokenBucket, SlidingLog, SlidingWindow, GCRA}
+
+func testStore(t *testing.T, clock *fakeClock, store Store) {
+	var ctx = context.Background()
+	var limiter = NewLimiter(store, PerSecondLimit(5))
+
+	for i := int64(0); i < 5; i++ {
+		var result, err = limiter.Allow(ctx, "day")
+		require.NoError(t, err)
+		require.True(t, result.Allowed, "request %d", i)
+		require.Equal(t, 4-i, result.Remaining)
+		require.Equal(t, time.Duration(0), result.RetryAfter)
+		require.True(t, result.ResetAfter > 0)
+	}
+
+	var result, err = limiter.Allow(ctx, "day")
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, int64(0), result.Remaining)
+	require.True(t, result.RetryAfter > 0)
+	require.True(t, result.RetryAfter <= time.Second)
+	require.True(t, IsLimitExceeded(result.Err()))
+
+	// other keys are limited separately.
+	result, err = limiter.Allow(ctx, "night")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// waiting for the retry-after allows the request again.
+	var waited time.Duration
+	for !result.Allowed || waited == 0 {
+		require.True(t, waited <= 2*time.Second, "waited %s", waited)
+		clock.Add(result.RetryAfter)
+		waited += result.RetryAfter
+
+		result, err = limiter.Allow(ctx, "day")
+		require.NoError(t, err)
+	}
+
+	// the full quota is available after the reset.
+	clock.Add(result.ResetAfter)
+	result, err = limiter.AllowN(ctx, "day", 5)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, int64(0), result.Remaining)
+}
+
+func TestMemoryStore(t *testing.T) {
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			var clock = newFakeClock()
+			testStore(t, clock, NewMemoryStore(algorithm, Clock(clock.Now)))
+		})
+	}
+}
+
+func TestInvalidLimits(t *testing.T) {
+	for _, algorithm := range algorithms {
+		var store = NewMemoryStore(algorithm)
+		for _, limit := range []Limit{
+			NewLimit(0, time.Second),
+			NewLimit(5, 0),
+			NewLimit(10, 5*time.Nanosecond),
+		} {
+			require.NotPanics(t, func() {
+				var _, err = store.AllowN(context.Background(), "day", limit, 1)
+				require.Error(t, err, "%s %+v", algorithm, limit)
+			})
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var client = redis.NewClient(&redis.Options{Addr: server.Addr(), Network: "tcp"})
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			var clock = newFakeClock()
+			testStore(t, clock, NewRedisStore(client, algorithm, Clock(clock.Now)))
+		})
+	}
+}
+
+func TestMemoryStoreConcurrency(t *testing.T) {
+	for _, algorithm := range algorithms {
+		var clock = newFakeClock()
+		var limiter = NewLimiter(NewMemoryStore(algorithm, Clock(clock.Now)), PerMinuteLimit(50))
+
+		var lock sync.Mutex
+		var allowed int
+		var waiter sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			waiter.Add(1)
+			go func() {
+				defer waiter.Done()
+				for j := 0; j < 20; j++ {
+					var result, err = limiter.Allow(context.Background(), "day")
+					require.NoError(t, err)
+					if result.Allowed {
+						lock.Lock()
+						allowed++
+						lock.Unlock()
+					}
+				}
+			}()
+		}
+		waiter.Wait()
+		require.Equal(t, 50, allowed, algorithm.String())
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	var limiter = NewLimiter(NewMemoryStore(GCRA), NewLimit(1, 20*time.Millisecond))
+	require.NoError(t, limiter.Wait(context.Background(), "day"))
+
+	var started = time.Now()
+	require.NoError(t, limiter.Wait(context.Background(), "day"))
+	require.True(t, time.Since(started) >= 10*time.Millisecond)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, limiter.Wait(ctx, "day"))
+}
+
+type ownerRequest string
+
+func (o ownerRequest) Owner() string     { return string(o) }
+func (o ownerRequest) Data() interface{} { return nil }
+
+func TestRateLimiterWithRedisIncr(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var store, err = NewRedisIncr(&redis.Options{Addr: server.Addr(), Network: "tcp"})
+	require.NoError(t, err)
+
+	var count int64
+	count, err = store.Count(context.Background(), ownerRequest("day"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), count)
+
+	var limiter = NewFactory(store, PerMinute).New(2)
+	require.NoError(t, limiter.RateLimit(context.Background(), ownerRequest("day")))
+	require.NoError(t, limiter.RateLimit(context.Background(), ownerRequest("day")))
+	require.True(t, IsLimitExceeded(limiter.RateLimit(context.Background(), ownerRequest("day"))))
+
+	server.FastForward(time.Minute)
+	require.NoError(t, limiter.RateLimit(context.Background(), ownerRequest("day")))
+}
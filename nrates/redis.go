@@ -0,0 +1,257 @@
+package nrates
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+)
+
+// All scripts receive the current time, rate, period, burst and count of
+// requests with times in microseconds, returning the fields of a Result
+// as {allowed, remaining, retry_after, reset_after}. Numbers are stored
+// with string.format as tostring loses precision on microsecond times.
+
+var tokenBucketScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local burst = tonumber(ARGV[4])
+local n = tonumber(ARGV[5])
+local per = rate / period
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) * per)
+end
+
+local allowed = 0
+local retry = 0
+if n <= tokens then
+	tokens = tokens - n
+	allowed = 1
+else
+	retry = math.ceil((n - tokens) / per)
+end
+
+local reset = math.ceil((burst - tokens) / per)
+if allowed == 0 and n > burst then
+	retry = reset
+end
+
+if reset > 0 then
+	redis.call("HMSET", KEYS[1], "tokens", string.format("%.6f", tokens), "last", string.format("%.0f", now))
+	redis.call("PEXPIRE", KEYS[1], math.ceil(reset / 1000) + 1)
+else
+	redis.call("DEL", KEYS[1])
+end
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+var slidingLogScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local n = tonumber(ARGV[5])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", string.format("%.0f", now - period))
+local count = redis.call("ZCARD", KEYS[1])
+
+local allowed = 0
+local retry = 0
+if count + n <= rate then
+	for i = 1, n do
+		redis.call("ZADD", KEYS[1], string.format("%.0f", now), ARGV[6] .. ":" .. i)
+	end
+	count = count + n
+	allowed = 1
+else
+	local needed = math.min(count + n - rate, count)
+	if needed > 0 then
+		local entry = redis.call("ZRANGE", KEYS[1], needed - 1, needed - 1, "WITHSCORES")
+		retry = tonumber(entry[2]) + period - now
+	else
+		retry = period
+	end
+end
+
+local reset = 0
+local newest = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+if newest[2] then
+	reset = tonumber(newest[2]) + period - now
+	redis.call("PEXPIRE", KEYS[1], math.ceil(reset / 1000) + 1)
+end
+return {allowed, rate - count, retry, reset}
+`)
+
+// slidingWindowScript receives the keys of the current and previous
+// windows and the time elapsed in the current window as ARGV[6].
+var slidingWindowScript = redis.NewScript(`
+local rate = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local n = tonumber(ARGV[5])
+local elapsed = tonumber(ARGV[6])
+
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+local left = period - elapsed
+local estimated = previous * left / period + current
+
+local allowed = 0
+local retry = 0
+if estimated + n <= rate then
+	current = redis.call("INCRBY", KEYS[1], n)
+	redis.call("PEXPIRE", KEYS[1], math.ceil((left + period) / 1000) + 1)
+	estimated = estimated + n
+	allowed = 1
+else
+	retry = left
+	if previous > 0 then
+		local wait = math.ceil((estimated + n - rate) * period / previous)
+		if wait < left then
+			retry = wait
+		end
+	end
+end
+
+local reset = 0
+if current > 0 then
+	reset = left + period
+elseif previous > 0 then
+	reset = left
+end
+return {allowed, math.max(0, math.floor(rate - estimated)), retry, reset}
+`)
+
+// gcraScript receives the emission interval of the limit as ARGV[6].
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local burst = tonumber(ARGV[4])
+local n = tonumber(ARGV[5])
+local interval = tonumber(ARGV[6])
+local tolerance = interval * burst
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tat < now then
+	tat = now
+end
+
+local allowed = 0
+local retry = 0
+local next = tat + interval * n
+local allowAt = next - tolerance
+if now < allowAt then
+	retry = allowAt - now
+else
+	tat = next
+	allowed = 1
+	redis.call("SET", KEYS[1], string.format("%.0f", tat), "PX", math.ceil((tat - now) / 1000) + 1)
+end
+return {allowed, math.max(0, math.floor((tolerance - (tat - now)) / interval)), retry, tat - now}
+`)
+
+var _ Store = (*RedisStore)(nil)
+
+// RedisStore implements the Store interface using redis, where every
+// algorithm runs as a lua script so requests are recorded atomically.
+//
+// The current time is provided by the caller, clocks of all users of a
+// store should hence be kept in sync.
+type RedisStore struct {
+	algorithm Algorithm
+	ops       options
+	Client    redis.UniversalClient
+}
+
+// NewRedisStore returns a new RedisStore using giving client and algorithm.
+func NewRedisStore(client redis.UniversalClient, algorithm Algorithm, ops ...Option) *RedisStore {
+	return &RedisStore{
+		algorithm: algorithm,
+		ops:       newOptions(ops),
+		Client:    client,
+	}
+}
+
+// AllowN records n requests for key if they are allowed by limit.
+func (rd *RedisStore) AllowN(ctx context.Context, key string, limit Limit, n int64) (Result, error) {
+	if err := limit.validate(); err != nil {
+		return Result{}, err
+	}
+
+	var now = rd.ops.now()
+	var name = rd.ops.prefix + rd.algorithm.String() + ":" + key
+	var args = []interface{}{
+		now.UnixNano() / int64(time.Microsecond),
+		limit.Rate,
+		limit.Period.Microseconds(),
+		limit.burst(),
+		n,
+	}
+
+	var script *redis.Script
+	var keys = []string{name}
+	switch rd.algorithm {
+	case TokenBucket:
+		script = tokenBucketScript
+	case SlidingLog:
+		script = slidingLogScript
+		args = append(args, nxid.New().String())
+	case SlidingWindow:
+		script = slidingWindowScript
+		var window = now.UnixNano() / int64(limit.Period)
+		var elapsed = time.Duration(now.UnixNano() - window*int64(limit.Period))
+		keys = []string{
+			name + ":" + strconv.FormatInt(window, 10),
+			name + ":" + strconv.FormatInt(window-1, 10),
+		}
+		args = append(args, elapsed.Microseconds())
+	case GCRA:
+		script = gcraScript
+		var interval = limit.interval().Microseconds()
+		if interval < 1 {
+			interval = 1
+		}
+		args = append(args, interval)
+	default:
+		return Result{}, nerror.New("unknown algorithm %d", rd.algorithm)
+	}
+
+	var reply, err = script.Run(ctx, rd.Client, keys, args...).Result()
+	if err != nil {
+		return Result{}, nerror.WrapOnly(err)
+	}
+
+	var values, ok = reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return Result{}, nerror.New("unexpected script result %v", values)
+	}
+
+	var result = Result{
+		Limit:      limit,
+		Allowed:    toInt64(values[0]) == 1,
+		Remaining:  toInt64(values[1]),
+		RetryAfter: time.Duration(toInt64(values[2])) * time.Microsecond,
+		ResetAfter: time.Duration(toInt64(values[3])) * time.Microsecond,
+	}
+	if !result.Allowed && result.RetryAfter <= 0 {
+		result.RetryAfter = time.Microsecond
+	}
+	return result, nil
+}
+
+func toInt64(value interface{}) int64 {
+	if number, ok := value.(int64); ok {
+		return number
+	}
+	return 0
+}
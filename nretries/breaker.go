@@ -0,0 +1,377 @@
+package nretries
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ErrCircuitOpen is returned by a Breaker when it does not allow calls.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// IsCircuitOpen returns true if giving error is or wraps ErrCircuitOpen,
+// including errors wrapped by a http.Client.
+func IsCircuitOpen(err error) bool {
+	return rootCause(err) == ErrCircuitOpen
+}
+
+// rootCause returns the error wrapped by all PointingError types and
+// errors implementing Unwrap, e.g the *url.Error of a http.Client.
+func rootCause(err error) error {
+	for {
+		err = nerror.UnwrapDeep(err)
+		var next = errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// StateClosed allows all calls, recording their outcome.
+	StateClosed State = iota
+
+	// StateOpen rejects all calls until the cool-down elapses.
+	StateOpen
+
+	// StateHalfOpen allows a limited number of probe calls, closing the
+	// breaker if they all succeed or opening it again if any fails.
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOption defines a function type which sets the options of a Breaker.
+type BreakerOption func(*Breaker)
+
+// FailureRatio sets the ratio (0 to 1) of failed calls within the rolling
+// window which trips the breaker, defaults to 0.5.
+func FailureRatio(ratio float64) BreakerOption {
+	return func(b *Breaker) {
+		b.ratio = ratio
+	}
+}
+
+// MinCalls sets the number of calls needed within the rolling window
+// before the breaker may trip, defaults to 10.
+func MinCalls(n int) BreakerOption {
+	return func(b *Breaker) {
+		b.minCalls = n
+	}
+}
+
+// RollingWindow sets the duration of the rolling window and the number of
+// buckets it is split into, defaults to 10 seconds of 10 buckets.
+//
+// A window which is not positive is ignored, buckets are clamped between 1
+// and the nanoseconds of the window.
+func RollingWindow(window time.Duration, buckets int) BreakerOption {
+	return func(b *Breaker) {
+		if window <= 0 {
+			return
+		}
+		if buckets < 1 {
+			buckets = 1
+		}
+		if time.Duration(buckets) > window {
+			buckets = int(window)
+		}
+		b.window = window
+		b.buckets = make([]bucket, buckets)
+	}
+}
+
+// CoolDown sets how long the breaker stays open before allowing probe
+// calls, defaults to 5 seconds.
+func CoolDown(d time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.coolDown = d
+	}
+}
+
+// HalfOpenProbes sets the number of probe calls allowed at once in the
+// half-open state, which must all succeed to close the breaker. Defaults
+// to 1.
+func HalfOpenProbes(n int) BreakerOption {
+	return func(b *Breaker) {
+		b.probes = n
+	}
+}
+
+// OnStateChange sets a function called with the previous and next state
+// when the state of the breaker changes. It is called with the breaker
+// locked and must not call the breaker.
+func OnStateChange(fn func(from State, to State)) BreakerOption {
+	return func(b *Breaker) {
+		b.onChange = fn
+	}
+}
+
+// FailureWhen sets the function deciding if an error counts as a failure,
+// defaults to all errors except context cancellations.
+func FailureWhen(fn func(error) bool) BreakerOption {
+	return func(b *Breaker) {
+		b.isFailure = fn
+	}
+}
+
+// BreakerClock sets the function used to get the current time, defaults
+// to time.Now.
+func BreakerClock(now func() time.Time) BreakerOption {
+	return func(b *Breaker) {
+		b.now = now
+	}
+}
+
+// bucket counts the outcome of calls within a slice of the rolling window.
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// Breaker implements a circuit breaker which stops calls to a failing
+// dependency.
+//
+// The breaker starts closed, recording the outcome of calls in a rolling
+// window. Once the window holds at least MinCalls calls and the ratio of
+// failures reaches FailureRatio, the breaker opens and rejects calls with
+// ErrCircuitOpen. After the cool-down it becomes half-open, allowing
+// HalfOpenProbes calls at once: the breaker closes once that many probes
+// succeed in a row, and opens again on the first failure.
+type Breaker struct {
+	ratio     float64
+	minCalls  int
+	window    time.Duration
+	coolDown  time.Duration
+	probes    int
+	onChange  func(from State, to State)
+	isFailure func(error) bool
+	now       func() time.Time
+
+	lock       sync.Mutex
+	state      State
+	generation uint64
+	openedAt   time.Time
+	buckets    []bucket
+	inProbe    int
+	probed     int
+}
+
+// NewBreaker returns a new closed Breaker.
+func NewBreaker(ops ...BreakerOption) *Breaker {
+	var b = &Breaker{
+		ratio:     0.5,
+		minCalls:  10,
+		window:    10 * time.Second,
+		buckets:   make([]bucket, 10),
+		coolDown:  5 * time.Second,
+		probes:    1,
+		isFailure: isFailure,
+		now:       time.Now,
+	}
+	for _, op := range ops {
+		op(b)
+	}
+	return b
+}
+
+func isFailure(err error) bool {
+	return err != nil && nerror.UnwrapDeep(err) != context.Canceled
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.currentState(b.now())
+}
+
+// Counts returns the successes and failures within the rolling window.
+func (b *Breaker) Counts() (successes int, failures int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.counts(b.now())
+}
+
+// Reset closes the breaker, clearing it's rolling window.
+func (b *Breaker) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.setState(StateClosed, b.now())
+}
+
+// Allow returns a function which must be called with the outcome of the
+// call if the breaker allows it, else an error matching IsCircuitOpen.
+func (b *Breaker) Allow() (func(err error), error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var now = b.now()
+	switch b.currentState(now) {
+	case StateOpen:
+		return nil, nerror.WrapOnly(ErrCircuitOpen)
+	case StateHalfOpen:
+		if b.inProbe >= b.probes {
+			return nil, nerror.WrapOnly(ErrCircuitOpen)
+		}
+		b.inProbe++
+	}
+
+	var generation = b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, err)
+		})
+	}, nil
+}
+
+// Do calls fn if the breaker allows it, recording it's outcome.
+func (b *Breaker) Do(fn func() error) error {
+	var done, err = b.Allow()
+	if err != nil {
+		return err
+	}
+
+	var fnErr = fn()
+	done(fnErr)
+	return fnErr
+}
+
+// DoContext calls fn with giving context if the breaker allows it,
+// recording it's outcome.
+func (b *Breaker) DoContext(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return b.Do(func() error {
+		return fn(ctx)
+	})
+}
+
+func (b *Breaker) done(generation uint64, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var now = b.now()
+	var state = b.currentState(now)
+
+	// calls started before the last state change are ignored.
+	if generation != b.generation {
+		return
+	}
+
+	var failed = b.isFailure(err)
+	switch state {
+	case StateClosed:
+		var current = b.bucket(now)
+		if failed {
+			current.failures++
+		} else {
+			current.successes++
+		}
+
+		var successes, failures = b.counts(now)
+		var total = successes + failures
+		if failed && total >= b.minCalls && float64(failures)/float64(total) >= b.ratio {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.inProbe--
+		if failed {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.probed++
+		if b.probed >= b.probes {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// currentState returns the state, moving an open breaker whose cool-down
+// elapsed to half-open.
+func (b *Breaker) currentState(now time.Time) State {
+	if b.state == StateOpen && !now.Before(b.openedAt.Add(b.coolDown)) {
+		b.setState(StateHalfOpen, now)
+	}
+	return b.state
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	var previous = b.state
+	b.state = state
+	b.generation++
+	b.inProbe = 0
+	b.probed = 0
+	for index := range b.buckets {
+		b.buckets[index] = bucket{}
+	}
+	if state == StateOpen {
+		b.openedAt = now
+	}
+
+	if previous != state && b.onChange != nil {
+		b.onChange(previous, state)
+	}
+}
+
+// bucket returns the bucket of the rolling window for giving time,
+// clearing it if it holds counts of an earlier window.
+func (b *Breaker) bucket(now time.Time) *bucket {
+	var size = b.window / time.Duration(len(b.buckets))
+	var start = now.Truncate(size)
+	var current = &b.buckets[int(start.UnixNano()/int64(size))%len(b.buckets)]
+	if !current.start.Equal(start) {
+		*current = bucket{start: start}
+	}
+	return current
+}
+
+func (b *Breaker) counts(now time.Time) (successes int, failures int) {
+	var since = now.Add(-b.window)
+	for _, current := range b.buckets {
+		if current.start.After(since) {
+			successes += current.successes
+			failures += current.failures
+		}
+	}
+	return successes, failures
+}
+
+// BreakerDoUntil calls fx through giving breaker until it succeeds or total
+// attempts are made, sleeping for the duration returned by backoff for the
+// attempt between calls. It stops as soon as the breaker rejects a call,
+// returning an error matching IsCircuitOpen.
+func BreakerDoUntil(breaker *Breaker, fx func() error, total int, backoff func(int) time.Duration) error {
+	var err error
+	for attempt := 0; attempt < total; attempt++ {
+		if err = breaker.Do(fx); err == nil || IsCircuitOpen(err) {
+			return err
+		}
+
+		if backoff != nil && attempt < total-1 {
+			time.Sleep(backoff(attempt))
+		}
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package nretries
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Add(d time.Duration) {
+	f.lock.Lock()
+	f.now = f.now.Add(d)
+	f.lock.Unlock()
+}
+
+var errBad = errors.New("bad")
+
+func TestBreaker(t *testing.T) {
+	var clock = newFakeClock()
+	var changes []string
+	var breaker = NewBreaker(
+		MinCalls(4),
+		FailureRatio(0.5),
+		CoolDown(time.Second),
+		HalfOpenProbes(2),
+		BreakerClock(clock.Now),
+		OnStateChange(func(from State, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		}),
+	)
+
+	require.NoError(t, breaker.Do(func() error { return nil }))
+	require.NoError(t, breaker.Do(func() error { return nil }))
+	require.Equal(t, errBad, breaker.Do(func() error { return errBad }))
+	require.Equal(t, StateClosed, breaker.State())
+
+	var successes, failures = breaker.Counts()
+	require.Equal(t, 2, successes)
+	require.Equal(t, 1, failures)
+
+	// 2 failures of 4 calls trips the breaker.
+	require.Equal(t, errBad, breaker.Do(func() error { return errBad }))
+	require.Equal(t, StateOpen, breaker.State())
+
+	var called bool
+	var err = breaker.Do(func() error {
+		called = true
+		return nil
+	})
+	require.True(t, IsCircuitOpen(err))
+	require.False(t, called)
+
+	// after the cool-down only 2 probes are allowed at once.
+	clock.Add(time.Second)
+	require.Equal(t, StateHalfOpen, breaker.State())
+
+	var first, firstErr = breaker.Allow()
+	require.NoError(t, firstErr)
+	var second, secondErr = breaker.Allow()
+	require.NoError(t, secondErr)
+	_, err = breaker.Allow()
+	require.True(t, IsCircuitOpen(err))
+
+	// a failed probe opens the breaker again.
+	first(nil)
+	second(errBad)
+	require.Equal(t, StateOpen, breaker.State())
+
+	clock.Add(time.Second)
+	require.NoError(t, breaker.Do(func() error { return nil }))
+	require.NoError(t, breaker.Do(func() error { return nil }))
+	require.Equal(t, StateClosed, breaker.State())
+
+	require.Equal(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, changes)
+}
+
+func TestBreakerRollingWindow(t *testing.T) {
+	var clock = newFakeClock()
+	var breaker = NewBreaker(MinCalls(2), RollingWindow(time.Second, 10), BreakerClock(clock.Now))
+
+	require.Error(t, breaker.Do(func() error { return errBad }))
+
+	// failures older than the window are forgotten.
+	clock.Add(2 * time.Second)
+	require.NoError(t, breaker.Do(func() error { return nil }))
+	require.Error(t, breaker.Do(func() error { return errBad }))
+	require.Equal(t, StateOpen, breaker.State())
+
+	breaker.Reset()
+	require.Equal(t, StateClosed, breaker.State())
+	var successes, failures = breaker.Counts()
+	require.Equal(t, 0, successes+failures)
+}
+
+func TestBreakerInvalidWindow(t *testing.T) {
+	var clock = newFakeClock()
+	for _, option := range []BreakerOption{
+		RollingWindow(time.Second, 0),
+		RollingWindow(time.Second, -1),
+		RollingWindow(0, 10),
+		RollingWindow(5*time.Nanosecond, 10),
+	} {
+		var breaker = NewBreaker(MinCalls(1), option, BreakerClock(clock.Now))
+		require.NotPanics(t, func() {
+			require.Error(t, breaker.Do(func() error { return errBad }))
+		})
+		require.Equal(t, StateOpen, breaker.State())
+	}
+}
+
+func TestBreakerDoUntil(t *testing.T) {
+	var breaker = NewBreaker(MinCalls(3), CoolDown(time.Minute))
+
+	var calls int
+	var err = BreakerDoUntil(breaker, func() error {
+		calls++
+		return errBad
+	}, 10, nil)
+	require.True(t, IsCircuitOpen(err))
+	require.Equal(t, 3, calls)
+
+	breaker.Reset()
+	calls = 0
+	err = BreakerDoUntil(breaker, func() error {
+		calls++
+		if calls < 2 {
+			return errBad
+		}
+		return nil
+	}, 10, func(int) time.Duration { return time.Millisecond })
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestIsCircuitOpenHTTPClient(t *testing.T) {
+	var breaker = NewBreaker(MinCalls(1), CoolDown(time.Hour))
+	require.Error(t, breaker.Do(func() error { return errBad }))
+
+	var client = &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var res *http.Response
+		var err = breaker.Do(func() error {
+			var err error
+			res, err = http.DefaultTransport.RoundTrip(req)
+			return err
+		})
+		return res, err
+	})}
+
+	// the http.Client wraps the error of the transport in a *url.Error.
+	var _, err = client.Get("http://localhost/")
+	var urlErr *url.Error
+	require.True(t, errors.As(err, &urlErr))
+	require.True(t, IsCircuitOpen(err))
+	require.False(t, IsBudgetExhausted(err))
+
+	require.True(t, IsCircuitOpen(&url.Error{Op: "Get", URL: "http://localhost/", Err: nerror.WrapOnly(ErrCircuitOpen)}))
+	require.False(t, IsCircuitOpen(&url.Error{Op: "Get", URL: "http://localhost/", Err: errBad}))
+}
@@ -0,0 +1,149 @@
+package nretries
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned when a retry is not allowed by a Budget.
+var ErrBudgetExhausted = errors.New("retry budget is exhausted")
+
+// IsBudgetExhausted returns true if giving error is or wraps ErrBudgetExhausted,
+// including errors wrapped by a http.Client.
+func IsBudgetExhausted(err error) bool {
+	return rootCause(err) == ErrBudgetExhausted
+}
+
+// BudgetOption defines a function type which sets the options of a Budget.
+type BudgetOption func(*Budget)
+
+// BudgetWindow sets the duration of the rolling window and the number of
+// buckets it is split into, defaults to 10 seconds of 10 buckets.
+//
+// A window which is not positive is ignored, buckets are clamped between 1
+// and the nanoseconds of the window.
+func BudgetWindow(window time.Duration, buckets int) BudgetOption {
+	return func(b *Budget) {
+		if window <= 0 {
+			return
+		}
+		if buckets < 1 {
+			buckets = 1
+		}
+		if time.Duration(buckets) > window {
+			buckets = int(window)
+		}
+		b.window = window
+		b.buckets = make([]budgetBucket, buckets)
+	}
+}
+
+// MinRetries sets the number of retries always allowed within the rolling
+// window, so retries are possible at low traffic. Defaults to 10.
+func MinRetries(n int) BudgetOption {
+	return func(b *Budget) {
+		b.minRetries = n
+	}
+}
+
+// BudgetClock sets the function used to get the current time, defaults
+// to time.Now.
+func BudgetClock(now func() time.Time) BudgetOption {
+	return func(b *Budget) {
+		b.now = now
+	}
+}
+
+// budgetBucket counts requests and retries within a slice of the rolling
+// window.
+type budgetBucket struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// Budget caps retries to a ratio of requests within a rolling window,
+// avoiding retry storms when a dependency fails: once it is exhausted
+// failures are returned instead of multiplying the load on the dependency.
+//
+// A Budget is usually shared by all callers of a dependency, see WithBudget,
+// BudgetTransport and HedgeBudget.
+type Budget struct {
+	ratio      float64
+	minRetries int
+	window     time.Duration
+	now        func() time.Time
+
+	lock    sync.Mutex
+	buckets []budgetBucket
+}
+
+// NewBudget returns a new Budget allowing retries up to giving ratio of
+// requests, e.g 0.1 for retries of at most 10% of requests.
+func NewBudget(ratio float64, ops ...BudgetOption) *Budget {
+	var b = &Budget{
+		ratio:      ratio,
+		minRetries: 10,
+		window:     10 * time.Second,
+		buckets:    make([]budgetBucket, 10),
+		now:        time.Now,
+	}
+	for _, op := range ops {
+		op(b)
+	}
+	return b
+}
+
+// Request records a request, first attempts must be recorded for the
+// budget to allow retries above MinRetries.
+func (b *Budget) Request() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.bucket(b.now()).requests++
+}
+
+// Withdraw records a retry returning true if the budget allows it, else
+// it returns false without recording it.
+func (b *Budget) Withdraw() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var now = b.now()
+	var requests, retries = b.counts(now)
+	if float64(retries) >= float64(b.minRetries)+b.ratio*float64(requests) {
+		return false
+	}
+	b.bucket(now).retries++
+	return true
+}
+
+// Counts returns the requests and retries within the rolling window.
+func (b *Budget) Counts() (requests int, retries int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.counts(b.now())
+}
+
+// bucket returns the bucket of the rolling window for giving time,
+// clearing it if it holds counts of an earlier window.
+func (b *Budget) bucket(now time.Time) *budgetBucket {
+	var size = b.window / time.Duration(len(b.buckets))
+	var start = now.Truncate(size)
+	var current = &b.buckets[int(start.UnixNano()/int64(size))%len(b.buckets)]
+	if !current.start.Equal(start) {
+		*current = budgetBucket{start: start}
+	}
+	return current
+}
+
+func (b *Budget) counts(now time.Time) (requests int, retries int) {
+	var since = now.Add(-b.window)
+	for _, current := range b.buckets {
+		if current.start.After(since) {
+			requests += current.requests
+			retries += current.retries
+		}
+	}
+	return requests, retries
+}
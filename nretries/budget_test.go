@@ -0,0 +1,142 @@
+package nretries
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+func TestBudget(t *testing.T) {
+	var clock = newFakeClock()
+	var budget = NewBudget(0.1, MinRetries(2), BudgetClock(clock.Now))
+
+	require.True(t, budget.Withdraw())
+	require.True(t, budget.Withdraw())
+	require.False(t, budget.Withdraw())
+
+	for i := 0; i < 20; i++ {
+		budget.Request()
+	}
+	require.True(t, budget.Withdraw())
+	require.True(t, budget.Withdraw())
+	require.False(t, budget.Withdraw())
+
+	var requests, retries = budget.Counts()
+	require.Equal(t, 20, requests)
+	require.Equal(t, 4, retries)
+
+	// the window rolls over.
+	clock.Add(11 * time.Second)
+	requests, retries = budget.Counts()
+	require.Equal(t, 0, requests+retries)
+	require.True(t, budget.Withdraw())
+}
+
+func TestBudgetInvalidWindow(t *testing.T) {
+	var clock = newFakeClock()
+	for _, option := range []BudgetOption{
+		BudgetWindow(time.Second, 0),
+		BudgetWindow(time.Second, -1),
+		BudgetWindow(0, 10),
+		BudgetWindow(5*time.Nanosecond, 10),
+	} {
+		var budget = NewBudget(0, MinRetries(1), BudgetClock(clock.Now), option)
+		require.NotPanics(t, budget.Request)
+		require.True(t, budget.Withdraw())
+		require.False(t, budget.Withdraw())
+
+		var requests, retries = budget.Counts()
+		require.Equal(t, 1, requests)
+		require.Equal(t, 1, retries)
+	}
+}
+
+func TestRetryWithBudget(t *testing.T) {
+	var budget = NewBudget(0, MinRetries(3))
+
+	var calls int
+	var fail = func(ctx context.Context) error {
+		calls++
+		return errBad
+	}
+
+	var err = Retry(context.Background(), fail, WithClock(newFakeClock()), MaxAttempts(3), WithBudget(budget))
+	require.Error(t, err)
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(context.Background(), fail, WithClock(newFakeClock()), MaxAttempts(3), WithBudget(budget))
+	require.Equal(t, 2, calls)
+	require.True(t, IsBudgetExhausted(AttemptErrors(err)[2]))
+}
+
+func TestBudgetTransport(t *testing.T) {
+	var hits int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var body = make([]byte, 5)
+			_, _ = r.Body.Read(body)
+			require.Equal(t, "hello", string(body))
+		}
+		if atomic.AddInt32(&hits, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	var client = &http.Client{
+		Transport: BudgetTransport(http.DefaultTransport, NewBudget(0.5), BackOff(func(int) time.Duration { return 0 })),
+	}
+
+	var res, err = client.Get(server.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	var req, reqErr = http.NewRequest(http.MethodPut, server.URL, strings.NewReader("hello"))
+	require.NoError(t, reqErr)
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, int32(6), atomic.LoadInt32(&hits))
+
+	// requests which are not idempotent are sent once.
+	res, err = client.Post(server.URL, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, int32(7), atomic.LoadInt32(&hits))
+}
+
+func TestIsBudgetExhaustedHTTPClient(t *testing.T) {
+	var refused = RoundTripperFunc(func(_ *http.Request) (*http.Response, error) {
+		return nil, nerror.WrapOnly(errBad)
+	})
+	var client = &http.Client{
+		Transport: BudgetTransport(refused, NewBudget(0, MinRetries(0)), BackOff(func(int) time.Duration { return 0 })),
+	}
+
+	// the http.Client wraps the error of the transport in a *url.Error.
+	var _, err = client.Get("http://localhost/")
+	var urlErr *url.Error
+	require.True(t, errors.As(err, &urlErr))
+	require.True(t, IsBudgetExhausted(err))
+	require.False(t, IsCircuitOpen(err))
+
+	require.False(t, IsBudgetExhausted(&url.Error{Op: "Get", URL: "http://localhost/", Err: errBad}))
+	require.False(t, IsBudgetExhausted(nil))
+}
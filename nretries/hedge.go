@@ -0,0 +1,259 @@
+package nretries
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// minHedgeSamples is the number of latencies a Hedger needs before using
+// the percentile as it's threshold.
+const minHedgeSamples = 20
+
+// HedgeOption defines a function type which sets the options of a Hedger.
+type HedgeOption func(*Hedger)
+
+// HedgePercentile sets the percentile (0 to 1) of observed latencies after
+// which another attempt is launched, defaults to 0.95.
+func HedgePercentile(p float64) HedgeOption {
+	return func(h *Hedger) {
+		h.percentile = p
+	}
+}
+
+// HedgeSamples sets the number of latest latencies the percentile is
+// computed from, defaults to 1000. It is clamped to at least 1.
+func HedgeSamples(n int) HedgeOption {
+	return func(h *Hedger) {
+		if n < 1 {
+			n = 1
+		}
+		h.samples = make([]time.Duration, 0, n)
+	}
+}
+
+// HedgeDelay sets the minimum delay before another attempt is launched,
+// also used until enough latencies are observed. Defaults to 50ms.
+func HedgeDelay(d time.Duration) HedgeOption {
+	return func(h *Hedger) {
+		h.minDelay = d
+	}
+}
+
+// MaxHedges sets the number of attempts launched in addition to the
+// first, each after another threshold elapsed. Defaults to 1, it is
+// clamped to at least 0 which disables hedging.
+func MaxHedges(n int) HedgeOption {
+	return func(h *Hedger) {
+		if n < 0 {
+			n = 0
+		}
+		h.hedges = n
+	}
+}
+
+// HedgeBudget withdraws every additional attempt from giving budget, no
+// attempt is launched once it is exhausted.
+func HedgeBudget(budget *Budget) HedgeOption {
+	return func(h *Hedger) {
+		h.budget = budget
+	}
+}
+
+// Hedger implements hedged execution: when an attempt takes longer than
+// a percentile of the latencies observed so far, another attempt is
+// launched and the first success is used, cancelling the others.
+//
+// Hedging trades extra load for lower tail latency, only operations which
+// are safe to run more than once should be hedged.
+type Hedger struct {
+	percentile float64
+	minDelay   time.Duration
+	hedges     int
+	budget     *Budget
+
+	lock      sync.Mutex
+	samples   []time.Duration
+	next      int
+	observed  int
+	threshold time.Duration
+}
+
+// NewHedger returns a new Hedger.
+func NewHedger(ops ...HedgeOption) *Hedger {
+	var h = &Hedger{
+		percentile: 0.95,
+		minDelay:   50 * time.Millisecond,
+		hedges:     1,
+		samples:    make([]time.Duration, 0, 1000),
+	}
+	for _, op := range ops {
+		op(h)
+	}
+	h.threshold = h.minDelay
+	return h
+}
+
+// Threshold returns the delay after which another attempt is launched.
+func (h *Hedger) Threshold() time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.threshold
+}
+
+// Observe records the latency of a successful attempt.
+func (h *Hedger) Observe(latency time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.samples) < cap(h.samples) {
+		h.samples = append(h.samples, latency)
+	} else {
+		h.samples[h.next] = latency
+		h.next = (h.next + 1) % len(h.samples)
+	}
+
+	// the percentile is recomputed every tenth of the samples, as
+	// sorting them for every observation is too costly.
+	h.observed++
+	if len(h.samples) < minHedgeSamples || h.observed < int(math.Max(1, float64(cap(h.samples)/10))) {
+		return
+	}
+	h.observed = 0
+
+	var sorted = make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	var index = int(math.Ceil(h.percentile*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	h.threshold = sorted[index]
+	if h.threshold < h.minDelay {
+		h.threshold = h.minDelay
+	}
+}
+
+// Do calls op, launching another attempt each time the threshold elapses
+// without a result, up to MaxHedges. It returns nil once an attempt
+// succeeds, cancelling the context of the others, or a RetryError holding
+// the errors of all attempts if they all fail.
+func (h *Hedger) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	var _, cancel, err = h.hedge(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, op(ctx)
+	}, nil)
+	if cancel != nil {
+		cancel()
+	}
+	return err
+}
+
+// DoValue calls op as Do does, returning the value of the first successful
+// attempt.
+func (h *Hedger) DoValue(ctx context.Context, op func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	var value, cancel, err = h.hedge(ctx, op, nil)
+	if cancel != nil {
+		cancel()
+	}
+	return value, err
+}
+
+type hedgeResult struct {
+	index   int
+	value   interface{}
+	err     error
+	latency time.Duration
+}
+
+// hedge runs op returning the value of the first successful attempt with
+// the function cancelling it's context, which must be called once the value
+// is no longer used. Values of other attempts which succeed are given to
+// discard if set.
+func (h *Hedger) hedge(ctx context.Context, op func(ctx context.Context) (interface{}, error), discard func(interface{})) (interface{}, context.CancelFunc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nerror.WrapOnly(err)
+	}
+	if h.budget != nil {
+		h.budget.Request()
+	}
+
+	var results = make(chan hedgeResult, h.hedges+1)
+	var cancels []context.CancelFunc
+	var launch = func() {
+		var attemptCtx, cancel = context.WithCancel(ctx)
+		var index = len(cancels)
+		cancels = append(cancels, cancel)
+
+		var start = time.Now()
+		go func() {
+			var value, err = op(attemptCtx)
+			results <- hedgeResult{index: index, value: value, err: err, latency: time.Since(start)}
+		}()
+	}
+
+	// cancelOthers cancels all attempts except giving one, handing the
+	// results of pending attempts to discard.
+	var pending int
+	var cancelOthers = func(except int) {
+		for index, cancel := range cancels {
+			if index != except {
+				cancel()
+			}
+		}
+		if pending > 0 {
+			go drainHedges(results, pending, discard)
+		}
+	}
+
+	var delay = h.Threshold()
+	var timer = time.NewTimer(delay)
+	defer timer.Stop()
+
+	launch()
+	pending++
+
+	var errs []error
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) > h.hedges || (h.budget != nil && !h.budget.Withdraw()) {
+				continue
+			}
+			launch()
+			pending++
+			timer.Reset(delay)
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				h.Observe(result.latency)
+				cancelOthers(result.index)
+				return result.value, cancels[result.index], nil
+			}
+
+			errs = append(errs, result.err)
+			if pending == 0 {
+				cancelOthers(-1)
+				return nil, nil, nerror.WrapOnly(&RetryError{Errors: errs})
+			}
+		case <-ctx.Done():
+			cancelOthers(-1)
+			return nil, nil, nerror.WrapOnly(&RetryError{Errors: append(errs, ctx.Err())})
+		}
+	}
+}
+
+func drainHedges(results chan hedgeResult, pending int, discard func(interface{})) {
+	for ; pending > 0; pending-- {
+		var result = <-results
+		if result.err == nil && discard != nil {
+			discard(result.value)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package nretries
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedger(t *testing.T) {
+	var hedger = NewHedger(HedgeDelay(10 * time.Millisecond))
+
+	var calls int32
+	var cancelled = make(chan struct{})
+	var value, err = hedger.DoValue(context.Background(), func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+		return "hedged", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "hedged", value)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow attempt was not cancelled")
+	}
+
+	// fast attempts are not hedged.
+	atomic.StoreInt32(&calls, 0)
+	require.NoError(t, hedger.Do(context.Background(), func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// all failures are returned.
+	err = hedger.Do(context.Background(), func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return errBad
+	})
+	require.Len(t, AttemptErrors(err), 2)
+}
+
+func TestHedgerThreshold(t *testing.T) {
+	var hedger = NewHedger(HedgeSamples(100), HedgePercentile(0.9), HedgeDelay(time.Millisecond))
+	require.Equal(t, time.Millisecond, hedger.Threshold())
+
+	for i := 1; i <= 100; i++ {
+		hedger.Observe(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, 90*time.Millisecond, hedger.Threshold())
+}
+
+func TestHedgerInvalidSamples(t *testing.T) {
+	for _, samples := range []int{0, -1} {
+		var hedger = NewHedger(HedgeSamples(samples), HedgeDelay(time.Millisecond))
+		require.NotPanics(t, func() {
+			for i := 1; i <= 100; i++ {
+				hedger.Observe(time.Duration(i) * time.Millisecond)
+			}
+		})
+		require.Equal(t, time.Millisecond, hedger.Threshold())
+	}
+}
+
+func TestHedgerInvalidHedges(t *testing.T) {
+	var hedger = NewHedger(MaxHedges(-5), HedgeDelay(time.Millisecond))
+
+	var calls int32
+	require.NotPanics(t, func() {
+		require.NoError(t, hedger.Do(context.Background(), func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}))
+	})
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHedgerBudget(t *testing.T) {
+	var hedger = NewHedger(HedgeDelay(time.Millisecond), HedgeBudget(NewBudget(0, MinRetries(0))))
+
+	var calls int32
+	require.NoError(t, hedger.Do(context.Background(), func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHedgeTransport(t *testing.T) {
+	var hits int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	var client = &http.Client{
+		Transport: HedgeTransport(http.DefaultTransport, NewHedger(HedgeDelay(20*time.Millisecond))),
+	}
+
+	var start = time.Now()
+	var res, err = client.Get(server.URL)
+	require.NoError(t, err)
+
+	var body, readErr = ioutil.ReadAll(res.Body)
+	require.NoError(t, readErr)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, "fast", string(body))
+	require.True(t, time.Since(start) < time.Second)
+	require.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
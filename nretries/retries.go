@@ -3,6 +3,7 @@ package nretries
 import (
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -36,9 +37,28 @@ func LinearDoUntil(fx func() error, total int, elapse time.Duration) error {
 
 var (
 	// random is used to generate pseudo-random numbers.
-	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	random = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())})
 )
 
+// lockedSource guards a rand.Source, as backoffs are called
+// from multiple goroutines.
+type lockedSource struct {
+	lock sync.Mutex
+	src  rand.Source
+}
+
+func (l *lockedSource) Int63() int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.src.Int63()
+}
+
+func (l *lockedSource) Seed(seed int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.src.Seed(seed)
+}
+
 // LinearBackOff returns increasing durations, each a second longer than the last
 func LinearBackOff(i int) time.Duration {
 	return time.Duration(i) * time.Second
@@ -0,0 +1,305 @@
+package nretries
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// Clock provides the current time and waits between attempts of Retry.
+type Clock interface {
+	Now() time.Time
+
+	// Sleep waits for giving duration, returning early with an error if
+	// the context is done.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// SystemClock implements the Clock interface using the time package.
+type SystemClock struct{}
+
+// Now returns time.Now.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep waits for giving duration or until the context is done.
+func (SystemClock) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	var timer = time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryOption defines a function type which sets the options of Retry.
+type RetryOption func(*retryOptions)
+
+type retryOptions struct {
+	backoff        func(int) time.Duration
+	maxAttempts    int
+	maxElapsed     time.Duration
+	attemptTimeout time.Duration
+	retryable      func(error) bool
+	onRetry        []func(attempt int, err error, wait time.Duration)
+	breaker        *Breaker
+	budget         *Budget
+	clock          Clock
+}
+
+// BackOff sets the function returning how long to wait after giving attempt,
+// starting at 0, e.g ExponentialJitterBackOff or RangedExponential(min, max).
+// Defaults to RangedExponential(100ms, 10s).
+func BackOff(fn func(int) time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.backoff = fn
+	}
+}
+
+// MaxAttempts sets the maximum number of attempts made, including the first.
+// A value of 0 removes the limit, leaving MaxElapsed to end retries.
+// Defaults to 3.
+func MaxAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// MaxElapsed sets the maximum time spent retrying, no attempt is started
+// after it elapses.
+func MaxElapsed(d time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.maxElapsed = d
+	}
+}
+
+// AttemptTimeout sets the timeout of the context received by each attempt.
+func AttemptTimeout(d time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.attemptTimeout = d
+	}
+}
+
+// RetryIf sets the function deciding if an attempt failing with giving
+// error is retried. Defaults to IsRetryable.
+func RetryIf(fn func(error) bool) RetryOption {
+	return func(o *retryOptions) {
+		o.retryable = fn
+	}
+}
+
+// OnRetry adds a function called before waiting for the next attempt with
+// the number of the failed attempt, it's error and the wait. It can be set
+// multiple times.
+func OnRetry(fn func(attempt int, err error, wait time.Duration)) RetryOption {
+	return func(o *retryOptions) {
+		o.onRetry = append(o.onRetry, fn)
+	}
+}
+
+// WithBreaker makes all attempts through giving breaker, retries stop as
+// soon as the breaker rejects an attempt.
+func WithBreaker(breaker *Breaker) RetryOption {
+	return func(o *retryOptions) {
+		o.breaker = breaker
+	}
+}
+
+// WithBudget records every call to Retry as a request of giving budget
+// and withdraws from it before each retry, retries stop with an error
+// matching IsBudgetExhausted once it is exhausted.
+func WithBudget(budget *Budget) RetryOption {
+	return func(o *retryOptions) {
+		o.budget = budget
+	}
+}
+
+// WithClock sets the Clock used to measure elapsed time and to wait
+// between attempts, defaults to SystemClock.
+func WithClock(clock Clock) RetryOption {
+	return func(o *retryOptions) {
+		o.clock = clock
+	}
+}
+
+// IsRetryable returns true for all errors except context cancellations
+// and errors of an open circuit breaker, including errors wrapped by a
+// http.Client.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var root = rootCause(err)
+	return root != context.Canceled && root != ErrCircuitOpen
+}
+
+// RetryOn returns a function for RetryIf which retries only errors
+// wrapping one of giving errors.
+func RetryOn(errs ...error) func(error) bool {
+	return func(err error) bool {
+		var root = rootCause(err)
+		for _, target := range errs {
+			if root == target {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryOnParam returns a function for RetryIf which retries only errors
+// carrying one of giving values for the key in the params of a
+// nerror.PointingError, e.g set with nerror.WrapOnly(err).Add("code", "503").
+func RetryOnParam(key string, values ...string) func(error) bool {
+	return func(err error) bool {
+		for err != nil {
+			var pe, ok = err.(*nerror.PointingError)
+			if !ok {
+				return false
+			}
+			if value, has := pe.Params[key]; has {
+				for _, expected := range values {
+					if value == expected {
+						return true
+					}
+				}
+			}
+			err = pe.Parent
+		}
+		return false
+	}
+}
+
+// RetryError is returned by Retry when no attempt succeeded, holding the
+// errors of all attempts in order.
+type RetryError struct {
+	Errors []error
+}
+
+// Last returns the error of the last attempt.
+func (r *RetryError) Last() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r.Errors[len(r.Errors)-1]
+}
+
+// Unwrap returns the error of the last attempt.
+func (r *RetryError) Unwrap() error {
+	return r.Last()
+}
+
+// Error returns the errors of all attempts, one per line.
+func (r *RetryError) Error() string {
+	var lines = make([]string, len(r.Errors))
+	for index, err := range r.Errors {
+		lines[index] = "- " + err.Error()
+	}
+	return "all attempts failed:\n" + strings.Join(lines, "\n")
+}
+
+// AttemptErrors returns the errors of all attempts if giving error is or
+// wraps a RetryError, else giving error alone.
+func AttemptErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if re, ok := nerror.UnwrapDeep(err).(*RetryError); ok {
+		return re.Errors
+	}
+	return []error{err}
+}
+
+// Retry calls op until it succeeds, waiting between attempts for the
+// duration returned by the backoff of the failed attempt.
+//
+// Retries stop once MaxAttempts attempts were made, MaxElapsed elapsed,
+// an error is not retryable, the breaker set with WithBreaker rejects an
+// attempt, the budget set with WithBudget is exhausted or the context is
+// done. The returned error is then a RetryError holding the errors of all
+// attempts, with the last one being the cause retries stopped. Use
+// AttemptErrors to retrieve them.
+func Retry(ctx context.Context, op func(ctx context.Context) error, ops ...RetryOption) error {
+	var o = retryOptions{
+		backoff:     RangedExponential(100*time.Millisecond, 10*time.Second),
+		maxAttempts: 3,
+		retryable:   IsRetryable,
+		clock:       SystemClock{},
+	}
+	for _, op := range ops {
+		op(&o)
+	}
+
+	if o.budget != nil {
+		o.budget.Request()
+	}
+
+	var errs []error
+	var start = o.clock.Now()
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		var err = o.attempt(ctx, op)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+
+		if !o.retryable(err) || IsCircuitOpen(err) {
+			break
+		}
+		if o.maxAttempts > 0 && attempt+1 >= o.maxAttempts {
+			break
+		}
+
+		var wait = o.backoff(attempt)
+		if o.maxElapsed > 0 && o.clock.Now().Add(wait).Sub(start) >= o.maxElapsed {
+			break
+		}
+
+		if o.budget != nil && !o.budget.Withdraw() {
+			errs = append(errs, nerror.WrapOnly(ErrBudgetExhausted))
+			break
+		}
+
+		for _, fn := range o.onRetry {
+			fn(attempt, err, wait)
+		}
+
+		if err := o.clock.Sleep(ctx, wait); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+	return nerror.WrapOnly(&RetryError{Errors: errs})
+}
+
+func (o *retryOptions) attempt(ctx context.Context, op func(ctx context.Context) error) error {
+	var call = func() error {
+		if o.attemptTimeout <= 0 {
+			return op(ctx)
+		}
+
+		var attemptCtx, cancel = context.WithTimeout(ctx, o.attemptTimeout)
+		defer cancel()
+		return op(attemptCtx)
+	}
+
+	if o.breaker != nil {
+		return o.breaker.Do(call)
+	}
+	return call()
+}
@@ -0,0 +1,171 @@
+package nretries
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/stretchr/testify/require"
+)
+
+// Sleep advances the clock by giving duration without waiting.
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.Add(d)
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	var clock = newFakeClock()
+	var start = clock.Now()
+	var waits []time.Duration
+
+	var calls int
+	var err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errBad
+		}
+		return nil
+	},
+		WithClock(clock),
+		MaxAttempts(5),
+		BackOff(RangedExponential(time.Second, time.Minute)),
+		OnRetry(func(attempt int, err error, wait time.Duration) {
+			require.Equal(t, errBad, err)
+			waits = append(waits, wait)
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	require.Equal(t, 3*time.Second, clock.Now().Sub(start))
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	var calls int
+	var err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		return nerror.New("attempt %d", calls)
+	}, WithClock(newFakeClock()), MaxAttempts(4))
+	require.Error(t, err)
+	require.Equal(t, 4, calls)
+
+	var errs = AttemptErrors(err)
+	require.Len(t, errs, 4)
+	require.Contains(t, errs[3].Error(), "attempt 4")
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	var calls int
+	var err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errBad
+	},
+		WithClock(newFakeClock()),
+		MaxAttempts(0),
+		MaxElapsed(10*time.Second),
+		BackOff(func(int) time.Duration { return 3 * time.Second }),
+	)
+	require.Error(t, err)
+
+	// attempts at 0s, 3s, 6s and the next wait would end past 10s.
+	require.Equal(t, 4, calls)
+}
+
+func TestRetryIf(t *testing.T) {
+	var errFatal = errors.New("fatal")
+
+	var calls int
+	var err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 2 {
+			return nerror.WrapOnly(errFatal)
+		}
+		return errBad
+	}, WithClock(newFakeClock()), MaxAttempts(5), RetryIf(RetryOn(errBad)))
+	require.Equal(t, 2, calls)
+	require.Len(t, AttemptErrors(err), 2)
+	require.Equal(t, errFatal, nerror.UnwrapDeep(AttemptErrors(err)[1]))
+
+	calls = 0
+	err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 2 {
+			return nerror.WrapOnly(errBad).Add("code", "400")
+		}
+		return nerror.WrapOnly(errBad).Add("code", "503")
+	}, WithClock(newFakeClock()), MaxAttempts(5), RetryIf(RetryOnParam("code", "502", "503")))
+	require.Error(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestIsRetryable(t *testing.T) {
+	var wrap = func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://localhost/", Err: err}
+	}
+
+	require.False(t, IsRetryable(nil))
+	require.True(t, IsRetryable(errBad))
+	require.True(t, IsRetryable(wrap(errBad)))
+
+	// the http.Client wraps cancellations in a *url.Error.
+	require.False(t, IsRetryable(context.Canceled))
+	require.False(t, IsRetryable(wrap(context.Canceled)))
+	require.False(t, IsRetryable(wrap(nerror.WrapOnly(context.Canceled))))
+	require.False(t, IsRetryable(wrap(nerror.WrapOnly(ErrCircuitOpen))))
+
+	require.True(t, RetryOn(errBad)(wrap(nerror.WrapOnly(errBad))))
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	var calls int
+	var err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		var _, ok = ctx.Deadline()
+		require.True(t, ok)
+		if calls == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, WithClock(newFakeClock()), AttemptTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestRetryContext(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+
+	var calls int
+	var err = Retry(ctx, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errBad
+	}, WithClock(newFakeClock()), MaxAttempts(5))
+	require.Equal(t, 1, calls)
+
+	var errs = AttemptErrors(err)
+	require.Len(t, errs, 2)
+	require.Equal(t, context.Canceled, errs[1])
+}
+
+func TestRetryWithBreaker(t *testing.T) {
+	var breaker = NewBreaker(MinCalls(2), CoolDown(time.Minute))
+
+	var calls int
+	var err = Retry(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errBad
+	}, WithClock(newFakeClock()), MaxAttempts(10), WithBreaker(breaker))
+	require.Equal(t, 2, calls)
+
+	var errs = AttemptErrors(err)
+	require.Len(t, errs, 3)
+	require.True(t, IsCircuitOpen(errs[2]))
+}
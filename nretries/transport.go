@@ -0,0 +1,189 @@
+package nretries
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// maxDrain is the maximum number of bytes read from the body of a discarded
+// response, allowing it's connection to be reused.
+const maxDrain = 4096
+
+// IsIdempotent returns true if giving request can be sent more than once
+// without changing it's outcome: either it's method is idempotent or it
+// carries an Idempotency-Key header.
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// IsReplayable returns true if giving request is idempotent and it's body,
+// if any, can be sent again using Request.GetBody.
+func IsReplayable(req *http.Request) bool {
+	if !IsIdempotent(req) {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// IsRetryableStatus returns true for status codes of responses which are
+// usually transient: 429, 502, 503 and 504.
+func IsRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// statusError is returned by attempts of a BudgetTransport receiving a
+// response with a retryable status.
+type statusError struct {
+	code int
+}
+
+func (s statusError) Error() string {
+	return "received retryable status " + strconv.Itoa(s.code)
+}
+
+// Replay returns the request sending giving request again for an attempt,
+// with a new body from Request.GetBody after the first attempt.
+func Replay(ctx context.Context, req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 {
+		return req.WithContext(ctx), nil
+	}
+
+	var next = req.Clone(ctx)
+	if req.GetBody != nil {
+		var body, err = req.GetBody()
+		if err != nil {
+			return nil, nerror.WrapOnly(err)
+		}
+		next.Body = body
+	}
+	return next, nil
+}
+
+// DiscardResponse drains up to 4KiB of the body of giving response and
+// closes it, allowing it's connection to be reused.
+func DiscardResponse(res *http.Response) {
+	_, _ = io.CopyN(ioutil.Discard, res.Body, maxDrain)
+	_ = res.Body.Close()
+}
+
+// CancelOnClose returns a body calling cancel once giving body is closed,
+// e.g to cancel the context of a request with it's response body.
+func CancelOnClose(body io.ReadCloser, cancel context.CancelFunc) io.ReadCloser {
+	return cancelBody{ReadCloser: body, cancel: cancel}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelBody) Close() error {
+	var err = c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// RoundTripperFunc implements the http.RoundTripper interface for a function.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls the function with giving request.
+func (fn RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// BudgetTransport returns a http.RoundTripper which retries replayable
+// requests sent through next with Retry, using giving budget to cap the
+// retries, see WithBudget.
+//
+// Requests failing with a transport error or receiving a response with a
+// retryable status are retried, see IsReplayable and IsRetryableStatus. If
+// all attempts receive such a response the last one is returned. The
+// context of attempts is the context of the request, AttemptTimeout must
+// hence not be set as it would cancel the body of the response.
+func BudgetTransport(next http.RoundTripper, budget *Budget, ops ...RetryOption) http.RoundTripper {
+	ops = append(ops, WithBudget(budget))
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !IsReplayable(req) {
+			budget.Request()
+			return next.RoundTrip(req)
+		}
+
+		var res *http.Response
+		var attempt int
+		var err = Retry(req.Context(), func(_ context.Context) error {
+			if res != nil {
+				DiscardResponse(res)
+				res = nil
+			}
+
+			var attemptReq, err = Replay(req.Context(), req, attempt)
+			attempt++
+			if err != nil {
+				return err
+			}
+
+			res, err = next.RoundTrip(attemptReq)
+			if err != nil {
+				return err
+			}
+			if IsRetryableStatus(res.StatusCode) {
+				return statusError{code: res.StatusCode}
+			}
+			return nil
+		}, ops...)
+
+		if res != nil {
+			return res, nil
+		}
+		return nil, err
+	})
+}
+
+// HedgeTransport returns a http.RoundTripper which hedges requests sent
+// through next with giving hedger, responses of attempts which lost are
+// discarded.
+//
+// Only requests with safe methods (GET, HEAD and OPTIONS) which are
+// replayable are hedged, others are sent once.
+func HedgeTransport(next http.RoundTripper, hedger *Hedger) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			return next.RoundTrip(req)
+		}
+		if !IsReplayable(req) {
+			return next.RoundTrip(req)
+		}
+
+		var value, cancel, err = hedger.hedge(req.Context(), func(ctx context.Context) (interface{}, error) {
+			var attemptReq, err = Replay(ctx, req, 1)
+			if err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(attemptReq)
+		}, func(value interface{}) {
+			DiscardResponse(value.(*http.Response))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var res = value.(*http.Response)
+		res.Body = CancelOnClose(res.Body, cancel)
+		return res, nil
+	})
+}
@@ -0,0 +1,332 @@
+package nstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+// ContextByteStore defines the context aware version of the ByteStore, where
+// every method receives a context which governs the deadline and cancellation
+// of the call.
+type ContextByteStore interface {
+	Each(ctx context.Context, fn EachItem) error
+	EachKeyMatch(ctx context.Context, regexp string) ([]string, error)
+	ScanMatch(ctx context.Context, count int64, lastIndex int64, lastKey string, regexp string) (ScanResult, error)
+
+	Count(ctx context.Context) (int64, error)
+	Keys(ctx context.Context) ([]string, error)
+	Save(ctx context.Context, key string, value []byte) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Update(ctx context.Context, key string, value []byte) error
+
+	RemoveKeys(ctx context.Context, keys ...string) error
+	Remove(ctx context.Context, key string) ([]byte, error)
+
+	Get(ctx context.Context, key string) ([]byte, error)
+	GetAllKeys(ctx context.Context, keys ...string) ([][]byte, error)
+	GetAnyKeys(ctx context.Context, keys ...string) ([][]byte, error)
+}
+
+// ContextExpirableStore defines the context aware version of the ExpirableStore.
+type ContextExpirableStore interface {
+	ContextByteStore
+
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	ExtendTTL(ctx context.Context, key string, expiration time.Duration) error
+	ResetTTL(ctx context.Context, key string, expiration time.Duration) error
+	SaveTTL(ctx context.Context, key string, value []byte, expiration time.Duration) error
+	UpdateTTL(ctx context.Context, key string, value []byte, expiration time.Duration) error
+}
+
+//**********************************************************************
+// ContextByteStore to ByteStore
+//**********************************************************************
+
+var (
+	_ ByteStore      = (*ContextAdapter)(nil)
+	_ ExpirableStore = (*ContextExpirableAdapter)(nil)
+)
+
+// ContextAdapter implements the ByteStore over a ContextByteStore, using
+// a single context for all calls.
+type ContextAdapter struct {
+	ctx   context.Context
+	store ContextByteStore
+}
+
+// FromContextStore returns a ByteStore which calls store with giving ctx.
+func FromContextStore(ctx context.Context, store ContextByteStore) *ContextAdapter {
+	return &ContextAdapter{ctx: ctx, store: store}
+}
+
+func (c *ContextAdapter) Each(fn EachItem) error {
+	return c.store.Each(c.ctx, fn)
+}
+
+func (c *ContextAdapter) EachKeyMatch(regexp string) ([]string, error) {
+	return c.store.EachKeyMatch(c.ctx, regexp)
+}
+
+func (c *ContextAdapter) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (ScanResult, error) {
+	return c.store.ScanMatch(c.ctx, count, lastIndex, lastKey, regexp)
+}
+
+func (c *ContextAdapter) Count() (int64, error) {
+	return c.store.Count(c.ctx)
+}
+
+func (c *ContextAdapter) Keys() ([]string, error) {
+	return c.store.Keys(c.ctx)
+}
+
+func (c *ContextAdapter) Save(key string, value []byte) error {
+	return c.store.Save(c.ctx, key, value)
+}
+
+func (c *ContextAdapter) Exists(key string) (bool, error) {
+	return c.store.Exists(c.ctx, key)
+}
+
+func (c *ContextAdapter) Update(key string, value []byte) error {
+	return c.store.Update(c.ctx, key, value)
+}
+
+func (c *ContextAdapter) RemoveKeys(keys ...string) error {
+	return c.store.RemoveKeys(c.ctx, keys...)
+}
+
+func (c *ContextAdapter) Remove(key string) ([]byte, error) {
+	return c.store.Remove(c.ctx, key)
+}
+
+func (c *ContextAdapter) Get(key string) ([]byte, error) {
+	return c.store.Get(c.ctx, key)
+}
+
+func (c *ContextAdapter) GetAllKeys(keys ...string) ([][]byte, error) {
+	return c.store.GetAllKeys(c.ctx, keys...)
+}
+
+func (c *ContextAdapter) GetAnyKeys(keys ...string) ([][]byte, error) {
+	return c.store.GetAnyKeys(c.ctx, keys...)
+}
+
+// ContextExpirableAdapter implements the ExpirableStore over a
+// ContextExpirableStore, using a single context for all calls.
+type ContextExpirableAdapter struct {
+	*ContextAdapter
+	store ContextExpirableStore
+}
+
+// FromContextExpirableStore returns a ExpirableStore which calls store with giving ctx.
+func FromContextExpirableStore(ctx context.Context, store ContextExpirableStore) *ContextExpirableAdapter {
+	return &ContextExpirableAdapter{
+		ContextAdapter: FromContextStore(ctx, store),
+		store:          store,
+	}
+}
+
+func (c *ContextExpirableAdapter) TTL(key string) (time.Duration, error) {
+	return c.store.TTL(c.ctx, key)
+}
+
+func (c *ContextExpirableAdapter) ExtendTTL(key string, expiration time.Duration) error {
+	return c.store.ExtendTTL(c.ctx, key, expiration)
+}
+
+func (c *ContextExpirableAdapter) ResetTTL(key string, expiration time.Duration) error {
+	return c.store.ResetTTL(c.ctx, key, expiration)
+}
+
+func (c *ContextExpirableAdapter) SaveTTL(key string, value []byte, expiration time.Duration) error {
+	return c.store.SaveTTL(c.ctx, key, value, expiration)
+}
+
+func (c *ContextExpirableAdapter) UpdateTTL(key string, value []byte, expiration time.Duration) error {
+	return c.store.UpdateTTL(c.ctx, key, value, expiration)
+}
+
+//**********************************************************************
+// ByteStore to ContextByteStore
+//**********************************************************************
+
+var (
+	_ ContextByteStore      = (*StoreAdapter)(nil)
+	_ ContextExpirableStore = (*ExpirableStoreAdapter)(nil)
+)
+
+// StoreAdapter implements the ContextByteStore over a ByteStore.
+//
+// Since a ByteStore can not be interrupted, the context is checked before
+// every call and between items when iterating, a call already running
+// will complete even if the context is cancelled.
+type StoreAdapter struct {
+	store ByteStore
+}
+
+// ToContextStore returns a ContextByteStore which calls store.
+func ToContextStore(store ByteStore) *StoreAdapter {
+	return &StoreAdapter{store: store}
+}
+
+// CheckContext returns an error if giving context is cancelled or
+// it's deadline has passed.
+func CheckContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+func (s *StoreAdapter) Each(ctx context.Context, fn EachItem) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+
+	var ctxErr error
+	var err = s.store.Each(func(value []byte, key string) error {
+		if ctxErr = CheckContext(ctx); ctxErr != nil {
+			return ErrJustStop
+		}
+		return fn(value, key)
+	})
+	if ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (s *StoreAdapter) EachKeyMatch(ctx context.Context, regexp string) ([]string, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.EachKeyMatch(regexp)
+}
+
+func (s *StoreAdapter) ScanMatch(ctx context.Context, count int64, lastIndex int64, lastKey string, regexp string) (ScanResult, error) {
+	if err := CheckContext(ctx); err != nil {
+		return ScanResult{}, err
+	}
+	return s.store.ScanMatch(count, lastIndex, lastKey, regexp)
+}
+
+func (s *StoreAdapter) Count(ctx context.Context) (int64, error) {
+	if err := CheckContext(ctx); err != nil {
+		return -1, err
+	}
+	return s.store.Count()
+}
+
+func (s *StoreAdapter) Keys(ctx context.Context) ([]string, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.Keys()
+}
+
+func (s *StoreAdapter) Save(ctx context.Context, key string, value []byte) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.Save(key, value)
+}
+
+func (s *StoreAdapter) Exists(ctx context.Context, key string) (bool, error) {
+	if err := CheckContext(ctx); err != nil {
+		return false, err
+	}
+	return s.store.Exists(key)
+}
+
+func (s *StoreAdapter) Update(ctx context.Context, key string, value []byte) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.Update(key, value)
+}
+
+func (s *StoreAdapter) RemoveKeys(ctx context.Context, keys ...string) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.RemoveKeys(keys...)
+}
+
+func (s *StoreAdapter) Remove(ctx context.Context, key string) ([]byte, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.Remove(key)
+}
+
+func (s *StoreAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.Get(key)
+}
+
+func (s *StoreAdapter) GetAllKeys(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.GetAllKeys(keys...)
+}
+
+func (s *StoreAdapter) GetAnyKeys(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.GetAnyKeys(keys...)
+}
+
+// ExpirableStoreAdapter implements the ContextExpirableStore over a ExpirableStore.
+type ExpirableStoreAdapter struct {
+	*StoreAdapter
+	store ExpirableStore
+}
+
+// ToContextExpirableStore returns a ContextExpirableStore which calls store.
+func ToContextExpirableStore(store ExpirableStore) *ExpirableStoreAdapter {
+	return &ExpirableStoreAdapter{
+		StoreAdapter: ToContextStore(store),
+		store:        store,
+	}
+}
+
+func (s *ExpirableStoreAdapter) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	return s.store.TTL(key)
+}
+
+func (s *ExpirableStoreAdapter) ExtendTTL(ctx context.Context, key string, expiration time.Duration) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.ExtendTTL(key, expiration)
+}
+
+func (s *ExpirableStoreAdapter) ResetTTL(ctx context.Context, key string, expiration time.Duration) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.ResetTTL(key, expiration)
+}
+
+func (s *ExpirableStoreAdapter) SaveTTL(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.SaveTTL(key, value, expiration)
+}
+
+func (s *ExpirableStoreAdapter) UpdateTTL(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
+	}
+	return s.store.UpdateTTL(key, value, expiration)
+}
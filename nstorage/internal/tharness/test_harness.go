@@ -1,6 +1,7 @@
 package tharness
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -261,6 +262,72 @@ func TestExpirableStore(t *testing.T, store nstorage.ExpirableStore) {
 	require.True(t, ttl > ttl2, fmt.Sprintf("TTL1: %q, TTL2: %q", ttl, ttl2))
 }
 
+func TestContextStore(t *testing.T, store nstorage.ContextByteStore) {
+	var ctx = context.Background()
+	for i := 0; i < 10; i++ {
+		var key = fmt.Sprintf("day-%d", i)
+		require.NoError(t, store.Save(ctx, key, string2Bytes("i")))
+	}
+
+	var val, err = store.Get(ctx, "day-1")
+	require.NoError(t, err)
+	require.Equal(t, "i", bytes2String(val))
+
+	var cancelled, cancel = context.WithCancel(ctx)
+	cancel()
+
+	require.Error(t, store.Save(cancelled, "night", string2Bytes("wrecker")))
+
+	_, err = store.Get(cancelled, "day-1")
+	require.Error(t, err)
+
+	_, err = store.Keys(cancelled)
+	require.Error(t, err)
+
+	var visited int
+	require.Error(t, store.Each(cancelled, func(_ []byte, _ string) error {
+		visited++
+		return nil
+	}))
+	require.Equal(t, 0, visited)
+
+	_, err = store.Get(ctx, "night")
+	require.Error(t, err)
+
+	var stopAt, stop = context.WithCancel(ctx)
+	defer stop()
+
+	visited = 0
+	require.Error(t, store.Each(stopAt, func(_ []byte, _ string) error {
+		visited++
+		stop()
+		return nil
+	}))
+	require.Equal(t, 1, visited)
+}
+
+func TestContextExpirableStore(t *testing.T, store nstorage.ContextExpirableStore) {
+	var ctx = context.Background()
+	require.NoError(t, store.SaveTTL(ctx, "day", string2Bytes("wrecker"), 3*time.Second))
+
+	var ttl, err = store.TTL(ctx, "day")
+	require.NoError(t, err)
+	require.True(t, ttl > 0)
+
+	var cancelled, cancel = context.WithCancel(ctx)
+	cancel()
+
+	_, err = store.TTL(cancelled, "day")
+	require.Error(t, err)
+	require.Error(t, store.ExtendTTL(cancelled, "day", time.Second))
+	require.Error(t, store.UpdateTTL(cancelled, "day", string2Bytes("tweeter"), time.Second))
+
+	var val []byte
+	val, err = store.Get(ctx, "day")
+	require.NoError(t, err)
+	require.Equal(t, "wrecker", bytes2String(val))
+}
+
 func bytes2String(bc []byte) string {
 	return *(*string)(unsafe.Pointer(&bc))
 }
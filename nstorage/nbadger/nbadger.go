@@ -1,6 +1,7 @@
 package nbadger
 
 import (
+	"context"
 	regexp2 "regexp"
 	"time"
 
@@ -11,19 +12,25 @@ import (
 	"github.com/influx6/npkg/nunsafe"
 )
 
-var _ nstorage.ExpirableStore = (*BadgerStore)(nil)
+var (
+	_ nstorage.ExpirableStore        = (*BadgerStore)(nil)
+	_ nstorage.ContextExpirableStore = (*ContextBadgerStore)(nil)
+)
 
-// BadgerStore implements session management, storage and access using Badger as
+// ContextBadgerStore implements session management, storage and access using Badger as
 // underline store.
-type BadgerStore struct {
+//
+// Since badger has no notion of a context, the context is checked before
+// each transaction and between items when iterating.
+type ContextBadgerStore struct {
 	ops  badger.Options
 	iter badger.IteratorOptions
 	Db   *badger.DB
 }
 
-// NewBadgerStore returns a new instance of a Badger store using provided prefix if present.
-func NewBadgerStore(ops badger.Options, iterator badger.IteratorOptions) (*BadgerStore, error) {
-	var red BadgerStore
+// NewContextBadgerStore returns a new instance of a context aware Badger store.
+func NewContextBadgerStore(ops badger.Options, iterator badger.IteratorOptions) (*ContextBadgerStore, error) {
+	var red ContextBadgerStore
 	red.ops = ops
 	red.iter = iterator
 	if err := red.createConnection(); err != nil {
@@ -33,7 +40,7 @@ func NewBadgerStore(ops badger.Options, iterator badger.IteratorOptions) (*Badge
 }
 
 // createConnection attempts to create a new Badger connection.
-func (rd *BadgerStore) createConnection() error {
+func (rd *ContextBadgerStore) createConnection() error {
 	db, err := badger.Open(rd.ops)
 	if err != nil {
 		return nerror.WrapOnly(err)
@@ -43,7 +50,11 @@ func (rd *BadgerStore) createConnection() error {
 }
 
 // Keys returns all giving keys of elements within store.
-func (rd *BadgerStore) Keys() ([]string, error) {
+func (rd *ContextBadgerStore) Keys(ctx context.Context) ([]string, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var keys = make([]string, 0)
 	var err = rd.Db.View(func(txn *badger.Txn) error {
 		var iteratorOption = rd.iter
@@ -52,6 +63,9 @@ func (rd *BadgerStore) Keys() ([]string, error) {
 		defer iterator.Close()
 
 		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			var item = iterator.Item()
 			if item.IsDeletedOrExpired() {
 				continue
@@ -66,7 +80,11 @@ func (rd *BadgerStore) Keys() ([]string, error) {
 // ScanMatch uses the value of lastKey instead the index to allow scanning keys over
 // a giving range, this is important and should be maintained and provided for
 // this to work with BadgerDB.
-func (rd *BadgerStore) ScanMatch(count int64, _ int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+func (rd *ContextBadgerStore) ScanMatch(ctx context.Context, count int64, _ int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nstorage.ScanResult{}, err
+	}
+
 	if len(regexp) == 0 {
 		regexp = ".+"
 	}
@@ -89,6 +107,9 @@ func (rd *BadgerStore) ScanMatch(count int64, _ int64, lastKey string, regexp st
 		}
 
 		for ; iterator.Valid(); iterator.Next() {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			var item = iterator.Item()
 			if item.IsDeletedOrExpired() {
 				continue
@@ -123,7 +144,11 @@ func (rd *BadgerStore) ScanMatch(count int64, _ int64, lastKey string, regexp st
 }
 
 // EachKeyMatch returns all matching results within using giving functions.
-func (rd *BadgerStore) EachKeyMatch(regexp string) (keys []string, err error) {
+func (rd *ContextBadgerStore) EachKeyMatch(ctx context.Context, regexp string) (keys []string, err error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var generatedRegEx *regexp2.Regexp
 	var rgErr error
 
@@ -140,6 +165,9 @@ func (rd *BadgerStore) EachKeyMatch(regexp string) (keys []string, err error) {
 		defer iterator.Close()
 
 		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			var item = iterator.Item()
 			if item.IsDeletedOrExpired() {
 				continue
@@ -154,9 +182,27 @@ func (rd *BadgerStore) EachKeyMatch(regexp string) (keys []string, err error) {
 	return
 }
 
-func (rd *BadgerStore) Count() (int64, error) {
+func (rd *ContextBadgerStore) Count(ctx context.Context) (int64, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return -1, err
+	}
+
 	var count int64
 	var readErr = rd.Db.View(func(txn *badger.Txn) error {
+		var iteratorOption = rd.iter
+		iteratorOption.PrefetchValues = false
+		var iterator = txn.NewIterator(iteratorOption)
+		defer iterator.Close()
+
+		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
+			if iterator.Item().IsDeletedOrExpired() {
+				continue
+			}
+			count++
+		}
 		return nil
 	})
 	if readErr != nil {
@@ -173,12 +219,19 @@ func (rd *BadgerStore) Count() (int64, error) {
 // be re-used for efficient memory management, so ensure to copy
 // given byte slice yourself within function to protect against
 // undefined behaviour.
-func (rd *BadgerStore) Each(fn nstorage.EachItem) error {
+func (rd *ContextBadgerStore) Each(ctx context.Context, fn nstorage.EachItem) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	return rd.Db.View(func(txn *badger.Txn) error {
 		var iterator = txn.NewIterator(rd.iter)
 		defer iterator.Close()
 
 		for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			var item = iterator.Item()
 			if item.IsDeletedOrExpired() {
 				continue
@@ -208,7 +261,11 @@ func (rd *BadgerStore) Each(fn nstorage.EachItem) error {
 }
 
 // Exists returns true/false if giving key exists.
-func (rd *BadgerStore) Exists(key string) (bool, error) {
+func (rd *ContextBadgerStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
 	var exist bool
 	if err := rd.Db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(nunsafe.String2Bytes(key))
@@ -229,10 +286,17 @@ func (rd *BadgerStore) Exists(key string) (bool, error) {
 // GetAnyKeys returns a list of values for any of the key's found.
 // Unless a specific error occurred retrieving the value of a key, if a
 // key is not found then it is ignored and a nil is set in it's place.
-func (rd *BadgerStore) GetAnyKeys(keys ...string) ([][]byte, error) {
+func (rd *ContextBadgerStore) GetAnyKeys(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var values = make([][]byte, 0, len(keys))
 	if err := rd.Db.View(func(txn *badger.Txn) error {
 		for _, key := range keys {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			item, err := txn.Get(nunsafe.String2Bytes(key))
 			if err != nil {
 				return nerror.WrapOnly(err)
@@ -258,10 +322,17 @@ func (rd *BadgerStore) GetAnyKeys(keys ...string) ([][]byte, error) {
 // GetAllKeys returns a list of values for any of the key's found.
 // if the value of a key is not found then we stop immediately, returning
 // an error and the current set of items retreived.
-func (rd *BadgerStore) GetAllKeys(keys ...string) ([][]byte, error) {
+func (rd *ContextBadgerStore) GetAllKeys(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var values = make([][]byte, 0, len(keys))
 	if err := rd.Db.View(func(txn *badger.Txn) error {
 		for _, key := range keys {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			item, err := txn.Get(nunsafe.String2Bytes(key))
 			if err != nil {
 				return nerror.WrapOnly(err)
@@ -285,7 +356,11 @@ func (rd *BadgerStore) GetAllKeys(keys ...string) ([][]byte, error) {
 
 // Get returns giving session stored with giving key, returning an
 // error if not found.
-func (rd *BadgerStore) Get(key string) ([]byte, error) {
+func (rd *ContextBadgerStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var value []byte
 	if err := rd.Db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(nunsafe.String2Bytes(key))
@@ -309,14 +384,18 @@ func (rd *BadgerStore) Get(key string) ([]byte, error) {
 }
 
 // Save adds giving session into storage using Badger as underline store.
-func (rd *BadgerStore) Save(key string, data []byte) error {
-	return rd.SaveTTL(key, data, 0)
+func (rd *ContextBadgerStore) Save(ctx context.Context, key string, data []byte) error {
+	return rd.SaveTTL(ctx, key, data, 0)
 }
 
 // SaveTTL adds giving session into storage using Badger as underline store, with provided
 // expiration.
 // Duration of 0 means no expiration.
-func (rd *BadgerStore) SaveTTL(key string, data []byte, expiration time.Duration) error {
+func (rd *ContextBadgerStore) SaveTTL(ctx context.Context, key string, data []byte, expiration time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	return rd.Db.Update(func(txn *badger.Txn) error {
 		var op badger.Entry
 		op.Value = data
@@ -334,7 +413,11 @@ func (rd *BadgerStore) SaveTTL(key string, data []byte, expiration time.Duration
 }
 
 // TTL returns giving expiration time for giving key.
-func (rd *BadgerStore) TTL(key string) (time.Duration, error) {
+func (rd *ContextBadgerStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
 	var ttl time.Duration
 	var err = rd.Db.Update(func(txn *badger.Txn) error {
 		var item, err = txn.Get(nunsafe.String2Bytes(key))
@@ -355,19 +438,23 @@ func (rd *BadgerStore) TTL(key string) (time.Duration, error) {
 }
 
 // Close updates to disk.
-func (rd *BadgerStore) Close() error {
+func (rd *ContextBadgerStore) Close() error {
 	return rd.Db.Close()
 }
 
 // Sync updates to disk.
-func (rd *BadgerStore) Sync() error {
+func (rd *ContextBadgerStore) Sync() error {
 	return rd.Db.Sync()
 }
 
 // ExtendTTL resets new TTL for giving key if it has not expired and is still accessible.
 //
 // A expiration value of zero means to persist the giving key.
-func (rd *BadgerStore) ExtendTTL(key string, expiration time.Duration) error {
+func (rd *ContextBadgerStore) ExtendTTL(ctx context.Context, key string, expiration time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	return rd.Db.Update(func(txn *badger.Txn) error {
 		var item, err = txn.Get(nunsafe.String2Bytes(key))
 		if err != nil {
@@ -411,7 +498,11 @@ func (rd *BadgerStore) ExtendTTL(key string, expiration time.Duration) error {
 // expired yet.
 //
 // A expiration value of zero means to persist the giving key.
-func (rd *BadgerStore) ResetTTL(key string, expiration time.Duration) error {
+func (rd *ContextBadgerStore) ResetTTL(ctx context.Context, key string, expiration time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	return rd.Db.Update(func(txn *badger.Txn) error {
 		var item, err = txn.Get(nunsafe.String2Bytes(key))
 		if err != nil {
@@ -443,8 +534,8 @@ func (rd *BadgerStore) ResetTTL(key string, expiration time.Duration) error {
 
 // Update updates giving session stored with giving key. It updates
 // the underline data for key, setting a 0 ttl duration.
-func (rd *BadgerStore) Update(key string, data []byte) error {
-	return rd.UpdateTTL(key, data, 0)
+func (rd *ContextBadgerStore) Update(ctx context.Context, key string, data []byte) error {
+	return rd.UpdateTTL(ctx, key, data, 0)
 }
 
 // UpdateTTL updates giving session stored with giving key. It updates
@@ -453,7 +544,11 @@ func (rd *BadgerStore) Update(key string, data []byte) error {
 //
 // if expiration is zero then giving value expiration will not be reset but left
 // as is.
-func (rd *BadgerStore) UpdateTTL(key string, data []byte, expiration time.Duration) error {
+func (rd *ContextBadgerStore) UpdateTTL(ctx context.Context, key string, data []byte, expiration time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	return rd.Db.Update(func(txn *badger.Txn) error {
 		var item, err = txn.Get(nunsafe.String2Bytes(key))
 		if err != nil {
@@ -481,7 +576,11 @@ func (rd *BadgerStore) UpdateTTL(key string, data []byte, expiration time.Durati
 
 // Remove removes underline key from the Badger store after retrieving it and
 // returning giving session.
-func (rd *BadgerStore) Remove(key string) ([]byte, error) {
+func (rd *ContextBadgerStore) Remove(ctx context.Context, key string) ([]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var old []byte
 	err := rd.Db.Update(func(txn *badger.Txn) error {
 		var item, err = txn.Get(nunsafe.String2Bytes(key))
@@ -501,14 +600,20 @@ func (rd *BadgerStore) Remove(key string) ([]byte, error) {
 }
 
 // RemoveKeys removes all keys found in the store.
-func (rd *BadgerStore) RemoveKeys(keys ...string) error {
+func (rd *ContextBadgerStore) RemoveKeys(ctx context.Context, keys ...string) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	if err := rd.Db.Update(func(txn *badger.Txn) error {
 		for _, key := range keys {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				return err
+			}
 			err := txn.Delete(nunsafe.String2Bytes(key))
 			if err != nil && err != badger.ErrEmptyKey && err != badger.ErrKeyNotFound {
 				return nerror.WrapOnly(err)
 			}
-			return err
 		}
 
 		return nil
@@ -518,6 +623,121 @@ func (rd *BadgerStore) RemoveKeys(keys ...string) error {
 	return nil
 }
 
+//**********************************************************************
+// BadgerStore
+//**********************************************************************
+
+// BadgerStore implements session management, storage and access using Badger as
+// underline store, calling the ContextBadgerStore with a background context.
+type BadgerStore struct {
+	*ContextBadgerStore
+	ctx context.Context
+}
+
+// NewBadgerStore returns a new instance of a Badger store using provided prefix if present.
+func NewBadgerStore(ops badger.Options, iterator badger.IteratorOptions) (*BadgerStore, error) {
+	var store, err = NewContextBadgerStore(ops, iterator)
+	if err != nil {
+		return nil, err
+	}
+	return &BadgerStore{ContextBadgerStore: store, ctx: context.Background()}, nil
+}
+
+// Context returns the underline context aware store.
+func (rd *BadgerStore) Context() *ContextBadgerStore {
+	return rd.ContextBadgerStore
+}
+
+// Keys returns all giving keys of elements within store.
+func (rd *BadgerStore) Keys() ([]string, error) {
+	return rd.ContextBadgerStore.Keys(rd.ctx)
+}
+
+// ScanMatch scans keys after lastKey matching giving regexp.
+func (rd *BadgerStore) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	return rd.ContextBadgerStore.ScanMatch(rd.ctx, count, lastIndex, lastKey, regexp)
+}
+
+// EachKeyMatch returns all keys matching giving regexp.
+func (rd *BadgerStore) EachKeyMatch(regexp string) ([]string, error) {
+	return rd.ContextBadgerStore.EachKeyMatch(rd.ctx, regexp)
+}
+
+// Count returns the total count of keys in the store.
+func (rd *BadgerStore) Count() (int64, error) {
+	return rd.ContextBadgerStore.Count(rd.ctx)
+}
+
+// Each runs through all elements for giving store.
+func (rd *BadgerStore) Each(fn nstorage.EachItem) error {
+	return rd.ContextBadgerStore.Each(rd.ctx, fn)
+}
+
+// Exists returns true/false if giving key exists.
+func (rd *BadgerStore) Exists(key string) (bool, error) {
+	return rd.ContextBadgerStore.Exists(rd.ctx, key)
+}
+
+// GetAnyKeys returns a list of values for any of the key's found.
+func (rd *BadgerStore) GetAnyKeys(keys ...string) ([][]byte, error) {
+	return rd.ContextBadgerStore.GetAnyKeys(rd.ctx, keys...)
+}
+
+// GetAllKeys returns a list of values for all of the key's.
+func (rd *BadgerStore) GetAllKeys(keys ...string) ([][]byte, error) {
+	return rd.ContextBadgerStore.GetAllKeys(rd.ctx, keys...)
+}
+
+// Get returns the value stored with giving key.
+func (rd *BadgerStore) Get(key string) ([]byte, error) {
+	return rd.ContextBadgerStore.Get(rd.ctx, key)
+}
+
+// Save adds giving key and value into the store.
+func (rd *BadgerStore) Save(key string, data []byte) error {
+	return rd.ContextBadgerStore.Save(rd.ctx, key, data)
+}
+
+// SaveTTL adds giving key and value into the store with provided expiration.
+func (rd *BadgerStore) SaveTTL(key string, data []byte, expiration time.Duration) error {
+	return rd.ContextBadgerStore.SaveTTL(rd.ctx, key, data, expiration)
+}
+
+// TTL returns giving expiration time for giving key.
+func (rd *BadgerStore) TTL(key string) (time.Duration, error) {
+	return rd.ContextBadgerStore.TTL(rd.ctx, key)
+}
+
+// ExtendTTL extends the expiration of giving key.
+func (rd *BadgerStore) ExtendTTL(key string, expiration time.Duration) error {
+	return rd.ContextBadgerStore.ExtendTTL(rd.ctx, key, expiration)
+}
+
+// ResetTTL resets the expiration of giving key.
+func (rd *BadgerStore) ResetTTL(key string, expiration time.Duration) error {
+	return rd.ContextBadgerStore.ResetTTL(rd.ctx, key, expiration)
+}
+
+// Update updates giving key with new data.
+func (rd *BadgerStore) Update(key string, data []byte) error {
+	return rd.ContextBadgerStore.Update(rd.ctx, key, data)
+}
+
+// UpdateTTL updates giving key with new data and expiration.
+func (rd *BadgerStore) UpdateTTL(key string, data []byte, expiration time.Duration) error {
+	return rd.ContextBadgerStore.UpdateTTL(rd.ctx, key, data, expiration)
+}
+
+// Remove removes giving key from the store, returning it's value.
+func (rd *BadgerStore) Remove(key string) ([]byte, error) {
+	return rd.ContextBadgerStore.Remove(rd.ctx, key)
+}
+
+// RemoveKeys removes all giving keys from the store.
+func (rd *BadgerStore) RemoveKeys(keys ...string) error {
+	return rd.ContextBadgerStore.RemoveKeys(rd.ctx, keys...)
+}
+
 // *****************************************************
 // internal methods
 // *****************************************************
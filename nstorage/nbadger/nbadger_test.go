@@ -96,3 +96,13 @@ func TestBadgerExpiryStoreTT(t *testing.T) {
 
 	tharness.TestExpiryReset(t, store)
 }
+
+func TestContextStoreWithBadgerStore(t *testing.T) {
+	var ops = badger.DefaultOptions("").WithInMemory(true)
+	var store, err = NewContextBadgerStore(ops, badger.DefaultIteratorOptions)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+
+	tharness.TestContextStore(t, store)
+	tharness.TestContextExpirableStore(t, store)
+}
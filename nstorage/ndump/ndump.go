@@ -0,0 +1,459 @@
+// Package ndump implements a streaming snapshot format for nstorage stores,
+// allowing stores to be backed up, restored and copied across backends.
+//
+// A dump is a newline delimited json stream made up of a header line,
+// a line for every record and a footer line. Each record holds it's key,
+// base64 encoded value, remaining ttl in milliseconds and a crc32 checksum
+// of the value, while the footer holds the total record count and a sha256
+// checksum over all records.
+package ndump
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"hash/crc32"
+	"io"
+	regexp2 "regexp"
+	"sort"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+// Format is the name written into the header of every dump.
+const Format = "ndump"
+
+// Version is the current version of the dump format.
+const Version = 1
+
+const (
+	kindHeader = "header"
+	kindRecord = "record"
+	kindFooter = "footer"
+)
+
+// Record holds a single key, it's value and remaining expiration.
+//
+// A zero TTL means the key does not expire.
+type Record struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration
+}
+
+// Header holds the details written at the start of a dump.
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Pattern string    `json:"pattern,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Stats holds the totals of a dump, restore or copy operation.
+type Stats struct {
+	Records int64
+	Bytes   int64
+	Skipped int64
+}
+
+// line is the json representation of every line within a dump.
+type line struct {
+	Kind string `json:"kind"`
+
+	// header fields
+	*Header `json:",omitempty"`
+
+	// record fields
+	Key   string `json:"key,omitempty"`
+	Value []byte `json:"value,omitempty"`
+	TTL   int64  `json:"ttl,omitempty"`
+	CRC   uint32 `json:"crc,omitempty"`
+
+	// footer fields
+	Count  int64  `json:"count,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+//**********************************************************************
+// Writer
+//**********************************************************************
+
+// Writer writes records into a dump stream.
+type Writer struct {
+	w       *bufio.Writer
+	enc     *json.Encoder
+	sum     hash.Hash
+	count   int64
+	bytes   int64
+	started bool
+	closed  bool
+	header  Header
+}
+
+// NewWriter returns a new Writer writing into w, where pattern is recorded
+// into the header as the key pattern used to produce the dump.
+func NewWriter(w io.Writer, pattern string) *Writer {
+	var bw = bufio.NewWriter(w)
+	return &Writer{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+		sum: sha256.New(),
+		header: Header{
+			Format:  Format,
+			Version: Version,
+			Pattern: pattern,
+			Created: time.Now().UTC(),
+		},
+	}
+}
+
+// Write writes giving record into the dump.
+func (w *Writer) Write(record Record) error {
+	if w.closed {
+		return nerror.New("writer is closed")
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	var ttl = record.TTL.Milliseconds()
+	writeChecksum(w.sum, record.Key, record.Value, ttl)
+
+	if err := w.enc.Encode(line{
+		Kind:  kindRecord,
+		Key:   record.Key,
+		Value: record.Value,
+		TTL:   ttl,
+		CRC:   crc32.ChecksumIEEE(record.Value),
+	}); err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	w.count++
+	w.bytes += int64(len(record.Value))
+	return nil
+}
+
+// Close writes the footer of the dump and flushes the underline writer.
+// It does not close the underline writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.closed = true
+	if err := w.enc.Encode(line{
+		Kind:   kindFooter,
+		Count:  w.count,
+		SHA256: hex.EncodeToString(w.sum.Sum(nil)),
+	}); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if err := w.w.Flush(); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+// Stats returns the totals of records written so far.
+func (w *Writer) Stats() Stats {
+	return Stats{Records: w.count, Bytes: w.bytes}
+}
+
+func (w *Writer) writeHeader() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	if err := w.enc.Encode(line{Kind: kindHeader, Header: &w.header}); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+//**********************************************************************
+// Reader
+//**********************************************************************
+
+// Reader reads and verifies records from a dump stream.
+type Reader struct {
+	dec    *json.Decoder
+	sum    hash.Hash
+	count  int64
+	bytes  int64
+	done   bool
+	header Header
+}
+
+// NewReader returns a new Reader for giving stream, reading and
+// validating the header of the dump.
+func NewReader(r io.Reader) (*Reader, error) {
+	var reader = &Reader{
+		dec: json.NewDecoder(bufio.NewReader(r)),
+		sum: sha256.New(),
+	}
+
+	var head line
+	if err := reader.dec.Decode(&head); err != nil {
+		return nil, nerror.Wrap(err, "failed to read dump header")
+	}
+	if head.Kind != kindHeader || head.Header == nil || head.Format != Format {
+		return nil, nerror.New("stream is not a %s dump", Format)
+	}
+	if head.Version > Version {
+		return nil, nerror.New("unsupported dump version %d", head.Version)
+	}
+
+	reader.header = *head.Header
+	return reader, nil
+}
+
+// Header returns the header of the dump.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Stats returns the totals of records read so far.
+func (r *Reader) Stats() Stats {
+	return Stats{Records: r.count, Bytes: r.bytes}
+}
+
+// Next returns the next record within the dump, verifying it's checksum.
+//
+// It returns io.EOF once the footer is read and the checksum of
+// all records is verified.
+func (r *Reader) Next() (Record, error) {
+	if r.done {
+		return Record{}, io.EOF
+	}
+
+	var next line
+	if err := r.dec.Decode(&next); err != nil {
+		if err == io.EOF {
+			return Record{}, nerror.New("dump is truncated, missing footer")
+		}
+		return Record{}, nerror.WrapOnly(err)
+	}
+
+	switch next.Kind {
+	case kindRecord:
+		if crc32.ChecksumIEEE(next.Value) != next.CRC {
+			return Record{}, nerror.New("checksum mismatch for key %q", next.Key)
+		}
+
+		writeChecksum(r.sum, next.Key, next.Value, next.TTL)
+		r.count++
+		r.bytes += int64(len(next.Value))
+		return Record{
+			Key:   next.Key,
+			Value: next.Value,
+			TTL:   time.Duration(next.TTL) * time.Millisecond,
+		}, nil
+	case kindFooter:
+		r.done = true
+		if next.Count != r.count {
+			return Record{}, nerror.New("dump has %d records, footer expects %d", r.count, next.Count)
+		}
+		if hex.EncodeToString(r.sum.Sum(nil)) != next.SHA256 {
+			return Record{}, nerror.New("dump checksum mismatch")
+		}
+		return Record{}, io.EOF
+	default:
+		return Record{}, nerror.New("unknown line kind %q", next.Kind)
+	}
+}
+
+// writeChecksum adds the canonical form of a record into giving hash.
+func writeChecksum(h hash.Hash, key string, value []byte, ttl int64) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(key)))
+	_, _ = h.Write(size[:])
+	_, _ = h.Write([]byte(key))
+	binary.BigEndian.PutUint64(size[:], uint64(len(value)))
+	_, _ = h.Write(size[:])
+	_, _ = h.Write(value)
+	binary.BigEndian.PutUint64(size[:], uint64(ttl))
+	_, _ = h.Write(size[:])
+}
+
+//**********************************************************************
+// Store operations
+//**********************************************************************
+
+// Walk calls fn for every key within store matching giving regexp in key
+// order. An empty regexp matches all keys.
+//
+// The remaining ttl of each key is included if the store implements
+// the nstorage.ExpirableStore, keys which expire during the walk are skipped.
+func Walk(store nstorage.ByteStore, regexp string, fn func(Record) error) error {
+	var regx *regexp2.Regexp
+	if len(regexp) != 0 {
+		var rgErr error
+		if regx, rgErr = regexp2.Compile(regexp); rgErr != nil {
+			return nerror.WrapOnly(rgErr)
+		}
+	}
+
+	var keys, err = store.Keys()
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	sort.Strings(keys)
+
+	var expirable, hasTTL = store.(nstorage.ExpirableStore)
+	for _, key := range keys {
+		if regx != nil && !regx.MatchString(key) {
+			continue
+		}
+
+		var value, getErr = store.Get(key)
+		if getErr != nil {
+			if exists, _ := store.Exists(key); !exists {
+				continue
+			}
+			return nerror.WrapOnly(getErr)
+		}
+
+		var record = Record{Key: key, Value: value}
+		if hasTTL {
+			var ttl, ttlErr = expirable.TTL(key)
+			if ttlErr != nil {
+				return nerror.WrapOnly(ttlErr)
+			}
+			if ttl > 0 {
+				record.TTL = ttl
+			}
+		}
+
+		if err := fn(record); err != nil {
+			if nerror.IsAny(err, nstorage.ErrJustStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Dump writes all keys of store matching giving regexp into w.
+func Dump(w io.Writer, store nstorage.ByteStore, regexp string) (Stats, error) {
+	var writer = NewWriter(w, regexp)
+	if err := Walk(store, regexp, writer.Write); err != nil {
+		return writer.Stats(), err
+	}
+	if err := writer.Close(); err != nil {
+		return writer.Stats(), err
+	}
+	return writer.Stats(), nil
+}
+
+// RestoreOption defines a function type which sets the behaviour of
+// a restore or copy.
+type RestoreOption func(*restoreConfig)
+
+type restoreConfig struct {
+	skipExisting bool
+}
+
+// SkipExisting sets a restore or copy to leave keys already in the target
+// store untouched.
+func SkipExisting() RestoreOption {
+	return func(c *restoreConfig) {
+		c.skipExisting = true
+	}
+}
+
+// Restore writes all records from giving dump stream into store.
+//
+// Records are written as they are read, hence a corrupt or truncated dump
+// leaves the records read before the error in the store, use Verify before
+// hand if that is undesired. Expirations are only restored if the store
+// implements the nstorage.ExpirableStore.
+func Restore(r io.Reader, store nstorage.ByteStore, ops ...RestoreOption) (Stats, error) {
+	var reader, err = NewReader(r)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var save = saver(store, ops)
+	var stats Stats
+	for {
+		var record, readErr = reader.Next()
+		if readErr == io.EOF {
+			return stats, nil
+		}
+		if readErr != nil {
+			return stats, readErr
+		}
+		if err := save(record, &stats); err != nil {
+			return stats, err
+		}
+	}
+}
+
+// Copy copies all keys matching giving regexp from src into dst.
+func Copy(dst nstorage.ByteStore, src nstorage.ByteStore, regexp string, ops ...RestoreOption) (Stats, error) {
+	var save = saver(dst, ops)
+	var stats Stats
+	var err = Walk(src, regexp, func(record Record) error {
+		return save(record, &stats)
+	})
+	return stats, err
+}
+
+// Verify reads through giving dump stream validating all checksums
+// without writing anything.
+func Verify(r io.Reader) (Stats, error) {
+	var reader, err = NewReader(r)
+	if err != nil {
+		return Stats{}, err
+	}
+	for {
+		if _, err := reader.Next(); err != nil {
+			if err == io.EOF {
+				return reader.Stats(), nil
+			}
+			return reader.Stats(), err
+		}
+	}
+}
+
+func saver(store nstorage.ByteStore, ops []RestoreOption) func(Record, *Stats) error {
+	var config restoreConfig
+	for _, op := range ops {
+		op(&config)
+	}
+
+	var expirable, hasTTL = store.(nstorage.ExpirableStore)
+	return func(record Record, stats *Stats) error {
+		if config.skipExisting {
+			var exists, err = store.Exists(record.Key)
+			if err == nil && exists {
+				stats.Skipped++
+				return nil
+			}
+		}
+
+		var err error
+		if hasTTL && record.TTL > 0 {
+			err = expirable.SaveTTL(record.Key, record.Value, record.TTL)
+		} else {
+			err = store.Save(record.Key, record.Value)
+		}
+		if err != nil {
+			return nerror.Wrap(err, "failed to save key %q", record.Key)
+		}
+
+		stats.Records++
+		stats.Bytes += int64(len(record.Value))
+		return nil
+	}
+}
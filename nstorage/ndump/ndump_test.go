@@ -0,0 +1,117 @@
+package ndump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nstorage/nbadger"
+	"github.com/influx6/npkg/nstorage/nmap"
+)
+
+func seed(t *testing.T, store *nmap.ExprByteStore) {
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Save(fmt.Sprintf("day-%d", i), []byte(fmt.Sprintf("value-%d", i))))
+		require.NoError(t, store.SaveTTL(fmt.Sprintf("night-%d", i), []byte("dark"), time.Minute))
+	}
+}
+
+func TestDumpAndRestore(t *testing.T) {
+	var src = nmap.NewExprByteStore(10)
+	seed(t, src)
+
+	var buf bytes.Buffer
+	var stats, err = Dump(&buf, src, "")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), stats.Records)
+
+	var ops = badger.DefaultOptions("").WithInMemory(true).WithLogger(nil)
+	var dst, dstErr = nbadger.NewBadgerStore(ops, badger.DefaultIteratorOptions)
+	require.NoError(t, dstErr)
+	defer dst.Close()
+
+	restored, err := Restore(bytes.NewReader(buf.Bytes()), dst)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), restored.Records)
+
+	var value, getErr = dst.Get("day-3")
+	require.NoError(t, getErr)
+	require.Equal(t, "value-3", string(value))
+
+	var ttl, ttlErr = dst.TTL("night-1")
+	require.NoError(t, ttlErr)
+	require.True(t, ttl > 50*time.Second && ttl <= time.Minute, "ttl %s", ttl)
+
+	ttl, ttlErr = dst.TTL("day-1")
+	require.NoError(t, ttlErr)
+	require.Equal(t, time.Duration(0), ttl)
+}
+
+func TestDumpWithPattern(t *testing.T) {
+	var src = nmap.NewExprByteStore(10)
+	seed(t, src)
+
+	var buf bytes.Buffer
+	var stats, err = Dump(&buf, src, "^night-")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stats.Records)
+
+	var reader, readErr = NewReader(&buf)
+	require.NoError(t, readErr)
+	require.Equal(t, "^night-", reader.Header().Pattern)
+}
+
+func TestCopy(t *testing.T) {
+	var src = nmap.NewExprByteStore(10)
+	seed(t, src)
+
+	var dst = nmap.NewExprByteStore(10)
+	require.NoError(t, dst.Save("day-0", []byte("kept")))
+
+	var stats, err = Copy(dst, src, "^day-", SkipExisting())
+	require.NoError(t, err)
+	require.Equal(t, int64(4), stats.Records)
+	require.Equal(t, int64(1), stats.Skipped)
+
+	var value, getErr = dst.Get("day-0")
+	require.NoError(t, getErr)
+	require.Equal(t, "kept", string(value))
+
+	var count, _ = dst.Count()
+	require.Equal(t, int64(5), count)
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	var src = nmap.NewExprByteStore(10)
+	seed(t, src)
+
+	var buf bytes.Buffer
+	var _, err = Dump(&buf, src, "")
+	require.NoError(t, err)
+
+	var stats, verifyErr = Verify(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, verifyErr)
+	require.Equal(t, int64(10), stats.Records)
+
+	var lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	var truncated = strings.Join(lines[:len(lines)-1], "\n")
+	_, verifyErr = Verify(strings.NewReader(truncated))
+	require.Error(t, verifyErr)
+
+	var dropped = strings.Join(append(append([]string{}, lines[:2]...), lines[3:]...), "\n")
+	_, verifyErr = Verify(strings.NewReader(dropped))
+	require.Error(t, verifyErr)
+
+	var tampered = strings.Replace(buf.String(), `"ttl":`, `"ttl":1`, 1)
+	_, verifyErr = Verify(strings.NewReader(tampered))
+	require.Error(t, verifyErr)
+
+	_, verifyErr = Verify(strings.NewReader(`{"kind":"record"}`))
+	require.Error(t, verifyErr)
+}
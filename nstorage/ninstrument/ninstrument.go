@@ -0,0 +1,397 @@
+// Package ninstrument provides decorators for nstorage stores which record
+// call counts, error counts, latency and value size histograms into a
+// nmetrics.Registry and start an opentracing span for every call.
+package ninstrument
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	"github.com/influx6/npkg/nmetrics"
+	"github.com/influx6/npkg/nstorage"
+	"github.com/influx6/npkg/ntrace"
+)
+
+var (
+	_ nstorage.ByteStore             = (*Store)(nil)
+	_ nstorage.ExpirableStore        = (*ExpirableStore)(nil)
+	_ nstorage.ContextByteStore      = (*ContextStore)(nil)
+	_ nstorage.ContextExpirableStore = (*ContextExpirableStore)(nil)
+)
+
+// Metric name suffixes recorded for every operation, the full name of a
+// metric is "<name>.<operation>.<suffix>", e.g "nstorage.get.latency".
+const (
+	CallsMetric     = "calls"
+	ErrorsMetric    = "errors"
+	LatencyMetric   = "latency"
+	ValueSizeMetric = "value_size"
+)
+
+// Option defines a function type which sets the internal state of a
+// ContextStore.
+type Option func(*ContextStore)
+
+// Name sets the prefix of all metric and span names, defaults to "nstorage".
+func Name(name string) Option {
+	return func(s *ContextStore) {
+		s.name = name
+	}
+}
+
+// Registry sets the registry metrics are recorded into, defaults to
+// nmetrics.DefaultRegistry.
+func Registry(registry *nmetrics.Registry) Option {
+	return func(s *ContextStore) {
+		s.registry = registry
+	}
+}
+
+// Tracer sets the tracer used to start spans, defaults to
+// opentracing.GlobalTracer at the time of each call.
+func Tracer(tracer opentracing.Tracer) Option {
+	return func(s *ContextStore) {
+		s.tracer = tracer
+	}
+}
+
+// HashKeys sets keys to be tagged on spans as a sha256 hash instead
+// of the raw key.
+func HashKeys() Option {
+	return func(s *ContextStore) {
+		s.hashKeys = true
+	}
+}
+
+// Store wraps a nstorage.ByteStore, recording metrics and tracing every
+// call. Spans are started as root spans, use ContextStore for spans to be
+// children of the span of each call's context.
+type Store struct {
+	*nstorage.ContextAdapter
+}
+
+// Instrument returns a new Store wrapping giving store.
+func Instrument(store nstorage.ByteStore, ops ...Option) *Store {
+	var instrumented = InstrumentContext(nstorage.ToContextStore(store), ops...)
+	return &Store{ContextAdapter: nstorage.FromContextStore(context.Background(), instrumented)}
+}
+
+// ExpirableStore wraps a nstorage.ExpirableStore, recording metrics and
+// tracing every call as root spans.
+type ExpirableStore struct {
+	*nstorage.ContextExpirableAdapter
+}
+
+// InstrumentExpirable returns a new ExpirableStore wrapping giving store.
+func InstrumentExpirable(store nstorage.ExpirableStore, ops ...Option) *ExpirableStore {
+	var instrumented = InstrumentContextExpirable(nstorage.ToContextExpirableStore(store), ops...)
+	return &ExpirableStore{ContextExpirableAdapter: nstorage.FromContextExpirableStore(context.Background(), instrumented)}
+}
+
+//**********************************************************************
+// ContextStore
+//**********************************************************************
+
+// ContextStore wraps a nstorage.ContextByteStore, recording metrics and
+// tracing every call. The span of a call is a child of the span found in
+// it's context using ntrace.GetSpanFromContext, else a root span.
+type ContextStore struct {
+	store    nstorage.ContextByteStore
+	name     string
+	registry *nmetrics.Registry
+	tracer   opentracing.Tracer
+	hashKeys bool
+	ops      map[string]*operation
+}
+
+// InstrumentContext returns a new ContextStore wrapping giving store.
+func InstrumentContext(store nstorage.ContextByteStore, ops ...Option) *ContextStore {
+	var s = &ContextStore{
+		store:    store,
+		name:     "nstorage",
+		registry: nmetrics.DefaultRegistry,
+	}
+	for _, op := range ops {
+		op(s)
+	}
+
+	s.ops = make(map[string]*operation, len(operationNames))
+	for _, name := range operationNames {
+		s.ops[name] = newOperation(s.registry, s.name, name)
+	}
+	return s
+}
+
+// operation holds the metrics of a single store method.
+type operation struct {
+	name      string
+	calls     *nmetrics.Counter
+	errors    *nmetrics.Counter
+	latency   *nmetrics.Histogram
+	valueSize *nmetrics.Histogram
+}
+
+func newOperation(registry *nmetrics.Registry, prefix string, name string) *operation {
+	var base = prefix + "." + name + "."
+	return &operation{
+		name:      prefix + "." + name,
+		calls:     registry.Counter(base + CallsMetric),
+		errors:    registry.Counter(base + ErrorsMetric),
+		latency:   registry.Histogram(base+LatencyMetric, nmetrics.LatencyBuckets),
+		valueSize: registry.Histogram(base+ValueSizeMetric, nmetrics.SizeBuckets),
+	}
+}
+
+var operationNames = []string{
+	"each", "each_key_match", "scan_match", "count", "keys", "save", "exists",
+	"update", "remove_keys", "remove", "get", "get_all_keys", "get_any_keys",
+	"ttl", "extend_ttl", "reset_ttl", "save_ttl", "update_ttl",
+}
+
+// call tracks a single running operation.
+type call struct {
+	op    *operation
+	span  opentracing.Span
+	start time.Time
+}
+
+func (s *ContextStore) start(ctx context.Context, name string, keys ...string) *call {
+	var op = s.ops[name]
+
+	var tracer = s.tracer
+	if tracer == nil {
+		tracer = opentracing.GlobalTracer()
+	}
+
+	var spanOps = make([]opentracing.StartSpanOption, 0, 1)
+	if parent, ok := ntrace.GetSpanFromContext(ctx); ok {
+		spanOps = append(spanOps, opentracing.ChildOf(parent.Context()))
+	}
+
+	var span = tracer.StartSpan(op.name, spanOps...)
+	ext.DBType.Set(span, "nstorage")
+	ext.SpanKindRPCClient.Set(span)
+	switch len(keys) {
+	case 0:
+	case 1:
+		ntrace.WithTag(span, "nstorage.key", s.tagKey(keys[0]))
+	default:
+		ntrace.WithTag(span, "nstorage.keys", len(keys))
+	}
+
+	op.calls.Inc()
+	return &call{op: op, span: span, start: time.Now()}
+}
+
+// value records the size of a value read or written by the call.
+func (c *call) value(value []byte) {
+	c.op.valueSize.Observe(float64(len(value)))
+}
+
+func (c *call) end(err error) {
+	c.op.latency.ObserveDuration(time.Since(c.start))
+	if err != nil {
+		c.op.errors.Inc()
+		ext.Error.Set(c.span, true)
+		c.span.SetTag("error.message", err.Error())
+	}
+	c.span.Finish()
+}
+
+func (s *ContextStore) tagKey(key string) string {
+	if !s.hashKeys {
+		return key
+	}
+	var sum = sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Each runs through all elements of underline store.
+func (s *ContextStore) Each(ctx context.Context, fn nstorage.EachItem) error {
+	var c = s.start(ctx, "each")
+	var err = s.store.Each(ctx, func(value []byte, key string) error {
+		c.value(value)
+		return fn(value, key)
+	})
+	c.end(err)
+	return err
+}
+
+// EachKeyMatch returns all keys of underline store matching giving regexp.
+func (s *ContextStore) EachKeyMatch(ctx context.Context, regexp string) ([]string, error) {
+	var c = s.start(ctx, "each_key_match")
+	var keys, err = s.store.EachKeyMatch(ctx, regexp)
+	c.end(err)
+	return keys, err
+}
+
+// ScanMatch scans underline store for keys matching giving regexp.
+func (s *ContextStore) ScanMatch(ctx context.Context, count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	var c = s.start(ctx, "scan_match")
+	var result, err = s.store.ScanMatch(ctx, count, lastIndex, lastKey, regexp)
+	c.end(err)
+	return result, err
+}
+
+// Count returns the total keys of underline store.
+func (s *ContextStore) Count(ctx context.Context) (int64, error) {
+	var c = s.start(ctx, "count")
+	var count, err = s.store.Count(ctx)
+	c.end(err)
+	return count, err
+}
+
+// Keys returns all keys of underline store.
+func (s *ContextStore) Keys(ctx context.Context) ([]string, error) {
+	var c = s.start(ctx, "keys")
+	var keys, err = s.store.Keys(ctx)
+	c.end(err)
+	return keys, err
+}
+
+// Save saves giving key and value into underline store.
+func (s *ContextStore) Save(ctx context.Context, key string, value []byte) error {
+	var c = s.start(ctx, "save", key)
+	c.value(value)
+	var err = s.store.Save(ctx, key, value)
+	c.end(err)
+	return err
+}
+
+// Exists returns true/false if giving key exists.
+func (s *ContextStore) Exists(ctx context.Context, key string) (bool, error) {
+	var c = s.start(ctx, "exists", key)
+	var exists, err = s.store.Exists(ctx, key)
+	c.end(err)
+	return exists, err
+}
+
+// Update updates giving key with value.
+func (s *ContextStore) Update(ctx context.Context, key string, value []byte) error {
+	var c = s.start(ctx, "update", key)
+	c.value(value)
+	var err = s.store.Update(ctx, key, value)
+	c.end(err)
+	return err
+}
+
+// RemoveKeys removes all giving keys.
+func (s *ContextStore) RemoveKeys(ctx context.Context, keys ...string) error {
+	var c = s.start(ctx, "remove_keys", keys...)
+	var err = s.store.RemoveKeys(ctx, keys...)
+	c.end(err)
+	return err
+}
+
+// Remove removes giving key, returning it's value.
+func (s *ContextStore) Remove(ctx context.Context, key string) ([]byte, error) {
+	var c = s.start(ctx, "remove", key)
+	var value, err = s.store.Remove(ctx, key)
+	if err == nil {
+		c.value(value)
+	}
+	c.end(err)
+	return value, err
+}
+
+// Get returns the value of giving key.
+func (s *ContextStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var c = s.start(ctx, "get", key)
+	var value, err = s.store.Get(ctx, key)
+	if err == nil {
+		c.value(value)
+	}
+	c.end(err)
+	return value, err
+}
+
+// GetAllKeys returns the values of all giving keys.
+func (s *ContextStore) GetAllKeys(ctx context.Context, keys ...string) ([][]byte, error) {
+	var c = s.start(ctx, "get_all_keys", keys...)
+	var values, err = s.store.GetAllKeys(ctx, keys...)
+	for _, value := range values {
+		c.value(value)
+	}
+	c.end(err)
+	return values, err
+}
+
+// GetAnyKeys returns the values of any of giving keys found.
+func (s *ContextStore) GetAnyKeys(ctx context.Context, keys ...string) ([][]byte, error) {
+	var c = s.start(ctx, "get_any_keys", keys...)
+	var values, err = s.store.GetAnyKeys(ctx, keys...)
+	for _, value := range values {
+		if value != nil {
+			c.value(value)
+		}
+	}
+	c.end(err)
+	return values, err
+}
+
+//**********************************************************************
+// ContextExpirableStore
+//**********************************************************************
+
+// ContextExpirableStore wraps a nstorage.ContextExpirableStore, recording
+// metrics and tracing every call.
+type ContextExpirableStore struct {
+	*ContextStore
+	expirable nstorage.ContextExpirableStore
+}
+
+// InstrumentContextExpirable returns a new ContextExpirableStore wrapping
+// giving store.
+func InstrumentContextExpirable(store nstorage.ContextExpirableStore, ops ...Option) *ContextExpirableStore {
+	return &ContextExpirableStore{
+		ContextStore: InstrumentContext(store, ops...),
+		expirable:    store,
+	}
+}
+
+// TTL returns the remaining expiration of giving key.
+func (s *ContextExpirableStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var c = s.start(ctx, "ttl", key)
+	var ttl, err = s.expirable.TTL(ctx, key)
+	c.end(err)
+	return ttl, err
+}
+
+// ExtendTTL extends the expiration of giving key.
+func (s *ContextExpirableStore) ExtendTTL(ctx context.Context, key string, expiration time.Duration) error {
+	var c = s.start(ctx, "extend_ttl", key)
+	var err = s.expirable.ExtendTTL(ctx, key, expiration)
+	c.end(err)
+	return err
+}
+
+// ResetTTL resets the expiration of giving key.
+func (s *ContextExpirableStore) ResetTTL(ctx context.Context, key string, expiration time.Duration) error {
+	var c = s.start(ctx, "reset_ttl", key)
+	var err = s.expirable.ResetTTL(ctx, key, expiration)
+	c.end(err)
+	return err
+}
+
+// SaveTTL saves giving key and value with provided expiration.
+func (s *ContextExpirableStore) SaveTTL(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	var c = s.start(ctx, "save_ttl", key)
+	c.value(value)
+	var err = s.expirable.SaveTTL(ctx, key, value, expiration)
+	c.end(err)
+	return err
+}
+
+// UpdateTTL updates giving key with value and provided expiration.
+func (s *ContextExpirableStore) UpdateTTL(ctx context.Context, key string, value []byte, expiration time.Duration) error {
+	var c = s.start(ctx, "update_ttl", key)
+	c.value(value)
+	var err = s.expirable.UpdateTTL(ctx, key, value, expiration)
+	c.end(err)
+	return err
+}
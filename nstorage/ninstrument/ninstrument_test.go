@@ -0,0 +1,71 @@
+package ninstrument
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nmetrics"
+	"github.com/influx6/npkg/nstorage"
+	"github.com/influx6/npkg/nstorage/internal/tharness"
+	"github.com/influx6/npkg/nstorage/nmap"
+	"github.com/influx6/npkg/ntrace"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	var store = InstrumentExpirable(nmap.NewExprByteStore(100), Registry(nmetrics.NewRegistry()), Tracer(mocktracer.New()))
+	tharness.TestByteStore(t, store)
+	tharness.TestExpirableStore(t, store)
+}
+
+func TestInstrumentedStoreMetrics(t *testing.T) {
+	var registry = nmetrics.NewRegistry()
+	var store = InstrumentExpirable(nmap.NewExprByteStore(100), Name("sessions"), Registry(registry), Tracer(mocktracer.New()))
+
+	require.NoError(t, store.Save("day", []byte("wrecker")))
+	var _, err = store.Get("day")
+	require.NoError(t, err)
+	_, err = store.Get("night")
+	require.Error(t, err)
+
+	var snap = registry.Snapshot()
+	require.Equal(t, int64(1), snap.Counters["sessions.save.calls"])
+	require.Equal(t, int64(0), snap.Counters["sessions.save.errors"])
+	require.Equal(t, int64(2), snap.Counters["sessions.get.calls"])
+	require.Equal(t, int64(1), snap.Counters["sessions.get.errors"])
+	require.Equal(t, uint64(2), snap.Histograms["sessions.get.latency"].Count)
+	require.Equal(t, uint64(1), snap.Histograms["sessions.get.value_size"].Count)
+	require.Equal(t, float64(7), snap.Histograms["sessions.save.value_size"].Sum)
+}
+
+func TestInstrumentedStoreSpans(t *testing.T) {
+	var tracer = mocktracer.New()
+	var store = InstrumentContext(nstorage.ToContextStore(nmap.NewExprByteStore(100)),
+		Registry(nmetrics.NewRegistry()), Tracer(tracer), HashKeys())
+
+	// every call is a child of the span of it's own context.
+	var first = tracer.StartSpan("first")
+	var second = tracer.StartSpan("second")
+	var _, err = store.Get(context.WithValue(context.Background(), ntrace.SpanKey, first), "day")
+	require.Error(t, err)
+	require.NoError(t, store.Save(context.WithValue(context.Background(), ntrace.SpanKey, second), "day", []byte("wrecker")))
+	_, err = store.Exists(context.Background(), "day")
+	require.NoError(t, err)
+
+	var spans = tracer.FinishedSpans()
+	require.Len(t, spans, 3)
+
+	var span = spans[0]
+	require.Equal(t, "nstorage.get", span.OperationName)
+	require.Equal(t, first.Context().(mocktracer.MockSpanContext).SpanID, span.ParentID)
+	require.Equal(t, true, span.Tag("error"))
+	require.Equal(t, "nstorage", span.Tag("db.type"))
+	require.NotEqual(t, "day", span.Tag("nstorage.key"))
+	require.Len(t, span.Tag("nstorage.key"), 64)
+
+	require.Equal(t, "nstorage.save", spans[1].OperationName)
+	require.Equal(t, second.Context().(mocktracer.MockSpanContext).SpanID, spans[1].ParentID)
+	require.Equal(t, 0, spans[2].ParentID)
+}
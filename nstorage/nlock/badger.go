@@ -0,0 +1,163 @@
+package nlock
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+var _ Store = (*BadgerStore)(nil)
+
+// BadgerStore implements the Store interface using badger transactions,
+// locking keys among all users of the same database.
+//
+// Badger expires entries with a precision of seconds, hence the expiry of
+// a lock is stored with it's owner and entries only use a ttl to be
+// cleaned up.
+type BadgerStore struct {
+	prefix string
+	Db     *badger.DB
+}
+
+// NewBadgerStore returns a new BadgerStore using giving database, all keys
+// are prefixed with giving prefix.
+func NewBadgerStore(prefix string, db *badger.DB) *BadgerStore {
+	return &BadgerStore{prefix: prefix, Db: db}
+}
+
+func (bd *BadgerStore) lockKey(key string) []byte {
+	return []byte(bd.prefix + key + ":lock")
+}
+
+func (bd *BadgerStore) tokenKey(key string) []byte {
+	return []byte(bd.prefix + key + ":token")
+}
+
+// Acquire sets owner as the holder of key if key is not held.
+func (bd *BadgerStore) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (uint64, error) {
+	var token uint64
+	var err = bd.update(ctx, func(txn *badger.Txn, now time.Time) error {
+		var current, expires, err = bd.holder(txn, key)
+		if err != nil {
+			return err
+		}
+		if current != "" && now.Before(expires) {
+			return nerror.WrapOnly(ErrLockHeld)
+		}
+
+		token = 0
+		var item, getErr = txn.Get(bd.tokenKey(key))
+		switch {
+		case getErr == badger.ErrKeyNotFound:
+		case getErr != nil:
+			return nerror.WrapOnly(getErr)
+		default:
+			if err := item.Value(func(val []byte) error {
+				token = binary.BigEndian.Uint64(val)
+				return nil
+			}); err != nil {
+				return nerror.WrapOnly(err)
+			}
+		}
+		token++
+
+		var tokenBytes = make([]byte, 8)
+		binary.BigEndian.PutUint64(tokenBytes, token)
+		if err := txn.Set(bd.tokenKey(key), tokenBytes); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return bd.setHolder(txn, key, owner, now.Add(ttl), ttl)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return token, nil
+}
+
+// Renew resets the ttl of key if it is still held by owner.
+func (bd *BadgerStore) Renew(ctx context.Context, key string, owner string, ttl time.Duration) error {
+	return bd.update(ctx, func(txn *badger.Txn, now time.Time) error {
+		var current, expires, err = bd.holder(txn, key)
+		if err != nil {
+			return err
+		}
+		if current != owner || !now.Before(expires) {
+			return nerror.WrapOnly(ErrLockLost)
+		}
+		return bd.setHolder(txn, key, owner, now.Add(ttl), ttl)
+	})
+}
+
+// Release removes key if it is still held by owner.
+func (bd *BadgerStore) Release(ctx context.Context, key string, owner string) error {
+	return bd.update(ctx, func(txn *badger.Txn, now time.Time) error {
+		var current, expires, err = bd.holder(txn, key)
+		if err != nil {
+			return err
+		}
+		if current != owner || !now.Before(expires) {
+			return nerror.WrapOnly(ErrLockLost)
+		}
+		if err := txn.Delete(bd.lockKey(key)); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return nil
+	})
+}
+
+// update runs fn in a transaction, retrying it on conflicts with other
+// transactions until it succeeds or the context is cancelled.
+func (bd *BadgerStore) update(ctx context.Context, fn func(txn *badger.Txn, now time.Time) error) error {
+	for {
+		if err := nstorage.CheckContext(ctx); err != nil {
+			return err
+		}
+
+		var err = bd.Db.Update(func(txn *badger.Txn) error {
+			return fn(txn, time.Now())
+		})
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+}
+
+// holder returns the owner and expiry of key, owner is empty if key
+// is not locked.
+func (bd *BadgerStore) holder(txn *badger.Txn, key string) (string, time.Time, error) {
+	var item, err = txn.Get(bd.lockKey(key))
+	if err == badger.ErrKeyNotFound {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, nerror.WrapOnly(err)
+	}
+
+	var owner string
+	var expires time.Time
+	if err := item.Value(func(val []byte) error {
+		expires = time.Unix(0, int64(binary.BigEndian.Uint64(val[:8])))
+		owner = string(val[8:])
+		return nil
+	}); err != nil {
+		return "", time.Time{}, nerror.WrapOnly(err)
+	}
+	return owner, expires, nil
+}
+
+func (bd *BadgerStore) setHolder(txn *badger.Txn, key string, owner string, expires time.Time, ttl time.Duration) error {
+	var value = make([]byte, 8+len(owner))
+	binary.BigEndian.PutUint64(value, uint64(expires.UnixNano()))
+	copy(value[8:], owner)
+
+	var entry = badger.NewEntry(bd.lockKey(key), value).WithTTL(ttl + time.Second)
+	if err := txn.SetEntry(entry); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package nlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+// MemoryStore implements the Store interface in memory, locking keys
+// only within the current process.
+type MemoryStore struct {
+	lock   sync.Mutex
+	locks  map[string]memoryLock
+	tokens map[string]uint64
+}
+
+// NewMemoryStore returns a new instance of a MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		locks:  map[string]memoryLock{},
+		tokens: map[string]uint64{},
+	}
+}
+
+// Acquire sets owner as the holder of key if key is not held.
+func (m *MemoryStore) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (uint64, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var now = time.Now()
+	if current, ok := m.locks[key]; ok && now.Before(current.expires) {
+		return 0, nerror.WrapOnly(ErrLockHeld)
+	}
+
+	m.tokens[key]++
+	m.locks[key] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return m.tokens[key], nil
+}
+
+// Renew resets the ttl of key if it is still held by owner.
+func (m *MemoryStore) Renew(ctx context.Context, key string, owner string, ttl time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var now = time.Now()
+	var current, ok = m.locks[key]
+	if !ok || current.owner != owner || !now.Before(current.expires) {
+		return nerror.WrapOnly(ErrLockLost)
+	}
+
+	current.expires = now.Add(ttl)
+	m.locks[key] = current
+	return nil
+}
+
+// Release removes key if it is still held by owner.
+func (m *MemoryStore) Release(ctx context.Context, key string, owner string) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var current, ok = m.locks[key]
+	if !ok || current.owner != owner || !time.Now().Before(current.expires) {
+		return nerror.WrapOnly(ErrLockLost)
+	}
+
+	delete(m.locks, key)
+	return nil
+}
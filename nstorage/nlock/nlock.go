@@ -0,0 +1,404 @@
+// Package nlock provides distributed locks and leases with fencing tokens
+// over redis, badger or an in-memory store.
+//
+// A Locker acquires a Lease on a key for a ttl, the lease is renewed in the
+// background until it is released, it's context is cancelled or renewal
+// fails, at which point the lease's context is cancelled so work guarded by
+// it can stop.
+//
+// Every successful acquisition of a key returns a fencing token greater than
+// any previously returned for that key, which should be passed to the
+// resources protected by the lock so writes from stale holders can be
+// rejected.
+package nlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nxid"
+)
+
+var (
+	// ErrLockHeld is returned when a lock is held by another owner.
+	ErrLockHeld = nerror.New("nlock: lock is held by another owner")
+
+	// ErrLockLost is returned when renewing or releasing a lock which is
+	// no longer held by the owner, either because it expired or was
+	// acquired by another owner.
+	ErrLockLost = nerror.New("nlock: lock is not held by owner")
+)
+
+// IsHeld returns true if giving error is or wraps ErrLockHeld.
+func IsHeld(err error) bool {
+	return rootCause(err) == ErrLockHeld
+}
+
+// IsLost returns true if giving error is or wraps ErrLockLost.
+func IsLost(err error) bool {
+	return rootCause(err) == ErrLockLost
+}
+
+// rootCause returns the error at the root of nerror wrappers and errors
+// wrapping others through an Unwrap method, e.g fmt.Errorf with %w.
+func rootCause(err error) error {
+	for {
+		err = nerror.UnwrapDeep(err)
+		var next = errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
+
+// Store defines the atomic operations a lock backend must provide.
+//
+// Keys are held by an owner until their ttl elapses or they are released,
+// operations by any other owner must fail with ErrLockHeld or ErrLockLost.
+type Store interface {
+	// Acquire sets owner as the holder of key for ttl if key is not held,
+	// returning a fencing token greater than any previous one of key.
+	Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (uint64, error)
+
+	// Renew resets the ttl of key if it is still held by owner.
+	Renew(ctx context.Context, key string, owner string, ttl time.Duration) error
+
+	// Release removes key if it is still held by owner.
+	Release(ctx context.Context, key string, owner string) error
+}
+
+// Defaults for the Locker options.
+const (
+	DefaultTTL        = 10 * time.Second
+	DefaultRetryEvery = 100 * time.Millisecond
+)
+
+// Option defines a function type which sets the internal state of a Locker.
+type Option func(*Locker)
+
+// TTL sets the ttl of acquired leases, defaults to DefaultTTL.
+func TTL(ttl time.Duration) Option {
+	return func(l *Locker) {
+		l.ttl = ttl
+	}
+}
+
+// RenewEvery sets the interval at which leases are renewed, defaults
+// to a third of the ttl.
+func RenewEvery(interval time.Duration) Option {
+	return func(l *Locker) {
+		l.renewEvery = interval
+	}
+}
+
+// NoRenewal disables background renewal, leases must be renewed
+// with Lease.Renew before their ttl elapses.
+func NoRenewal() Option {
+	return func(l *Locker) {
+		l.noRenewal = true
+	}
+}
+
+// ReleaseTimeout sets the timeout of releasing a lease once it's parent
+// context is done, defaults to the ttl of leases.
+func ReleaseTimeout(timeout time.Duration) Option {
+	return func(l *Locker) {
+		l.releaseTimeout = timeout
+	}
+}
+
+// RetryEvery sets the interval at which Locker.Wait retries
+// acquiring a held lock, defaults to DefaultRetryEvery.
+func RetryEvery(interval time.Duration) Option {
+	return func(l *Locker) {
+		l.retryEvery = interval
+	}
+}
+
+// Locker acquires leases on keys of a Store.
+type Locker struct {
+	store          Store
+	ttl            time.Duration
+	renewEvery     time.Duration
+	retryEvery     time.Duration
+	releaseTimeout time.Duration
+	noRenewal      bool
+}
+
+// New returns a new Locker over giving store.
+func New(store Store, ops ...Option) *Locker {
+	var l = &Locker{
+		store:      store,
+		ttl:        DefaultTTL,
+		retryEvery: DefaultRetryEvery,
+	}
+	for _, op := range ops {
+		op(l)
+	}
+	if l.renewEvery <= 0 {
+		l.renewEvery = l.ttl / 3
+	}
+	if l.releaseTimeout <= 0 {
+		l.releaseTimeout = l.ttl
+	}
+	return l
+}
+
+// Acquire attempts once to acquire a lease on key, returning an error
+// matching IsHeld if it is held by another owner.
+//
+// The lease lives until it is released, lost or giving context is
+// cancelled.
+func (l *Locker) Acquire(ctx context.Context, key string) (*Lease, error) {
+	var owner = nxid.New().String()
+	var token, err = l.store.Acquire(ctx, key, owner, l.ttl)
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return l.newLease(ctx, key, owner, token), nil
+}
+
+// Wait retries acquiring a lease on key until it succeeds, a error other
+// than ErrLockHeld occurs or giving context is cancelled.
+func (l *Locker) Wait(ctx context.Context, key string) (*Lease, error) {
+	var ticker = time.NewTicker(l.retryEvery)
+	defer ticker.Stop()
+
+	for {
+		var lease, err = l.Acquire(ctx, key)
+		if err == nil || !IsHeld(err) {
+			return lease, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nerror.WrapOnly(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Do attempts once to acquire a lease on key, calling fn with the lease's
+// context if it succeeds and releasing the lease after fn returns.
+//
+// The context given to fn is cancelled if the lease is lost, an error
+// matching IsHeld is returned if the lock is held by another owner.
+func (l *Locker) Do(ctx context.Context, key string, fn func(ctx context.Context, lease *Lease) error) error {
+	var lease, err = l.Acquire(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var fnErr = fn(lease.Context(), lease)
+	var releaseErr = lease.Release(context.Background())
+	if fnErr != nil {
+		return fnErr
+	}
+	if releaseErr != nil && !IsLost(releaseErr) {
+		return releaseErr
+	}
+	return nil
+}
+
+func (l *Locker) newLease(ctx context.Context, key string, owner string, token uint64) *Lease {
+	var leaseCtx, cancel = context.WithCancel(ctx)
+	var lease = &Lease{
+		locker:    l,
+		key:       key,
+		owner:     owner,
+		token:     token,
+		ctx:       leaseCtx,
+		cancel:    cancel,
+		expiresAt: time.Now().Add(l.ttl),
+		stopped:   make(chan struct{}),
+	}
+
+	if l.noRenewal {
+		go lease.watch()
+	} else {
+		go lease.renewLoop()
+	}
+	return lease
+}
+
+// Lease is a lock held on a key.
+type Lease struct {
+	locker  *Locker
+	key     string
+	owner   string
+	token   uint64
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	lock      sync.Mutex
+	expiresAt time.Time
+	released  bool
+	err       error
+}
+
+// Key returns the locked key.
+func (le *Lease) Key() string {
+	return le.key
+}
+
+// Owner returns the unique owner id of the lease.
+func (le *Lease) Owner() string {
+	return le.owner
+}
+
+// Token returns the fencing token of the lease.
+func (le *Lease) Token() uint64 {
+	return le.token
+}
+
+// Context returns a context which is cancelled when the lease is
+// released or lost.
+func (le *Lease) Context() context.Context {
+	return le.ctx
+}
+
+// Done returns a channel closed when the lease is released or lost.
+func (le *Lease) Done() <-chan struct{} {
+	return le.ctx.Done()
+}
+
+// Err returns the reason the lease ended, nil while the lease is held
+// or if it was released.
+func (le *Lease) Err() error {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	return le.err
+}
+
+// Renew resets the ttl of the lease, ending the lease if it was lost.
+func (le *Lease) Renew(ctx context.Context) error {
+	if err := le.ctx.Err(); err != nil {
+		return nerror.WrapOnly(ErrLockLost)
+	}
+
+	var started = time.Now()
+	if err := le.locker.store.Renew(ctx, le.key, le.owner, le.locker.ttl); err != nil {
+		if IsLost(err) {
+			le.end(err)
+		}
+		return nerror.WrapOnly(err)
+	}
+
+	le.lock.Lock()
+	le.expiresAt = started.Add(le.locker.ttl)
+	le.lock.Unlock()
+	return nil
+}
+
+// Release releases the lease and stops it's renewal. Releasing a lease
+// whose context was cancelled returns nil as it is released when
+// cancelled.
+func (le *Lease) Release(ctx context.Context) error {
+	le.lock.Lock()
+	if le.released || le.err != nil {
+		var err = le.err
+		le.lock.Unlock()
+		return err
+	}
+	if le.ctx.Err() != nil {
+		// the parent context was cancelled, the lock is released by stop.
+		le.lock.Unlock()
+		<-le.stopped
+		return nil
+	}
+	le.released = true
+	le.lock.Unlock()
+
+	le.cancel()
+	<-le.stopped
+
+	if err := le.locker.store.Release(ctx, le.key, le.owner); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+func (le *Lease) end(err error) {
+	le.lock.Lock()
+	if le.err == nil && !le.released && le.ctx.Err() == nil {
+		le.err = err
+	}
+	le.lock.Unlock()
+	le.cancel()
+}
+
+// stop is called once the lease context is done, releasing the lock if
+// the lease was ended by it's parent context.
+func (le *Lease) stop() {
+	le.lock.Lock()
+	var cancelled = !le.released && le.err == nil
+	le.lock.Unlock()
+
+	if cancelled {
+		var ctx, cancel = context.WithTimeout(context.Background(), le.locker.releaseTimeout)
+		_ = le.locker.store.Release(ctx, le.key, le.owner)
+		cancel()
+	}
+	close(le.stopped)
+}
+
+func (le *Lease) expired() bool {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	return time.Now().After(le.expiresAt)
+}
+
+// watch ends a lease without renewal once it's ttl elapses.
+func (le *Lease) watch() {
+	defer le.stop()
+
+	le.lock.Lock()
+	var timer = time.NewTimer(time.Until(le.expiresAt))
+	le.lock.Unlock()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-le.ctx.Done():
+			return
+		case <-timer.C:
+			if le.expired() {
+				le.end(nerror.WrapOnly(ErrLockLost))
+				return
+			}
+			le.lock.Lock()
+			timer.Reset(time.Until(le.expiresAt))
+			le.lock.Unlock()
+		}
+	}
+}
+
+// renewLoop renews the lease until it ends, failed renewals are retried
+// until the ttl of the lease elapses.
+func (le *Lease) renewLoop() {
+	defer le.stop()
+
+	var ticker = time.NewTicker(le.locker.renewEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-le.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var ctx, cancel = context.WithTimeout(le.ctx, le.locker.renewEvery)
+		var err = le.Renew(ctx)
+		cancel()
+
+		if err != nil && !IsLost(err) && le.expired() {
+			le.end(nerror.WrapOnly(ErrLockLost))
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package nlock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/dgraph-io/badger/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, store Store) {
+	var ctx = context.Background()
+
+	var token, err = store.Acquire(ctx, "cron", "day", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), token)
+
+	_, err = store.Acquire(ctx, "cron", "night", time.Minute)
+	require.True(t, IsHeld(err))
+
+	require.True(t, IsLost(store.Renew(ctx, "cron", "night", time.Minute)))
+	require.True(t, IsLost(store.Release(ctx, "cron", "night")))
+	require.NoError(t, store.Renew(ctx, "cron", "day", time.Minute))
+	require.NoError(t, store.Release(ctx, "cron", "day"))
+	require.True(t, IsLost(store.Release(ctx, "cron", "day")))
+
+	token, err = store.Acquire(ctx, "cron", "night", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), token)
+
+	token, err = store.Acquire(ctx, "backup", "night", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), token)
+}
+
+func testLocker(t *testing.T, store Store) {
+	var locker = New(store, TTL(300*time.Millisecond), RetryEvery(10*time.Millisecond))
+
+	var lease, err = locker.Acquire(context.Background(), "leader")
+	require.NoError(t, err)
+
+	_, err = locker.Acquire(context.Background(), "leader")
+	require.True(t, IsHeld(err))
+
+	// the lease is renewed in the background past it's ttl.
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, lease.Context().Err())
+	_, err = locker.Acquire(context.Background(), "leader")
+	require.True(t, IsHeld(err))
+
+	var waited = make(chan *Lease, 1)
+	go func() {
+		var next, waitErr = locker.Wait(context.Background(), "leader")
+		require.NoError(t, waitErr)
+		waited <- next
+	}()
+
+	require.NoError(t, lease.Release(context.Background()))
+	require.Error(t, lease.Context().Err())
+	require.NoError(t, lease.Err())
+
+	var next = <-waited
+	require.True(t, next.Token() > lease.Token())
+
+	// cancelling the parent context releases the lease.
+	var ctx, cancel = context.WithCancel(context.Background())
+	require.NoError(t, next.Release(context.Background()))
+	lease, err = locker.Acquire(ctx, "leader")
+	require.NoError(t, err)
+	cancel()
+	require.NoError(t, lease.Release(context.Background()))
+
+	lease, err = locker.Acquire(context.Background(), "leader")
+	require.NoError(t, err)
+	require.NoError(t, lease.Release(context.Background()))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+	testLocker(t, NewMemoryStore())
+}
+
+func TestBadgerStore(t *testing.T) {
+	var db, err = badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testStore(t, NewBadgerStore("nlock:", db))
+	testLocker(t, NewBadgerStore("nlock_locker:", db))
+}
+
+func TestRedisStore(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var client = redis.NewClient(&redis.Options{Addr: server.Addr(), Network: "tcp"})
+	var store = NewRedisStore("nlock:", client)
+	testStore(t, store)
+
+	var ctx = context.Background()
+	var _, err = store.Acquire(ctx, "expiring", "day", time.Second)
+	require.NoError(t, err)
+
+	server.FastForward(2 * time.Second)
+	require.True(t, IsLost(store.Renew(ctx, "expiring", "day", time.Second)))
+
+	var token uint64
+	token, err = store.Acquire(ctx, "expiring", "night", time.Second)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), token)
+}
+
+func TestLeaseLost(t *testing.T) {
+	var store = NewMemoryStore()
+	var locker = New(store, TTL(50*time.Millisecond), NoRenewal())
+
+	var lease, err = locker.Acquire(context.Background(), "leader")
+	require.NoError(t, err)
+	require.NoError(t, lease.Renew(context.Background()))
+
+	select {
+	case <-lease.Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "lease should have expired")
+	}
+	require.True(t, IsLost(lease.Err()))
+	require.True(t, IsLost(lease.Release(context.Background())))
+
+	lease, err = locker.Acquire(context.Background(), "leader")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), lease.Token())
+}
+
+func TestLockerDo(t *testing.T) {
+	var locker = New(NewMemoryStore(), TTL(time.Second))
+
+	var running int32
+	var ran int32
+	var waiter sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			var err = locker.Do(context.Background(), "job", func(ctx context.Context, lease *Lease) error {
+				require.Equal(t, int32(1), atomic.AddInt32(&running, 1))
+				atomic.AddInt32(&ran, 1)
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+			if err != nil {
+				require.True(t, IsHeld(err))
+			}
+		}()
+	}
+	waiter.Wait()
+	require.True(t, atomic.LoadInt32(&ran) >= 1)
+}
+
+// slowStore releases keys after a delay, unless the context is done first.
+type slowStore struct {
+	*MemoryStore
+	delay time.Duration
+}
+
+func (s slowStore) Release(ctx context.Context, key string, owner string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.delay):
+	}
+	return s.MemoryStore.Release(ctx, key, owner)
+}
+
+func TestLeaseReleaseTimeout(t *testing.T) {
+	var store = slowStore{MemoryStore: NewMemoryStore(), delay: 20 * time.Millisecond}
+	var locker = New(store, TTL(time.Minute), RetryEvery(time.Millisecond))
+
+	// the release of a cancelled lease is not bound by the retry interval.
+	var ctx, cancel = context.WithCancel(context.Background())
+	var lease, err = locker.Acquire(ctx, "leader")
+	require.NoError(t, err)
+	cancel()
+	<-lease.stopped
+
+	_, err = locker.Acquire(context.Background(), "leader")
+	require.NoError(t, err)
+
+	// releases taking longer than the timeout leave the lock held.
+	locker = New(store, TTL(time.Minute), ReleaseTimeout(time.Millisecond))
+	ctx, cancel = context.WithCancel(context.Background())
+	lease, err = locker.Acquire(ctx, "follower")
+	require.NoError(t, err)
+	cancel()
+	<-lease.stopped
+
+	_, err = locker.Acquire(context.Background(), "follower")
+	require.True(t, IsHeld(err))
+	require.False(t, IsLost(err))
+	require.True(t, IsHeld(fmt.Errorf("acquiring follower: %w", err)))
+}
@@ -0,0 +1,93 @@
+package nlock
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+var _ Store = (*RedisStore)(nil)
+
+// acquireScript sets the lock with SET NX PX, incrementing the fencing
+// token of the key only if the lock was set.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisStore implements the Store interface using redis, where locks are
+// set with SET NX PX and renewed or released by lua scripts which check
+// the owner of the lock.
+type RedisStore struct {
+	prefix string
+	Client redis.UniversalClient
+}
+
+// NewRedisStore returns a new RedisStore using giving client, all keys are
+// prefixed with giving prefix.
+func NewRedisStore(prefix string, client redis.UniversalClient) *RedisStore {
+	return &RedisStore{prefix: prefix, Client: client}
+}
+
+func (rd *RedisStore) lockKey(key string) string {
+	return rd.prefix + key + ":lock"
+}
+
+func (rd *RedisStore) tokenKey(key string) string {
+	return rd.prefix + key + ":token"
+}
+
+// Acquire sets owner as the holder of key if key is not held.
+func (rd *RedisStore) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (uint64, error) {
+	var token, err = acquireScript.Run(ctx, rd.Client, []string{rd.lockKey(key), rd.tokenKey(key)}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, nerror.WrapOnly(err)
+	}
+	if token == 0 {
+		return 0, nerror.WrapOnly(ErrLockHeld)
+	}
+	return uint64(token), nil
+}
+
+// Renew resets the ttl of key if it is still held by owner.
+func (rd *RedisStore) Renew(ctx context.Context, key string, owner string, ttl time.Duration) error {
+	var renewed, err = renewScript.Run(ctx, rd.Client, []string{rd.lockKey(key)}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if renewed == 0 {
+		return nerror.WrapOnly(ErrLockLost)
+	}
+	return nil
+}
+
+// Release removes key if it is still held by owner.
+func (rd *RedisStore) Release(ctx context.Context, key string, owner string) error {
+	var released, err = releaseScript.Run(ctx, rd.Client, []string{rd.lockKey(key)}, owner).Int64()
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if released == 0 {
+		return nerror.WrapOnly(ErrLockLost)
+	}
+	return nil
+}
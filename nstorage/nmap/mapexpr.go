@@ -1,34 +1,51 @@
 package nmap
 
 import (
+	"context"
 	regexp2 "regexp"
+	"sort"
 	"time"
 
 	"github.com/influx6/npkg/nerror"
 	"github.com/influx6/npkg/nstorage"
 )
 
-var _ nstorage.ExpirableStore = (*ExprByteStore)(nil)
+var (
+	_ nstorage.ExpirableStore        = (*ExprByteStore)(nil)
+	_ nstorage.ContextExpirableStore = (*ContextExprByteStore)(nil)
+)
 
-// ExprByteStore implements an expiring byte store that
-// matches the nstorage.ExpirableStorage interface.
-type ExprByteStore struct {
+// ContextExprByteStore implements an expiring byte store that
+// matches the nstorage.ContextExpirableStore interface.
+//
+// All operations are in-memory, the context is checked before
+// each call and between items when iterating.
+type ContextExprByteStore struct {
 	cache *ExpiringByteMap
 }
 
-// NewExprByteStore returns a new instance of a ExprByteStore.
-func NewExprByteStore(initial ...uint) *ExprByteStore {
-	var expr ExprByteStore
+// NewContextExprByteStore returns a new instance of a ContextExprByteStore.
+func NewContextExprByteStore(initial ...uint) *ContextExprByteStore {
+	var expr ContextExprByteStore
 	expr.cache = NewExpiringByteMap(initial...)
 	return &expr
 }
 
-func (expr *ExprByteStore) Count() (int64, error) {
+// Count returns the total count of keys in the store.
+func (expr *ContextExprByteStore) Count(ctx context.Context) (int64, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return -1, err
+	}
+
 	return expr.cache.Count(), nil
 }
 
 // Keys returns the list of all keys registered to giving store.
-func (expr *ExprByteStore) Keys() ([]string, error) {
+func (expr *ContextExprByteStore) Keys(ctx context.Context) ([]string, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var keys []string
 	expr.cache.GetMany(func(values map[string]ExpiringValue) {
 		keys = make([]string, 0, len(values))
@@ -40,17 +57,29 @@ func (expr *ExprByteStore) Keys() ([]string, error) {
 }
 
 // GetAnyKeys returns the giving values of any key if it exists and has not expired.
-func (expr *ExprByteStore) GetAnyKeys(k ...string) ([][]byte, error) {
+func (expr *ContextExprByteStore) GetAnyKeys(ctx context.Context, k ...string) ([][]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	return expr.cache.GetAnyKeys(k...)
 }
 
 // GetAllKeys returns the giving values of all keys.
-func (expr *ExprByteStore) GetAllKeys(k ...string) ([][]byte, error) {
+func (expr *ContextExprByteStore) GetAllKeys(ctx context.Context, k ...string) ([][]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	return expr.cache.GetAllKeys(k...)
 }
 
 // Get returns the giving value of key if it exists and has not expired.
-func (expr *ExprByteStore) Get(k string) ([]byte, error) {
+func (expr *ContextExprByteStore) Get(ctx context.Context, k string) ([]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	if !expr.cache.Has(k) {
 		return nil, nerror.New("not found")
 	}
@@ -60,17 +89,29 @@ func (expr *ExprByteStore) Get(k string) ([]byte, error) {
 // TTL returns the remaining time left for giving key before expiration.
 //
 // A zero value means has no expiration.
-func (expr *ExprByteStore) TTL(k string) (time.Duration, error) {
+func (expr *ContextExprByteStore) TTL(ctx context.Context, k string) (time.Duration, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
 	return expr.cache.TTL(k), nil
 }
 
 // Exists returns true/false if giving key exists.
-func (expr *ExprByteStore) Exists(k string) (bool, error) {
+func (expr *ContextExprByteStore) Exists(ctx context.Context, k string) (bool, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
 	return expr.cache.Has(k), nil
 }
 
 // Save adds giving key and value into store.
-func (expr *ExprByteStore) Save(k string, v []byte) error {
+func (expr *ContextExprByteStore) Save(ctx context.Context, k string, v []byte) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	var cm = append(make([]byte, 0, len(v)), v...)
 	expr.cache.Set(k, cm, 0)
 	return nil
@@ -80,7 +121,11 @@ func (expr *ExprByteStore) Save(k string, v []byte) error {
 // expired yet.
 //
 // A expiration value of zero means to persist the giving key.
-func (expr *ExprByteStore) ExtendTTL(k string, t time.Duration) error {
+func (expr *ContextExprByteStore) ExtendTTL(ctx context.Context, k string, t time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	expr.cache.ExtendTTL(k, t)
 	return nil
 }
@@ -89,27 +134,43 @@ func (expr *ExprByteStore) ExtendTTL(k string, t time.Duration) error {
 // expired yet.
 //
 // A expiration value of zero means to persist the giving key.
-func (expr *ExprByteStore) ResetTTL(k string, t time.Duration) error {
+func (expr *ContextExprByteStore) ResetTTL(ctx context.Context, k string, t time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	expr.cache.ResetTTL(k, t)
 	return nil
 }
 
 // Updates updates giving key and value into store.
-func (expr *ExprByteStore) Update(k string, v []byte) error {
+func (expr *ContextExprByteStore) Update(ctx context.Context, k string, v []byte) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	var cm = append(make([]byte, 0, len(v)), v...)
 	expr.cache.Set(k, cm, 0)
 	return nil
 }
 
 // SaveTTL updates giving key and value into store with expiration value.
-func (expr *ExprByteStore) SaveTTL(k string, v []byte, t time.Duration) error {
+func (expr *ContextExprByteStore) SaveTTL(ctx context.Context, k string, v []byte, t time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	var cm = append(make([]byte, 0, len(v)), v...)
 	expr.cache.Set(k, cm, t)
 	return nil
 }
 
 // UpdateTTL updates giving key and value into store with expiration value.
-func (expr *ExprByteStore) UpdateTTL(k string, v []byte, t time.Duration) error {
+func (expr *ContextExprByteStore) UpdateTTL(ctx context.Context, k string, v []byte, t time.Duration) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	if !expr.cache.Has(k) {
 		return nerror.New("key does not exists")
 	}
@@ -124,10 +185,18 @@ func (expr *ExprByteStore) UpdateTTL(k string, v []byte, t time.Duration) error
 // To ensure no-undesired behaviour, ensure to copy the value to avoid
 // possible change to it, as the underline store owns the giving value
 // slice and maybe re-used as it sees fit.
-func (expr *ExprByteStore) Each(fn nstorage.EachItem) error {
+func (expr *ContextExprByteStore) Each(ctx context.Context, fn nstorage.EachItem) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
 	var recvErr error
 	expr.cache.GetMany(func(values map[string]ExpiringValue) {
 		for key, value := range values {
+			if err := nstorage.CheckContext(ctx); err != nil {
+				recvErr = err
+				break
+			}
 			if err := fn(value.Value, key); err != nil {
 				if nerror.IsAny(err, nstorage.ErrJustStop) {
 					return
@@ -144,30 +213,38 @@ func (expr *ExprByteStore) Each(fn nstorage.EachItem) error {
 }
 
 // ScanMatch returns all elements matching giving function and count.
-func (expr *ExprByteStore) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+func (expr *ContextExprByteStore) ScanMatch(ctx context.Context, count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
 	var rs nstorage.ScanResult
 
-	var keys, keyFetchErr = expr.EachKeyMatch(regexp)
+	var keys, keyFetchErr = expr.EachKeyMatch(ctx, regexp)
 	if keyFetchErr != nil {
 		return rs, nerror.WrapOnly(keyFetchErr)
 	}
 
-	var remainingKeys = keys[lastIndex : lastIndex+count]
+	sort.Strings(keys)
+
+	var total = int64(len(keys))
+	if lastIndex > total {
+		lastIndex = total
+	}
 
-	rs.Keys = remainingKeys
-	rs.LastIndex = lastIndex + count
-	if int(rs.LastIndex) > len(keys) {
-		rs.LastIndex = int64(len(keys))
+	var endIndex = lastIndex + count
+	if endIndex >= total {
+		endIndex = total
 		rs.Finished = true
-		return rs, nil
 	}
 
-	rs.LastIndex = lastIndex + count
+	rs.Keys = keys[lastIndex:endIndex]
+	rs.LastIndex = endIndex
 	return rs, nil
 }
 
 // Find returns all elements matching giving function and count.
-func (expr *ExprByteStore) EachKeyMatch(regexp string) ([]string, error) {
+func (expr *ContextExprByteStore) EachKeyMatch(ctx context.Context, regexp string) ([]string, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	if len(regexp) == 0 {
 		regexp = ".+"
 	}
@@ -190,8 +267,12 @@ func (expr *ExprByteStore) EachKeyMatch(regexp string) ([]string, error) {
 }
 
 // RemoveKeys deletes giving key from underling store.
-func (expr *ExprByteStore) RemoveKeys(ks ...string) error {
-	expr.cache.GetMany(func(values map[string]ExpiringValue) {
+func (expr *ContextExprByteStore) RemoveKeys(ctx context.Context, ks ...string) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	expr.cache.SetMany(func(values map[string]ExpiringValue) {
 		for _, key := range ks {
 			delete(values, key)
 		}
@@ -199,15 +280,19 @@ func (expr *ExprByteStore) RemoveKeys(ks ...string) error {
 	return nil
 }
 
-func (expr *ExprByteStore) Clear() {
+func (expr *ContextExprByteStore) Clear() {
 	expr.cache.Reset()
 }
 
 // Remove deletes giving key from underling store.
-func (expr *ExprByteStore) Remove(k string) ([]byte, error) {
+func (expr *ContextExprByteStore) Remove(ctx context.Context, k string) ([]byte, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
 	var v []byte
 	var found bool
-	expr.cache.GetMany(func(values map[string]ExpiringValue) {
+	expr.cache.SetMany(func(values map[string]ExpiringValue) {
 		var value, hasKey = values[k]
 		if !hasKey {
 			return
@@ -224,3 +309,117 @@ func (expr *ExprByteStore) Remove(k string) ([]byte, error) {
 	}
 	return v, nil
 }
+
+//**********************************************************************
+// ExprByteStore
+//**********************************************************************
+
+// ExprByteStore implements an expiring byte store that
+// matches the nstorage.ExpirableStorage interface.
+type ExprByteStore struct {
+	*ContextExprByteStore
+	ctx context.Context
+}
+
+// NewExprByteStore returns a new instance of a ExprByteStore.
+func NewExprByteStore(initial ...uint) *ExprByteStore {
+	return &ExprByteStore{
+		ContextExprByteStore: NewContextExprByteStore(initial...),
+		ctx:                  context.Background(),
+	}
+}
+
+// Context returns the underline context aware store.
+func (expr *ExprByteStore) Context() *ContextExprByteStore {
+	return expr.ContextExprByteStore
+}
+
+// Count returns the total count of keys in the store.
+func (expr *ExprByteStore) Count() (int64, error) {
+	return expr.ContextExprByteStore.Count(expr.ctx)
+}
+
+// Keys returns the list of all keys registered to giving store.
+func (expr *ExprByteStore) Keys() ([]string, error) {
+	return expr.ContextExprByteStore.Keys(expr.ctx)
+}
+
+// GetAnyKeys returns the giving values of any key if it exists and has not expired.
+func (expr *ExprByteStore) GetAnyKeys(k ...string) ([][]byte, error) {
+	return expr.ContextExprByteStore.GetAnyKeys(expr.ctx, k...)
+}
+
+// GetAllKeys returns the giving values of all keys.
+func (expr *ExprByteStore) GetAllKeys(k ...string) ([][]byte, error) {
+	return expr.ContextExprByteStore.GetAllKeys(expr.ctx, k...)
+}
+
+// Get returns the giving value of key if it exists and has not expired.
+func (expr *ExprByteStore) Get(k string) ([]byte, error) {
+	return expr.ContextExprByteStore.Get(expr.ctx, k)
+}
+
+// TTL returns the remaining time left for giving key before expiration.
+func (expr *ExprByteStore) TTL(k string) (time.Duration, error) {
+	return expr.ContextExprByteStore.TTL(expr.ctx, k)
+}
+
+// Exists returns true/false if giving key exists.
+func (expr *ExprByteStore) Exists(k string) (bool, error) {
+	return expr.ContextExprByteStore.Exists(expr.ctx, k)
+}
+
+// Save adds giving key and value into store.
+func (expr *ExprByteStore) Save(k string, v []byte) error {
+	return expr.ContextExprByteStore.Save(expr.ctx, k, v)
+}
+
+// ExtendTTL extends expiration of giving key.
+func (expr *ExprByteStore) ExtendTTL(k string, t time.Duration) error {
+	return expr.ContextExprByteStore.ExtendTTL(expr.ctx, k, t)
+}
+
+// ResetTTL resets expiration of giving key.
+func (expr *ExprByteStore) ResetTTL(k string, t time.Duration) error {
+	return expr.ContextExprByteStore.ResetTTL(expr.ctx, k, t)
+}
+
+// Update updates giving key and value into store.
+func (expr *ExprByteStore) Update(k string, v []byte) error {
+	return expr.ContextExprByteStore.Update(expr.ctx, k, v)
+}
+
+// SaveTTL updates giving key and value into store with expiration value.
+func (expr *ExprByteStore) SaveTTL(k string, v []byte, t time.Duration) error {
+	return expr.ContextExprByteStore.SaveTTL(expr.ctx, k, v, t)
+}
+
+// UpdateTTL updates giving key and value into store with expiration value.
+func (expr *ExprByteStore) UpdateTTL(k string, v []byte, t time.Duration) error {
+	return expr.ContextExprByteStore.UpdateTTL(expr.ctx, k, v, t)
+}
+
+// Each alternatives through all keys and values from underline cache.
+func (expr *ExprByteStore) Each(fn nstorage.EachItem) error {
+	return expr.ContextExprByteStore.Each(expr.ctx, fn)
+}
+
+// ScanMatch returns all elements matching giving function and count.
+func (expr *ExprByteStore) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	return expr.ContextExprByteStore.ScanMatch(expr.ctx, count, lastIndex, lastKey, regexp)
+}
+
+// EachKeyMatch returns all keys matching giving regexp.
+func (expr *ExprByteStore) EachKeyMatch(regexp string) ([]string, error) {
+	return expr.ContextExprByteStore.EachKeyMatch(expr.ctx, regexp)
+}
+
+// RemoveKeys deletes giving keys from underling store.
+func (expr *ExprByteStore) RemoveKeys(ks ...string) error {
+	return expr.ContextExprByteStore.RemoveKeys(expr.ctx, ks...)
+}
+
+// Remove deletes giving key from underling store.
+func (expr *ExprByteStore) Remove(k string) ([]byte, error) {
+	return expr.ContextExprByteStore.Remove(expr.ctx, k)
+}
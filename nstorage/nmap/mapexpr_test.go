@@ -1,8 +1,10 @@
 package nmap
 
 import (
+	"context"
 	"testing"
 
+	"github.com/influx6/npkg/nstorage"
 	"github.com/influx6/npkg/nstorage/internal/tharness"
 	"github.com/stretchr/testify/require"
 )
@@ -69,3 +71,55 @@ func TestNMapExpiryStore(t *testing.T) {
 
 	tharness.TestExpirableStore(t, store)
 }
+
+func TestContextStoreWithNMapStore(t *testing.T) {
+	var store = NewContextExprByteStore(100)
+	require.NotNil(t, store)
+
+	tharness.TestContextStore(t, store)
+	tharness.TestContextExpirableStore(t, store)
+}
+
+func TestContextAdaptersWithNMapStore(t *testing.T) {
+	var store = nstorage.ToContextExpirableStore(NewExprByteStore(100))
+	tharness.TestContextStore(t, store)
+	tharness.TestContextExpirableStore(t, store)
+
+	var plain = nstorage.FromContextExpirableStore(context.Background(), NewContextExprByteStore(100))
+	tharness.TestByteStore(t, plain)
+	tharness.TestExpirableStore(t, plain)
+}
+
+func TestExprByteStoreScanMatchPages(t *testing.T) {
+	var store = NewExprByteStore(100)
+	for _, key := range []string{"e", "c", "a", "d", "b"} {
+		require.NoError(t, store.Save(key, []byte(key)))
+	}
+
+	// pages are taken from the keys in order.
+	var result, err = store.ScanMatch(2, 0, "", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, result.Keys)
+	require.False(t, result.Finished)
+
+	result, err = store.ScanMatch(2, result.LastIndex, "", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "d"}, result.Keys)
+	require.False(t, result.Finished)
+
+	// the last page sliced keys past their end, panicking.
+	require.NotPanics(t, func() {
+		result, err = store.ScanMatch(2, result.LastIndex, "", "")
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"e"}, result.Keys)
+	require.True(t, result.Finished)
+	require.Equal(t, int64(5), result.LastIndex)
+
+	require.NotPanics(t, func() {
+		result, err = store.ScanMatch(2, 10, "", "")
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Keys, 0)
+	require.True(t, result.Finished)
+}
@@ -0,0 +1,329 @@
+package nqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+var _ Store = (*BadgerStore)(nil)
+
+// badgerJob is the stored record of a job.
+type badgerJob struct {
+	Job     Job   `json:"job"`
+	Visible int64 `json:"visible"`
+}
+
+// BadgerStore implements the Store interface using badger transactions,
+// sharing jobs among all users of the same database.
+//
+// Every job is stored as json with an index key ordering the jobs of it's
+// queue by visibility time, and dead jobs with an index key ordering them
+// by the time they died.
+type BadgerStore struct {
+	prefix string
+	Db     *badger.DB
+}
+
+// NewBadgerStore returns a new BadgerStore using giving database, all keys
+// are prefixed with giving prefix.
+func NewBadgerStore(prefix string, db *badger.DB) *BadgerStore {
+	return &BadgerStore{prefix: prefix, Db: db}
+}
+
+func (bd *BadgerStore) jobKey(id string) []byte {
+	return []byte(bd.prefix + "job:" + id)
+}
+
+// scheduleKey returns the prefix of the index keys of queue, the name of
+// the queue is ended by a zero byte so it is not the prefix of another.
+func (bd *BadgerStore) scheduleKey(queue string) []byte {
+	return []byte(bd.prefix + "schedule:" + queue + "\x00")
+}
+
+func (bd *BadgerStore) deadKey(queue string) []byte {
+	return []byte(bd.prefix + "dead:" + queue + "\x00")
+}
+
+func (bd *BadgerStore) uniqueKey(job *Job) []byte {
+	return []byte(bd.prefix + "unique:" + job.Queue + "\x00" + job.UniqueKey)
+}
+
+// indexKey returns giving prefix followed by the time and id of a job,
+// ordering keys of the same prefix by time.
+func indexKey(prefix []byte, at int64, id string) []byte {
+	var key = make([]byte, len(prefix)+8+len(id))
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], uint64(at))
+	copy(key[len(prefix)+8:], id)
+	return key
+}
+
+// Enqueue adds job, visible from job.RunAt.
+func (bd *BadgerStore) Enqueue(ctx context.Context, job *Job) error {
+	return bd.update(ctx, func(txn *badger.Txn) error {
+		if job.UniqueKey != "" {
+			var _, err = txn.Get(bd.uniqueKey(job))
+			if err == nil {
+				return nerror.WrapOnly(ErrDuplicate)
+			}
+			if err != badger.ErrKeyNotFound {
+				return nerror.WrapOnly(err)
+			}
+			if err := txn.Set(bd.uniqueKey(job), []byte(job.ID)); err != nil {
+				return nerror.WrapOnly(err)
+			}
+		}
+
+		var record = &badgerJob{Job: *job, Visible: job.RunAt.UnixNano()}
+		if err := txn.Set(indexKey(bd.scheduleKey(job.Queue), record.Visible, job.ID), nil); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return bd.set(txn, record)
+	})
+}
+
+// Dequeue claims the visible job of queue with the earliest visibility.
+func (bd *BadgerStore) Dequeue(ctx context.Context, queue string, now time.Time, until time.Time, receipt string) (*Job, error) {
+	var job *Job
+	var err = bd.update(ctx, func(txn *badger.Txn) error {
+		job = nil
+
+		var prefix = bd.scheduleKey(queue)
+		var options = badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = prefix
+
+		var iterator = txn.NewIterator(options)
+		iterator.Seek(prefix)
+		if !iterator.ValidForPrefix(prefix) {
+			iterator.Close()
+			return nil
+		}
+		var key = iterator.Item().KeyCopy(nil)
+		iterator.Close()
+
+		var visible = int64(binary.BigEndian.Uint64(key[len(prefix):]))
+		if visible > now.UnixNano() {
+			return nil
+		}
+
+		var record, err = bd.get(txn, string(key[len(prefix)+8:]))
+		if err != nil {
+			return err
+		}
+
+		record.Job.Attempts++
+		record.Job.Receipt = receipt
+		if err := bd.move(txn, record, until.UnixNano()); err != nil {
+			return err
+		}
+
+		var claimed = record.Job
+		job = &claimed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Extend moves the expiry of the claim on job.
+func (bd *BadgerStore) Extend(ctx context.Context, job *Job, until time.Time) error {
+	return bd.update(ctx, func(txn *badger.Txn) error {
+		var record, err = bd.claimed(txn, job)
+		if err != nil {
+			return err
+		}
+		return bd.move(txn, record, until.UnixNano())
+	})
+}
+
+// Ack removes job.
+func (bd *BadgerStore) Ack(ctx context.Context, job *Job) error {
+	return bd.update(ctx, func(txn *badger.Txn) error {
+		var record, err = bd.claimed(txn, job)
+		if err != nil {
+			return err
+		}
+		if err := txn.Delete(bd.jobKey(job.ID)); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return bd.remove(txn, record)
+	})
+}
+
+// Retry makes job visible again at giving time.
+func (bd *BadgerStore) Retry(ctx context.Context, job *Job, at time.Time) error {
+	return bd.update(ctx, func(txn *badger.Txn) error {
+		var record, err = bd.claimed(txn, job)
+		if err != nil {
+			return err
+		}
+
+		record.Job.RunAt = at
+		record.Job.Receipt = ""
+		record.Job.LastError = job.LastError
+		return bd.move(txn, record, at.UnixNano())
+	})
+}
+
+// Bury moves job to the dead letters of it's queue.
+func (bd *BadgerStore) Bury(ctx context.Context, job *Job) error {
+	return bd.update(ctx, func(txn *badger.Txn) error {
+		var record, err = bd.claimed(txn, job)
+		if err != nil {
+			return err
+		}
+		if err := bd.remove(txn, record); err != nil {
+			return err
+		}
+
+		record.Job.Receipt = ""
+		record.Job.LastError = job.LastError
+		if err := txn.Set(indexKey(bd.deadKey(job.Queue), time.Now().UnixNano(), job.ID), nil); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return bd.set(txn, record)
+	})
+}
+
+// DeadLetters returns the dead jobs of queue.
+func (bd *BadgerStore) DeadLetters(ctx context.Context, queue string) ([]*Job, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	var err = bd.Db.View(func(txn *badger.Txn) error {
+		var prefix = bd.deadKey(queue)
+		var options = badger.DefaultIteratorOptions
+		options.PrefetchValues = false
+		options.Prefix = prefix
+
+		var iterator = txn.NewIterator(options)
+		defer iterator.Close()
+
+		for iterator.Seek(prefix); iterator.ValidForPrefix(prefix); iterator.Next() {
+			var key = iterator.Item().Key()
+			var record, err = bd.get(txn, string(key[len(prefix)+8:]))
+			if err != nil {
+				return err
+			}
+			var job = record.Job
+			jobs = append(jobs, &job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// update runs fn in a transaction, retrying it on conflicts with other
+// transactions until it succeeds or the context is cancelled.
+func (bd *BadgerStore) update(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	for {
+		if err := nstorage.CheckContext(ctx); err != nil {
+			return err
+		}
+
+		var err = bd.Db.Update(fn)
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+}
+
+func (bd *BadgerStore) get(txn *badger.Txn, id string) (*badgerJob, error) {
+	var item, err = txn.Get(bd.jobKey(id))
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	var record badgerJob
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &record)
+	}); err != nil {
+		return nil, nerror.Wrap(err, "failed to decode job %q", id)
+	}
+	return &record, nil
+}
+
+func (bd *BadgerStore) set(txn *badger.Txn, record *badgerJob) error {
+	var data, err = json.Marshal(record)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if err := txn.Set(bd.jobKey(record.Job.ID), data); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+// claimed returns the stored job if it is claimed by the receipt of job.
+func (bd *BadgerStore) claimed(txn *badger.Txn, job *Job) (*badgerJob, error) {
+	var record, err = bd.get(txn, job.ID)
+	if err != nil && nerror.UnwrapDeep(err) == badger.ErrKeyNotFound {
+		return nil, nerror.WrapOnly(ErrNotClaimed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.Receipt == "" || record.Job.Receipt != job.Receipt {
+		return nil, nerror.WrapOnly(ErrNotClaimed)
+	}
+	return record, nil
+}
+
+// move moves the index key of a job to giving visibility time, saving it.
+func (bd *BadgerStore) move(txn *badger.Txn, record *badgerJob, visible int64) error {
+	var scheduled = bd.scheduleKey(record.Job.Queue)
+	if err := txn.Delete(indexKey(scheduled, record.Visible, record.Job.ID)); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if err := txn.Set(indexKey(scheduled, visible, record.Job.ID), nil); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	record.Visible = visible
+	return bd.set(txn, record)
+}
+
+// remove removes the index key of a job and releases it's unique key.
+func (bd *BadgerStore) remove(txn *badger.Txn, record *badgerJob) error {
+	if err := txn.Delete(indexKey(bd.scheduleKey(record.Job.Queue), record.Visible, record.Job.ID)); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if record.Job.UniqueKey == "" {
+		return nil
+	}
+
+	var item, err = txn.Get(bd.uniqueKey(&record.Job))
+	if err == badger.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	var holder []byte
+	if holder, err = item.ValueCopy(nil); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if !bytes.Equal(holder, []byte(record.Job.ID)) {
+		return nil
+	}
+	if err := txn.Delete(bd.uniqueKey(&record.Job)); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
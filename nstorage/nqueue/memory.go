@@ -0,0 +1,197 @@
+package nqueue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+type memoryJob struct {
+	job     Job
+	visible time.Time
+}
+
+// MemoryStore implements the Store interface in memory, sharing jobs only
+// within the current process.
+type MemoryStore struct {
+	lock   sync.Mutex
+	jobs   map[string]*memoryJob
+	unique map[string]string
+	dead   map[string][]Job
+}
+
+// NewMemoryStore returns a new instance of a MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		jobs:   map[string]*memoryJob{},
+		unique: map[string]string{},
+		dead:   map[string][]Job{},
+	}
+}
+
+func uniqueKey(job *Job) string {
+	return job.Queue + ":" + job.UniqueKey
+}
+
+// Enqueue adds job, visible from job.RunAt.
+func (m *MemoryStore) Enqueue(ctx context.Context, job *Job) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if job.UniqueKey != "" {
+		if _, ok := m.unique[uniqueKey(job)]; ok {
+			return nerror.WrapOnly(ErrDuplicate)
+		}
+		m.unique[uniqueKey(job)] = job.ID
+	}
+	m.jobs[job.ID] = &memoryJob{job: *job, visible: job.RunAt}
+	return nil
+}
+
+// Dequeue claims the visible job of queue with the earliest visibility.
+func (m *MemoryStore) Dequeue(ctx context.Context, queue string, now time.Time, until time.Time, receipt string) (*Job, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var next *memoryJob
+	for _, current := range m.jobs {
+		if current.job.Queue != queue || current.visible.After(now) {
+			continue
+		}
+		if next == nil || current.visible.Before(next.visible) ||
+			(current.visible.Equal(next.visible) && current.job.ID < next.job.ID) {
+			next = current
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	next.visible = until
+	next.job.Attempts++
+	next.job.Receipt = receipt
+
+	var job = next.job
+	return &job, nil
+}
+
+// claimed returns the stored job if it is claimed by the receipt of job.
+func (m *MemoryStore) claimed(job *Job) (*memoryJob, error) {
+	var current, ok = m.jobs[job.ID]
+	if !ok || current.job.Receipt != job.Receipt || job.Receipt == "" {
+		return nil, nerror.WrapOnly(ErrNotClaimed)
+	}
+	return current, nil
+}
+
+// Extend moves the expiry of the claim on job.
+func (m *MemoryStore) Extend(ctx context.Context, job *Job, until time.Time) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var current, err = m.claimed(job)
+	if err != nil {
+		return err
+	}
+	current.visible = until
+	return nil
+}
+
+// Ack removes job.
+func (m *MemoryStore) Ack(ctx context.Context, job *Job) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, err := m.claimed(job); err != nil {
+		return err
+	}
+	m.remove(job)
+	return nil
+}
+
+// Retry makes job visible again at giving time.
+func (m *MemoryStore) Retry(ctx context.Context, job *Job, at time.Time) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var current, err = m.claimed(job)
+	if err != nil {
+		return err
+	}
+	current.visible = at
+	current.job.RunAt = at
+	current.job.Receipt = ""
+	current.job.LastError = job.LastError
+	return nil
+}
+
+// Bury moves job to the dead letters of it's queue.
+func (m *MemoryStore) Bury(ctx context.Context, job *Job) error {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var current, err = m.claimed(job)
+	if err != nil {
+		return err
+	}
+
+	var dead = current.job
+	dead.Receipt = ""
+	dead.LastError = job.LastError
+	m.dead[job.Queue] = append(m.dead[job.Queue], dead)
+	m.remove(job)
+	return nil
+}
+
+func (m *MemoryStore) remove(job *Job) {
+	delete(m.jobs, job.ID)
+	if job.UniqueKey != "" && m.unique[uniqueKey(job)] == job.ID {
+		delete(m.unique, uniqueKey(job))
+	}
+}
+
+// DeadLetters returns the dead jobs of queue.
+func (m *MemoryStore) DeadLetters(ctx context.Context, queue string) ([]*Job, error) {
+	if err := nstorage.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var jobs = make([]*Job, len(m.dead[queue]))
+	for index := range m.dead[queue] {
+		var job = m.dead[queue][index]
+		jobs[index] = &job
+	}
+	return jobs, nil
+}
@@ -0,0 +1,491 @@
+// Package nqueue provides a durable job queue over redis, badger or an
+// in-memory store.
+//
+// Jobs are enqueued by name with a json payload, optionally delayed or
+// made unique by a key, and processed by workers running the handler
+// registered for their name.
+//
+// Delivery is at-least-once: a dequeued job is invisible to other workers
+// until it's visibility timeout elapses, which is extended while it's
+// handler runs. Jobs are removed once acked, a failed job is retried with
+// backoff and moved to the dead letters of it's queue once it used all
+// it's attempts.
+package nqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nretries"
+	"github.com/influx6/npkg/nxid"
+)
+
+var (
+	// ErrDuplicate is returned when enqueuing a job with the unique key
+	// of a job which is not yet acked or dead.
+	ErrDuplicate = errors.New("nqueue: a job with the unique key is already queued")
+
+	// ErrNotClaimed is returned when acking, retrying or extending a job
+	// whose visibility timeout elapsed, it may since have been delivered
+	// to another worker.
+	ErrNotClaimed = errors.New("nqueue: job is not claimed by receipt")
+)
+
+// IsDuplicate returns true if giving error is or wraps ErrDuplicate.
+func IsDuplicate(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrDuplicate
+}
+
+// IsNotClaimed returns true if giving error is or wraps ErrNotClaimed.
+func IsNotClaimed(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrNotClaimed
+}
+
+// Job is a unit of work of a queue.
+type Job struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Name        string          `json:"name"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	UniqueKey   string          `json:"unique_key,omitempty"`
+	MaxAttempts int             `json:"max_attempts"`
+	EnqueuedAt  time.Time       `json:"enqueued_at"`
+
+	// RunAt is the time the job is due, it is moved by retries.
+	RunAt time.Time `json:"run_at"`
+
+	// Attempts is the number of times the job was delivered, including
+	// the current delivery.
+	Attempts int `json:"attempts"`
+
+	// LastError is the error of the last failed attempt.
+	LastError string `json:"last_error,omitempty"`
+
+	// Receipt identifies the current delivery of the job, it is set by
+	// the Store when the job is dequeued.
+	Receipt string `json:"receipt,omitempty"`
+}
+
+// Decode decodes the payload of the job into giving value.
+func (j *Job) Decode(v interface{}) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return nerror.Wrap(err, "failed to decode payload of job %q", j.ID)
+	}
+	return nil
+}
+
+// String returns a description of the job.
+func (j *Job) String() string {
+	return fmt.Sprintf("%s(%s) on %s, attempt %d/%d", j.Name, j.ID, j.Queue, j.Attempts, j.MaxAttempts)
+}
+
+// Store defines the atomic operations a queue backend must provide.
+//
+// Every job has a visibility time: the time it is due until it is
+// dequeued, then the time it's claim by the receipt of the delivery
+// expires. Acking, retrying, burying or extending a job must fail with
+// ErrNotClaimed if the receipt is not the one of it's last delivery or
+// the claim expired and the job was delivered again.
+type Store interface {
+	// Enqueue adds job, visible from job.RunAt, failing with ErrDuplicate
+	// if job has a unique key held by another job of it's queue.
+	Enqueue(ctx context.Context, job *Job) error
+
+	// Dequeue claims the job of queue with the earliest visibility at or
+	// before now until giving time, incrementing it's attempts and setting
+	// it's receipt. It returns nil if no job is visible.
+	Dequeue(ctx context.Context, queue string, now time.Time, until time.Time, receipt string) (*Job, error)
+
+	// Extend moves the expiry of the claim on job to giving time.
+	Extend(ctx context.Context, job *Job, until time.Time) error
+
+	// Ack removes job, releasing it's unique key.
+	Ack(ctx context.Context, job *Job) error
+
+	// Retry releases the claim on job, making it visible again at giving
+	// time with job.LastError recorded.
+	Retry(ctx context.Context, job *Job, at time.Time) error
+
+	// Bury moves job to the dead letters of it's queue with job.LastError
+	// recorded, releasing it's unique key.
+	Bury(ctx context.Context, job *Job) error
+
+	// DeadLetters returns the dead jobs of queue, in the order they died.
+	DeadLetters(ctx context.Context, queue string) ([]*Job, error)
+}
+
+// Handler processes a job, returning an error fails the attempt.
+type Handler func(ctx context.Context, job *Job) error
+
+// Defaults for the Queue options.
+const (
+	DefaultWorkers     = 1
+	DefaultVisibility  = 30 * time.Second
+	DefaultPollEvery   = 500 * time.Millisecond
+	DefaultMaxAttempts = 5
+)
+
+// Option defines a function type which sets the internal state of a Queue.
+type Option func(*Queue)
+
+// Workers sets the number of jobs processed at once by Run, defaults to
+// DefaultWorkers.
+func Workers(n int) Option {
+	return func(q *Queue) {
+		q.workers = n
+	}
+}
+
+// Visibility sets the time a dequeued job is hidden from other workers,
+// defaults to DefaultVisibility which is also used for values which are
+// not positive. It is extended every third of it while the handler of the
+// job runs.
+func Visibility(d time.Duration) Option {
+	return func(q *Queue) {
+		q.visibility = d
+	}
+}
+
+// PollEvery sets the interval at which idle workers check for visible
+// jobs, defaults to DefaultPollEvery which is also used for values which
+// are not positive.
+func PollEvery(d time.Duration) Option {
+	return func(q *Queue) {
+		q.pollEvery = d
+	}
+}
+
+// MaxAttempts sets the number of times jobs enqueued without the
+// Attempts option are delivered before they die, defaults to
+// DefaultMaxAttempts.
+func MaxAttempts(n int) Option {
+	return func(q *Queue) {
+		q.maxAttempts = n
+	}
+}
+
+// BackOff sets the function returning the delay before a failed job is
+// retried from the number of attempts made, defaults to
+// nretries.ExponentialJitterBackOff.
+func BackOff(fn func(attempts int) time.Duration) Option {
+	return func(q *Queue) {
+		q.backOff = fn
+	}
+}
+
+// OnError sets a function called with the errors of failed attempts and
+// of the store while processing jobs, job is nil for errors not related
+// to a job.
+func OnError(fn func(job *Job, err error)) Option {
+	return func(q *Queue) {
+		q.onError = fn
+	}
+}
+
+// EnqueueOption defines a function type which sets the options of an
+// enqueued job.
+type EnqueueOption func(*Job)
+
+// Delay delays the first delivery of the job by giving duration.
+func Delay(d time.Duration) EnqueueOption {
+	return func(j *Job) {
+		j.RunAt = j.EnqueuedAt.Add(d)
+	}
+}
+
+// At delays the first delivery of the job until giving time.
+func At(t time.Time) EnqueueOption {
+	return func(j *Job) {
+		j.RunAt = t
+	}
+}
+
+// Unique sets the unique key of the job, enqueuing fails with
+// ErrDuplicate while another job of the queue with the same key is not
+// acked or dead.
+func Unique(key string) EnqueueOption {
+	return func(j *Job) {
+		j.UniqueKey = key
+	}
+}
+
+// Attempts sets the number of times the job is delivered before it dies,
+// overriding the MaxAttempts of the queue.
+func Attempts(n int) EnqueueOption {
+	return func(j *Job) {
+		j.MaxAttempts = n
+	}
+}
+
+// Queue enqueues jobs into a named queue of a Store and processes them
+// with the handlers registered for their name.
+type Queue struct {
+	name        string
+	store       Store
+	workers     int
+	visibility  time.Duration
+	pollEvery   time.Duration
+	maxAttempts int
+	backOff     func(int) time.Duration
+	onError     func(*Job, error)
+
+	lock     sync.RWMutex
+	handlers map[string]Handler
+}
+
+// New returns a new Queue for the named queue of giving store.
+func New(store Store, name string, ops ...Option) *Queue {
+	var q = &Queue{
+		name:        name,
+		store:       store,
+		workers:     DefaultWorkers,
+		visibility:  DefaultVisibility,
+		pollEvery:   DefaultPollEvery,
+		maxAttempts: DefaultMaxAttempts,
+		backOff:     nretries.ExponentialJitterBackOff,
+		handlers:    map[string]Handler{},
+	}
+	for _, op := range ops {
+		op(q)
+	}
+	if q.workers < 1 {
+		q.workers = 1
+	}
+	if q.pollEvery <= 0 {
+		q.pollEvery = DefaultPollEvery
+	}
+
+	// the visibility is extended every third of it, which must be above zero.
+	if q.visibility <= 0 {
+		q.visibility = DefaultVisibility
+	}
+	if q.visibility < 3 {
+		q.visibility = 3
+	}
+	return q
+}
+
+// Name returns the name of the queue.
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Handle registers the handler of jobs with giving name, replacing any
+// previous one. Jobs without a handler are buried on delivery.
+func (q *Queue) Handle(name string, handler Handler) {
+	q.lock.Lock()
+	q.handlers[name] = handler
+	q.lock.Unlock()
+}
+
+// Enqueue adds a job with giving name and payload encoded as json,
+// returning an error matching IsDuplicate if it's unique key is held.
+func (q *Queue) Enqueue(ctx context.Context, name string, payload interface{}, ops ...EnqueueOption) (*Job, error) {
+	var encoded, err = json.Marshal(payload)
+	if err != nil {
+		return nil, nerror.Wrap(err, "failed to encode payload of job %q", name)
+	}
+
+	var now = time.Now()
+	var job = &Job{
+		ID:          nxid.New().String(),
+		Queue:       q.name,
+		Name:        name,
+		Payload:     encoded,
+		MaxAttempts: q.maxAttempts,
+		EnqueuedAt:  now,
+		RunAt:       now,
+	}
+	for _, op := range ops {
+		op(job)
+	}
+
+	if err := q.store.Enqueue(ctx, job); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return job, nil
+}
+
+// DeadLetters returns the jobs of the queue which used all their
+// attempts, in the order they died.
+func (q *Queue) DeadLetters(ctx context.Context) ([]*Job, error) {
+	var jobs, err = q.store.DeadLetters(ctx, q.name)
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+	return jobs, nil
+}
+
+// Run processes jobs with Workers workers until giving context is
+// cancelled, then waits for the jobs being processed.
+//
+// The context given to handlers is cancelled when Run's context is, jobs
+// failing after it was cancelled are made visible again right away.
+func (q *Queue) Run(ctx context.Context) {
+	var waiter sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			q.work(ctx)
+		}()
+	}
+	waiter.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	var ticker = time.NewTicker(q.pollEvery)
+	defer ticker.Stop()
+
+	for {
+		var processed, err = q.Process(ctx)
+		if err != nil {
+			q.report(nil, err)
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process processes the next visible job of the queue, returning false
+// if there was none.
+//
+// The job is acked if it's handler succeeds, else it is retried after the
+// backoff of it's attempts or buried once it used all it's attempts.
+func (q *Queue) Process(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, nil
+	}
+
+	var now = time.Now()
+	var job, err = q.store.Dequeue(ctx, q.name, now, now.Add(q.visibility), nxid.New().String())
+	if err != nil {
+		return false, nerror.WrapOnly(err)
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	q.lock.RLock()
+	var handler, found = q.handlers[job.Name]
+	q.lock.RUnlock()
+
+	var handleErr error
+	if found {
+		handleErr = q.handle(ctx, handler, job)
+	} else {
+		handleErr = nerror.New("no handler for job %q", job.Name)
+	}
+
+	// the job is settled even if ctx is cancelled, within the time it is
+	// still claimed.
+	var settleCtx, cancel = context.WithTimeout(context.Background(), q.visibility)
+	defer cancel()
+
+	if handleErr == nil {
+		if err := q.store.Ack(settleCtx, job); err != nil {
+			return true, nerror.Wrap(err, "failed to ack job %q", job.ID)
+		}
+		return true, nil
+	}
+
+	q.report(job, handleErr)
+	job.LastError = handleErr.Error()
+
+	if ctx.Err() != nil {
+		err = q.store.Retry(settleCtx, job, time.Now())
+	} else if !found || job.Attempts >= job.MaxAttempts {
+		err = q.store.Bury(settleCtx, job)
+	} else {
+		err = q.store.Retry(settleCtx, job, time.Now().Add(q.backOff(job.Attempts)))
+	}
+	if err != nil {
+		return true, nerror.Wrap(err, "failed to settle job %q", job.ID)
+	}
+	return true, nil
+}
+
+// handle runs the handler of job, extending it's visibility until it
+// returns. The context given to the handler is cancelled if the claim
+// on the job is lost.
+func (q *Queue) handle(ctx context.Context, handler Handler, job *Job) error {
+	var handlerCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+
+	var done = make(chan struct{})
+	var extended = make(chan struct{})
+	go func() {
+		defer close(extended)
+		q.extend(handlerCtx, cancel, job, done)
+	}()
+
+	var err = q.call(handlerCtx, handler, job)
+	close(done)
+	<-extended
+	return err
+}
+
+// call calls handler, recovering panics as errors.
+func (q *Queue) call(ctx context.Context, handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = nerror.New("job %q panicked: %v\n%s", job.ID, r, debug.Stack())
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (q *Queue) extend(ctx context.Context, cancel context.CancelFunc, job *Job, done chan struct{}) {
+	var ticker = time.NewTicker(q.visibility / 3)
+	defer ticker.Stop()
+
+	// a failed extension is retried on the next tick, it's only reported
+	// once the claim would have expired without one.
+	var claimedUntil = time.Now().Add(q.visibility)
+	var reported bool
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		var extendCtx, cancelExtend = context.WithTimeout(ctx, q.visibility/3)
+		var until = time.Now().Add(q.visibility)
+		var err = q.store.Extend(extendCtx, job, until)
+		cancelExtend()
+
+		if IsNotClaimed(err) {
+			q.report(job, err)
+			cancel()
+			return
+		}
+		if err == nil {
+			claimedUntil, reported = until, false
+			continue
+		}
+		if !reported && time.Now().After(claimedUntil) {
+			reported = true
+			q.report(job, nerror.Wrap(err, "failed to extend visibility of job %q", job.ID))
+		}
+	}
+}
+
+func (q *Queue) report(job *Job, err error) {
+	if q.onError != nil {
+		q.onError(job, err)
+	}
+}
@@ -0,0 +1,272 @@
+package nqueue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/dgraph-io/badger/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, store Store) {
+	var ctx = context.Background()
+	var now = time.Now()
+
+	var first = &Job{ID: "1", Queue: "mails", Name: "welcome", Payload: []byte(`"ada"`), MaxAttempts: 3, EnqueuedAt: now, RunAt: now}
+	var delayed = &Job{ID: "2", Queue: "mails", Name: "welcome", MaxAttempts: 3, EnqueuedAt: now, RunAt: now.Add(time.Hour)}
+	var unique = &Job{ID: "3", Queue: "mails", Name: "digest", UniqueKey: "digest", MaxAttempts: 3, EnqueuedAt: now, RunAt: now}
+	require.NoError(t, store.Enqueue(ctx, first))
+	require.NoError(t, store.Enqueue(ctx, delayed))
+	require.NoError(t, store.Enqueue(ctx, unique))
+	require.True(t, IsDuplicate(store.Enqueue(ctx, &Job{ID: "4", Queue: "mails", UniqueKey: "digest", RunAt: now})))
+	require.NoError(t, store.Enqueue(ctx, &Job{ID: "5", Queue: "reports", UniqueKey: "digest", RunAt: now.Add(time.Hour)}))
+
+	var claimed, err = store.Dequeue(ctx, "mails", now, now.Add(time.Minute), "receipt-1")
+	require.NoError(t, err)
+	require.Equal(t, "1", claimed.ID)
+	require.Equal(t, 1, claimed.Attempts)
+	require.Equal(t, "receipt-1", claimed.Receipt)
+
+	var name string
+	require.NoError(t, claimed.Decode(&name))
+	require.Equal(t, "ada", name)
+
+	var other *Job
+	other, err = store.Dequeue(ctx, "mails", now, now.Add(time.Minute), "receipt-2")
+	require.NoError(t, err)
+	require.Equal(t, "3", other.ID)
+
+	// the delayed job is not visible yet.
+	var none *Job
+	none, err = store.Dequeue(ctx, "mails", now, now.Add(time.Minute), "receipt-3")
+	require.NoError(t, err)
+	require.Nil(t, none)
+	require.NoError(t, store.Ack(ctx, other))
+
+	// the claim expired and the job is delivered again.
+	var later = now.Add(2 * time.Minute)
+	var again *Job
+	again, err = store.Dequeue(ctx, "mails", later, later.Add(time.Minute), "receipt-4")
+	require.NoError(t, err)
+	require.Equal(t, "1", again.ID)
+	require.Equal(t, 2, again.Attempts)
+	require.True(t, IsNotClaimed(store.Ack(ctx, claimed)))
+
+	require.NoError(t, store.Extend(ctx, again, later.Add(time.Hour)))
+	again.LastError = "smtp down"
+	require.NoError(t, store.Retry(ctx, again, later.Add(time.Minute)))
+	require.True(t, IsNotClaimed(store.Ack(ctx, again)))
+
+	var retried *Job
+	retried, err = store.Dequeue(ctx, "mails", later.Add(time.Minute), later.Add(time.Hour), "receipt-5")
+	require.NoError(t, err)
+	require.Equal(t, "1", retried.ID)
+	require.Equal(t, 3, retried.Attempts)
+	require.Equal(t, "smtp down", retried.LastError)
+
+	retried.LastError = "smtp still down"
+	require.NoError(t, store.Bury(ctx, retried))
+
+	// acking and burying release the unique key.
+	require.NoError(t, store.Enqueue(ctx, &Job{ID: "6", Queue: "mails", UniqueKey: "digest", RunAt: now.Add(time.Hour)}))
+
+	var dead []*Job
+	dead, err = store.DeadLetters(ctx, "mails")
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	require.Equal(t, "1", dead[0].ID)
+	require.Equal(t, 3, dead[0].Attempts)
+	require.Equal(t, "smtp still down", dead[0].LastError)
+
+	dead, err = store.DeadLetters(ctx, "reports")
+	require.NoError(t, err)
+	require.Len(t, dead, 0)
+}
+
+func testQueue(t *testing.T, store Store, workers int) {
+	var failed = make(chan error, 10)
+	var queue = New(store, "mails",
+		Workers(workers),
+		PollEvery(5*time.Millisecond),
+		Visibility(300*time.Millisecond),
+		MaxAttempts(2),
+		BackOff(func(_ int) time.Duration { return 10 * time.Millisecond }),
+		OnError(func(job *Job, err error) {
+			// only failures of handlers, store errors like a slow
+			// extension depend on the load of the machine.
+			var message = err.Error()
+			if job != nil && (message == "mailbox full" || strings.Contains(message, "no handler")) {
+				failed <- err
+			}
+		}),
+	)
+
+	var lock sync.Mutex
+	var sent []string
+	var done = make(chan struct{}, 10)
+	queue.Handle("welcome", func(_ context.Context, job *Job) error {
+		var name string
+		if err := job.Decode(&name); err != nil {
+			return err
+		}
+		lock.Lock()
+		sent = append(sent, name)
+		lock.Unlock()
+		done <- struct{}{}
+		return nil
+	})
+	queue.Handle("bounce", func(_ context.Context, job *Job) error {
+		done <- struct{}{}
+		return errors.New("mailbox full")
+	})
+	queue.Handle("slow", func(ctx context.Context, job *Job) error {
+		// outlives the visibility timeout, which is extended.
+		time.Sleep(500 * time.Millisecond)
+		done <- struct{}{}
+		return nil
+	})
+
+	var ctx = context.Background()
+	var _, err = queue.Enqueue(ctx, "welcome", "ada")
+	require.NoError(t, err)
+	_, err = queue.Enqueue(ctx, "welcome", "grace", Delay(50*time.Millisecond), Unique("grace"))
+	require.NoError(t, err)
+	_, err = queue.Enqueue(ctx, "welcome", "grace", Unique("grace"))
+	require.True(t, IsDuplicate(err))
+	_, err = queue.Enqueue(ctx, "bounce", "nobody")
+	require.NoError(t, err)
+	_, err = queue.Enqueue(ctx, "slow", "linus")
+	require.NoError(t, err)
+	_, err = queue.Enqueue(ctx, "unknown", nil)
+	require.NoError(t, err)
+
+	var runCtx, cancel = context.WithCancel(ctx)
+	var stopped = make(chan struct{})
+	go func() {
+		queue.Run(runCtx)
+		close(stopped)
+	}()
+
+	// two welcomes, two attempts of the bounce and the slow job.
+	for i := 0; i < 5; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("jobs were not processed")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		var dead, err = queue.DeadLetters(ctx)
+		return err == nil && len(dead) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-stopped
+
+	lock.Lock()
+	require.Equal(t, []string{"ada", "grace"}, sent)
+	lock.Unlock()
+	require.Len(t, done, 0)
+
+	var dead, deadErr = queue.DeadLetters(ctx)
+	require.NoError(t, deadErr)
+	var names = map[string]string{}
+	for _, job := range dead {
+		names[job.Name] = job.LastError
+	}
+	require.Equal(t, "mailbox full", names["bounce"])
+	require.Contains(t, names["unknown"], "no handler")
+	require.Len(t, failed, 3)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+	testQueue(t, NewMemoryStore(), 2)
+}
+
+func TestBadgerStore(t *testing.T) {
+	var db, err = badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	require.NoError(t, err)
+	defer db.Close()
+
+	testStore(t, NewBadgerStore("nqueue:", db))
+	testQueue(t, NewBadgerStore("nqueue_queue:", db), 2)
+}
+
+func TestRedisStore(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var client = redis.NewClient(&redis.Options{Addr: server.Addr(), Network: "tcp"})
+	testStore(t, NewRedisStore("nqueue:", client))
+
+	// miniredis does not run scripts atomically, concurrent workers could
+	// claim the same job.
+	testQueue(t, NewRedisStore("nqueue_queue:", client), 1)
+}
+
+func TestQueueLostClaim(t *testing.T) {
+	var store = NewMemoryStore()
+	var queue = New(store, "reports", Visibility(30*time.Millisecond))
+
+	var canceled = make(chan struct{})
+	queue.Handle("build", func(ctx context.Context, job *Job) error {
+		// another worker claims the job once it's visibility elapsed.
+		store.lock.Lock()
+		store.jobs[job.ID].job.Receipt = "stolen"
+		store.lock.Unlock()
+
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+
+	var _, err = queue.Enqueue(context.Background(), "build", nil)
+	require.NoError(t, err)
+
+	var processed bool
+	processed, err = queue.Process(context.Background())
+	require.True(t, processed)
+	require.True(t, IsNotClaimed(err))
+	<-canceled
+}
+
+func TestQueueInvalidIntervals(t *testing.T) {
+	for _, option := range []Option{Visibility(0), Visibility(-time.Second), Visibility(1), PollEvery(0), PollEvery(-time.Second)} {
+		var queue = New(NewMemoryStore(), "reports", option)
+		require.True(t, queue.visibility >= 3)
+		require.True(t, queue.pollEvery > 0)
+
+		var handled = make(chan struct{}, 1)
+		queue.Handle("build", func(ctx context.Context, job *Job) error {
+			// jobs of a short visibility can be delivered again.
+			select {
+			case handled <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+
+		var _, err = queue.Enqueue(context.Background(), "build", nil)
+		require.NoError(t, err)
+
+		// a panic of the tickers would crash the test binary.
+		var ctx, cancel = context.WithCancel(context.Background())
+		var done = make(chan struct{})
+		go func() {
+			defer close(done)
+			queue.Run(ctx)
+		}()
+		<-handled
+		cancel()
+		<-done
+	}
+}
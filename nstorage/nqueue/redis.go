@@ -0,0 +1,260 @@
+package nqueue
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+
+	"github.com/influx6/npkg/nerror"
+)
+
+var _ Store = (*RedisStore)(nil)
+
+// enqueueScript holds the unique key with SET NX before adding the job to
+// the schedule of it's queue.
+var enqueueScript = redis.NewScript(`
+if KEYS[3] ~= "" and not redis.call("SET", KEYS[3], ARGV[1], "NX") then
+	return 0
+end
+redis.call("HMSET", KEYS[1], "data", ARGV[2], "attempts", 0, "receipt", "", "error", "", "run_at", ARGV[3])
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+return 1
+`)
+
+// dequeueScript moves the earliest visible job of the schedule to the end
+// of it's claim, ARGV[4] is the prefix of job keys.
+var dequeueScript = redis.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, 1)
+if #ids == 0 then
+	return false
+end
+local key = ARGV[4] .. ids[1]
+redis.call("ZADD", KEYS[1], ARGV[2], ids[1])
+redis.call("HINCRBY", key, "attempts", 1)
+redis.call("HSET", key, "receipt", ARGV[3])
+return redis.call("HMGET", key, "data", "attempts", "receipt", "error", "run_at")
+`)
+
+var extendScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "receipt") ~= ARGV[1] then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[2])
+return 1
+`)
+
+var ackScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "receipt") ~= ARGV[1] then
+	return 0
+end
+redis.call("ZREM", KEYS[2], ARGV[2])
+redis.call("DEL", KEYS[1])
+if KEYS[3] ~= "" and redis.call("GET", KEYS[3]) == ARGV[2] then
+	redis.call("DEL", KEYS[3])
+end
+return 1
+`)
+
+var retryScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "receipt") ~= ARGV[1] then
+	return 0
+end
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[2])
+redis.call("HMSET", KEYS[1], "receipt", "", "error", ARGV[4], "run_at", ARGV[3])
+return 1
+`)
+
+// buryScript moves the job from the schedule to the dead letter list, the
+// job itself is kept for DeadLetters.
+var buryScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "receipt") ~= ARGV[1] then
+	return 0
+end
+redis.call("ZREM", KEYS[2], ARGV[2])
+redis.call("HMSET", KEYS[1], "receipt", "", "error", ARGV[3])
+redis.call("RPUSH", KEYS[3], ARGV[2])
+if KEYS[4] ~= "" and redis.call("GET", KEYS[4]) == ARGV[2] then
+	redis.call("DEL", KEYS[4])
+end
+return 1
+`)
+
+// RedisStore implements the Store interface using redis, where every queue
+// has a sorted set of job ids scored by their visibility time and a list of
+// dead job ids, and every job is a hash.
+//
+// Jobs are claimed, acked and retried by lua scripts which check the
+// receipt of the job. Scripts compute job keys, hence a cluster must hold
+// all keys of a prefix on the same node, e.g with a "{queues}:" prefix.
+type RedisStore struct {
+	prefix string
+	Client redis.UniversalClient
+}
+
+// NewRedisStore returns a new RedisStore using giving client, all keys are
+// prefixed with giving prefix.
+func NewRedisStore(prefix string, client redis.UniversalClient) *RedisStore {
+	return &RedisStore{prefix: prefix, Client: client}
+}
+
+func (rd *RedisStore) jobKey(id string) string {
+	return rd.prefix + "job:" + id
+}
+
+func (rd *RedisStore) scheduleKey(queue string) string {
+	return rd.prefix + queue + ":schedule"
+}
+
+func (rd *RedisStore) deadKey(queue string) string {
+	return rd.prefix + queue + ":dead"
+}
+
+func (rd *RedisStore) uniqueKey(job *Job) string {
+	if job.UniqueKey == "" {
+		return ""
+	}
+	return rd.prefix + job.Queue + ":unique:" + job.UniqueKey
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Enqueue adds job, visible from job.RunAt.
+func (rd *RedisStore) Enqueue(ctx context.Context, job *Job) error {
+	var data, err = json.Marshal(job)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	var keys = []string{rd.jobKey(job.ID), rd.scheduleKey(job.Queue), rd.uniqueKey(job)}
+	var added int64
+	if added, err = enqueueScript.Run(ctx, rd.Client, keys, job.ID, data, millis(job.RunAt)).Int64(); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if added == 0 {
+		return nerror.WrapOnly(ErrDuplicate)
+	}
+	return nil
+}
+
+// Dequeue claims the visible job of queue with the earliest visibility.
+func (rd *RedisStore) Dequeue(ctx context.Context, queue string, now time.Time, until time.Time, receipt string) (*Job, error) {
+	var result, err = dequeueScript.Run(ctx, rd.Client, []string{rd.scheduleKey(queue)},
+		millis(now), millis(until), receipt, rd.jobKey("")).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	var fields, ok = result.([]interface{})
+	if !ok || len(fields) != 5 {
+		return nil, nerror.New("unexpected dequeue result %#v", result)
+	}
+	return decodeRedisJob(fields)
+}
+
+// decodeRedisJob returns the job of the data, attempts, receipt, error and
+// run_at fields of it's hash.
+func decodeRedisJob(fields []interface{}) (*Job, error) {
+	var values = make([]string, len(fields))
+	for index, field := range fields {
+		values[index], _ = field.(string)
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(values[0]), &job); err != nil {
+		return nil, nerror.Wrap(err, "failed to decode job")
+	}
+
+	var attempts, err = strconv.Atoi(values[1])
+	if err != nil {
+		return nil, nerror.Wrap(err, "failed to decode attempts of job %q", job.ID)
+	}
+	var runAt int64
+	if runAt, err = strconv.ParseInt(values[4], 10, 64); err != nil {
+		return nil, nerror.Wrap(err, "failed to decode run_at of job %q", job.ID)
+	}
+
+	job.Attempts = attempts
+	job.Receipt = values[2]
+	job.LastError = values[3]
+	job.RunAt = time.Unix(0, runAt*int64(time.Millisecond))
+	return &job, nil
+}
+
+// run runs a script checking the receipt of a job, the receipt of jobs not
+// claimed is empty hence job must have one.
+func (rd *RedisStore) run(ctx context.Context, job *Job, script *redis.Script, keys []string, args ...interface{}) error {
+	if job.Receipt == "" {
+		return nerror.WrapOnly(ErrNotClaimed)
+	}
+
+	var done, err = script.Run(ctx, rd.Client, keys, args...).Int64()
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if done == 0 {
+		return nerror.WrapOnly(ErrNotClaimed)
+	}
+	return nil
+}
+
+// Extend moves the expiry of the claim on job.
+func (rd *RedisStore) Extend(ctx context.Context, job *Job, until time.Time) error {
+	return rd.run(ctx, job, extendScript, []string{rd.jobKey(job.ID), rd.scheduleKey(job.Queue)},
+		job.Receipt, job.ID, millis(until))
+}
+
+// Ack removes job.
+func (rd *RedisStore) Ack(ctx context.Context, job *Job) error {
+	return rd.run(ctx, job, ackScript, []string{rd.jobKey(job.ID), rd.scheduleKey(job.Queue), rd.uniqueKey(job)},
+		job.Receipt, job.ID)
+}
+
+// Retry makes job visible again at giving time.
+func (rd *RedisStore) Retry(ctx context.Context, job *Job, at time.Time) error {
+	return rd.run(ctx, job, retryScript, []string{rd.jobKey(job.ID), rd.scheduleKey(job.Queue)},
+		job.Receipt, job.ID, millis(at), job.LastError)
+}
+
+// Bury moves job to the dead letters of it's queue.
+func (rd *RedisStore) Bury(ctx context.Context, job *Job) error {
+	return rd.run(ctx, job, buryScript,
+		[]string{rd.jobKey(job.ID), rd.scheduleKey(job.Queue), rd.deadKey(job.Queue), rd.uniqueKey(job)},
+		job.Receipt, job.ID, job.LastError)
+}
+
+// DeadLetters returns the dead jobs of queue.
+func (rd *RedisStore) DeadLetters(ctx context.Context, queue string) ([]*Job, error) {
+	var ids, err = rd.Client.LRange(ctx, rd.deadKey(queue), 0, -1).Result()
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	var pipe = rd.Client.Pipeline()
+	var commands = make([]*redis.SliceCmd, len(ids))
+	for index, id := range ids {
+		commands[index] = pipe.HMGet(ctx, rd.jobKey(id), "data", "attempts", "receipt", "error", "run_at")
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, nerror.WrapOnly(err)
+		}
+	}
+
+	var jobs = make([]*Job, 0, len(ids))
+	for _, command := range commands {
+		var job, err = decodeRedisJob(command.Val())
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -13,12 +13,14 @@ import (
 	"github.com/influx6/npkg/nunsafe"
 )
 
-var _ nstorage.ExpirableStore = (*RedisStore)(nil)
+var (
+	_ nstorage.ExpirableStore        = (*RedisStore)(nil)
+	_ nstorage.ContextExpirableStore = (*ContextRedisStore)(nil)
+)
 
-// RedisStore implements session management, storage and access using redis as
-// underline store.
-type RedisStore struct {
-	ctx       context.Context
+// ContextRedisStore implements session management, storage and access using redis as
+// underline store, where every call uses the context provided to it.
+type ContextRedisStore struct {
 	tableName string
 	hashList  string
 	hashZList string
@@ -27,40 +29,40 @@ type RedisStore struct {
 	Client    *redis.Client
 }
 
-// NewRedisStore returns a new instance of a redis store.
-func NewRedisStore(ctx context.Context, tableName string, config redis.Options) (*RedisStore, error) {
-	var red RedisStore
-	red.ctx = ctx
-	red.tableName = tableName
-	red.hashList = tableName + "_keys"
-	red.hashElem = tableName + "_item"
+// NewContextRedisStore returns a new instance of a context aware redis store.
+func NewContextRedisStore(ctx context.Context, tableName string, config redis.Options) (*ContextRedisStore, error) {
+	var red = newContextRedisStore(tableName)
 	red.Config = &config
-	if err := red.createConnection(); err != nil {
+	if err := red.createConnection(ctx); err != nil {
 		return nil, nerror.WrapOnly(err)
 	}
-	return &red, nil
+	return red, nil
 }
 
-// FromRedisStore returns a new instance of a RedisStore using giving client.
-func FromRedisStore(ctx context.Context, tableName string, conn *redis.Client) (*RedisStore, error) {
+// FromContextRedisStore returns a new instance of a ContextRedisStore using giving client.
+func FromContextRedisStore(ctx context.Context, tableName string, conn *redis.Client) (*ContextRedisStore, error) {
 	if status := conn.Ping(ctx); status.Err() != nil {
 		return nil, status.Err()
 	}
 
-	var red RedisStore
-	red.ctx = ctx
+	var red = newContextRedisStore(tableName)
+	red.Client = conn
+	return red, nil
+}
+
+func newContextRedisStore(tableName string) *ContextRedisStore {
+	var red ContextRedisStore
 	red.tableName = tableName
 	red.hashList = tableName + "_keys"
 	red.hashElem = tableName + "_item"
 	red.hashZList = tableName + "_zset"
-	red.Client = conn
-	return &red, nil
+	return &red
 }
 
 // createConnection attempts to create a new redis connection.
-func (rd *RedisStore) createConnection() error {
+func (rd *ContextRedisStore) createConnection(ctx context.Context) error {
 	client := redis.NewClient(rd.Config)
-	status := client.Ping(rd.ctx)
+	status := client.Ping(ctx)
 	if err := status.Err(); err != nil {
 		return nerror.WrapOnly(err)
 	}
@@ -68,21 +70,21 @@ func (rd *RedisStore) createConnection() error {
 	return nil
 }
 
-func (rd *RedisStore) Close() error {
+func (rd *ContextRedisStore) Close() error {
 	return rd.Client.Close()
 }
 
 // doHashKey returns formatted for unique form towards using creating
 // efficient hashmaps to contain list of keys.
-func (rd *RedisStore) doHashKey(key string) string {
+func (rd *ContextRedisStore) doHashKey(key string) string {
 	return strings.Join([]string{rd.hashElem, key}, "_")
 }
 
-func (rd *RedisStore) unHashKey(key string) string {
+func (rd *ContextRedisStore) unHashKey(key string) string {
 	return strings.TrimPrefix(key, rd.hashElem+"_")
 }
 
-func (rd *RedisStore) unHashKeyList(keys []string) []string {
+func (rd *ContextRedisStore) unHashKeyList(keys []string) []string {
 	for index, key := range keys {
 		keys[index] = rd.unHashKey(key)
 	}
@@ -90,8 +92,8 @@ func (rd *RedisStore) unHashKeyList(keys []string) []string {
 }
 
 // Keys returns all giving keys of elements within store.
-func (rd *RedisStore) Keys() ([]string, error) {
-	var nstatus = rd.Client.SMembers(rd.ctx, rd.hashList)
+func (rd *ContextRedisStore) Keys(ctx context.Context) ([]string, error) {
+	var nstatus = rd.Client.SMembers(ctx, rd.hashList)
 	if err := nstatus.Err(); err != nil {
 		return nil, nerror.WrapOnly(err)
 	}
@@ -101,8 +103,8 @@ func (rd *RedisStore) Keys() ([]string, error) {
 
 // Each runs through all elements for giving store, skipping keys
 // in redis who have no data or an empty byte slice.
-func (rd *RedisStore) Each(fn nstorage.EachItem) error {
-	var nstatus = rd.Client.SMembers(rd.ctx, rd.hashList)
+func (rd *ContextRedisStore) Each(ctx context.Context, fn nstorage.EachItem) error {
+	var nstatus = rd.Client.SMembers(ctx, rd.hashList)
 	if err := nstatus.Err(); err != nil {
 		return nerror.WrapOnly(err)
 	}
@@ -112,20 +114,23 @@ func (rd *RedisStore) Each(fn nstorage.EachItem) error {
 
 	var values = make([]*redis.StringCmd, len(keys))
 	for index, key := range keys {
-		var result = pipeliner.Get(rd.ctx, key)
+		var result = pipeliner.Get(ctx, key)
 		values[index] = result
 	}
 
-	var _, err = pipeliner.Exec(rd.ctx)
+	var _, err = pipeliner.Exec(ctx)
 	if err != nil && err != redis.Nil {
 		return nerror.WrapOnly(err)
 	}
 
 	for index, item := range values {
+		if err := nstorage.CheckContext(ctx); err != nil {
+			return err
+		}
 		if item.Err() != nil {
 			continue
 		}
-		var key = keys[index]
+		var key = rd.unHashKey(keys[index])
 		var data = nunsafe.String2Bytes(item.Val())
 		if doErr := fn(data, key); doErr != nil {
 			if nerror.IsAny(doErr, nstorage.ErrJustStop) {
@@ -143,8 +148,8 @@ func (rd *RedisStore) Each(fn nstorage.EachItem) error {
 // if an error occurs, the partially collected list of keys and error is returned.
 //
 // Return nstorage.ErrJustStop if you want to just stop iterating.
-func (rd *RedisStore) EachKeyMatch(regexp string) ([]string, error) {
-	return rd.FindPrefixFor(100, regexp)
+func (rd *ContextRedisStore) EachKeyMatch(ctx context.Context, regexp string) ([]string, error) {
+	return rd.FindPrefixFor(ctx, 100, regexp)
 }
 
 // ScanMatche uses underline redis scan methods for a hashmap, relying on the lastIndex
@@ -153,7 +158,7 @@ func (rd *RedisStore) EachKeyMatch(regexp string) ([]string, error) {
 // or less/none.
 //
 // With scan the order is not guaranteed.
-func (rd *RedisStore) ScanMatch(count int64, lastIndex int64, _ string, regexp string) (nstorage.ScanResult, error) {
+func (rd *ContextRedisStore) ScanMatch(ctx context.Context, count int64, lastIndex int64, _ string, regexp string) (nstorage.ScanResult, error) {
 	if len(regexp) == 0 {
 		regexp = ".+"
 	}
@@ -164,7 +169,7 @@ func (rd *RedisStore) ScanMatch(count int64, lastIndex int64, _ string, regexp s
 		return rs, nerror.WrapOnly(rgErr)
 	}
 
-	var scanned = rd.Client.ZRange(rd.ctx, rd.hashZList, lastIndex, lastIndex+count-1)
+	var scanned = rd.Client.ZRange(ctx, rd.hashZList, lastIndex, lastIndex+count-1)
 	var ky, err = scanned.Result()
 	if err != nil {
 		return rs, nerror.WrapOnly(err)
@@ -182,7 +187,7 @@ func (rd *RedisStore) ScanMatch(count int64, lastIndex int64, _ string, regexp s
 	// rs.Finished = cursor == 0
 	var lastKey string
 	if keysCount := len(keys); keysCount > 0 {
-		lastKey = keys[count-1]
+		lastKey = keys[keysCount-1]
 	}
 
 	// var isFinished = cursor == 0
@@ -195,8 +200,8 @@ func (rd *RedisStore) ScanMatch(count int64, lastIndex int64, _ string, regexp s
 }
 
 // Count returns the total count of element in the store.
-func (rd *RedisStore) Count() (int64, error) {
-	var command = rd.Client.HLen(rd.ctx, rd.hashElem)
+func (rd *ContextRedisStore) Count(ctx context.Context) (int64, error) {
+	var command = rd.Client.HLen(ctx, rd.hashElem)
 
 	var err = command.Err()
 	if err != nil {
@@ -211,7 +216,7 @@ func (rd *RedisStore) Count() (int64, error) {
 // count then all values returned.
 //
 // if an error occurs, the partially collected list of keys and error is returned.
-func (rd *RedisStore) FindPrefixFor(count int64, regexp string) ([]string, error) {
+func (rd *ContextRedisStore) FindPrefixFor(ctx context.Context, count int64, regexp string) ([]string, error) {
 	if len(regexp) == 0 {
 		regexp = ".+"
 	}
@@ -223,36 +228,33 @@ func (rd *RedisStore) FindPrefixFor(count int64, regexp string) ([]string, error
 
 	var cursor uint64
 	var keys = make([]string, 0, count)
-	var err error
 	for {
-		var scanned = rd.Client.SScan(rd.ctx, rd.hashList, cursor, "*", count)
-		var ky, cursor, err = scanned.Result()
+		var scanned = rd.Client.SScan(ctx, rd.hashList, cursor, "*", count)
+		var ky, nextCursor, err = scanned.Result()
 		if err != nil {
 			return keys, nerror.WrapOnly(err)
 		}
 
 		for _, item := range ky {
-			if !regx.MatchString(item) {
+			var ritem = rd.unHashKey(item)
+			if !regx.MatchString(ritem) {
 				continue
 			}
-			keys = append(keys, item)
+			keys = append(keys, ritem)
 		}
 
+		cursor = nextCursor
 		if cursor == 0 {
 			break
 		}
 	}
-
-	if err != nil {
-		return nil, nerror.WrapOnly(err)
-	}
 	return keys, nil
 }
 
 // Exists returns true/false if giving key exists.
-func (rd *RedisStore) Exists(key string) (bool, error) {
+func (rd *ContextRedisStore) Exists(ctx context.Context, key string) (bool, error) {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.SIsMember(rd.ctx, rd.hashList, hashKey)
+	var nstatus = rd.Client.SIsMember(ctx, rd.hashList, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return false, nerror.WrapOnly(err)
 	}
@@ -260,9 +262,9 @@ func (rd *RedisStore) Exists(key string) (bool, error) {
 }
 
 // exists returns true/false if giving key is set in redis.
-func (rd *RedisStore) exists(key string) (bool, error) {
+func (rd *ContextRedisStore) exists(ctx context.Context, key string) (bool, error) {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.Exists(rd.ctx, hashKey)
+	var nstatus = rd.Client.Exists(ctx, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return false, nerror.WrapOnly(err)
 	}
@@ -270,21 +272,21 @@ func (rd *RedisStore) exists(key string) (bool, error) {
 }
 
 // expire expires giving key set from underline hash set.
-func (rd *RedisStore) expire(keys []string) error {
+func (rd *ContextRedisStore) expire(ctx context.Context, keys []string) error {
 	var items = make([]interface{}, len(keys))
 	for index, elem := range keys {
 		items[index] = elem
 	}
-	var _, err = rd.Client.TxPipelined(rd.ctx, func(pipeliner redis.Pipeliner) error {
-		var zstatus = pipeliner.ZRem(rd.ctx, rd.hashZList, items...)
+	var _, err = rd.Client.TxPipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		var zstatus = pipeliner.ZRem(ctx, rd.hashZList, items...)
 		if err := zstatus.Err(); err != nil {
 			return err
 		}
-		var mstatus = pipeliner.SRem(rd.ctx, rd.hashList, items...)
+		var mstatus = pipeliner.SRem(ctx, rd.hashList, items...)
 		if err := mstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
-		var dstatus = pipeliner.Del(rd.ctx, keys...)
+		var dstatus = pipeliner.Del(ctx, keys...)
 		if err := dstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
@@ -297,17 +299,17 @@ func (rd *RedisStore) expire(keys []string) error {
 }
 
 // Save adds giving session into storage using redis as underline store.
-func (rd *RedisStore) Save(key string, data []byte) error {
-	return rd.SaveTTL(key, data, 0)
+func (rd *ContextRedisStore) Save(ctx context.Context, key string, data []byte) error {
+	return rd.SaveTTL(ctx, key, data, 0)
 }
 
 // SaveTTL adds giving session into storage using redis as underline store, with provided
 // expiration.
 // Duration of 0 means no expiration.
-func (rd *RedisStore) SaveTTL(key string, data []byte, expiration time.Duration) error {
+func (rd *ContextRedisStore) SaveTTL(ctx context.Context, key string, data []byte, expiration time.Duration) error {
 	var hashKey = rd.doHashKey(key)
-	var _, pipeErr = rd.Client.TxPipelined(rd.ctx, func(pipeliner redis.Pipeliner) error {
-		var nstatus = pipeliner.SAdd(rd.ctx, rd.hashList, hashKey)
+	var _, pipeErr = rd.Client.TxPipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		var nstatus = pipeliner.SAdd(ctx, rd.hashList, hashKey)
 		if err := nstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
@@ -316,12 +318,12 @@ func (rd *RedisStore) SaveTTL(key string, data []byte, expiration time.Duration)
 		zs.Score = 0
 		zs.Member = hashKey
 
-		var zstatus = pipeliner.ZAdd(rd.ctx, rd.hashZList, &zs)
+		var zstatus = pipeliner.ZAdd(ctx, rd.hashZList, &zs)
 		if err := zstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
 
-		var nset = pipeliner.Set(rd.ctx, hashKey, data, expiration)
+		var nset = pipeliner.Set(ctx, hashKey, data, expiration)
 		if err := nset.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
@@ -336,8 +338,8 @@ func (rd *RedisStore) SaveTTL(key string, data []byte, expiration time.Duration)
 }
 
 // Update updates giving key with new data slice with 0 duration.
-func (rd *RedisStore) Update(key string, data []byte) error {
-	return rd.UpdateTTL(key, data, 0)
+func (rd *ContextRedisStore) Update(ctx context.Context, key string, data []byte) error {
+	return rd.UpdateTTL(ctx, key, data, 0)
 }
 
 // UpdateTTL updates giving session stored with giving key. It updates
@@ -345,9 +347,9 @@ func (rd *RedisStore) Update(key string, data []byte) error {
 //
 // if expiration is zero then giving value expiration will not be reset but left
 // as is.
-func (rd *RedisStore) UpdateTTL(key string, data []byte, expiration time.Duration) error {
+func (rd *ContextRedisStore) UpdateTTL(ctx context.Context, key string, data []byte, expiration time.Duration) error {
 	var hashKey = rd.doHashKey(key)
-	var fstatus = rd.Client.SIsMember(rd.ctx, rd.hashList, hashKey)
+	var fstatus = rd.Client.SIsMember(ctx, rd.hashList, hashKey)
 	if err := fstatus.Err(); err != nil {
 		return nerror.WrapOnly(err)
 	}
@@ -355,9 +357,9 @@ func (rd *RedisStore) UpdateTTL(key string, data []byte, expiration time.Duratio
 		return nerror.New("key does not exist")
 	}
 
-	var _, pipeErr = rd.Client.TxPipelined(rd.ctx, func(cl redis.Pipeliner) error {
+	var _, pipeErr = rd.Client.TxPipelined(ctx, func(cl redis.Pipeliner) error {
 		if len(data) == 0 {
-			var dstatus = cl.Del(rd.ctx, hashKey)
+			var dstatus = cl.Del(ctx, hashKey)
 			if err := dstatus.Err(); err != nil {
 				return err
 			}
@@ -365,7 +367,7 @@ func (rd *RedisStore) UpdateTTL(key string, data []byte, expiration time.Duratio
 			var zs redis.Z
 			zs.Score = 0
 			zs.Member = hashKey
-			var zstatus = cl.ZRem(rd.ctx, rd.hashZList, zs)
+			var zstatus = cl.ZRem(ctx, rd.hashZList, zs)
 			if err := zstatus.Err(); err != nil {
 				return err
 			}
@@ -377,12 +379,12 @@ func (rd *RedisStore) UpdateTTL(key string, data []byte, expiration time.Duratio
 		zs.Score = 0
 		zs.Member = hashKey
 
-		var zstatus = cl.ZAdd(rd.ctx, rd.hashZList, &zs)
+		var zstatus = cl.ZAdd(ctx, rd.hashZList, &zs)
 		if err := zstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
 
-		var nset = cl.Set(rd.ctx, hashKey, data, expiration)
+		var nset = cl.Set(ctx, hashKey, data, expiration)
 		if err := nset.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
@@ -396,9 +398,9 @@ func (rd *RedisStore) UpdateTTL(key string, data []byte, expiration time.Duratio
 }
 
 // TTL returns current expiration time for giving key.
-func (rd *RedisStore) TTL(key string) (time.Duration, error) {
+func (rd *ContextRedisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.PTTL(rd.ctx, hashKey)
+	var nstatus = rd.Client.PTTL(ctx, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return 0, nerror.WrapOnly(err)
 	}
@@ -410,9 +412,9 @@ func (rd *RedisStore) TTL(key string) (time.Duration, error) {
 
 // ExtendTTL extends the expiration of a giving key if it exists, the duration is expected to be
 // in milliseconds. If expiration value is zero then we consider that you wish to remove the expiration.
-func (rd *RedisStore) ExtendTTL(key string, expiration time.Duration) error {
+func (rd *ContextRedisStore) ExtendTTL(ctx context.Context, key string, expiration time.Duration) error {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.PTTL(rd.ctx, hashKey)
+	var nstatus = rd.Client.PTTL(ctx, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return nerror.WrapOnly(err)
 	}
@@ -422,13 +424,13 @@ func (rd *RedisStore) ExtendTTL(key string, expiration time.Duration) error {
 	}
 
 	var newExpiration = expiration + nstatus.Val()
-	var _, pipeErr = rd.Client.TxPipelined(rd.ctx, func(cl redis.Pipeliner) error {
+	var _, pipeErr = rd.Client.TxPipelined(ctx, func(cl redis.Pipeliner) error {
 		if expiration == 0 {
-			var exstatus = cl.Persist(rd.ctx, hashKey)
+			var exstatus = cl.Persist(ctx, hashKey)
 			return exstatus.Err()
 		}
 
-		var exstatus = cl.Expire(rd.ctx, hashKey, newExpiration)
+		var exstatus = cl.Expire(ctx, hashKey, newExpiration)
 		return exstatus.Err()
 	})
 
@@ -442,9 +444,9 @@ func (rd *RedisStore) ExtendTTL(key string, expiration time.Duration) error {
 // ResetTTL resets giving expiration value to provided duration.
 //
 // A duration of zero persists the giving key.
-func (rd *RedisStore) ResetTTL(key string, expiration time.Duration) error {
+func (rd *ContextRedisStore) ResetTTL(ctx context.Context, key string, expiration time.Duration) error {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.PTTL(rd.ctx, hashKey)
+	var nstatus = rd.Client.PTTL(ctx, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return nerror.WrapOnly(err)
 	}
@@ -453,13 +455,13 @@ func (rd *RedisStore) ResetTTL(key string, expiration time.Duration) error {
 		return nil
 	}
 
-	var _, pipeErr = rd.Client.TxPipelined(rd.ctx, func(cl redis.Pipeliner) error {
+	var _, pipeErr = rd.Client.TxPipelined(ctx, func(cl redis.Pipeliner) error {
 		if expiration == 0 {
-			var exstatus = cl.Persist(rd.ctx, hashKey)
+			var exstatus = cl.Persist(ctx, hashKey)
 			return exstatus.Err()
 		}
 
-		var exstatus = cl.Expire(rd.ctx, hashKey, expiration)
+		var exstatus = cl.Expire(ctx, hashKey, expiration)
 		return exstatus.Err()
 	})
 	if err := pipeErr; err != nil {
@@ -472,13 +474,13 @@ func (rd *RedisStore) ResetTTL(key string, expiration time.Duration) error {
 // GetAnyKeys returns a list of values for any of the key's found.
 // Unless a specific error occurred retrieving the value of a key, if a
 // key is not found then it is ignored and a nil is set in it's place.
-func (rd *RedisStore) GetAnyKeys(keys ...string) ([][]byte, error) {
+func (rd *ContextRedisStore) GetAnyKeys(ctx context.Context, keys ...string) ([][]byte, error) {
 	var modifiedKeys = make([]string, len(keys))
 	for index, key := range keys {
 		modifiedKeys[index] = rd.doHashKey(key)
 	}
 
-	var nstatus = rd.Client.MGet(rd.ctx, modifiedKeys...)
+	var nstatus = rd.Client.MGet(ctx, modifiedKeys...)
 	if err := nstatus.Err(); err != nil {
 		return nil, nerror.WrapOnly(err)
 	}
@@ -501,13 +503,13 @@ func (rd *RedisStore) GetAnyKeys(keys ...string) ([][]byte, error) {
 // GetAllKeys returns a list of values for any of the key's found.
 // if the value of a key is not found then we stop immediately, returning
 // an error and the current set of items retreived.
-func (rd *RedisStore) GetAllKeys(keys ...string) ([][]byte, error) {
+func (rd *ContextRedisStore) GetAllKeys(ctx context.Context, keys ...string) ([][]byte, error) {
 	var modifiedKeys = make([]string, len(keys))
 	for index, key := range keys {
 		modifiedKeys[index] = rd.doHashKey(key)
 	}
 
-	var nstatus = rd.Client.MGet(rd.ctx, modifiedKeys...)
+	var nstatus = rd.Client.MGet(ctx, modifiedKeys...)
 	if err := nstatus.Err(); err != nil {
 		return nil, nerror.WrapOnly(err)
 	}
@@ -529,9 +531,9 @@ func (rd *RedisStore) GetAllKeys(keys ...string) ([][]byte, error) {
 
 // Get returns giving session stored with giving key, returning an
 // error if not found.
-func (rd *RedisStore) Get(key string) ([]byte, error) {
+func (rd *ContextRedisStore) Get(ctx context.Context, key string) ([]byte, error) {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.Get(rd.ctx, hashKey)
+	var nstatus = rd.Client.Get(ctx, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return nil, nerror.WrapOnly(err)
 	}
@@ -540,7 +542,7 @@ func (rd *RedisStore) Get(key string) ([]byte, error) {
 
 // RemoveKeys removes underline key from the redis store after retrieving it and
 // returning giving session.
-func (rd *RedisStore) RemoveKeys(keys ...string) error {
+func (rd *ContextRedisStore) RemoveKeys(ctx context.Context, keys ...string) error {
 	var modifiedKeys = make([]string, len(keys))
 	var modifiedIKeys = make([]interface{}, len(keys))
 
@@ -550,16 +552,16 @@ func (rd *RedisStore) RemoveKeys(keys ...string) error {
 		modifiedIKeys[index] = mod
 	}
 
-	var _, err = rd.Client.TxPipelined(rd.ctx, func(pipeliner redis.Pipeliner) error {
-		var zstatus = pipeliner.ZRem(rd.ctx, rd.hashZList, modifiedIKeys...)
+	var _, err = rd.Client.TxPipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		var zstatus = pipeliner.ZRem(ctx, rd.hashZList, modifiedIKeys...)
 		if err := zstatus.Err(); err != nil {
 			return err
 		}
-		var mstatus = pipeliner.SRem(rd.ctx, rd.hashList, modifiedIKeys...)
+		var mstatus = pipeliner.SRem(ctx, rd.hashList, modifiedIKeys...)
 		if err := mstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
-		var dstatus = pipeliner.Del(rd.ctx, modifiedKeys...)
+		var dstatus = pipeliner.Del(ctx, modifiedKeys...)
 		if err := dstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
@@ -573,23 +575,23 @@ func (rd *RedisStore) RemoveKeys(keys ...string) error {
 
 // Remove removes underline key from the redis store after retrieving it and
 // returning giving session.
-func (rd *RedisStore) Remove(key string) ([]byte, error) {
+func (rd *ContextRedisStore) Remove(ctx context.Context, key string) ([]byte, error) {
 	var hashKey = rd.doHashKey(key)
-	var nstatus = rd.Client.Get(rd.ctx, hashKey)
+	var nstatus = rd.Client.Get(ctx, hashKey)
 	if err := nstatus.Err(); err != nil {
 		return nil, nerror.WrapOnly(err)
 	}
 
-	var _, err = rd.Client.TxPipelined(rd.ctx, func(pipeliner redis.Pipeliner) error {
-		var zstatus = pipeliner.ZRem(rd.ctx, rd.hashZList, hashKey)
+	var _, err = rd.Client.TxPipelined(ctx, func(pipeliner redis.Pipeliner) error {
+		var zstatus = pipeliner.ZRem(ctx, rd.hashZList, hashKey)
 		if err := zstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
-		var mstatus = pipeliner.SRem(rd.ctx, rd.hashList, hashKey)
+		var mstatus = pipeliner.SRem(ctx, rd.hashList, hashKey)
 		if err := mstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
-		var dstatus = pipeliner.Del(rd.ctx, hashKey)
+		var dstatus = pipeliner.Del(ctx, hashKey)
 		if err := dstatus.Err(); err != nil {
 			return nerror.WrapOnly(err)
 		}
@@ -600,3 +602,135 @@ func (rd *RedisStore) Remove(key string) ([]byte, error) {
 	}
 	return nunsafe.String2Bytes(nstatus.Val()), nil
 }
+
+//**********************************************************************
+// RedisStore
+//**********************************************************************
+
+// RedisStore implements session management, storage and access using redis as
+// underline store, using the context it was created with for all calls.
+//
+// Use RedisStore.Context or the ContextRedisStore for per call
+// deadlines and cancellation.
+type RedisStore struct {
+	*ContextRedisStore
+	ctx context.Context
+}
+
+// NewRedisStore returns a new instance of a redis store.
+func NewRedisStore(ctx context.Context, tableName string, config redis.Options) (*RedisStore, error) {
+	var store, err = NewContextRedisStore(ctx, tableName, config)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{ContextRedisStore: store, ctx: ctx}, nil
+}
+
+// FromRedisStore returns a new instance of a RedisStore using giving client.
+func FromRedisStore(ctx context.Context, tableName string, conn *redis.Client) (*RedisStore, error) {
+	var store, err = FromContextRedisStore(ctx, tableName, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{ContextRedisStore: store, ctx: ctx}, nil
+}
+
+// Context returns the underline context aware store.
+func (rd *RedisStore) Context() *ContextRedisStore {
+	return rd.ContextRedisStore
+}
+
+// Keys returns all giving keys of elements within store.
+func (rd *RedisStore) Keys() ([]string, error) {
+	return rd.ContextRedisStore.Keys(rd.ctx)
+}
+
+// Each runs through all elements for giving store.
+func (rd *RedisStore) Each(fn nstorage.EachItem) error {
+	return rd.ContextRedisStore.Each(rd.ctx, fn)
+}
+
+// EachKeyMatch returns all keys matching giving regexp.
+func (rd *RedisStore) EachKeyMatch(regexp string) ([]string, error) {
+	return rd.ContextRedisStore.EachKeyMatch(rd.ctx, regexp)
+}
+
+// ScanMatch scans keys of store matching giving regexp.
+func (rd *RedisStore) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	return rd.ContextRedisStore.ScanMatch(rd.ctx, count, lastIndex, lastKey, regexp)
+}
+
+// Count returns the total count of element in the store.
+func (rd *RedisStore) Count() (int64, error) {
+	return rd.ContextRedisStore.Count(rd.ctx)
+}
+
+// FindPrefixFor returns all keys matching giving regexp.
+func (rd *RedisStore) FindPrefixFor(count int64, regexp string) ([]string, error) {
+	return rd.ContextRedisStore.FindPrefixFor(rd.ctx, count, regexp)
+}
+
+// Exists returns true/false if giving key exists.
+func (rd *RedisStore) Exists(key string) (bool, error) {
+	return rd.ContextRedisStore.Exists(rd.ctx, key)
+}
+
+// Save adds giving key and value into the store.
+func (rd *RedisStore) Save(key string, data []byte) error {
+	return rd.ContextRedisStore.Save(rd.ctx, key, data)
+}
+
+// SaveTTL adds giving key and value into the store with provided expiration.
+func (rd *RedisStore) SaveTTL(key string, data []byte, expiration time.Duration) error {
+	return rd.ContextRedisStore.SaveTTL(rd.ctx, key, data, expiration)
+}
+
+// Update updates giving key with new data slice with 0 duration.
+func (rd *RedisStore) Update(key string, data []byte) error {
+	return rd.ContextRedisStore.Update(rd.ctx, key, data)
+}
+
+// UpdateTTL updates giving key with new data and expiration.
+func (rd *RedisStore) UpdateTTL(key string, data []byte, expiration time.Duration) error {
+	return rd.ContextRedisStore.UpdateTTL(rd.ctx, key, data, expiration)
+}
+
+// TTL returns current expiration time for giving key.
+func (rd *RedisStore) TTL(key string) (time.Duration, error) {
+	return rd.ContextRedisStore.TTL(rd.ctx, key)
+}
+
+// ExtendTTL extends the expiration of a giving key if it exists.
+func (rd *RedisStore) ExtendTTL(key string, expiration time.Duration) error {
+	return rd.ContextRedisStore.ExtendTTL(rd.ctx, key, expiration)
+}
+
+// ResetTTL resets giving expiration value to provided duration.
+func (rd *RedisStore) ResetTTL(key string, expiration time.Duration) error {
+	return rd.ContextRedisStore.ResetTTL(rd.ctx, key, expiration)
+}
+
+// GetAnyKeys returns a list of values for any of the key's found.
+func (rd *RedisStore) GetAnyKeys(keys ...string) ([][]byte, error) {
+	return rd.ContextRedisStore.GetAnyKeys(rd.ctx, keys...)
+}
+
+// GetAllKeys returns a list of values for all of the key's.
+func (rd *RedisStore) GetAllKeys(keys ...string) ([][]byte, error) {
+	return rd.ContextRedisStore.GetAllKeys(rd.ctx, keys...)
+}
+
+// Get returns giving value stored with giving key.
+func (rd *RedisStore) Get(key string) ([]byte, error) {
+	return rd.ContextRedisStore.Get(rd.ctx, key)
+}
+
+// RemoveKeys removes all giving keys from the store.
+func (rd *RedisStore) RemoveKeys(keys ...string) error {
+	return rd.ContextRedisStore.RemoveKeys(rd.ctx, keys...)
+}
+
+// Remove removes giving key from the store, returning it's value.
+func (rd *RedisStore) Remove(key string) ([]byte, error) {
+	return rd.ContextRedisStore.Remove(rd.ctx, key)
+}
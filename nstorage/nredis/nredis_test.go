@@ -2,12 +2,15 @@ package nredis
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/alicebob/miniredis"
 	redis "github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/require"
 
+	"github.com/influx6/npkg/nstorage"
 	"github.com/influx6/npkg/nstorage/internal/tharness"
 )
 
@@ -36,3 +39,64 @@ func TestRedisStore(t *testing.T) {
 
 	tharness.TestByteStore(t, store)
 }
+
+func TestContextRedisStore(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NotNil(t, server)
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var redisClient = redis.NewClient(&redis.Options{Addr: server.Addr(), Network: "tcp"})
+
+	var store, err = FromContextRedisStore(context.Background(), "testing_mb", redisClient)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+
+	tharness.TestContextStore(t, store)
+	tharness.TestContextExpirableStore(t, store)
+}
+
+func TestRedisStoreKeysAreUnhashed(t *testing.T) {
+	var server = miniredis.NewMiniRedis()
+	require.NoError(t, server.StartAddr("localhost:0"))
+	defer server.Close()
+
+	var redisClient = redis.NewClient(&redis.Options{Addr: server.Addr(), Network: "tcp"})
+	var store, err = FromRedisStore(context.Background(), "testing_mb", redisClient)
+	require.NoError(t, err)
+
+	var saved []string
+	for i := 0; i < 30; i++ {
+		var key = fmt.Sprintf("key-%02d", i)
+		require.NoError(t, store.Save(key, []byte("value")))
+		saved = append(saved, key)
+	}
+	require.NoError(t, store.Save("other", []byte("value")))
+
+	// Each gave the keys with the store prefix they are saved with.
+	var keys []string
+	require.NoError(t, store.Each(func(_ []byte, key string) error {
+		keys = append(keys, key)
+		return nil
+	}))
+	require.ElementsMatch(t, append([]string{"other"}, saved...), keys)
+
+	// FindPrefixFor gave prefixed keys, never matching a regexp anchored
+	// on the key, and scanned the first page again without end once the
+	// keys did not fit in one.
+	var found []string
+	found, err = store.FindPrefixFor(5, "^key-")
+	require.NoError(t, err)
+	sort.Strings(found)
+	require.Equal(t, saved, found)
+
+	// ScanMatch took the last key at index count-1, panicking when less
+	// keys than count matched.
+	var result nstorage.ScanResult
+	require.NotPanics(t, func() {
+		result, err = store.ScanMatch(100, 0, "", "^other$")
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"other"}, result.Keys)
+	require.Equal(t, "other", result.LastKey)
+}
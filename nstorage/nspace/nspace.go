@@ -0,0 +1,510 @@
+package nspace
+
+import (
+	regexp2 "regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+var (
+	_ nstorage.ByteStore      = (*Store)(nil)
+	_ nstorage.ExpirableStore = (*ExpirableStore)(nil)
+)
+
+// ErrQuotaExceeded is returned when a write would take a namespace
+// above it's key or byte quota.
+var ErrQuotaExceeded = nerror.New("namespace quota exceeded")
+
+// DefaultSeparator is placed between the namespace prefix and a key.
+const DefaultSeparator = ":"
+
+// dropBatchSize sets how many keys are removed per call when dropping
+// a namespace.
+const dropBatchSize = 500
+
+// Option defines a function type which sets the internal state of a Store.
+type Option func(*Store)
+
+// MaxKeys sets the maximum number of keys allowed within the namespace.
+func MaxKeys(n int64) Option {
+	return func(s *Store) {
+		s.maxKeys = n
+	}
+}
+
+// MaxBytes sets the maximum total size of all values within the namespace.
+func MaxBytes(n int64) Option {
+	return func(s *Store) {
+		s.maxBytes = n
+	}
+}
+
+// Separator sets the separator placed between the prefix and keys,
+// defaults to DefaultSeparator.
+func Separator(sep string) Option {
+	return func(s *Store) {
+		s.separator = sep
+	}
+}
+
+// Usage holds the current tracked usage of a namespace.
+type Usage struct {
+	Keys  int64
+	Bytes int64
+}
+
+// Store implements a nstorage.ByteStore which transparently prefixes all
+// keys with a namespace before passing them on to an underline store, and
+// strips the prefix from all keys returned.
+//
+// Quotas are tracked in memory by the Store instance, hence they are only
+// enforced for writes going through the same instance. Keys which expire
+// within the underline store are only removed from the usage on a call to
+// Store.Refresh.
+type Store struct {
+	store     nstorage.ByteStore
+	prefix    string
+	separator string
+	maxKeys   int64
+	maxBytes  int64
+
+	lock   sync.Mutex
+	loaded bool
+	usage  Usage
+}
+
+// Namespace returns a new Store which scopes all keys of store within
+// giving prefix.
+func Namespace(store nstorage.ByteStore, prefix string, ops ...Option) *Store {
+	var ns Store
+	ns.store = store
+	ns.separator = DefaultSeparator
+	for _, op := range ops {
+		op(&ns)
+	}
+	ns.prefix = prefix + ns.separator
+	return &ns
+}
+
+// Prefix returns the prefix added to all keys of the namespace.
+func (s *Store) Prefix() string {
+	return s.prefix
+}
+
+// Usage returns the current usage of the namespace, loading it from
+// the underline store if not yet done.
+func (s *Store) Usage() (Usage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.loadUsage(false); err != nil {
+		return Usage{}, err
+	}
+	return s.usage, nil
+}
+
+// Refresh reloads the usage of the namespace from the underline store.
+func (s *Store) Refresh() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.loadUsage(true)
+}
+
+// Drop removes all keys within the namespace from the underline store,
+// removing keys in batches.
+func (s *Store) Drop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var keys, err = s.namespacedKeys()
+	if err != nil {
+		return err
+	}
+
+	for len(keys) > 0 {
+		var batch = keys
+		if len(batch) > dropBatchSize {
+			batch = keys[:dropBatchSize]
+		}
+		if err := s.store.RemoveKeys(batch...); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		keys = keys[len(batch):]
+	}
+
+	s.usage = Usage{}
+	s.loaded = true
+	return nil
+}
+
+// Each runs through all elements of the namespace.
+//
+// Return nstorage.ErrJustStop if you want to just stop iterating.
+func (s *Store) Each(fn nstorage.EachItem) error {
+	return s.store.Each(func(value []byte, key string) error {
+		if !strings.HasPrefix(key, s.prefix) {
+			return nil
+		}
+		return fn(value, s.unprefix(key))
+	})
+}
+
+// EachKeyMatch returns all keys within the namespace matching giving regexp,
+// where the regexp is matched against the key without the prefix.
+func (s *Store) EachKeyMatch(regexp string) ([]string, error) {
+	var regx, rgErr = compileMatch(regexp)
+	if rgErr != nil {
+		return nil, nerror.WrapOnly(rgErr)
+	}
+
+	var keys, err = s.namespacedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched = make([]string, 0, len(keys))
+	for _, key := range keys {
+		var ukey = s.unprefix(key)
+		if regx.MatchString(ukey) {
+			matched = append(matched, ukey)
+		}
+	}
+	return matched, nil
+}
+
+// ScanMatch scans the underline store for keys within the namespace, then
+// filters them with giving regexp. Since filtering happens after the underline
+// scan, a page may hold less keys then count, use ScanResult.Finished to know
+// when the scan is complete.
+func (s *Store) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	var regx, rgErr = compileMatch(regexp)
+	if rgErr != nil {
+		return nstorage.ScanResult{}, nerror.WrapOnly(rgErr)
+	}
+
+	if len(lastKey) != 0 {
+		lastKey = s.prefixed(lastKey)
+	}
+
+	var result, err = s.store.ScanMatch(count, lastIndex, lastKey, s.matchPrefix())
+	if err != nil {
+		return result, nerror.WrapOnly(err)
+	}
+
+	var keys = make([]string, 0, len(result.Keys))
+	for _, key := range result.Keys {
+		var ukey = s.unprefix(key)
+		if regx.MatchString(ukey) {
+			keys = append(keys, ukey)
+		}
+	}
+
+	result.Keys = keys
+	if len(result.LastKey) != 0 {
+		result.LastKey = s.unprefix(result.LastKey)
+	}
+	return result, nil
+}
+
+// Count returns the total number of keys within the namespace.
+func (s *Store) Count() (int64, error) {
+	var keys, err = s.namespacedKeys()
+	if err != nil {
+		return -1, err
+	}
+	return int64(len(keys)), nil
+}
+
+// Keys returns all keys within the namespace.
+func (s *Store) Keys() ([]string, error) {
+	var keys, err = s.namespacedKeys()
+	if err != nil {
+		return nil, err
+	}
+	for index, key := range keys {
+		keys[index] = s.unprefix(key)
+	}
+	return keys, nil
+}
+
+// Save adds giving key and value into the namespace.
+func (s *Store) Save(key string, value []byte) error {
+	return s.write(key, value, false, func(pkey string) error {
+		return s.store.Save(pkey, value)
+	})
+}
+
+// Exists returns true/false if giving key exists within the namespace.
+func (s *Store) Exists(key string) (bool, error) {
+	return s.store.Exists(s.prefixed(key))
+}
+
+// Update updates giving key with new value.
+func (s *Store) Update(key string, value []byte) error {
+	return s.write(key, value, true, func(pkey string) error {
+		return s.store.Update(pkey, value)
+	})
+}
+
+// RemoveKeys removes all giving keys from the namespace.
+func (s *Store) RemoveKeys(keys ...string) error {
+	var pkeys = s.prefixedList(keys)
+	if !s.hasQuota() {
+		return s.store.RemoveKeys(pkeys...)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.loadUsage(false); err != nil {
+		return err
+	}
+
+	var removed Usage
+	for _, pkey := range pkeys {
+		var size, found, err = s.sizeOf(pkey)
+		if err != nil {
+			return err
+		}
+		if found {
+			removed.Keys++
+			removed.Bytes += size
+		}
+	}
+
+	if err := s.store.RemoveKeys(pkeys...); err != nil {
+		return err
+	}
+
+	s.usage.Keys -= removed.Keys
+	s.usage.Bytes -= removed.Bytes
+	return nil
+}
+
+// Remove removes giving key from the namespace, returning it's value.
+func (s *Store) Remove(key string) ([]byte, error) {
+	if !s.hasQuota() {
+		return s.store.Remove(s.prefixed(key))
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.loadUsage(false); err != nil {
+		return nil, err
+	}
+
+	var value, err = s.store.Remove(s.prefixed(key))
+	if err != nil {
+		return nil, err
+	}
+
+	s.usage.Keys--
+	s.usage.Bytes -= int64(len(value))
+	return value, nil
+}
+
+// Get returns the value of giving key within the namespace.
+func (s *Store) Get(key string) ([]byte, error) {
+	return s.store.Get(s.prefixed(key))
+}
+
+// GetAllKeys returns values of all giving keys within the namespace.
+func (s *Store) GetAllKeys(keys ...string) ([][]byte, error) {
+	return s.store.GetAllKeys(s.prefixedList(keys)...)
+}
+
+// GetAnyKeys returns values of any of the giving keys found within the namespace.
+func (s *Store) GetAnyKeys(keys ...string) ([][]byte, error) {
+	return s.store.GetAnyKeys(s.prefixedList(keys)...)
+}
+
+// *****************************************************
+// internal methods
+// *****************************************************
+
+func (s *Store) prefixed(key string) string {
+	return s.prefix + key
+}
+
+func (s *Store) unprefix(key string) string {
+	return strings.TrimPrefix(key, s.prefix)
+}
+
+func (s *Store) prefixedList(keys []string) []string {
+	var pkeys = make([]string, len(keys))
+	for index, key := range keys {
+		pkeys[index] = s.prefixed(key)
+	}
+	return pkeys
+}
+
+func (s *Store) matchPrefix() string {
+	return "^" + regexp2.QuoteMeta(s.prefix)
+}
+
+// namespacedKeys returns all keys of the underline store with the
+// namespace prefix, without removing the prefix.
+func (s *Store) namespacedKeys() ([]string, error) {
+	var keys, err = s.store.EachKeyMatch(s.matchPrefix())
+	if err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	// guard against stores which do not anchor the regexp.
+	var filtered = keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, s.prefix) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *Store) hasQuota() bool {
+	return s.maxKeys > 0 || s.maxBytes > 0
+}
+
+// write runs fn for giving key if the write keeps the namespace within
+// it's quota, updating the tracked usage after.
+func (s *Store) write(key string, value []byte, mustExist bool, fn func(pkey string) error) error {
+	var pkey = s.prefixed(key)
+	if !s.hasQuota() {
+		return fn(pkey)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.loadUsage(false); err != nil {
+		return err
+	}
+
+	var oldSize, found, err = s.sizeOf(pkey)
+	if err != nil {
+		return err
+	}
+
+	var next = s.usage
+	next.Bytes += int64(len(value)) - oldSize
+	if !found {
+		if mustExist {
+			return nerror.New("key %q does not exist", key)
+		}
+		next.Keys++
+	}
+
+	if s.maxKeys > 0 && next.Keys > s.maxKeys {
+		return nerror.Wrap(ErrQuotaExceeded, "namespace %q would have %d keys above limit %d", s.prefix, next.Keys, s.maxKeys)
+	}
+	if s.maxBytes > 0 && next.Bytes > s.maxBytes {
+		return nerror.Wrap(ErrQuotaExceeded, "namespace %q would have %d bytes above limit %d", s.prefix, next.Bytes, s.maxBytes)
+	}
+
+	if err := fn(pkey); err != nil {
+		return err
+	}
+
+	s.usage = next
+	return nil
+}
+
+// sizeOf returns the size of the value of the prefixed key if it exists.
+func (s *Store) sizeOf(pkey string) (int64, bool, error) {
+	var exists, err = s.store.Exists(pkey)
+	if err != nil {
+		return 0, false, nerror.WrapOnly(err)
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	var value, getErr = s.store.Get(pkey)
+	if getErr != nil {
+		return 0, false, nerror.WrapOnly(getErr)
+	}
+	return int64(len(value)), true, nil
+}
+
+// loadUsage loads the usage of the namespace from the underline store,
+// it must be called with the lock held.
+func (s *Store) loadUsage(force bool) error {
+	if s.loaded && !force {
+		return nil
+	}
+
+	var usage Usage
+	var err = s.store.Each(func(value []byte, key string) error {
+		if !strings.HasPrefix(key, s.prefix) {
+			return nil
+		}
+		usage.Keys++
+		usage.Bytes += int64(len(value))
+		return nil
+	})
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+
+	s.usage = usage
+	s.loaded = true
+	return nil
+}
+
+//**********************************************************************
+// ExpirableStore
+//**********************************************************************
+
+// ExpirableStore implements the nstorage.ExpirableStore over a namespace.
+type ExpirableStore struct {
+	*Store
+	expirable nstorage.ExpirableStore
+}
+
+// NamespaceExpirable returns a new ExpirableStore which scopes all keys of
+// store within giving prefix.
+func NamespaceExpirable(store nstorage.ExpirableStore, prefix string, ops ...Option) *ExpirableStore {
+	return &ExpirableStore{
+		Store:     Namespace(store, prefix, ops...),
+		expirable: store,
+	}
+}
+
+// TTL returns the remaining expiration of giving key.
+func (s *ExpirableStore) TTL(key string) (time.Duration, error) {
+	return s.expirable.TTL(s.prefixed(key))
+}
+
+// ExtendTTL extends the expiration of giving key by provided duration.
+func (s *ExpirableStore) ExtendTTL(key string, expiration time.Duration) error {
+	return s.expirable.ExtendTTL(s.prefixed(key), expiration)
+}
+
+// ResetTTL resets the expiration of giving key to provided duration.
+func (s *ExpirableStore) ResetTTL(key string, expiration time.Duration) error {
+	return s.expirable.ResetTTL(s.prefixed(key), expiration)
+}
+
+// SaveTTL saves giving key and value into the namespace with provided expiration.
+func (s *ExpirableStore) SaveTTL(key string, value []byte, expiration time.Duration) error {
+	return s.write(key, value, false, func(pkey string) error {
+		return s.expirable.SaveTTL(pkey, value, expiration)
+	})
+}
+
+// UpdateTTL updates giving key with new value and provided expiration.
+func (s *ExpirableStore) UpdateTTL(key string, value []byte, expiration time.Duration) error {
+	return s.write(key, value, true, func(pkey string) error {
+		return s.expirable.UpdateTTL(pkey, value, expiration)
+	})
+}
+
+func compileMatch(regexp string) (*regexp2.Regexp, error) {
+	if len(regexp) == 0 {
+		regexp = ".+"
+	}
+	return regexp2.Compile(regexp)
+}
@@ -0,0 +1,179 @@
+package nspace
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage/internal/tharness"
+	"github.com/influx6/npkg/nstorage/nbadger"
+	"github.com/influx6/npkg/nstorage/nmap"
+)
+
+func TestNamespaceWithNMapStore(t *testing.T) {
+	var store = NamespaceExpirable(nmap.NewExprByteStore(100), "tenant")
+	tharness.TestByteStore(t, store)
+}
+
+func TestNamespaceWithNMapStoreFindPrefix(t *testing.T) {
+	var store = NamespaceExpirable(nmap.NewExprByteStore(100), "tenant")
+	tharness.TestByteStoreFindPrefix(t, store)
+}
+
+func TestNamespaceWithNMapStoreScanMatch(t *testing.T) {
+	var store = NamespaceExpirable(nmap.NewExprByteStore(100), "tenant")
+	tharness.TestByteStoreScanMatch(t, store)
+}
+
+func TestNamespaceWithNMapStoreRemoveKeys(t *testing.T) {
+	var store = NamespaceExpirable(nmap.NewExprByteStore(100), "tenant", MaxKeys(20))
+	tharness.TestByteStoreRemoveKeys(t, store)
+}
+
+func TestNamespaceWithNMapExpirableStore(t *testing.T) {
+	var store = NamespaceExpirable(nmap.NewExprByteStore(100), "tenant")
+	tharness.TestExpirableStore(t, store)
+}
+
+func TestNamespaceWithBadgerStore(t *testing.T) {
+	var ops = badger.DefaultOptions("").WithInMemory(true)
+	var bstore, err = nbadger.NewBadgerStore(ops, badger.DefaultIteratorOptions)
+	require.NoError(t, err)
+	defer bstore.Close()
+
+	var store = NamespaceExpirable(bstore, "tenant")
+	tharness.TestByteStore(t, store)
+	tharness.TestByteStoreScanMatch(t, Namespace(bstore, "other"))
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	var base = nmap.NewExprByteStore(100)
+	var first = Namespace(base, "first")
+	var second = Namespace(base, "second")
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, first.Save(fmt.Sprintf("day-%d", i), []byte("first")))
+	}
+	require.NoError(t, second.Save("day-0", []byte("second")))
+
+	var value, err = second.Get("day-0")
+	require.NoError(t, err)
+	require.Equal(t, "second", string(value))
+
+	var exists, existErr = second.Exists("day-1")
+	require.NoError(t, existErr)
+	require.False(t, exists)
+
+	var keys, keysErr = first.Keys()
+	require.NoError(t, keysErr)
+	require.Len(t, keys, 5)
+	require.Contains(t, keys, "day-0")
+
+	var matched, matchErr = first.EachKeyMatch("^day-[12]$")
+	require.NoError(t, matchErr)
+	require.ElementsMatch(t, []string{"day-1", "day-2"}, matched)
+
+	var count, countErr = second.Count()
+	require.NoError(t, countErr)
+	require.Equal(t, int64(1), count)
+
+	var seen int
+	require.NoError(t, second.Each(func(value []byte, key string) error {
+		require.Equal(t, "day-0", key)
+		seen++
+		return nil
+	}))
+	require.Equal(t, 1, seen)
+
+	var baseKeys, baseErr = base.Keys()
+	require.NoError(t, baseErr)
+	require.Contains(t, baseKeys, "first:day-0")
+	require.Contains(t, baseKeys, "second:day-0")
+}
+
+func TestNamespaceDrop(t *testing.T) {
+	var base = nmap.NewExprByteStore(100)
+	var first = Namespace(base, "first")
+	var second = Namespace(base, "second")
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, first.Save(fmt.Sprintf("day-%d", i), []byte("first")))
+		require.NoError(t, second.Save(fmt.Sprintf("day-%d", i), []byte("second")))
+	}
+
+	require.NoError(t, first.Drop())
+
+	var count, err = first.Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), count)
+
+	count, err = second.Count()
+	require.NoError(t, err)
+	require.Equal(t, int64(10), count)
+}
+
+func TestNamespaceKeyQuota(t *testing.T) {
+	var store = Namespace(nmap.NewExprByteStore(100), "tenant", MaxKeys(2))
+
+	require.NoError(t, store.Save("a", []byte("1")))
+	require.NoError(t, store.Save("b", []byte("1")))
+	require.NoError(t, store.Save("b", []byte("2")), "replacing a key must not count as a new key")
+
+	var err = store.Save("c", []byte("1"))
+	require.Error(t, err)
+	require.True(t, nerror.IsAny(err, ErrQuotaExceeded))
+
+	var _, removeErr = store.Remove("a")
+	require.NoError(t, removeErr)
+	require.NoError(t, store.Save("c", []byte("1")))
+
+	var usage, usageErr = store.Usage()
+	require.NoError(t, usageErr)
+	require.Equal(t, Usage{Keys: 2, Bytes: 2}, usage)
+}
+
+func TestNamespaceByteQuota(t *testing.T) {
+	var base = nmap.NewExprByteStore(100)
+	require.NoError(t, base.Save("tenant:existing", []byte("1234")))
+
+	var store = Namespace(base, "tenant", MaxBytes(10))
+
+	require.NoError(t, store.Save("a", []byte("12345")))
+	require.Error(t, store.Save("b", []byte("12")), "existing keys must be accounted for")
+	require.NoError(t, store.Update("a", []byte("123456")))
+	require.Error(t, store.Update("a", []byte("1234567")))
+
+	require.NoError(t, store.RemoveKeys("existing"))
+	require.NoError(t, store.Save("b", []byte("1234")))
+
+	var usage, err = store.Usage()
+	require.NoError(t, err)
+	require.Equal(t, Usage{Keys: 2, Bytes: 10}, usage)
+}
+
+// failingExists fails Exists, as a store which is unreachable.
+type failingExists struct {
+	*nmap.ExprByteStore
+}
+
+func (failingExists) Exists(_ string) (bool, error) {
+	return false, errors.New("store is unreachable")
+}
+
+func TestNamespaceQuotaStoreErrors(t *testing.T) {
+	var base = nmap.NewExprByteStore(100)
+	var store = Namespace(failingExists{ExprByteStore: base}, "tenant", MaxKeys(2))
+	require.NoError(t, base.Save("tenant:a", []byte("1")))
+
+	// an error reading the size of a key must not count it as missing.
+	require.Error(t, store.Save("a", []byte("12")))
+	require.Error(t, store.RemoveKeys("a"))
+
+	var usage, err = store.Usage()
+	require.NoError(t, err)
+	require.Equal(t, Usage{Keys: 1, Bytes: 1}, usage)
+}
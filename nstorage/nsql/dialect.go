@@ -0,0 +1,148 @@
+package nsql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the database specific parts of the sql needed
+// by the SQLStore, every other statement is built from standard sql.
+type Dialect struct {
+	Name string
+
+	// Bind returns the parameter placeholder for the argument at
+	// giving 1-based position.
+	Bind func(position int) string
+
+	// CreateTable is a format string which receives the table name
+	// and creates the table if it does not already exists.
+	CreateTable string
+
+	// Upsert is a format string which receives the table name and
+	// the placeholders for the key, value and expiry columns in that order.
+	Upsert string
+}
+
+// SQLite defines the Dialect for sqlite3 drivers.
+var SQLite = Dialect{
+	Name: "sqlite",
+	Bind: questionBind,
+	CreateTable: `CREATE TABLE IF NOT EXISTS %s (
+	nkey TEXT NOT NULL PRIMARY KEY,
+	nvalue BLOB,
+	expires_at BIGINT NOT NULL DEFAULT 0
+)`,
+	Upsert: "INSERT INTO %s (nkey, nvalue, expires_at) VALUES (%s, %s, %s) " +
+		"ON CONFLICT(nkey) DO UPDATE SET nvalue = excluded.nvalue, expires_at = excluded.expires_at",
+}
+
+// Postgres defines the Dialect for postgres drivers.
+var Postgres = Dialect{
+	Name: "postgres",
+	Bind: dollarBind,
+	CreateTable: `CREATE TABLE IF NOT EXISTS %s (
+	nkey TEXT NOT NULL PRIMARY KEY,
+	nvalue BYTEA,
+	expires_at BIGINT NOT NULL DEFAULT 0
+)`,
+	Upsert: "INSERT INTO %s (nkey, nvalue, expires_at) VALUES (%s, %s, %s) " +
+		"ON CONFLICT (nkey) DO UPDATE SET nvalue = EXCLUDED.nvalue, expires_at = EXCLUDED.expires_at",
+}
+
+// MySQL defines the Dialect for mysql and mariadb drivers.
+var MySQL = Dialect{
+	Name: "mysql",
+	Bind: questionBind,
+	CreateTable: `CREATE TABLE IF NOT EXISTS %s (
+	nkey VARCHAR(255) NOT NULL PRIMARY KEY,
+	nvalue LONGBLOB,
+	expires_at BIGINT NOT NULL DEFAULT 0
+)`,
+	Upsert: "INSERT INTO %s (nkey, nvalue, expires_at) VALUES (%s, %s, %s) " +
+		"ON DUPLICATE KEY UPDATE nvalue = VALUES(nvalue), expires_at = VALUES(expires_at)",
+}
+
+func questionBind(_ int) string {
+	return "?"
+}
+
+func dollarBind(position int) string {
+	return "$" + strconv.Itoa(position)
+}
+
+// statements holds the generated sql used by a SQLStore for a
+// giving table.
+type statements struct {
+	createTable string
+	upsert      string
+	update      string
+	get         string
+	exists      string
+	expiry      string
+	setExpiry   string
+	count       string
+	pageKeys    string
+	pageItems   string
+	remove      string
+	purge       string
+}
+
+// buildStatements generates all statements for giving table using dialect.
+//
+// Every read filters out expired rows by comparing the expires_at column
+// against the current time supplied as an argument, where a zero
+// value means the row never expires.
+func buildStatements(dialect Dialect, table string) statements {
+	var b = dialect.Bind
+	var live = func(position int) string {
+		return fmt.Sprintf("(expires_at = 0 OR expires_at > %s)", b(position))
+	}
+
+	var st statements
+	st.createTable = fmt.Sprintf(dialect.CreateTable, table)
+	st.upsert = fmt.Sprintf(dialect.Upsert, table, b(1), b(2), b(3))
+	st.update = fmt.Sprintf(
+		"UPDATE %s SET nvalue = %s, expires_at = %s WHERE nkey = %s AND %s",
+		table, b(1), b(2), b(3), live(4),
+	)
+	st.get = fmt.Sprintf("SELECT nvalue FROM %s WHERE nkey = %s AND %s", table, b(1), live(2))
+	st.exists = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE nkey = %s AND %s", table, b(1), live(2))
+	st.expiry = fmt.Sprintf("SELECT expires_at FROM %s WHERE nkey = %s AND %s", table, b(1), live(2))
+	st.setExpiry = fmt.Sprintf(
+		"UPDATE %s SET expires_at = %s WHERE nkey = %s AND %s",
+		table, b(1), b(2), live(3),
+	)
+	st.count = fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, live(1))
+	st.pageKeys = fmt.Sprintf(
+		"SELECT nkey FROM %s WHERE nkey > %s AND %s ORDER BY nkey LIMIT %s",
+		table, b(1), live(2), b(3),
+	)
+	st.pageItems = fmt.Sprintf(
+		"SELECT nkey, nvalue FROM %s WHERE nkey > %s AND %s ORDER BY nkey LIMIT %s",
+		table, b(1), live(2), b(3),
+	)
+	st.remove = fmt.Sprintf("DELETE FROM %s WHERE nkey = %s", table, b(1))
+	st.purge = fmt.Sprintf("DELETE FROM %s WHERE expires_at <> 0 AND expires_at <= %s", table, b(1))
+	return st
+}
+
+// validTableName returns true/false if giving name is safe to be
+// interpolated into a statement as a table name.
+func validTableName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for index, char := range name {
+		switch {
+		case char == '_':
+		case char >= 'a' && char <= 'z':
+		case char >= 'A' && char <= 'Z':
+		case char >= '0' && char <= '9' && index > 0:
+		case char == '.' && index > 0 && !strings.HasSuffix(name, "."):
+		default:
+			return false
+		}
+	}
+	return true
+}
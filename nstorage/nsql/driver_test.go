@@ -0,0 +1,261 @@
+package nsql
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// fakeDriver implements a in-memory database/sql driver which only
+// understands the statements generated by buildStatements for the
+// SQLite dialect, allowing the SQLStore to be tested without a database.
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeDB
+}
+
+var fake = &fakeDriver{dbs: map[string]*fakeDB{}}
+
+func init() {
+	sql.Register("nsql_fake", fake)
+}
+
+const fakeTable = "nstore"
+
+// openFake returns a new sql.DB backed by a fresh fakeDB named by dsn.
+func openFake(dsn string) (*sql.DB, *fakeDB) {
+	var fdb = &fakeDB{
+		stmts: buildStatements(SQLite, fakeTable),
+		rows:  map[string]fakeRow{},
+	}
+
+	fake.mu.Lock()
+	fake.dbs[dsn] = fdb
+	fake.mu.Unlock()
+
+	var db, err = sql.Open("nsql_fake", dsn)
+	if err != nil {
+		panic(err)
+	}
+	return db, fdb
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var db, ok = d.dbs[dsn]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q", dsn)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeRow struct {
+	value     []byte
+	expiresAt int64
+}
+
+func (r fakeRow) live(now int64) bool {
+	return r.expiresAt == 0 || r.expiresAt > now
+}
+
+type fakeDB struct {
+	mu    sync.Mutex
+	stmts statements
+	rows  map[string]fakeRow
+
+	// changedRows counts changed rows as affected instead of matched
+	// ones, as mysql does by default.
+	changedRows bool
+}
+
+// affected returns the rows affected by replacing row with next.
+func (db *fakeDB) affected(row fakeRow, next fakeRow) driver.Result {
+	if db.changedRows && bytes.Equal(row.value, next.value) && row.expiresAt == next.expiresAt {
+		return driver.RowsAffected(0)
+	}
+	return driver.RowsAffected(1)
+}
+
+func (db *fakeDB) sortedKeys(after string, now int64) []string {
+	var keys = make([]string, 0, len(db.rows))
+	for key, row := range db.rows {
+		if key > after && row.live(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (db *fakeDB) exec(query string, args []driver.Value) (driver.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch query {
+	case db.stmts.createTable:
+		return driver.RowsAffected(0), nil
+	case db.stmts.upsert:
+		db.rows[args[0].(string)] = fakeRow{value: args[1].([]byte), expiresAt: args[2].(int64)}
+		return driver.RowsAffected(1), nil
+	case db.stmts.update:
+		var key = args[2].(string)
+		var row, ok = db.rows[key]
+		if !ok || !row.live(args[3].(int64)) {
+			return driver.RowsAffected(0), nil
+		}
+		var next = fakeRow{value: args[0].([]byte), expiresAt: args[1].(int64)}
+		db.rows[key] = next
+		return db.affected(row, next), nil
+	case db.stmts.setExpiry:
+		var key = args[1].(string)
+		var row, ok = db.rows[key]
+		if !ok || !row.live(args[2].(int64)) {
+			return driver.RowsAffected(0), nil
+		}
+		var next = row
+		next.expiresAt = args[0].(int64)
+		db.rows[key] = next
+		return db.affected(row, next), nil
+	case db.stmts.remove:
+		var key = args[0].(string)
+		if _, ok := db.rows[key]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		delete(db.rows, key)
+		return driver.RowsAffected(1), nil
+	case db.stmts.purge:
+		var now = args[0].(int64)
+		var removed int64
+		for key, row := range db.rows {
+			if !row.live(now) {
+				delete(db.rows, key)
+				removed++
+			}
+		}
+		return driver.RowsAffected(removed), nil
+	}
+	return nil, fmt.Errorf("unknown exec statement: %s", query)
+}
+
+func (db *fakeDB) query(query string, args []driver.Value) (driver.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch query {
+	case db.stmts.get, db.stmts.expiry, db.stmts.exists:
+		var row, ok = db.rows[args[0].(string)]
+		ok = ok && row.live(args[1].(int64))
+		switch query {
+		case db.stmts.exists:
+			var count int64
+			if ok {
+				count = 1
+			}
+			return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{count}}}, nil
+		case db.stmts.get:
+			if !ok {
+				return &fakeRows{columns: []string{"nvalue"}}, nil
+			}
+			return &fakeRows{columns: []string{"nvalue"}, values: [][]driver.Value{{row.value}}}, nil
+		default:
+			if !ok {
+				return &fakeRows{columns: []string{"expires_at"}}, nil
+			}
+			return &fakeRows{columns: []string{"expires_at"}, values: [][]driver.Value{{row.expiresAt}}}, nil
+		}
+	case db.stmts.count:
+		var count = int64(len(db.sortedKeys("", args[0].(int64))))
+		return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{count}}}, nil
+	case db.stmts.pageKeys, db.stmts.pageItems:
+		var keys = db.sortedKeys(args[0].(string), args[1].(int64))
+		if limit := int(args[2].(int64)); len(keys) > limit {
+			keys = keys[:limit]
+		}
+
+		var rows = &fakeRows{columns: []string{"nkey"}}
+		if query == db.stmts.pageItems {
+			rows.columns = append(rows.columns, "nvalue")
+		}
+		for _, key := range keys {
+			if query == db.stmts.pageItems {
+				rows.values = append(rows.values, []driver.Value{key, db.rows[key].value})
+				continue
+			}
+			rows.values = append(rows.values, []driver.Value{key})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unknown query statement: %s", query)
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+// Begin returns a transaction which applies changes immediately,
+// which is enough for the store's usage within tests.
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.db.exec(s.query, args)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.db.query(s.query, args)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
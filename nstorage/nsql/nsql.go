@@ -0,0 +1,591 @@
+package nsql
+
+import (
+	"context"
+	"database/sql"
+	regexp2 "regexp"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/nstorage"
+)
+
+var _ nstorage.ExpirableStore = (*SQLStore)(nil)
+
+const defaultPageSize = 100
+
+// Options defines the configuration for a SQLStore.
+type Options struct {
+	// Table is the name of the table holding all keys, it is created
+	// if it does not exists.
+	Table string
+
+	// Dialect provides the database specific statements, e.g SQLite,
+	// Postgres or MySQL.
+	Dialect Dialect
+
+	// PurgeInterval sets how often expired rows are deleted by a
+	// background goroutine. A zero value disables the background purge,
+	// expired rows are still never returned.
+	PurgeInterval time.Duration
+
+	// PageSize sets the number of rows read at a time when iterating
+	// over the table, defaults to 100.
+	PageSize int
+
+	// Now provides the current time, defaults to time.Now.
+	Now func() time.Time
+}
+
+// SQLStore implements the nstorage.ExpirableStore on top of a database/sql
+// connection, storing each key as a row with it's value and expiry time.
+type SQLStore struct {
+	ctx    context.Context
+	ops    Options
+	stmts  statements
+	DB     *sql.DB
+	closer chan struct{}
+	once   sync.Once
+	waiter sync.WaitGroup
+}
+
+// NewSQLStore returns a new instance of a SQLStore using giving db, creating
+// the table for the store if it does not exists.
+func NewSQLStore(ctx context.Context, db *sql.DB, ops Options) (*SQLStore, error) {
+	if !validTableName(ops.Table) {
+		return nil, nerror.New("invalid table name %q", ops.Table)
+	}
+	if ops.Dialect.Bind == nil {
+		return nil, nerror.New("Options.Dialect is required")
+	}
+	if ops.PageSize <= 0 {
+		ops.PageSize = defaultPageSize
+	}
+	if ops.Now == nil {
+		ops.Now = time.Now
+	}
+
+	var store SQLStore
+	store.DB = db
+	store.ctx = ctx
+	store.ops = ops
+	store.closer = make(chan struct{})
+	store.stmts = buildStatements(ops.Dialect, ops.Table)
+
+	if _, err := db.ExecContext(ctx, store.stmts.createTable); err != nil {
+		return nil, nerror.WrapOnly(err)
+	}
+
+	if ops.PurgeInterval > 0 {
+		store.waiter.Add(1)
+		go store.purgeLoop()
+	}
+	return &store, nil
+}
+
+// Close stops the background purge if running. It does not close
+// the underline database which is owned by the caller.
+func (sq *SQLStore) Close() error {
+	sq.once.Do(func() {
+		close(sq.closer)
+	})
+	sq.waiter.Wait()
+	return nil
+}
+
+func (sq *SQLStore) purgeLoop() {
+	defer sq.waiter.Done()
+
+	var ticker = time.NewTicker(sq.ops.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sq.ctx.Done():
+			return
+		case <-sq.closer:
+			return
+		case <-ticker.C:
+			_, _ = sq.Purge()
+		}
+	}
+}
+
+// Purge deletes all expired rows from the table, returning the
+// total rows removed.
+func (sq *SQLStore) Purge() (int64, error) {
+	var result, err = sq.DB.ExecContext(sq.ctx, sq.stmts.purge, sq.now())
+	if err != nil {
+		return 0, nerror.WrapOnly(err)
+	}
+	var removed, rerr = result.RowsAffected()
+	if rerr != nil {
+		return 0, nerror.WrapOnly(rerr)
+	}
+	return removed, nil
+}
+
+// Count returns the total count of unexpired keys in the store.
+func (sq *SQLStore) Count() (int64, error) {
+	var count int64
+	if err := sq.DB.QueryRowContext(sq.ctx, sq.stmts.count, sq.now()).Scan(&count); err != nil {
+		return -1, nerror.WrapOnly(err)
+	}
+	return count, nil
+}
+
+// Keys returns all giving keys of elements within store.
+func (sq *SQLStore) Keys() ([]string, error) {
+	var keys = make([]string, 0, sq.ops.PageSize)
+	var err = sq.eachKeyPage("", func(page []string) (bool, error) {
+		keys = append(keys, page...)
+		return true, nil
+	})
+	return keys, err
+}
+
+// Each runs through all elements in the store in key order, reading
+// the table in pages of Options.PageSize.
+//
+// Return nstorage.ErrJustStop if you want to just stop iterating.
+func (sq *SQLStore) Each(fn nstorage.EachItem) error {
+	var lastKey string
+	for {
+		var rows, err = sq.DB.QueryContext(sq.ctx, sq.stmts.pageItems, lastKey, sq.now(), int64(sq.ops.PageSize))
+		if err != nil {
+			return nerror.WrapOnly(err)
+		}
+
+		var keys = make([]string, 0, sq.ops.PageSize)
+		var values = make([][]byte, 0, sq.ops.PageSize)
+		for rows.Next() {
+			var key string
+			var value []byte
+			if err := rows.Scan(&key, &value); err != nil {
+				_ = rows.Close()
+				return nerror.WrapOnly(err)
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+		if err := rows.Close(); err != nil {
+			return nerror.WrapOnly(err)
+		}
+		if err := rows.Err(); err != nil {
+			return nerror.WrapOnly(err)
+		}
+
+		for index, key := range keys {
+			if doErr := fn(values[index], key); doErr != nil {
+				if nerror.IsAny(doErr, nstorage.ErrJustStop) {
+					return nil
+				}
+				return doErr
+			}
+		}
+
+		if len(keys) < sq.ops.PageSize {
+			return nil
+		}
+		lastKey = keys[len(keys)-1]
+	}
+}
+
+// EachKeyMatch returns all keys matching giving regexp.
+func (sq *SQLStore) EachKeyMatch(regexp string) ([]string, error) {
+	var regx, rgErr = compileMatch(regexp)
+	if rgErr != nil {
+		return nil, nerror.WrapOnly(rgErr)
+	}
+
+	var keys = make([]string, 0, 10)
+	var err = sq.eachKeyPage("", func(page []string) (bool, error) {
+		for _, key := range page {
+			if regx.MatchString(key) {
+				keys = append(keys, key)
+			}
+		}
+		return true, nil
+	})
+	return keys, err
+}
+
+// ScanMatch pages through the keys in key order, starting after lastKey,
+// returning at most count keys matching giving regexp.
+//
+// The lastKey of the returned result must be provided to get the next
+// page, the lastIndex is only kept as a running total of keys returned.
+func (sq *SQLStore) ScanMatch(count int64, lastIndex int64, lastKey string, regexp string) (nstorage.ScanResult, error) {
+	var rs nstorage.ScanResult
+	var regx, rgErr = compileMatch(regexp)
+	if rgErr != nil {
+		return rs, nerror.WrapOnly(rgErr)
+	}
+	if count <= 0 {
+		return rs, nerror.New("count must be above zero")
+	}
+
+	var keys = make([]string, 0, count)
+	var cursor = lastKey
+	for int64(len(keys)) < count {
+		var page, err = sq.keyPage(cursor, count)
+		if err != nil {
+			return rs, nerror.WrapOnly(err)
+		}
+
+		var filled bool
+		for _, key := range page {
+			cursor = key
+			if !regx.MatchString(key) {
+				continue
+			}
+			keys = append(keys, key)
+			if filled = int64(len(keys)) == count; filled {
+				break
+			}
+		}
+
+		// keys after the cursor of a filled page remain to be scanned.
+		if !filled && int64(len(page)) < count {
+			rs.Finished = true
+			break
+		}
+	}
+
+	rs.Keys = keys
+	rs.LastKey = cursor
+	rs.LastIndex = lastIndex + int64(len(keys))
+	return rs, nil
+}
+
+// Exists returns true/false if giving key exists and has not expired.
+func (sq *SQLStore) Exists(key string) (bool, error) {
+	var exists, err = sq.exists(sq.DB, key)
+	if err != nil {
+		return false, nerror.WrapOnly(err)
+	}
+	return exists, nil
+}
+
+// Get returns the value of giving key, returning an error if not found.
+func (sq *SQLStore) Get(key string) ([]byte, error) {
+	var value, err = sq.get(sq.DB, key)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return value, nil
+}
+
+// GetAnyKeys returns a list of values for any of the key's found.
+// Unless a specific error occurred retrieving the value of a key, if a
+// key is not found then it is ignored and a nil is set in it's place.
+func (sq *SQLStore) GetAnyKeys(keys ...string) ([][]byte, error) {
+	var values = make([][]byte, len(keys))
+	for index, key := range keys {
+		var value, err = sq.get(sq.DB, key)
+		if err != nil && err != sql.ErrNoRows {
+			return values, nerror.WrapOnly(err)
+		}
+		values[index] = value
+	}
+	return values, nil
+}
+
+// GetAllKeys returns a list of values for all of the key's.
+// if the value of a key is not found then we stop immediately, returning
+// an error and the current set of items retrieved.
+func (sq *SQLStore) GetAllKeys(keys ...string) ([][]byte, error) {
+	var values = make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		var value, err = sq.get(sq.DB, key)
+		if err != nil {
+			return values, wrapErr(err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Save adds giving key and value into the store without an expiration.
+func (sq *SQLStore) Save(key string, data []byte) error {
+	return sq.SaveTTL(key, data, 0)
+}
+
+// SaveTTL adds or replaces giving key and value into the store with
+// provided expiration.
+//
+// Duration of 0 means no expiration.
+func (sq *SQLStore) SaveTTL(key string, data []byte, expiration time.Duration) error {
+	if _, err := sq.DB.ExecContext(sq.ctx, sq.stmts.upsert, key, copyBytes(data), sq.expiresAt(expiration)); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+// Update updates giving key with new data slice, persisting the key.
+func (sq *SQLStore) Update(key string, data []byte) error {
+	return sq.UpdateTTL(key, data, 0)
+}
+
+// UpdateTTL updates the value of an existing key and sets it's
+// expiration to provided duration, returning an error if the key
+// does not exists.
+//
+// A zero duration persists the key.
+func (sq *SQLStore) UpdateTTL(key string, data []byte, expiration time.Duration) error {
+	return sq.withTx(func(tx *sql.Tx) error {
+		var result, err = tx.ExecContext(sq.ctx, sq.stmts.update, copyBytes(data), sq.expiresAt(expiration), key, sq.now())
+		if err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return sq.mustAffect(tx, result, key)
+	})
+}
+
+// TTL returns the remaining time before giving key expires.
+//
+// A zero value means the key has no expiration.
+func (sq *SQLStore) TTL(key string) (time.Duration, error) {
+	var expiresAt, err = sq.expiry(sq.DB, key)
+	if err != nil {
+		return 0, wrapErr(err)
+	}
+	if expiresAt == 0 {
+		return 0, nil
+	}
+
+	var remaining = time.Duration(expiresAt-sq.now()) * time.Millisecond
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// ExtendTTL adds giving duration to the remaining expiration of
+// key, a key without an expiration will expire after giving duration.
+//
+// A expiration value of zero means to persist the giving key.
+func (sq *SQLStore) ExtendTTL(key string, expiration time.Duration) error {
+	return sq.withTx(func(tx *sql.Tx) error {
+		var expiresAt, err = sq.expiry(tx, key)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		var next int64
+		switch {
+		case expiration == 0:
+			next = 0
+		case expiresAt == 0:
+			next = sq.expiresAt(expiration)
+		default:
+			next = expiresAt + expiration.Milliseconds()
+		}
+
+		var result, execErr = tx.ExecContext(sq.ctx, sq.stmts.setExpiry, next, key, sq.now())
+		if execErr != nil {
+			return nerror.WrapOnly(execErr)
+		}
+		return sq.mustAffect(tx, result, key)
+	})
+}
+
+// ResetTTL resets the expiration of giving key to provided duration.
+//
+// A expiration value of zero means to persist the giving key.
+func (sq *SQLStore) ResetTTL(key string, expiration time.Duration) error {
+	return sq.withTx(func(tx *sql.Tx) error {
+		var result, err = tx.ExecContext(sq.ctx, sq.stmts.setExpiry, sq.expiresAt(expiration), key, sq.now())
+		if err != nil {
+			return nerror.WrapOnly(err)
+		}
+		return sq.mustAffect(tx, result, key)
+	})
+}
+
+// Remove deletes giving key from the store, returning it's last value.
+func (sq *SQLStore) Remove(key string) ([]byte, error) {
+	var value []byte
+	var err = sq.withTx(func(tx *sql.Tx) error {
+		var getErr error
+		value, getErr = sq.get(tx, key)
+		if getErr != nil {
+			return wrapErr(getErr)
+		}
+		if _, execErr := tx.ExecContext(sq.ctx, sq.stmts.remove, key); execErr != nil {
+			return nerror.WrapOnly(execErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// RemoveKeys deletes all giving keys from the store within a single
+// transaction.
+func (sq *SQLStore) RemoveKeys(keys ...string) error {
+	return sq.withTx(func(tx *sql.Tx) error {
+		for _, key := range keys {
+			if _, err := tx.ExecContext(sq.ctx, sq.stmts.remove, key); err != nil {
+				return nerror.WrapOnly(err)
+			}
+		}
+		return nil
+	})
+}
+
+// *****************************************************
+// internal methods
+// *****************************************************
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// get returns the value of giving key, returning sql.ErrNoRows if
+// the key does not exists or has expired.
+func (sq *SQLStore) get(q querier, key string) ([]byte, error) {
+	var value []byte
+	if err := q.QueryRowContext(sq.ctx, sq.stmts.get, key, sq.now()).Scan(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// exists returns true/false if giving key exists and has not expired.
+func (sq *SQLStore) exists(q querier, key string) (bool, error) {
+	var count int64
+	if err := q.QueryRowContext(sq.ctx, sq.stmts.exists, key, sq.now()).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// expiry returns the expires_at column of giving key, returning sql.ErrNoRows if
+// the key does not exists or has expired.
+func (sq *SQLStore) expiry(q querier, key string) (int64, error) {
+	var expiresAt int64
+	if err := q.QueryRowContext(sq.ctx, sq.stmts.expiry, key, sq.now()).Scan(&expiresAt); err != nil {
+		return 0, err
+	}
+	return expiresAt, nil
+}
+
+func (sq *SQLStore) keyPage(after string, limit int64) ([]string, error) {
+	var rows, err = sq.DB.QueryContext(sq.ctx, sq.stmts.pageKeys, after, sq.now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys = make([]string, 0, limit)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// eachKeyPage calls fn with every page of keys until fn returns false,
+// an error occurs or the keys are exhausted.
+func (sq *SQLStore) eachKeyPage(after string, fn func([]string) (bool, error)) error {
+	var pageSize = int64(sq.ops.PageSize)
+	for {
+		var page, err = sq.keyPage(after, pageSize)
+		if err != nil {
+			return nerror.WrapOnly(err)
+		}
+
+		var next, fnErr = fn(page)
+		if fnErr != nil {
+			return fnErr
+		}
+		if !next || int64(len(page)) < pageSize {
+			return nil
+		}
+		after = page[len(page)-1]
+	}
+}
+
+func (sq *SQLStore) withTx(fn func(tx *sql.Tx) error) error {
+	var tx, err = sq.DB.BeginTx(sq.ctx, nil)
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	return nil
+}
+
+// now returns the current time in unix milliseconds.
+func (sq *SQLStore) now() int64 {
+	return toMillis(sq.ops.Now())
+}
+
+// expiresAt returns the unix millisecond time when a key saved now
+// with giving ttl expires, zero means no expiration.
+func (sq *SQLStore) expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return toMillis(sq.ops.Now().Add(ttl))
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// mustAffect returns a not found error if result affected no rows and
+// giving key does not exists. Drivers like mysql count changed rows
+// instead of matched ones, an update writing the same values hence
+// affects no rows, so the key is checked within the same transaction.
+func (sq *SQLStore) mustAffect(q querier, result sql.Result, key string) error {
+	var affected, err = result.RowsAffected()
+	if err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if exists, err = sq.exists(q, key); err != nil {
+		return nerror.WrapOnly(err)
+	}
+	if !exists {
+		return nerror.New("not found")
+	}
+	return nil
+}
+
+// wrapErr wraps giving error, turning sql.ErrNoRows into a not found error.
+func wrapErr(err error) error {
+	if err == sql.ErrNoRows {
+		return nerror.New("not found")
+	}
+	return nerror.WrapOnly(err)
+}
+
+func compileMatch(regexp string) (*regexp2.Regexp, error) {
+	if len(regexp) == 0 {
+		regexp = ".+"
+	}
+	return regexp2.Compile(regexp)
+}
+
+func copyBytes(bu []byte) []byte {
+	var cu = make([]byte, len(bu))
+	copy(cu, bu)
+	return cu
+}
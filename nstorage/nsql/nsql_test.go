@@ -0,0 +1,228 @@
+package nsql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nstorage/internal/tharness"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newStore(t *testing.T, ops Options) (*SQLStore, *fakeDB) {
+	var db, fdb = openFake(t.Name())
+	ops.Table = fakeTable
+	ops.Dialect = SQLite
+
+	var store, err = NewSQLStore(context.Background(), db, ops)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+
+	t.Cleanup(func() {
+		require.NoError(t, store.Close())
+		require.NoError(t, db.Close())
+	})
+	return store, fdb
+}
+
+func TestSQLStore(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestByteStore(t, store)
+}
+
+func TestSQLStoreRemoveKeys(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestByteStoreRemoveKeys(t, store)
+}
+
+func TestSQLStoreGetAnyKeys(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestByteStoreGetAnykeys(t, store)
+}
+
+func TestSQLStoreGetAllKeys(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestByteStoreGetAllkeys(t, store)
+}
+
+func TestSQLStoreScanMatch(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestByteStoreScanMatch(t, store)
+}
+
+func TestSQLStoreFindPrefix(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestByteStoreFindPrefix(t, store)
+}
+
+func TestSQLStoreFindEach(t *testing.T) {
+	var store, _ = newStore(t, Options{PageSize: 3})
+	tharness.TestByteStoreFindEach(t, store)
+}
+
+func TestSQLStoreFindAll(t *testing.T) {
+	var store, _ = newStore(t, Options{PageSize: 3})
+	tharness.TestByteStoreFindAll(t, store)
+}
+
+func TestSQLStoreExpirable(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestExpirableStore(t, store)
+}
+
+func TestSQLStoreExpiryReset(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	tharness.TestExpiryReset(t, store)
+}
+
+func TestSQLStoreScanMatchPaging(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Save(fmt.Sprintf("day-%d", i), []byte("i")))
+		require.NoError(t, store.Save(fmt.Sprintf("night-%d", i), []byte("i")))
+	}
+
+	var seen []string
+	var result, err = store.ScanMatch(4, 0, "", "^night-")
+	require.NoError(t, err)
+	for {
+		seen = append(seen, result.Keys...)
+		if result.Finished {
+			break
+		}
+		result, err = store.ScanMatch(4, result.LastIndex, result.LastKey, "^night-")
+		require.NoError(t, err)
+	}
+
+	require.Len(t, seen, 10)
+	require.Equal(t, int64(10), result.LastIndex)
+	for _, key := range seen {
+		require.True(t, strings.HasPrefix(key, "night-"))
+	}
+}
+
+func TestSQLStoreScanMatchFilledPage(t *testing.T) {
+	var store, _ = newStore(t, Options{})
+	for _, key := range []string{"a1", "a2", "b1", "b2", "x1", "x2", "x3"} {
+		require.NoError(t, store.Save(key, []byte("i")))
+	}
+
+	// the count is reached within the last page, x3 must still be read.
+	var result, err = store.ScanMatch(4, 0, "", "^(a|x)")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a1", "a2", "x1", "x2"}, result.Keys)
+	require.False(t, result.Finished)
+
+	result, err = store.ScanMatch(4, result.LastIndex, result.LastKey, "^(a|x)")
+	require.NoError(t, err)
+	require.Equal(t, []string{"x3"}, result.Keys)
+	require.True(t, result.Finished)
+	require.Equal(t, int64(5), result.LastIndex)
+}
+
+func TestSQLStoreExpiration(t *testing.T) {
+	var clock = &fakeClock{now: time.Unix(1000, 0)}
+	var store, fdb = newStore(t, Options{Now: clock.Now})
+
+	require.NoError(t, store.SaveTTL("day", []byte("wrecker"), time.Second))
+	require.NoError(t, store.Save("night", []byte("tweeter")))
+
+	var ttl, err = store.TTL("day")
+	require.NoError(t, err)
+	require.Equal(t, time.Second, ttl)
+
+	require.NoError(t, store.ExtendTTL("day", time.Second))
+	ttl, err = store.TTL("day")
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Second, ttl)
+
+	clock.Add(3 * time.Second)
+
+	var exists, existErr = store.Exists("day")
+	require.NoError(t, existErr)
+	require.False(t, exists)
+
+	var _, getErr = store.Get("day")
+	require.Error(t, getErr)
+	require.Error(t, store.UpdateTTL("day", []byte("v"), 0))
+
+	var count, countErr = store.Count()
+	require.NoError(t, countErr)
+	require.Equal(t, int64(1), count)
+
+	var removed, purgeErr = store.Purge()
+	require.NoError(t, purgeErr)
+	require.Equal(t, int64(1), removed)
+	require.Len(t, fdb.rows, 1)
+}
+
+func TestSQLStoreUnchangedRows(t *testing.T) {
+	var clock = &fakeClock{now: time.Unix(1000, 0)}
+	var store, fdb = newStore(t, Options{Now: clock.Now})
+	fdb.changedRows = true
+
+	require.NoError(t, store.SaveTTL("day", []byte("wrecker"), time.Second))
+
+	// writing the same values affects no rows, the key still exists.
+	require.NoError(t, store.UpdateTTL("day", []byte("wrecker"), time.Second))
+	require.NoError(t, store.ResetTTL("day", time.Second))
+	require.NoError(t, store.ExtendTTL("day", 0))
+	require.NoError(t, store.ExtendTTL("day", 0))
+
+	require.Error(t, store.UpdateTTL("night", []byte("tweeter"), 0))
+	require.Error(t, store.ResetTTL("night", time.Second))
+	require.Error(t, store.ExtendTTL("night", time.Second))
+}
+
+func TestSQLStoreBackgroundPurge(t *testing.T) {
+	var clock = &fakeClock{now: time.Unix(1000, 0)}
+	var store, fdb = newStore(t, Options{Now: clock.Now, PurgeInterval: 5 * time.Millisecond})
+
+	require.NoError(t, store.SaveTTL("day", []byte("wrecker"), time.Second))
+	clock.Add(2 * time.Second)
+
+	require.Eventually(t, func() bool {
+		fdb.mu.Lock()
+		defer fdb.mu.Unlock()
+		return len(fdb.rows) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSQLStoreInvalidTable(t *testing.T) {
+	var db, _ = openFake(t.Name())
+	defer db.Close()
+
+	var _, err = NewSQLStore(context.Background(), db, Options{Table: "users; DROP TABLE users", Dialect: SQLite})
+	require.Error(t, err)
+}
+
+func TestDialectStatements(t *testing.T) {
+	var pg = buildStatements(Postgres, "store")
+	require.Equal(t, "SELECT nvalue FROM store WHERE nkey = $1 AND (expires_at = 0 OR expires_at > $2)", pg.get)
+	require.Contains(t, pg.upsert, "VALUES ($1, $2, $3) ON CONFLICT (nkey)")
+
+	var my = buildStatements(MySQL, "store")
+	require.Contains(t, my.upsert, "VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE")
+	require.Equal(t, "DELETE FROM store WHERE expires_at <> 0 AND expires_at <= ?", my.purge)
+}
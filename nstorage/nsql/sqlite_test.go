@@ -0,0 +1,69 @@
+//go:build cgo
+// +build cgo
+
+package nsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nstorage/internal/tharness"
+)
+
+// newSQLiteStore returns a SQLStore over a fresh in-memory sqlite
+// database, which also runs the statements of the Postgres dialect.
+func newSQLiteStore(t *testing.T, dialect Dialect) *SQLStore {
+	var db, err = sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	require.NoError(t, err)
+
+	// a single connection keeps the in-memory database alive.
+	db.SetMaxOpenConns(1)
+
+	var store *SQLStore
+	store, err = NewSQLStore(context.Background(), db, Options{Table: "nstore", Dialect: dialect})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, store.Close())
+		require.NoError(t, db.Close())
+	})
+	return store
+}
+
+func TestSQLiteDialects(t *testing.T) {
+	for _, dialect := range []Dialect{SQLite, Postgres} {
+		t.Run(dialect.Name, func(t *testing.T) {
+			t.Run("ByteStore", func(t *testing.T) {
+				tharness.TestByteStore(t, newSQLiteStore(t, dialect))
+			})
+			t.Run("RemoveKeys", func(t *testing.T) {
+				tharness.TestByteStoreRemoveKeys(t, newSQLiteStore(t, dialect))
+			})
+			t.Run("ScanMatch", func(t *testing.T) {
+				tharness.TestByteStoreScanMatch(t, newSQLiteStore(t, dialect))
+			})
+			t.Run("FindAll", func(t *testing.T) {
+				tharness.TestByteStoreFindAll(t, newSQLiteStore(t, dialect))
+			})
+			t.Run("Expirable", func(t *testing.T) {
+				tharness.TestExpirableStore(t, newSQLiteStore(t, dialect))
+			})
+			t.Run("ExpiryReset", func(t *testing.T) {
+				tharness.TestExpiryReset(t, newSQLiteStore(t, dialect))
+			})
+			t.Run("UnchangedRows", func(t *testing.T) {
+				var store = newSQLiteStore(t, dialect)
+				require.NoError(t, store.SaveTTL("day", []byte("wrecker"), time.Minute))
+				require.NoError(t, store.UpdateTTL("day", []byte("wrecker"), time.Minute))
+				require.NoError(t, store.ExtendTTL("day", time.Minute))
+				require.Error(t, store.UpdateTTL("night", []byte("tweeter"), 0))
+			})
+		})
+	}
+}
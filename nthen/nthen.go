@@ -1,36 +1,189 @@
 package nthen
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/npool"
+)
+
+var (
+	// ErrAlreadyResolved is returned when resolving a future which was
+	// already resolved.
+	ErrAlreadyResolved = errors.New("future is already resolved")
+
+	// ErrCanceled is the error of futures resolved by Cancel.
+	ErrCanceled = errors.New("future was canceled")
+)
+
+// IsCanceled returns true if giving error is or wraps ErrCanceled.
+func IsCanceled(err error) bool {
+	return nerror.UnwrapDeep(err) == ErrCanceled
+}
+
+// PanicError is the error of futures whose function panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error returns the value the function panicked with.
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("future function panicked: %v", p.Value)
+}
+
+// IsPanic returns true if giving error is or wraps a *PanicError.
+func IsPanic(err error) bool {
+	var _, ok = nerror.UnwrapDeep(err).(*PanicError)
+	return ok
+}
+
+// AggregateError is the error of an Any future whose futures all failed,
+// holding their errors in order.
+type AggregateError struct {
+	Errors []error
+}
+
+// Error returns the errors of all futures.
+func (a *AggregateError) Error() string {
+	var messages = make([]string, len(a.Errors))
+	for index, err := range a.Errors {
+		messages[index] = err.Error()
+	}
+	return "all futures failed: [" + strings.Join(messages, "; ") + "]"
+}
+
+// Future holds the value and error of an operation which is resolved
+// once, safely waited on by many goroutines.
 type Future struct {
-	err error
-	val interface{}
+	lock     sync.Mutex
+	err      error
+	val      interface{}
 	resolved chan interface{}
+	ctx      context.Context
+	cancel   context.CancelFunc
 }
 
+// NewFuture returns a new unresolved Future.
 func NewFuture() *Future {
+	var ctx, cancel = context.WithCancel(context.Background())
 	return &Future{
-		resolved:   make(chan interface{}, 0),
+		resolved: make(chan interface{}, 0),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
+// Fn returns a new Future which giving function resolves from a goroutine,
+// the future fails with a PanicError if the function panics.
 func Fn(fn func(ft *Future)) *Future {
 	var ft = NewFuture()
-	go fn(ft)
+	go func() {
+		var _, err = protect(func() (interface{}, error) {
+			fn(ft)
+			return nil, nil
+		})
+		if err != nil {
+			_ = ft.WithError(err)
+		}
+	}()
 	return ft
 }
 
+// From returns a new Future resolved with the results of giving function
+// called from a goroutine, the future fails with a PanicError if the
+// function panics.
 func From(fn func() (interface{}, error)) *Future {
 	var ft = NewFuture()
 	go func() {
-		var val, err = fn()
-		if err != nil {
-			ft.WithError(err)
-			return
+		ft.resolveWith(protect(fn))
+	}()
+	return ft
+}
+
+// FromContext returns a new Future resolved with the results of giving
+// function called from a goroutine. The function is given the context of
+// the future, which is canceled once the future is canceled or giving
+// context is done, in which case the future fails with the error of the
+// context.
+func FromContext(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) *Future {
+	var ft = NewFuture()
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = ft.WithError(nerror.WrapOnly(ctx.Err()))
+		case <-ft.resolved:
 		}
-		ft.WithValue(val)
 	}()
+	go func() {
+		ft.resolveWith(protect(func() (interface{}, error) {
+			return fn(ft.ctx)
+		}))
+	}()
+	return ft
+}
+
+// FnOn returns a new Future which giving function resolves, run by the
+// executor instead of a goroutine of it's own. The future fails with the
+// error of the executor if the function is rejected or dropped.
+func FnOn(executor npool.Executor, fn func(ft *Future)) *Future {
+	var ft = NewFuture()
+	ft.executeOn(executor, func() {
+		var _, err = protect(func() (interface{}, error) {
+			fn(ft)
+			return nil, nil
+		})
+		if err != nil {
+			_ = ft.WithError(err)
+		}
+	})
 	return ft
 }
 
+// FromOn returns a new Future resolved with the results of giving function
+// run by the executor instead of a goroutine of it's own, e.g a npool.Pool
+// bounding the number of functions running at once. The future fails with
+// the error of the executor if the function is rejected or dropped, and
+// the function is dropped if the future is canceled while it is queued.
+func FromOn(executor npool.Executor, fn func() (interface{}, error)) *Future {
+	var ft = NewFuture()
+	ft.executeOn(executor, func() {
+		ft.resolveWith(protect(fn))
+	})
+	return ft
+}
+
+func (f *Future) executeOn(executor npool.Executor, fn func()) {
+	var err = executor.Execute(f.ctx, npool.Task{
+		Run: func(_ context.Context) {
+			fn()
+		},
+		OnDrop: func(err error) {
+			_ = f.WithError(err)
+		},
+	})
+	if err != nil {
+		_ = f.WithError(err)
+	}
+}
+
+// protect calls giving function, returning a PanicError if it panics.
+func protect(fn func() (interface{}, error)) (val interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			val = nil
+			err = nerror.WrapOnly(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	return fn()
+}
 
 // CollectFor collects all resolved results (value or error)
 // as a list which is used to resolve the returned future.
@@ -39,10 +192,14 @@ func CollectFor(fts ...*Future) *Future {
 	go func(targets []*Future) {
 		var results = make([]interface{}, len(targets))
 		for index, work := range targets {
-			work.Wait()
-			results[index] = work.Value()
+			var value, err = work.Get()
+			if err != nil {
+				results[index] = err
+				continue
+			}
+			results[index] = value
 		}
-		 ft.WithValue(results)
+		_ = ft.WithValue(results)
 	}(fts)
 	return ft
 }
@@ -56,45 +213,232 @@ func WaitFor(fts ...*Future) *Future {
 	go func(targets []*Future) {
 		var results = make([]interface{}, 0, len(targets))
 		for _, work := range targets {
-			work.Wait()
 			var value, err = work.Get()
 			if value != nil {
 				results = append(results, value)
 			}
 			if err != nil {
-				 ft.WithValueAndError(results, err)
-				break
+				_ = ft.WithValueAndError(results, err)
+				return
 			}
 		}
-		 ft.WithValue(results)
+		_ = ft.WithValue(results)
 	}(fts)
 	return ft
 }
 
+// Result holds the value and error a future resolved with.
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// AllSettled returns a future resolved with the Result of all giving
+// futures, in order, once they are all resolved.
+func AllSettled(fts ...*Future) *Future {
+	var ft = NewFuture()
+	go func(targets []*Future) {
+		var results = make([]Result, len(targets))
+		for index, work := range targets {
+			results[index].Value, results[index].Err = work.Get()
+		}
+		_ = ft.WithValue(results)
+	}(fts)
+	return ft
+}
+
+// Race returns a future resolved with the value and error of the first
+// of giving futures to be resolved. Futures losing the race are left as
+// is, they can be canceled once the race is resolved if no longer needed.
+func Race(fts ...*Future) *Future {
+	var ft = NewFuture()
+	for _, work := range fts {
+		go func(work *Future) {
+			select {
+			case <-work.resolved:
+				_ = ft.WithValueAndError(work.val, work.err)
+			case <-ft.resolved:
+			}
+		}(work)
+	}
+	return ft
+}
+
+// Any returns a future resolved with the value of the first of giving
+// futures to resolve without an error, or failing with an AggregateError
+// of all errors if they all fail.
+func Any(fts ...*Future) *Future {
+	var ft = NewFuture()
+	if len(fts) == 0 {
+		_ = ft.WithError(nerror.WrapOnly(&AggregateError{}))
+		return ft
+	}
+
+	var lock sync.Mutex
+	var failed int
+	var errs = make([]error, len(fts))
+	for index, work := range fts {
+		go func(index int, work *Future) {
+			select {
+			case <-work.resolved:
+			case <-ft.resolved:
+				return
+			}
+
+			if work.err == nil {
+				_ = ft.WithValue(work.val)
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			errs[index] = work.err
+			if failed++; failed == len(errs) {
+				_ = ft.WithError(nerror.WrapOnly(&AggregateError{Errors: errs}))
+			}
+		}(index, work)
+	}
+	return ft
+}
+
 // Then will async-ly wait in a goroutine for the target
 // future to be resolved at which the provided next Future will
 // be resolved as well.
-func (f *Future) Then(next *Future)  {
-	go func(){
-		f.WaitThen(next)
+func (f *Future) Then(next *Future) {
+	go func() {
+		select {
+		case <-f.resolved:
+			f.WaitThen(next)
+		case <-next.resolved:
+		}
 	}()
 }
 
 // WaitThen will block till this future resolves, at which it
 // resolves the next future provided as an argument.
-func (f *Future) WaitThen(next *Future)   {
+func (f *Future) WaitThen(next *Future) {
 	f.Wait()
-	if f.val != nil {
-		next.WithValue(f.val)
-	}
-	next.WithError(f.err)
+	_ = next.WithValueAndError(f.val, f.err)
+}
+
+// Map returns a future resolved with the value returned by fn for the
+// value of this future, once it resolves. If this future fails, fn is not
+// called and the returned future fails with the same error, so canceling
+// this future also cancels the returned one.
+func (f *Future) Map(fn func(value interface{}) (interface{}, error)) *Future {
+	return f.chain(func(next *Future) {
+		if f.err != nil {
+			_ = next.WithValueAndError(f.val, f.err)
+			return
+		}
+		next.resolveWith(protect(func() (interface{}, error) {
+			return fn(f.val)
+		}))
+	})
+}
+
+// FlatMap returns a future resolved as the future returned by fn for the
+// value of this future, once it resolves. If this future fails, fn is not
+// called and the returned future fails with the same error. Canceling the
+// returned future cancels the future returned by fn.
+func (f *Future) FlatMap(fn func(value interface{}) *Future) *Future {
+	return f.chain(func(next *Future) {
+		if f.err != nil {
+			_ = next.WithValueAndError(f.val, f.err)
+			return
+		}
+
+		var inner *Future
+		var _, err = protect(func() (interface{}, error) {
+			inner = fn(f.val)
+			return nil, nil
+		})
+		if err != nil {
+			_ = next.WithError(err)
+			return
+		}
+
+		select {
+		case <-inner.resolved:
+			_ = next.WithValueAndError(inner.val, inner.err)
+		case <-next.resolved:
+			inner.Cancel()
+		}
+	})
+}
+
+// Recover returns a future resolved with the results of fn for the error
+// of this future if it fails, else it resolves as this future. Futures
+// which were canceled are not recovered, so cancellation still propagates.
+func (f *Future) Recover(fn func(err error) (interface{}, error)) *Future {
+	return f.chain(func(next *Future) {
+		if f.err == nil || IsCanceled(f.err) {
+			_ = next.WithValueAndError(f.val, f.err)
+			return
+		}
+		next.resolveWith(protect(func() (interface{}, error) {
+			return fn(f.err)
+		}))
+	})
+}
+
+// chain returns a new future which giving function resolves once this
+// future resolves, unless it was canceled before.
+func (f *Future) chain(fn func(next *Future)) *Future {
+	var next = NewFuture()
+	go func() {
+		select {
+		case <-f.resolved:
+			fn(next)
+		case <-next.resolved:
+		}
+	}()
+	return next
+}
+
+// Cancel fails this future with ErrCanceled if it is not yet resolved,
+// returning true if it was canceled. Futures chained to it with Then, Map,
+// FlatMap or Recover fail with ErrCanceled as well.
+func (f *Future) Cancel() bool {
+	return f.resolve(nil, nerror.WrapOnly(ErrCanceled)) == nil
+}
+
+// Context returns a context canceled once this future is resolved, used
+// to stop work whose result is no longer needed.
+func (f *Future) Context() context.Context {
+	return f.ctx
 }
 
 // Wait blocks till future is resolved.
-func (f *Future) Wait()  {
+func (f *Future) Wait() {
 	<-f.resolved
 }
 
+// WaitContext blocks till future is resolved or giving context is done,
+// returning the error of the context in the latter case.
+func (f *Future) WaitContext(ctx context.Context) error {
+	select {
+	case <-f.resolved:
+		return nil
+	case <-ctx.Done():
+		return nerror.WrapOnly(ctx.Err())
+	}
+}
+
+// WaitTimeout blocks till future is resolved or giving duration elapsed,
+// returning context.DeadlineExceeded in the latter case.
+func (f *Future) WaitTimeout(timeout time.Duration) error {
+	var timer = time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-f.resolved:
+		return nil
+	case <-timer.C:
+		return nerror.WrapOnly(context.DeadlineExceeded)
+	}
+}
+
 // IsResolved returns true/false if future is resolved either as error or value.
 func (f *Future) IsResolved() bool {
 	select {
@@ -106,14 +450,51 @@ func (f *Future) IsResolved() bool {
 }
 
 // Get returns result of future as a tuple of value and error.
-func (f *Future) Get()  (interface{}, error) {
+func (f *Future) Get() (interface{}, error) {
 	f.Wait()
 	return f.val, f.err
 }
 
+// GetContext returns the result of future as Get, unless giving context
+// is done before the future is resolved.
+func (f *Future) GetContext(ctx context.Context) (interface{}, error) {
+	if err := f.WaitContext(ctx); err != nil {
+		return nil, err
+	}
+	return f.val, f.err
+}
+
+// Into blocks till the future is resolved, then sets it's value into the
+// value pointed to by target, returning the error of the future if any.
+// An error is returned if the value is not assignable to target.
+func (f *Future) Into(target interface{}) error {
+	var value, err = f.Get()
+	if err != nil {
+		return err
+	}
+
+	var pointer = reflect.ValueOf(target)
+	if pointer.Kind() != reflect.Ptr || pointer.IsNil() {
+		return nerror.New("target must be a non-nil pointer, got %T", target)
+	}
+
+	var elem = pointer.Elem()
+	if value == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+
+	var rv = reflect.ValueOf(value)
+	if !rv.Type().AssignableTo(elem.Type()) {
+		return nerror.New("future value of type %T is not assignable to %s", value, elem.Type())
+	}
+	elem.Set(rv)
+	return nil
+}
+
 // Value returns the value of the future, it blocks if the future is yet
 // resolved and will wait till it's resolved.
-func (f *Future) Value()  interface{} {
+func (f *Future) Value() interface{} {
 	f.Wait()
 	return f.val
 }
@@ -127,43 +508,42 @@ func (f *Future) Err() error {
 
 // WithError resolves this Future as a failed operation with provided
 // error.
-func (f *Future) WithError(v error)  {
-	select {
-	case <-f.resolved:
-		return
-	default:
-		f.err = v
-		close(f.resolved)
-	}
-	return
+func (f *Future) WithError(v error) error {
+	return f.resolve(nil, v)
 }
 
 // WithValueAndError resolves this Future as a with a value for
 // both result and error. Useful for operations where a
 // value is returned but so was an error.
-func (f *Future) WithValueAndError(v interface{}, err error)  {
-	select {
-	case <-f.resolved:
-		return
-	default:
-		f.val = v
-		f.err = err
-		close(f.resolved)
-	}
-	return
+func (f *Future) WithValueAndError(v interface{}, err error) error {
+	return f.resolve(v, err)
 }
 
-
 // WithValue resolves this Future as a completed operation with provided
 // value.
-func (f *Future) WithValue(v interface{})  {
+func (f *Future) WithValue(v interface{}) error {
+	return f.resolve(v, nil)
+}
+
+func (f *Future) resolveWith(v interface{}, err error) {
+	_ = f.resolve(v, err)
+}
+
+// resolve resolves this future, returning ErrAlreadyResolved if it was
+// resolved before.
+func (f *Future) resolve(v interface{}, err error) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
 	select {
 	case <-f.resolved:
-		return
+		return nerror.WrapOnly(ErrAlreadyResolved)
 	default:
-		f.val = v
-		close(f.resolved)
 	}
-	return
-}
 
+	f.val = v
+	f.err = err
+	close(f.resolved)
+	f.cancel()
+	return nil
+}
@@ -1,13 +1,18 @@
 package nthen
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/influx6/npkg/nerror"
+	"github.com/influx6/npkg/npool"
 )
 
-func TestFuture_CollectFor(t *testing.T){
+func TestFuture_CollectFor(t *testing.T) {
 	var ft = NewFuture()
 	require.False(t, ft.IsResolved())
 	require.NoError(t, ft.WithValue(1))
@@ -22,7 +27,7 @@ func TestFuture_CollectFor(t *testing.T){
 	require.Len(t, ft3.Value(), 2)
 }
 
-func TestFuture_WaitFor(t *testing.T){
+func TestFuture_WaitFor(t *testing.T) {
 	var ft = NewFuture()
 	require.False(t, ft.IsResolved())
 	require.NoError(t, ft.WithValue(1))
@@ -38,7 +43,7 @@ func TestFuture_WaitFor(t *testing.T){
 	require.Error(t, ft3.Err())
 }
 
-func TestFuture(t *testing.T){
+func TestFuture(t *testing.T) {
 	var ft = NewFuture()
 	require.False(t, ft.IsResolved())
 
@@ -48,7 +53,7 @@ func TestFuture(t *testing.T){
 	require.NotNil(t, ft.Value())
 }
 
-func TestFuture_Error(t *testing.T){
+func TestFuture_Error(t *testing.T) {
 	var ft = NewFuture()
 	require.False(t, ft.IsResolved())
 
@@ -56,4 +61,191 @@ func TestFuture_Error(t *testing.T){
 	require.True(t, ft.IsResolved())
 	require.Error(t, ft.Err())
 	require.Nil(t, ft.Value())
-}
\ No newline at end of file
+}
+
+func TestFuture_AlreadyResolved(t *testing.T) {
+	var ft = NewFuture()
+	require.NoError(t, ft.WithValue(1))
+	require.Error(t, ft.WithValue(2))
+	require.False(t, ft.Cancel())
+	require.Equal(t, 1, ft.Value())
+}
+
+func TestFuture_WaitContext(t *testing.T) {
+	var ft = NewFuture()
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, ft.WaitContext(ctx))
+	require.Error(t, ft.WaitTimeout(10*time.Millisecond))
+
+	require.NoError(t, ft.WithValue(1))
+	require.NoError(t, ft.WaitTimeout(10*time.Millisecond))
+
+	var value, err = ft.GetContext(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, value)
+}
+
+func TestFuture_Panic(t *testing.T) {
+	var ft = From(func() (interface{}, error) {
+		panic("bad op")
+	})
+	require.True(t, IsPanic(ft.Err()))
+
+	var ft2 = Fn(func(_ *Future) {
+		panic("bad op")
+	})
+	require.True(t, IsPanic(ft2.Err()))
+}
+
+func TestFuture_Cancel(t *testing.T) {
+	var started = make(chan struct{})
+	var ft = FromContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	var mapped = ft.Map(func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	var recovered = mapped.Recover(func(err error) (interface{}, error) {
+		return 1, nil
+	})
+
+	<-started
+	require.True(t, ft.Cancel())
+	require.True(t, IsCanceled(ft.Err()))
+	require.True(t, IsCanceled(mapped.Err()))
+	require.True(t, IsCanceled(recovered.Err()))
+	require.Error(t, ft.Context().Err())
+}
+
+func TestFuture_FromContext(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	var ft = FromContext(ctx, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, nil
+	})
+	cancel()
+	require.Error(t, ft.Err())
+}
+
+func TestFuture_Map(t *testing.T) {
+	var ft = From(func() (interface{}, error) {
+		return 2, nil
+	})
+
+	var doubled = ft.Map(func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	})
+	var flat = doubled.FlatMap(func(value interface{}) *Future {
+		return From(func() (interface{}, error) {
+			return value.(int) + 1, nil
+		})
+	})
+
+	var result int
+	require.NoError(t, flat.Into(&result))
+	require.Equal(t, 5, result)
+
+	var name string
+	require.Error(t, flat.Into(&name))
+}
+
+func TestFuture_FlatMapCancel(t *testing.T) {
+	var inner = NewFuture()
+	var mapped = make(chan struct{})
+	var ft = NewFuture()
+	var flat = ft.FlatMap(func(value interface{}) *Future {
+		close(mapped)
+		return inner
+	})
+
+	require.NoError(t, ft.WithValue(1))
+	<-mapped
+	require.True(t, flat.Cancel())
+	require.True(t, IsCanceled(inner.Err()))
+}
+
+func TestFuture_Recover(t *testing.T) {
+	var ft = NewFuture()
+	var recovered = ft.Recover(func(err error) (interface{}, error) {
+		return "recovered", nil
+	})
+	var mapped = ft.Map(func(value interface{}) (interface{}, error) {
+		return "mapped", nil
+	})
+
+	require.NoError(t, ft.WithError(errors.New("bad op")))
+	require.Equal(t, "recovered", recovered.Value())
+	require.Error(t, mapped.Err())
+}
+
+func TestRace(t *testing.T) {
+	var slow = NewFuture()
+	var fast = NewFuture()
+	var race = Race(slow, fast)
+
+	require.NoError(t, fast.WithError(errors.New("bad op")))
+	require.Error(t, race.Err())
+	require.False(t, slow.IsResolved())
+}
+
+func TestAny(t *testing.T) {
+	var failed = NewFuture()
+	var pending = NewFuture()
+	var done = NewFuture()
+	var first = Any(failed, pending, done)
+
+	require.NoError(t, failed.WithError(errors.New("bad op")))
+	require.NoError(t, done.WithValue(1))
+	require.Equal(t, 1, first.Value())
+
+	var allFailed = Any(failed, Fn(func(ft *Future) {
+		_ = ft.WithError(errors.New("bad op 2"))
+	}))
+	var aggregate, ok = nerror.UnwrapDeep(allFailed.Err()).(*AggregateError)
+	require.True(t, ok)
+	require.Len(t, aggregate.Errors, 2)
+}
+
+func TestAllSettled(t *testing.T) {
+	var ft = NewFuture()
+	var ft2 = NewFuture()
+	require.NoError(t, ft.WithValue(1))
+	require.NoError(t, ft2.WithError(errors.New("bad op")))
+
+	var results = AllSettled(ft, ft2).Value().([]Result)
+	require.Len(t, results, 2)
+	require.Equal(t, 1, results[0].Value)
+	require.NoError(t, results[0].Err)
+	require.Error(t, results[1].Err)
+}
+
+func TestFromOn(t *testing.T) {
+	var pool = npool.NewPool(npool.Workers(1), npool.QueueSize(1))
+	defer pool.ShutdownNow()
+
+	var release = make(chan struct{})
+	var first = FromOn(pool, func() (interface{}, error) {
+		<-release
+		return 1, nil
+	})
+	require.Eventually(t, func() bool {
+		return pool.Stats().Busy == 1
+	}, time.Second, time.Millisecond)
+
+	// the queue is full once the second function is queued.
+	var second = FnOn(pool, func(ft *Future) {
+		_ = ft.WithValue(2)
+	})
+	var rejected = FromOn(pool, func() (interface{}, error) {
+		return 3, nil
+	})
+	require.True(t, npool.IsRejected(rejected.Err()))
+
+	close(release)
+	require.Equal(t, 1, first.Value())
+	require.Equal(t, 2, second.Value())
+}